@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+)
+
+// APIKeyHeader is the request header that carries an API key
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to search for
+const apiKeyPrefix = "acc_"
+
+// apiKeyPrefixLength is the number of characters of a key that are stored
+// in clear, to recognize keys in lists
+const apiKeyPrefixLength = len(apiKeyPrefix) + 8
+
+// Scopes of API keys. Users read and write bills and read exchange rates;
+// the configured admins have all scopes. Exchange rates are shared by all
+// users, so only admins write them, and metrics cover the bills of all
+// users, so only admins read them.
+const (
+	ScopeBillsRead    = "bills:read"
+	ScopeBillsWrite   = "bills:write"
+	ScopeFXRatesRead  = "fx-rates:read"
+	ScopeFXRatesWrite = "fx-rates:write"
+	ScopeMetricsRead  = "metrics:read"
+	ScopeAdmin        = "admin"
+)
+
+// Scopes lists all scopes in their canonical order
+var Scopes = []string{ScopeBillsRead, ScopeBillsWrite, ScopeFXRatesRead, ScopeFXRatesWrite, ScopeMetricsRead, ScopeAdmin}
+
+// UserScopes are the scopes of users who are not admins
+var UserScopes = []string{ScopeBillsRead, ScopeBillsWrite, ScopeFXRatesRead}
+
+// ValidScope reports whether scope is one of Scopes
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// NormalizeScopes sorts scopes into the canonical order and removes
+// duplicates. Unknown scopes are kept at the end in the given order.
+func NormalizeScopes(scopes []string) []string {
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range Scopes {
+		if slices.Contains(scopes, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	for _, scope := range scopes {
+		if !ValidScope(scope) && !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized
+}
+
+// GenerateAPIKey returns a new random API key
+func GenerateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up by. Keys
+// carry 256 random bits, so a fast hash suffices; there is nothing to guess.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefix returns the part of an API key that is stored in clear
+func APIKeyPrefix(key string) string {
+	if len(key) < apiKeyPrefixLength {
+		return key
+	}
+	return key[:apiKeyPrefixLength]
+}
@@ -0,0 +1,112 @@
+// Package auth identifies the user behind a request. An Authenticator either
+// verifies the JWT bearer token of the request or reads the identity that a
+// trusted gateway or BFF passes on in headers; automation authenticates with
+// an API key instead. The Principal a request resolves to scopes every query
+// of the request to the data of that user, and its scopes limit the routes
+// it may use.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// ErrUnauthenticated is returned when a request carries no usable identity
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Claims are the claims of a verified token, as decoded from its JSON payload
+type Claims map[string]interface{}
+
+// String returns a string claim, or "" if it is missing or not a string
+func (c Claims) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Identity is the user an Authenticator found on a request
+type Identity struct {
+	User models.UserInput
+	// Claims of the bearer token; nil if the identity was not read from a token
+	Claims Claims
+}
+
+// Principal is the authenticated user of a request
+type Principal struct {
+	// UserID is the ID of the user record; bills are owned by it
+	UserID int64
+	// Subject is the stable identifier of the user at the identity provider
+	Subject string
+	// Claims of the bearer token the request was authenticated with, if any
+	Claims Claims
+	// Scopes the principal is granted, e.g. ScopeBillsRead
+	Scopes []string
+	// APIKeyID is the ID of the API key the request was authenticated with,
+	// or 0 if it was authenticated as a user
+	APIKeyID int64
+}
+
+// HasScope reports whether the principal is granted scope
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// Authenticator extracts the identity of the user behind a request
+type Authenticator interface {
+	// Authenticate returns the identity of the user, or an error wrapping
+	// ErrUnauthenticated if the request does not identify one
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// HeaderAuthenticator trusts headers that the gateway or BFF sets on every
+// request after authenticating the user. It must only be used when clients
+// cannot reach the service directly and the gateway strips these headers
+// from incoming requests, otherwise anyone can claim any identity.
+type HeaderAuthenticator struct {
+	// SubjectHeader holds the subject and is required
+	SubjectHeader string
+	// EmailHeader and NameHeader hold optional profile data; empty names
+	// disable them
+	EmailHeader string
+	NameHeader  string
+	// EmailVerifiedHeader holds "true" if the identity provider verified
+	// the email; an empty name disables it, leaving every email unverified
+	EmailVerifiedHeader string
+}
+
+// Authenticate implements Authenticator
+func (a *HeaderAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	subject := strings.TrimSpace(r.Header.Get(a.SubjectHeader))
+	if subject == "" {
+		return nil, ErrUnauthenticated
+	}
+	identity := &Identity{User: models.UserInput{Subject: subject}}
+	if a.EmailHeader != "" {
+		identity.User.Email = strings.TrimSpace(r.Header.Get(a.EmailHeader))
+	}
+	if a.EmailVerifiedHeader != "" {
+		identity.User.EmailVerified, _ = strconv.ParseBool(strings.TrimSpace(r.Header.Get(a.EmailVerifiedHeader)))
+	}
+	if a.NameHeader != "" {
+		identity.User.Name = strings.TrimSpace(r.Header.Get(a.NameHeader))
+	}
+	return identity, nil
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the principal
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of ctx, or nil if it has none
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
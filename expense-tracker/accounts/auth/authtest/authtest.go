@@ -0,0 +1,117 @@
+// Package authtest issues JWTs for tests, offline and without an identity
+// provider. An Issuer generates its keys when it is created and serves their
+// JWKS from a local HTTP server:
+//
+//	func TestSomething(t *testing.T) {
+//		issuer := authtest.NewIssuer(t)
+//		authenticator := issuer.Authenticator(t)
+//		token := issuer.Token(t, "alice", nil)
+//		...
+//	}
+package authtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/auth"
+)
+
+// Issuer and audience of the tokens of an Issuer
+const (
+	IssuerName = "https://issuer.test"
+	Audience   = "accounts-test"
+)
+
+// HS256Secret is the secret of the HS256 signer of an Issuer
+var HS256Secret = []byte("authtest-hs256-secret-of-32-bytes!")
+
+// Issuer signs tokens with freshly generated keys
+type Issuer struct {
+	// ES256, RS256 and HS256 sign tokens with the respective algorithm
+	ES256 *auth.Signer
+	RS256 *auth.Signer
+	HS256 *auth.Signer
+
+	// JWKSURL serves the public keys of the ES256 and RS256 signers
+	JWKSURL string
+}
+
+// NewIssuer generates the keys of an issuer and starts serving their JWKS
+// until the end of the test
+func NewIssuer(t *testing.T) *Issuer {
+	t.Helper()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &Issuer{HS256: auth.NewHS256Signer(HS256Secret, IssuerName, Audience)}
+	if issuer.ES256, err = auth.NewKeySigner(ecKey, IssuerName, Audience); err != nil {
+		t.Fatal(err)
+	}
+	if issuer.RS256, err = auth.NewKeySigner(rsaKey, IssuerName, Audience); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := issuer.JWKS(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(server.Close)
+	issuer.JWKSURL = server.URL
+	return issuer
+}
+
+// JWKS returns the public keys of the ES256 and RS256 signers
+func (i *Issuer) JWKS(t *testing.T) auth.JWKS {
+	t.Helper()
+	var jwks auth.JWKS
+	for _, signer := range []*auth.Signer{i.ES256, i.RS256} {
+		keys, err := signer.JWKS()
+		if err != nil {
+			t.Fatal(err)
+		}
+		jwks.Keys = append(jwks.Keys, keys.Keys...)
+	}
+	return jwks
+}
+
+// Authenticator returns an authenticator that accepts the tokens of all
+// signers of the issuer, fetching the public keys from JWKSURL
+func (i *Issuer) Authenticator(t *testing.T) *auth.JWTAuthenticator {
+	t.Helper()
+	authenticator, err := auth.NewJWTAuthenticator(auth.JWTConfig{
+		Keys:        auth.NewRemoteKeySet(i.JWKSURL, time.Hour),
+		HS256Secret: HS256Secret,
+		Issuer:      IssuerName,
+		Audience:    Audience,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticator
+}
+
+// Token returns an ES256 token for the subject that is valid for an hour
+func (i *Issuer) Token(t *testing.T, subject string, claims auth.Claims) string {
+	t.Helper()
+	token, err := i.ES256.Sign(subject, time.Hour, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// errUnknownKey is returned for a key ID that is not in a key set
+var errUnknownKey = errors.New("unknown signing key")
+
+// ErrKeysUnavailable is returned when the keys of a RemoteKeySet cannot be
+// fetched. Tokens cannot be verified then, which is a server-side failure
+// rather than a problem of the token.
+var ErrKeysUnavailable = errors.New("signing keys unavailable")
+
+// KeySet provides the public keys that tokens are verified with
+type KeySet interface {
+	// Key returns the key with the ID; an empty ID matches the only key of
+	// a set with a single key
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517). Only RSA and
+// elliptic curve keys are supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Elliptic curve
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK returns the JWK of an RSA or ECDSA public key
+func NewJWK(kid string, key crypto.PublicKey) (JWK, error) {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return JWK{}, errors.New("only P-256 elliptic curve keys are supported")
+		}
+		point, err := key.ECDH()
+		if err != nil {
+			return JWK{}, err
+		}
+		// The uncompressed point is 0x04 followed by X and Y
+		raw := point.Bytes()[1:]
+		return JWK{
+			Kty: "EC",
+			Kid: kid,
+			Use: "sig",
+			Alg: "ES256",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(raw[:32]),
+			Y:   base64.RawURLEncoding.EncodeToString(raw[32:]),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// PublicKey decodes the key
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, errors.New("invalid RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key of %d bits is too short", key.N.BitLen())
+		}
+		return key, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 coordinates")
+		}
+		// Parsing the uncompressed point checks that it is on the curve
+		point, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, err
+		}
+		return ecdsaPublicKey(point), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// ecdsaPublicKey converts a validated P-256 point to an ECDSA key
+func ecdsaPublicKey(point *ecdh.PublicKey) *ecdsa.PublicKey {
+	raw := point.Bytes()[1:]
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(raw[:32]),
+		Y:     new(big.Int).SetBytes(raw[32:]),
+	}
+}
+
+// staticKeySet is a key set that does not change
+type staticKeySet map[string]crypto.PublicKey
+
+// Key implements KeySet
+func (s staticKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if kid == "" && len(s) == 1 {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+// ParseJWKS decodes a JSON Web Key Set. Keys that are not meant for
+// signatures are skipped; keys of unsupported types are an error.
+func ParseJWKS(data []byte) (KeySet, error) {
+	var jwks JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(staticKeySet, len(jwks.Keys))
+	for i, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d (%q) of JWKS: %w", i, jwk.Kid, err)
+		}
+		if _, ok := keys[jwk.Kid]; ok {
+			return nil, fmt.Errorf("JWKS contains key ID %q twice", jwk.Kid)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no signing keys")
+	}
+	return keys, nil
+}
+
+// LoadJWKSFile reads a JSON Web Key Set from a file
+func LoadJWKSFile(path string) (KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// RemoteKeySet fetches a JSON Web Key Set from a URL, usually the jwks_uri of
+// the identity provider. The keys are fetched on first use and again after
+// the refresh interval. An unknown key ID triggers an early refresh, so keys
+// rotated by the provider are picked up, but at most once per minimum
+// interval so that tokens with made-up key IDs cannot flood the provider.
+type RemoteKeySet struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	minInterval     time.Duration
+
+	mu        sync.Mutex
+	keys      KeySet
+	fetchedAt time.Time
+}
+
+// NewRemoteKeySet returns a key set that is fetched from url and refreshed
+// every refreshInterval
+func NewRemoteKeySet(url string, refreshInterval time.Duration) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:             url,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: refreshInterval,
+		minInterval:     time.Minute,
+	}
+}
+
+// Key implements KeySet
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := time.Since(s.fetchedAt)
+	if s.keys == nil || age >= s.refreshInterval {
+		if err := s.fetch(ctx); err != nil && s.keys == nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeysUnavailable, err)
+		}
+	}
+
+	key, err := s.keys.Key(ctx, kid)
+	if errors.Is(err, errUnknownKey) && time.Since(s.fetchedAt) >= s.minInterval {
+		if err := s.fetch(ctx); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrKeysUnavailable, err)
+		}
+		key, err = s.keys.Key(ctx, kid)
+	}
+	return key, err
+}
+
+// fetch replaces the keys with the current ones. On failure the previous
+// keys are kept, so an unreachable provider does not reject valid tokens.
+// The caller must hold the lock.
+func (s *RemoteKeySet) fetch(ctx context.Context) error {
+	// Failed attempts count as well, so a failing provider is not retried on every request
+	s.fetchedAt = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// MinHS256SecretLength is the minimum length in bytes of an HS256 secret
+const MinHS256SecretLength = 32
+
+// TokenError reports a missing or invalid bearer token. It matches
+// ErrUnauthenticated; Description is safe to show to clients.
+type TokenError struct {
+	// Code is the RFC 6750 error code, e.g. "invalid_token"; it is empty
+	// when the request carries no token at all
+	Code        string
+	Description string
+	Err         error
+}
+
+func (e *TokenError) Error() string {
+	if e.Err == nil {
+		return e.Description
+	}
+	return e.Description + ": " + e.Err.Error()
+}
+
+// Is makes token errors match ErrUnauthenticated
+func (e *TokenError) Is(target error) bool {
+	return target == ErrUnauthenticated
+}
+
+func (e *TokenError) Unwrap() error {
+	return e.Err
+}
+
+// Challenge returns the WWW-Authenticate header of the response
+func (e *TokenError) Challenge() string {
+	if e.Code == "" {
+		return "Bearer"
+	}
+	return fmt.Sprintf("Bearer error=%q, error_description=%q", e.Code, e.Description)
+}
+
+// JWTConfig configures a JWTAuthenticator
+type JWTConfig struct {
+	// Keys verifies RS256 and ES256 tokens, e.g. a RemoteKeySet for the
+	// JWKS URL of the identity provider; nil disables these algorithms
+	Keys KeySet
+	// HS256Secret verifies HS256 tokens, meant for local development; empty
+	// disables HS256
+	HS256Secret []byte
+
+	// Issuer and Audience must match the iss and aud claims
+	Issuer   string
+	Audience string
+	// Leeway allows for clock skew when checking exp, nbf and iat
+	Leeway time.Duration
+}
+
+// JWTAuthenticator authenticates requests with a JWT bearer token in the
+// Authorization header. Tokens must be signed with an enabled algorithm,
+// carry the configured issuer and audience, and have a subject and an
+// expiry in the future.
+type JWTAuthenticator struct {
+	keys        KeySet
+	hs256Secret []byte
+	parser      *jwt.Parser
+}
+
+// NewJWTAuthenticator returns an authenticator for the configured keys
+func NewJWTAuthenticator(cfg JWTConfig) (*JWTAuthenticator, error) {
+	var methods []string
+	if cfg.Keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg())
+	}
+	if len(cfg.HS256Secret) > 0 {
+		if len(cfg.HS256Secret) < MinHS256SecretLength {
+			return nil, fmt.Errorf("HS256 secret must be at least %d bytes long", MinHS256SecretLength)
+		}
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no keys to verify tokens with")
+	}
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, errors.New("the issuer and audience of tokens are required")
+	}
+
+	return &JWTAuthenticator{
+		keys:        cfg.Keys,
+		hs256Secret: cfg.HS256Secret,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods(methods),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+			jwt.WithLeeway(cfg.Leeway),
+		),
+	}, nil
+}
+
+// Authenticate implements Authenticator
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, &TokenError{Description: "missing bearer token"}
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, &TokenError{Code: "invalid_request", Description: "the Authorization header must contain a bearer token"}
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(strings.TrimSpace(token), claims, a.keyfunc(r.Context())); err != nil {
+		if errors.Is(err, ErrKeysUnavailable) {
+			return nil, err
+		}
+		return nil, &TokenError{Code: "invalid_token", Description: tokenErrorDescription(err), Err: err}
+	}
+
+	identity := &Identity{
+		User:   models.UserInput{Subject: stringClaim(claims, "sub")},
+		Claims: Claims(claims),
+	}
+	if identity.User.Subject == "" {
+		return nil, &TokenError{Code: "invalid_token", Description: "token has no subject"}
+	}
+	identity.User.Email = stringClaim(claims, "email")
+	identity.User.EmailVerified = boolClaim(claims, "email_verified")
+	identity.User.Name = stringClaim(claims, "name")
+	return identity, nil
+}
+
+// keyfunc returns the key a token is verified with. The key type must match
+// the algorithm, so a public key can never be used as an HS256 secret.
+func (a *JWTAuthenticator) keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if token.Method == jwt.SigningMethodHS256 {
+			return a.hs256Secret, nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		key, err := a.keys.Key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if token.Method != jwt.SigningMethodRS256 {
+				return nil, errors.New("key does not match the algorithm")
+			}
+		case *ecdsa.PublicKey:
+			if token.Method != jwt.SigningMethodES256 {
+				return nil, errors.New("key does not match the algorithm")
+			}
+		}
+		return key, nil
+	}
+}
+
+// tokenErrorDescription describes why a token was rejected
+func tokenErrorDescription(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "token is expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "token is not valid yet"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "token has an invalid issuer"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "token has an invalid audience"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "token is missing a required claim"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return "token signature is invalid"
+	default:
+		return "token is invalid"
+	}
+}
+
+// stringClaim returns a string claim, or "" if it is missing or not a string
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return strings.TrimSpace(value)
+}
+
+// boolClaim returns a boolean claim. Some providers send booleans as the
+// strings "true" and "false"; anything else is false.
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		parsed, _ := strconv.ParseBool(strings.TrimSpace(value))
+		return parsed
+	}
+	return false
+}
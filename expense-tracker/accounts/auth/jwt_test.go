@@ -0,0 +1,217 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jo/choreo-tutorial/accounts/auth"
+	"github.com/jo/choreo-tutorial/accounts/auth/authtest"
+)
+
+// authenticate authenticates a request with the bearer token
+func authenticate(authenticator auth.Authenticator, token string) (*auth.Identity, error) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return authenticator.Authenticate(req)
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	issuer := authtest.NewIssuer(t)
+	authenticator := issuer.Authenticator(t)
+
+	for name, signer := range map[string]*auth.Signer{"ES256": issuer.ES256, "RS256": issuer.RS256, "HS256": issuer.HS256} {
+		t.Run(name, func(t *testing.T) {
+			token, err := signer.Sign("alice", time.Minute, auth.Claims{"email": "alice@example.com", "name": "Alice", "roles": []string{"admin"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			identity, err := authenticate(authenticator, token)
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if identity.User.Subject != "alice" || identity.User.Email != "alice@example.com" || identity.User.Name != "Alice" {
+				t.Errorf("user = %+v, want alice with email and name", identity.User)
+			}
+			if identity.Claims.String("iss") != authtest.IssuerName || identity.Claims["roles"] == nil {
+				t.Errorf("claims = %v, want all claims of the token", identity.Claims)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorRejects(t *testing.T) {
+	issuer := authtest.NewIssuer(t)
+	authenticator := issuer.Authenticator(t)
+
+	sign := func(signer *auth.Signer, subject string, ttl time.Duration) string {
+		t.Helper()
+		token, err := signer.Sign(subject, ttl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, err := auth.NewKeySigner(otherKey, authtest.IssuerName, authtest.Audience)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := sign(issuer.ES256, "alice", time.Minute)
+	parts := strings.Split(valid, ".")
+
+	// An HS256 token whose secret is the public key of the issuer must not
+	// verify, whatever the key ID says
+	keys := issuer.JWKS(t)
+	jwks, _ := json.Marshal(keys)
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": authtest.IssuerName, "aud": authtest.Audience, "sub": "mallory", "exp": time.Now().Add(time.Minute).Unix(),
+	})
+	confused.Header["kid"] = keys.Keys[0].Kid
+	confusedToken, _ := confused.SignedString(jwks)
+
+	tests := []struct {
+		name        string
+		header      string
+		code        string
+		description string
+	}{
+		{"missing token", "", "", "missing bearer token"},
+		{"other scheme", "Basic YWxpY2U6c2VjcmV0", "invalid_request", "the Authorization header must contain a bearer token"},
+		{"malformed", "Bearer not-a-token", "invalid_token", "token is invalid"},
+		{"expired", "Bearer " + sign(issuer.ES256, "alice", -time.Minute), "invalid_token", "token is expired"},
+		{"wrong issuer", "Bearer " + sign(auth.NewHS256Signer(authtest.HS256Secret, "https://other.test", authtest.Audience), "alice", time.Minute), "invalid_token", "token has an invalid issuer"},
+		{"wrong audience", "Bearer " + sign(auth.NewHS256Signer(authtest.HS256Secret, authtest.IssuerName, "other"), "alice", time.Minute), "invalid_token", "token has an invalid audience"},
+		{"no subject", "Bearer " + sign(issuer.ES256, "", time.Minute), "invalid_token", "token has no subject"},
+		{"unknown key", "Bearer " + sign(unknown, "alice", time.Minute), "invalid_token", "token signature is invalid"},
+		{"tampered", "Bearer " + parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory"}`)) + "." + parts[2], "invalid_token", "token signature is invalid"},
+		{"algorithm confusion", "Bearer " + confusedToken, "invalid_token", "token signature is invalid"},
+		{"none algorithm", "Bearer " + base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".", "invalid_token", "token signature is invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			_, err := authenticator.Authenticate(req)
+			var tokenErr *auth.TokenError
+			if !errors.As(err, &tokenErr) || !errors.Is(err, auth.ErrUnauthenticated) {
+				t.Fatalf("Authenticate error = %v, want a TokenError", err)
+			}
+			if tokenErr.Code != tt.code || tokenErr.Description != tt.description {
+				t.Errorf("error = %q %q, want %q %q", tokenErr.Code, tokenErr.Description, tt.code, tt.description)
+			}
+		})
+	}
+}
+
+func TestJWKSFile(t *testing.T) {
+	issuer := authtest.NewIssuer(t)
+	data, err := json.Marshal(issuer.JWKS(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := auth.LoadJWKSFile(path)
+	if err != nil {
+		t.Fatalf("LoadJWKSFile: %v", err)
+	}
+	authenticator, err := auth.NewJWTAuthenticator(auth.JWTConfig{Keys: keys, Issuer: authtest.IssuerName, Audience: authtest.Audience})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticate(authenticator, issuer.Token(t, "alice", nil)); err != nil {
+		t.Errorf("Authenticate ES256 token: %v", err)
+	}
+	// HS256 is disabled without a secret
+	token, _ := issuer.HS256.Sign("alice", time.Minute, nil)
+	if _, err := authenticate(authenticator, token); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("Authenticate HS256 token error = %v, want ErrUnauthenticated", err)
+	}
+
+	for name, data := range map[string]string{
+		"not JSON":      "{",
+		"no keys":       `{"keys":[]}`,
+		"short RSA key": `{"keys":[{"kty":"RSA","n":"AQAB","e":"AQAB"}]}`,
+		"off curve":     `{"keys":[{"kty":"EC","crv":"P-256","x":"` + strings.Repeat("A", 43) + `","y":"` + strings.Repeat("A", 43) + `"}]}`,
+	} {
+		if _, err := auth.ParseJWKS([]byte(data)); err == nil {
+			t.Errorf("ParseJWKS(%s) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	issuer := authtest.NewIssuer(t)
+	rotated := authtest.NewIssuer(t)
+
+	// The provider serves the keys of issuer first and rotates to those of rotated
+	jwks := issuer.JWKS(t)
+	fail := false
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if fail {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(server.Close)
+
+	authenticator, err := auth.NewJWTAuthenticator(auth.JWTConfig{
+		Keys:     auth.NewRemoteKeySet(server.URL, time.Hour),
+		Issuer:   authtest.IssuerName,
+		Audience: authtest.Audience,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := authenticate(authenticator, issuer.Token(t, "alice", nil)); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if _, err := authenticate(authenticator, issuer.Token(t, "alice", nil)); err != nil || fetches != 1 {
+		t.Fatalf("Authenticate again = %v after %d fetches, want the cached keys", err, fetches)
+	}
+
+	// An unknown key is only looked up again after the minimum interval
+	jwks = rotated.JWKS(t)
+	if _, err := authenticate(authenticator, rotated.Token(t, "alice", nil)); !errors.Is(err, auth.ErrUnauthenticated) || fetches != 1 {
+		t.Errorf("Authenticate with a rotated key = %v after %d fetches, want ErrUnauthenticated without fetching", err, fetches)
+	}
+
+	// Without any keys, tokens cannot be verified at all
+	fail = true
+	unavailable, _ := auth.NewJWTAuthenticator(auth.JWTConfig{
+		Keys:     auth.NewRemoteKeySet(server.URL, time.Hour),
+		Issuer:   authtest.IssuerName,
+		Audience: authtest.Audience,
+	})
+	_, err = authenticate(unavailable, issuer.Token(t, "alice", nil))
+	if !errors.Is(err, auth.ErrKeysUnavailable) || errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("Authenticate without keys error = %v, want ErrKeysUnavailable", err)
+	}
+}
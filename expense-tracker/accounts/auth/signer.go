@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signer issues tokens without an identity provider, for local development
+// and tests. Tokens of a key signer are verified with the key set of its
+// JWKS, tokens of an HS256 signer with the same secret.
+type Signer struct {
+	Issuer   string
+	Audience string
+
+	method jwt.SigningMethod
+	key    interface{}
+	kid    string
+	public crypto.PublicKey
+}
+
+// NewHS256Signer returns a signer for HS256 tokens
+func NewHS256Signer(secret []byte, issuer, audience string) *Signer {
+	return &Signer{Issuer: issuer, Audience: audience, method: jwt.SigningMethodHS256, key: secret}
+}
+
+// NewKeySigner returns a signer for RS256 tokens with an RSA key or ES256
+// tokens with a P-256 key. The key ID is the RFC 7638 thumbprint of the
+// public key.
+func NewKeySigner(key crypto.Signer, issuer, audience string) (*Signer, error) {
+	signer := &Signer{Issuer: issuer, Audience: audience, key: key, public: key.Public()}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signer.method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 elliptic curve keys are supported")
+		}
+		signer.method = jwt.SigningMethodES256
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	jwk, err := NewJWK("", signer.public)
+	if err != nil {
+		return nil, err
+	}
+	signer.kid = jwk.Thumbprint()
+	return signer, nil
+}
+
+// Sign issues a token for the subject that expires after ttl. Extra claims
+// such as email and name are added to the registered ones.
+func (s *Signer) Sign(subject string, ttl time.Duration, extra Claims) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for name, value := range extra {
+		claims[name] = value
+	}
+	claims["iss"] = s.Issuer
+	claims["aud"] = s.Audience
+	claims["sub"] = subject
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	token := jwt.NewWithClaims(s.method, claims)
+	if s.kid != "" {
+		token.Header["kid"] = s.kid
+	}
+	return token.SignedString(s.key)
+}
+
+// JWKS returns the key set that verifies the tokens of a key signer
+func (s *Signer) JWKS() (JWKS, error) {
+	if s.public == nil {
+		return JWKS{}, errors.New("HS256 signers have no public key")
+	}
+	jwk, err := NewJWK(s.kid, s.public)
+	if err != nil {
+		return JWKS{}, err
+	}
+	return JWKS{Keys: []JWK{jwk}}, nil
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key, base64url encoded
+func (k JWK) Thumbprint() string {
+	// The required members in lexicographic order, without whitespace
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// LoadOrCreateKeyFile reads a PKCS #8 private key from a PEM file. If the
+// file does not exist, a new P-256 key is generated and written to it.
+func LoadOrCreateKeyFile(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded PKCS #8 private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s contains an unsupported key", path)
+	}
+	return signer, nil
+}
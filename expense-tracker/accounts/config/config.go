@@ -1,9 +1,15 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
 )
 
 // Config represents the application configuration
@@ -14,7 +20,74 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
+	DBSSLMode  string // For PostgreSQL
 	DBPath     string // For SQLite
+
+	// AutoMigrate applies pending schema migrations on startup
+	AutoMigrate bool
+
+	// DBQueryTimeout bounds every database operation; zero disables the limit
+	DBQueryTimeout time.Duration
+
+	// Port is the TCP port the HTTP server listens on
+	Port string
+
+	// HTTP server timeouts; zero disables the respective limit
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// ShutdownDelay is how long the server keeps serving, while reporting not
+	// ready, after a shutdown signal before it starts draining
+	ShutdownDelay time.Duration
+
+	// ShutdownTimeout is the grace period for in-flight requests on shutdown
+	ShutdownTimeout time.Duration
+
+	// TracesExporter selects where spans are sent: "none", "otlp" or "console"
+	TracesExporter string
+	// TracesFile is the file the console exporter writes to; empty means stdout
+	TracesFile string
+
+	// LogLevel is the minimum level of log records
+	LogLevel slog.Level
+	// LogFormat is "json" or "text"
+	LogFormat string
+
+	// Validation bounds the bills and items accepted by the API
+	Validation models.ValidationLimits
+
+	// RecurringBillsInterval is how often bills are generated from recurring
+	// bills; zero disables the generator
+	RecurringBillsInterval time.Duration
+	// RecurringBillsLeadDays is how many days before their due date bills
+	// are generated
+	RecurringBillsLeadDays int
+
+	// AuthMode selects how requests are authenticated: "jwt" verifies bearer
+	// tokens, "header" trusts identity headers set by the gateway or BFF
+	AuthMode string
+
+	// JWT verification; RS256 and ES256 keys come from a JWKS URL or file,
+	// the HS256 secret is meant for local development
+	AuthJWKSURL     string
+	AuthJWKSFile    string
+	AuthJWKSRefresh time.Duration
+	AuthHS256Secret string
+	AuthIssuer      string
+	AuthAudience    string
+	AuthClockSkew   time.Duration
+
+	// Headers the gateway or BFF identifies the user of a request with; the
+	// email, email verification and name headers are optional
+	AuthSubjectHeader       string
+	AuthEmailHeader         string
+	AuthEmailVerifiedHeader string
+	AuthNameHeader          string
+
+	// AuthAdminSubjects are the subjects of the users who may manage API keys
+	AuthAdminSubjects []string
 }
 
 // LoadConfig loads the configuration from environment variables
@@ -25,12 +98,44 @@ func LoadConfig() (*Config, error) {
 	}
 
 	dbType = strings.ToLower(dbType)
-	if dbType != "mysql" && dbType != "sqlite" {
+	if dbType != "mysql" && dbType != "postgres" && dbType != "sqlite" && dbType != "memory" {
 		return nil, fmt.Errorf("unsupported database type: %s", dbType)
 	}
 
+	autoMigrate, err := getEnvBool("DB_AUTO_MIGRATE", true)
+	if err != nil {
+		return nil, err
+	}
+
+	queryTimeout, err := getEnvDuration("DB_QUERY_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
 	config := &Config{
-		DBType: dbType,
+		DBType:         dbType,
+		AutoMigrate:    autoMigrate,
+		DBQueryTimeout: queryTimeout,
+		Port:           getEnv("PORT", "8080"),
+	}
+
+	// HTTP server timeouts
+	durations := []struct {
+		key      string
+		fallback time.Duration
+		target   *time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", 15 * time.Second, &config.ReadTimeout},
+		{"HTTP_READ_HEADER_TIMEOUT", 5 * time.Second, &config.ReadHeaderTimeout},
+		{"HTTP_WRITE_TIMEOUT", 30 * time.Second, &config.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", 60 * time.Second, &config.IdleTimeout},
+		{"SHUTDOWN_DELAY", 0, &config.ShutdownDelay},
+		{"SHUTDOWN_TIMEOUT", 20 * time.Second, &config.ShutdownTimeout},
+	}
+	for _, d := range durations {
+		if *d.target, err = getEnvDuration(d.key, d.fallback); err != nil {
+			return nil, err
+		}
 	}
 
 	switch dbType {
@@ -40,13 +145,125 @@ func LoadConfig() (*Config, error) {
 		config.DBUser = getEnv("DB_USER", "root")
 		config.DBPassword = getEnv("DB_PASSWORD", "")
 		config.DBName = getEnv("DB_NAME", "accounts")
+	case "postgres":
+		config.DBHost = getEnv("DB_HOST", "localhost")
+		config.DBPort = getEnv("DB_PORT", "5432")
+		config.DBUser = getEnv("DB_USER", "postgres")
+		config.DBPassword = getEnv("DB_PASSWORD", "")
+		config.DBName = getEnv("DB_NAME", "accounts")
+		config.DBSSLMode = strings.ToLower(getEnv("DB_SSLMODE", "disable"))
+		switch config.DBSSLMode {
+		case "disable", "require", "verify-ca", "verify-full":
+		default:
+			return nil, fmt.Errorf("unsupported DB_SSLMODE: %s", config.DBSSLMode)
+		}
 	case "sqlite":
 		config.DBPath = getEnv("DB_PATH", "./accounts.db")
 	}
 
+	// Tracing
+	config.TracesExporter = strings.ToLower(getEnv("OTEL_TRACES_EXPORTER", "none"))
+	switch config.TracesExporter {
+	case "none", "otlp", "console":
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER: %s", config.TracesExporter)
+	}
+	config.TracesFile = os.Getenv("TRACES_FILE")
+
+	// Logging
+	if err := config.LogLevel.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		return nil, fmt.Errorf("invalid value for LOG_LEVEL: %q", os.Getenv("LOG_LEVEL"))
+	}
+	config.LogFormat = strings.ToLower(getEnv("LOG_FORMAT", "json"))
+	if config.LogFormat != "json" && config.LogFormat != "text" {
+		return nil, fmt.Errorf("unsupported LOG_FORMAT: %s", config.LogFormat)
+	}
+
+	// Validation limits
+	config.Validation = models.DefaultValidationLimits()
+	ints := []struct {
+		key    string
+		max    int
+		target *int
+	}{
+		{"VALIDATION_MAX_TITLE_LENGTH", models.MaxTitleLength, &config.Validation.MaxTitleLength},
+		{"VALIDATION_MAX_NAME_LENGTH", models.MaxNameLength, &config.Validation.MaxNameLength},
+		{"VALIDATION_MAX_DESCRIPTION_LENGTH", 0, &config.Validation.MaxDescriptionLength},
+		{"VALIDATION_MAX_ITEMS", 0, &config.Validation.MaxItems},
+		{"VALIDATION_MAX_QUANTITY", 0, &config.Validation.MaxQuantity},
+		{"VALIDATION_DUE_DATE_YEARS", 0, &config.Validation.DueDateYears},
+	}
+	for _, i := range ints {
+		if *i.target, err = getEnvInt(i.key, *i.target, i.max); err != nil {
+			return nil, err
+		}
+	}
+	if value := os.Getenv("VALIDATION_MAX_AMOUNT"); value != "" {
+		config.Validation.MaxAmount, err = models.ParseMoney(value)
+		if err != nil || config.Validation.MaxAmount <= 0 {
+			return nil, fmt.Errorf("invalid value for VALIDATION_MAX_AMOUNT: %q", value)
+		}
+	}
+
+	// Recurring bills
+	if config.RecurringBillsInterval, err = getEnvDuration("RECURRING_BILLS_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if config.RecurringBillsLeadDays, err = getEnvInt("RECURRING_BILLS_LEAD_DAYS", 7, 366); err != nil {
+		return nil, err
+	}
+
+	// Authentication
+	config.AuthMode = strings.ToLower(getEnv("AUTH_MODE", "jwt"))
+	switch config.AuthMode {
+	case "jwt":
+		if err := loadJWTConfig(config); err != nil {
+			return nil, err
+		}
+	case "header":
+		config.AuthSubjectHeader = getEnv("AUTH_SUBJECT_HEADER", "X-User-Subject")
+		config.AuthEmailHeader = getEnv("AUTH_EMAIL_HEADER", "X-User-Email")
+		config.AuthEmailVerifiedHeader = getEnv("AUTH_EMAIL_VERIFIED_HEADER", "X-User-Email-Verified")
+		config.AuthNameHeader = getEnv("AUTH_NAME_HEADER", "X-User-Name")
+	default:
+		return nil, fmt.Errorf("unsupported AUTH_MODE: %s", config.AuthMode)
+	}
+	for _, subject := range strings.Split(os.Getenv("AUTH_ADMIN_SUBJECTS"), ",") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			config.AuthAdminSubjects = append(config.AuthAdminSubjects, subject)
+		}
+	}
+
 	return config, nil
 }
 
+// loadJWTConfig loads the settings of the jwt authentication mode. Whether
+// they suffice to verify tokens is checked when the server starts, so that
+// subcommands such as migrate work without them.
+func loadJWTConfig(config *Config) error {
+	config.AuthJWKSURL = os.Getenv("AUTH_JWKS_URL")
+	config.AuthJWKSFile = os.Getenv("AUTH_JWKS_FILE")
+	config.AuthHS256Secret = os.Getenv("AUTH_HS256_SECRET")
+	config.AuthIssuer = os.Getenv("AUTH_ISSUER")
+	config.AuthAudience = os.Getenv("AUTH_AUDIENCE")
+
+	var err error
+	if config.AuthJWKSRefresh, err = getEnvDuration("AUTH_JWKS_REFRESH_INTERVAL", time.Hour); err != nil {
+		return err
+	}
+	if config.AuthClockSkew, err = getEnvDuration("AUTH_CLOCK_SKEW", 30*time.Second); err != nil {
+		return err
+	}
+
+	if config.AuthJWKSURL != "" && config.AuthJWKSFile != "" {
+		return errors.New("AUTH_JWKS_URL and AUTH_JWKS_FILE are mutually exclusive")
+	}
+	if config.AuthJWKSRefresh <= 0 {
+		return fmt.Errorf("invalid value for AUTH_JWKS_REFRESH_INTERVAL: %q", os.Getenv("AUTH_JWKS_REFRESH_INTERVAL"))
+	}
+	return nil
+}
+
 // getEnv gets the environment variable or returns the fallback value
 func getEnv(key, fallback string) string {
 	value := os.Getenv(key)
@@ -55,3 +272,46 @@ func getEnv(key, fallback string) string {
 	}
 	return value
 }
+
+// getEnvBool gets a boolean environment variable or returns the fallback value
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: %q", key, value)
+	}
+	return parsed, nil
+}
+
+// getEnvInt gets a positive integer environment variable or returns the
+// fallback value; max bounds the value unless it is zero
+func getEnvInt(key string, fallback, max int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		return 0, fmt.Errorf("invalid value for %s: %q", key, value)
+	}
+	if max > 0 && parsed > max {
+		return 0, fmt.Errorf("invalid value for %s: %q (at most %d)", key, value, max)
+	}
+	return parsed, nil
+}
+
+// getEnvDuration gets a duration environment variable such as "5s" or returns the fallback value
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid value for %s: %q", key, value)
+	}
+	return parsed, nil
+}
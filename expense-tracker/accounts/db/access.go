@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// Access control of bills. A user's role on a bill is the highest of:
+//
+//   - owner, if the user owns the bill or the ledger it is filed in
+//   - the role of a share of the bill with the user
+//   - the role of a share of the ledger of the bill with the user
+//
+// Users without a role do not see the bill at all. The helpers take the
+// placeholder conversion of the dialect, e.g. rebind for PostgreSQL, and
+// work on a connection pool or a transaction.
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// billRole returns the role of the user on the bill, or ErrNotFound if the
+// bill does not exist or the user has no role on it
+func billRole(ctx context.Context, q querier, bind func(string) string, userID, billID int64) (models.Role, error) {
+	var ownerID int64
+	var ledgerOwnerID sql.NullInt64
+	var billShare, ledgerShare sql.NullString
+	err := q.QueryRowContext(ctx, bind(`
+	SELECT b.owner_id, l.owner_id, bs.role, ls.role
+	FROM bills b
+	LEFT JOIN ledgers l ON l.id = b.ledger_id
+	LEFT JOIN bill_shares bs ON bs.bill_id = b.id AND bs.user_id = ?
+	LEFT JOIN ledger_shares ls ON ls.ledger_id = b.ledger_id AND ls.user_id = ?
+	WHERE b.id = ?
+	`), userID, userID, billID).Scan(&ownerID, &ledgerOwnerID, &billShare, &ledgerShare)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var role models.Role
+	if ownerID == userID || (ledgerOwnerID.Valid && ledgerOwnerID.Int64 == userID) {
+		role = models.RoleOwner
+	}
+	role = role.Max(models.Role(billShare.String)).Max(models.Role(ledgerShare.String))
+	if !role.Valid() {
+		return "", ErrNotFound
+	}
+	return role, nil
+}
+
+// requireBillRole returns the role of the user on the bill if it includes
+// the required one, and a RoleError otherwise
+func requireBillRole(ctx context.Context, q querier, bind func(string) string, userID, billID int64, required models.Role) (models.Role, error) {
+	role, err := billRole(ctx, q, bind, userID, billID)
+	if err != nil {
+		return "", err
+	}
+	if !role.Allows(required) {
+		return "", &RoleError{Type: models.ShareTypeBill, Role: role, Required: required}
+	}
+	return role, nil
+}
+
+// ledgerRole returns the role of the user on the ledger, or ErrNotFound if
+// the ledger does not exist or the user has no role on it
+func ledgerRole(ctx context.Context, q querier, bind func(string) string, userID, ledgerID int64) (models.Role, error) {
+	var ownerID int64
+	var share sql.NullString
+	err := q.QueryRowContext(ctx, bind(`
+	SELECT l.owner_id, ls.role
+	FROM ledgers l
+	LEFT JOIN ledger_shares ls ON ls.ledger_id = l.id AND ls.user_id = ?
+	WHERE l.id = ?
+	`), userID, ledgerID).Scan(&ownerID, &share)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	role := models.Role(share.String)
+	if ownerID == userID {
+		role = models.RoleOwner
+	}
+	if !role.Valid() {
+		return "", ErrNotFound
+	}
+	return role, nil
+}
+
+// requireLedgerRole returns the role of the user on the ledger if it
+// includes the required one, and a RoleError otherwise
+func requireLedgerRole(ctx context.Context, q querier, bind func(string) string, userID, ledgerID int64, required models.Role) (models.Role, error) {
+	role, err := ledgerRole(ctx, q, bind, userID, ledgerID)
+	if err != nil {
+		return "", err
+	}
+	if !role.Allows(required) {
+		return "", &RoleError{Type: models.ShareTypeLedger, Role: role, Required: required}
+	}
+	return role, nil
+}
+
+// checkBillLedger checks that a bill may be filed in the ledger of the
+// input: the user must be an editor of it. A ledger the user cannot see is
+// reported as an invalid field rather than ErrNotFound, which would read as
+// a missing bill.
+func checkBillLedger(ctx context.Context, q querier, bind func(string) string, userID int64, billInput *models.BillInput) error {
+	if billInput.LedgerID == nil {
+		return nil
+	}
+	_, err := requireLedgerRole(ctx, q, bind, userID, *billInput.LedgerID, models.RoleEditor)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return NewValidationError("ledger_id", "must be a ledger you have access to")
+	case errors.Is(err, ErrForbidden):
+		return NewValidationError("ledger_id", "must be a ledger you are an editor of")
+	}
+	return err
+}
+
+// checkBillUpdate checks that the user may update a bill with the input:
+// editors change bills within their ledger, and only owners move them to
+// another ledger, which they must be an editor of.
+func checkBillUpdate(ctx context.Context, q querier, bind func(string) string, userID, billID int64, billInput *models.BillInput) error {
+	role, err := requireBillRole(ctx, q, bind, userID, billID, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	var ledgerID sql.NullInt64
+	err = q.QueryRowContext(ctx, bind("SELECT ledger_id FROM bills WHERE id = ?"), billID).Scan(&ledgerID)
+	if err != nil {
+		return err
+	}
+	if sameID(nullInt64(ledgerID), billInput.LedgerID) {
+		return nil
+	}
+	if !role.Allows(models.RoleOwner) {
+		return &RoleError{Type: models.ShareTypeBill, Role: role, Required: models.RoleOwner}
+	}
+	return checkBillLedger(ctx, q, bind, userID, billInput)
+}
+
+// itemBillID returns the ID of the bill of an item if the user may edit
+// the bill, and ErrNotFound if the item does not exist for the user
+func itemBillID(ctx context.Context, q querier, bind func(string) string, userID, itemID int64) (int64, error) {
+	var billID int64
+	err := q.QueryRowContext(ctx, bind("SELECT bill_id FROM bill_items WHERE id = ?"), itemID).Scan(&billID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := requireBillRole(ctx, q, bind, userID, billID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	return billID, nil
+}
+
+// nullInt64 converts a nullable ID column to a pointer
+func nullInt64(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
+
+// sameID reports whether two optional IDs are equal
+func sameID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// billAccessCondition returns a condition on the bills table "b" that holds
+// for the bills the user has any role on, with its arguments
+func billAccessCondition(userID int64) (string, []interface{}) {
+	return `(b.owner_id = ?
+		OR EXISTS (SELECT 1 FROM bill_shares bs WHERE bs.bill_id = b.id AND bs.user_id = ?)
+		OR EXISTS (SELECT 1 FROM ledgers l WHERE l.id = b.ledger_id AND l.owner_id = ?)
+		OR EXISTS (SELECT 1 FROM ledger_shares ls WHERE ls.ledger_id = b.ledger_id AND ls.user_id = ?))`,
+		[]interface{}{userID, userID, userID, userID}
+}
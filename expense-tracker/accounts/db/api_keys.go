@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// sqlAPIKeys implements the API key methods on a connection pool. The
+// statements use ? placeholders and are passed through bind, e.g. rebind
+// for PostgreSQL. Scopes are stored space-separated.
+type sqlAPIKeys struct {
+	db   *sql.DB
+	bind func(string) string
+}
+
+// getAPIKeys returns all API keys, including revoked ones, oldest first
+func (k sqlAPIKeys) getAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := k.db.QueryContext(ctx, k.bind(apiKeyColumns+`
+	FROM api_keys
+	ORDER BY id
+	`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// findAPIKey returns the API key matching a condition on the api_keys table
+func (k sqlAPIKeys) findAPIKey(ctx context.Context, condition string, args ...interface{}) (*models.APIKey, error) {
+	key, err := scanAPIKey(k.db.QueryRowContext(ctx, k.bind(apiKeyColumns+`
+	FROM api_keys
+	WHERE `+condition), args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+// createAPIKey stores a key and returns its ID. The hash is unique, so the
+// key is read back by it instead of relying on the driver for the new ID.
+func (k sqlAPIKeys) createAPIKey(ctx context.Context, key *models.APIKey) (int64, error) {
+	_, err := k.db.ExecContext(ctx, k.bind(`
+	INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes) VALUES (?, ?, ?, ?, ?)
+	`), key.UserID, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, " "))
+	if err != nil {
+		return 0, translateError(err)
+	}
+	stored, err := k.findAPIKey(ctx, "key_hash = ?", key.Hash)
+	if err != nil {
+		return 0, err
+	}
+	return stored.ID, nil
+}
+
+// revokeAPIKey marks a key as revoked; revoking it again keeps the time of
+// the first revocation
+func (k sqlAPIKeys) revokeAPIKey(ctx context.Context, id int64) error {
+	// Check if the key exists; RowsAffected is not usable for this in MySQL
+	if _, err := k.findAPIKey(ctx, "id = ?", id); err != nil {
+		return err
+	}
+	_, err := k.db.ExecContext(ctx, k.bind(`
+	UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL
+	`), id)
+	return translateError(err)
+}
+
+// touchAPIKey records that a key was used now
+func (k sqlAPIKeys) touchAPIKey(ctx context.Context, id int64) error {
+	_, err := k.db.ExecContext(ctx, k.bind(`
+	UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?
+	`), id)
+	return translateError(err)
+}
+
+// apiKeyColumns selects the columns scanAPIKey reads
+const apiKeyColumns = `
+	SELECT id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at`
+
+// scanAPIKey reads a row selected with apiKeyColumns
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.Hash,
+		&scopes,
+		&key.CreatedAt,
+		&lastUsedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = strings.Fields(scopes)
+	key.LastUsedAt = nullTime(lastUsedAt)
+	key.RevokedAt = nullTime(revokedAt)
+	return &key, nil
+}
+
+// nullTime returns the time of a nullable column, or nil if it is NULL
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// billSortColumns maps the sort fields accepted by the API to columns of the bills table
+var billSortColumns = map[string]string{
+	models.BillSortDueDate:   "b.due_date",
+	models.BillSortTotal:     "b.total_cents",
+	models.BillSortTitle:     "b.title",
+	models.BillSortCreatedAt: "b.created_at",
+	models.BillSortUpdatedAt: "b.updated_at",
+}
+
+// buildBillFilter builds the WHERE clause and its arguments for listing the
+// bills a user has access to, or the bills of all users for AllOwners.
+// The clause uses ? placeholders and refers to the bills table as "b".
+func buildBillFilter(userID int64, query *models.BillQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if userID != AllOwners {
+		condition, accessArgs := billAccessCondition(userID)
+		conditions = append(conditions, condition)
+		args = append(args, accessArgs...)
+	}
+	if query.LedgerID != nil {
+		conditions = append(conditions, "b.ledger_id = ?")
+		args = append(args, *query.LedgerID)
+	}
+	if query.CategoryID != nil {
+		condition, categoryArgs := categoryCondition(*query.CategoryID)
+		conditions = append(conditions, condition)
+		args = append(args, categoryArgs...)
+	}
+	if query.MerchantID != nil {
+		conditions = append(conditions, "b.merchant_id = ?")
+		args = append(args, *query.MerchantID)
+	}
+	if len(query.Tags) > 0 {
+		condition, tagArgs := tagCondition(query.Tags, query.TagMatch == models.TagMatchAll)
+		conditions = append(conditions, condition)
+		args = append(args, tagArgs...)
+	}
+
+	if query.Paid != nil {
+		conditions = append(conditions, "b.paid = ?")
+		args = append(args, *query.Paid)
+	}
+	if query.PaymentStatus != "" {
+		condition, statusArgs := paymentStatusCondition(query.PaymentStatus)
+		conditions = append(conditions, condition)
+		args = append(args, statusArgs...)
+	}
+	if query.DueFrom != nil {
+		conditions = append(conditions, "b.due_date >= ?")
+		args = append(args, query.DueFrom.Format("2006-01-02"))
+	}
+	if query.DueTo != nil {
+		conditions = append(conditions, "b.due_date <= ?")
+		args = append(args, query.DueTo.Format("2006-01-02"))
+	}
+	if query.MinTotal != nil {
+		conditions = append(conditions, "b.total_cents >= ?")
+		args = append(args, *query.MinTotal)
+	}
+	if query.MaxTotal != nil {
+		conditions = append(conditions, "b.total_cents <= ?")
+		args = append(args, *query.MaxTotal)
+	}
+	if query.Currency != "" {
+		conditions = append(conditions, "b.currency = ?")
+		args = append(args, query.Currency)
+	}
+	if query.Title != "" {
+		conditions = append(conditions, "LOWER(b.title) LIKE ? ESCAPE '!'")
+		args = append(args, "%"+escapeLike(strings.ToLower(query.Title))+"%")
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// buildBillOrder builds the ORDER BY clause for listing bills.
+// The bill ID is always used as a tie-breaker so that pages are stable.
+func buildBillOrder(query *models.BillQuery) string {
+	column, ok := billSortColumns[query.SortBy]
+	if !ok {
+		column = billSortColumns[models.BillSortDueDate]
+	}
+
+	direction := "ASC"
+	if query.SortOrder == models.SortDesc {
+		direction = "DESC"
+	}
+
+	return "ORDER BY " + column + " " + direction + ", b.id " + direction
+}
+
+// escapeLike escapes the LIKE wildcards in a search term using '!' as the escape character
+func escapeLike(term string) string {
+	replacer := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return replacer.Replace(term)
+}
+
+// rebind converts ? placeholders into the numbered $1, $2, ... placeholders used by PostgreSQL
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,246 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// sqlCategories implements the category methods on a connection pool, like
+// sqlSharing. Users see the default categories, which have no owner, and
+// their own.
+type sqlCategories struct {
+	db          *sql.DB
+	bind        func(string) string
+	returningID bool
+}
+
+const categoryColumns = `
+	SELECT id, owner_id, parent_id, name, created_at, updated_at
+	FROM categories
+	WHERE (owner_id IS NULL OR owner_id = ?)`
+
+// getCategories returns the default categories and those of the user, by name
+func (s sqlCategories) getCategories(ctx context.Context, userID int64) ([]models.Category, error) {
+	rows, err := s.db.QueryContext(ctx, s.bind(categoryColumns+`
+	ORDER BY name, id
+	`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+	return categories, rows.Err()
+}
+
+// getCategory returns a default category or one of the user
+func (s sqlCategories) getCategory(ctx context.Context, userID, id int64) (*models.Category, error) {
+	category, err := scanCategory(s.db.QueryRowContext(ctx, s.bind(categoryColumns+`
+	AND id = ?
+	`), userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return category, err
+}
+
+// createCategory creates a category owned by the user
+func (s sqlCategories) createCategory(ctx context.Context, userID int64, input *models.CategoryInput) (id int64, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = checkCategoryParent(ctx, tx, s.bind, userID, 0, input.ParentID); err != nil {
+		return 0, err
+	}
+
+	query := "INSERT INTO categories (owner_id, parent_id, name) VALUES (?, ?, ?)"
+	if s.returningID {
+		err = tx.QueryRowContext(ctx, s.bind(query+" RETURNING id"), userID, input.ParentID, input.Name).Scan(&id)
+		if err = translateError(err); err != nil {
+			return 0, err
+		}
+	} else {
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, s.bind(query), userID, input.ParentID, input.Name)
+		if err = translateError(err); err != nil {
+			return 0, err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// updateCategory renames and moves a category of the user
+func (s sqlCategories) updateCategory(ctx context.Context, userID, id int64, input *models.CategoryInput) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = ownCategory(ctx, tx, s.bind, userID, id); err != nil {
+		return err
+	}
+	if err = checkCategoryParent(ctx, tx, s.bind, userID, id, input.ParentID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind("UPDATE categories SET parent_id = ?, name = ? WHERE id = ?"), input.ParentID, input.Name, id)
+	if err = translateError(err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteCategory deletes a category of the user and its subcategories; their
+// bills and items are kept uncategorized
+func (s sqlCategories) deleteCategory(ctx context.Context, userID, id int64) error {
+	if err := ownCategory(ctx, s.db, s.bind, userID, id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.bind("DELETE FROM categories WHERE id = ?"), id)
+	return translateError(err)
+}
+
+// ownCategory returns ErrNotFound if the user does not see the category and
+// ErrDefaultCategory if it is a default one
+func ownCategory(ctx context.Context, q querier, bind func(string) string, userID, id int64) error {
+	var ownerID sql.NullInt64
+	err := q.QueryRowContext(ctx, bind(`
+	SELECT owner_id FROM categories WHERE id = ? AND (owner_id IS NULL OR owner_id = ?)
+	`), id, userID).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !ownerID.Valid {
+		return ErrDefaultCategory
+	}
+	return nil
+}
+
+// checkCategoryParent checks that the user sees the parent of the category
+// with the ID, 0 for a new one, and that the parent is not the category
+// itself or one of its subcategories, which would make a cycle
+func checkCategoryParent(ctx context.Context, q querier, bind func(string) string, userID, id int64, parentID *int64) error {
+	for ancestor := parentID; ancestor != nil; {
+		if *ancestor == id {
+			return NewValidationError("parent_id", "must not be the category itself or one of its subcategories")
+		}
+
+		var ownerID, next sql.NullInt64
+		err := q.QueryRowContext(ctx, bind("SELECT owner_id, parent_id FROM categories WHERE id = ?"), *ancestor).Scan(&ownerID, &next)
+		if errors.Is(err, sql.ErrNoRows) {
+			return NewValidationError("parent_id", "must be a category you have access to")
+		}
+		if err != nil {
+			return err
+		}
+		// Ancestors of a category the user sees are visible as well
+		if ancestor == parentID && ownerID.Valid && ownerID.Int64 != userID {
+			return NewValidationError("parent_id", "must be a category you have access to")
+		}
+		ancestor = nullInt64(next)
+	}
+	return nil
+}
+
+// checkBillCategories checks the categories of a bill and its items with
+// checkCategory and reports all invalid ones. The bill ID is 0 for a new bill.
+func checkBillCategories(ctx context.Context, q querier, bind func(string) string, userID, billID int64, billInput *models.BillInput) error {
+	verr := &ValidationError{}
+	if err := checkCategory(ctx, q, bind, userID, billID, billInput.CategoryID, "category_id", verr); err != nil {
+		return err
+	}
+	for i, item := range billInput.Items {
+		if err := checkCategory(ctx, q, bind, userID, billID, item.CategoryID, fmt.Sprintf("items[%d].category_id", i), verr); err != nil {
+			return err
+		}
+	}
+	return verr.Err()
+}
+
+// checkItemCategory checks the category of an item of the bill like
+// checkBillCategories
+func checkItemCategory(ctx context.Context, q querier, bind func(string) string, userID, billID int64, itemInput *models.BillItemInput) error {
+	verr := &ValidationError{}
+	if err := checkCategory(ctx, q, bind, userID, billID, itemInput.CategoryID, "category_id", verr); err != nil {
+		return err
+	}
+	return verr.Err()
+}
+
+// checkCategory adds the field to verr unless the category may be used on
+// the bill: default categories, and those of the user or of the owner of the
+// bill, so that members of a shared bill keep the categories of the owner
+func checkCategory(ctx context.Context, q querier, bind func(string) string, userID, billID int64, categoryID *int64, field string, verr *ValidationError) error {
+	if categoryID == nil {
+		return nil
+	}
+	var exists int
+	err := q.QueryRowContext(ctx, bind(`
+	SELECT COUNT(*) FROM categories c
+	WHERE c.id = ? AND (c.owner_id IS NULL OR c.owner_id = ? OR c.owner_id = (SELECT b.owner_id FROM bills b WHERE b.id = ?))
+	`), *categoryID, userID, billID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		verr.Add(field, "must be a category you have access to")
+	}
+	return nil
+}
+
+// categoryCondition returns a condition on the bills table "b" that holds
+// for the bills in the category or one of its subcategories, or with an item
+// in them, with its arguments
+func categoryCondition(categoryID int64) (string, []interface{}) {
+	return `EXISTS (
+		WITH RECURSIVE tree (id) AS (
+			SELECT id FROM categories WHERE id = ?
+			UNION ALL
+			SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT 1 FROM tree t
+		WHERE t.id = b.category_id
+			OR t.id IN (SELECT bi.category_id FROM bill_items bi WHERE bi.bill_id = b.id))`,
+		[]interface{}{categoryID}
+}
+
+// scanCategory scans a row of categoryColumns
+func scanCategory(row interface{ Scan(...interface{}) error }) (*models.Category, error) {
+	var category models.Category
+	var ownerID, parentID sql.NullInt64
+	err := row.Scan(&category.ID, &ownerID, &parentID, &category.Name, &category.CreatedAt, &category.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	category.OwnerID = nullInt64(ownerID)
+	category.ParentID = nullInt64(parentID)
+	return &category, nil
+}
@@ -1,43 +1,172 @@
 package db
 
 import (
-	"errors"
+	"context"
+	"database/sql"
+	"time"
 
 	"github.com/jo/choreo-tutorial/accounts/config"
+	"github.com/jo/choreo-tutorial/accounts/migrations"
 	"github.com/jo/choreo-tutorial/accounts/models"
-)
 
-// Common errors
-var (
-	ErrNotFound = errors.New("record not found")
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// Database is the interface for database operations
+// AllOwners makes GetBills and GetBillTotals cover the bills of all users.
+// It is meant for service-wide aggregates such as metrics and is never
+// accepted by the other methods, which only see bills the given user has
+// access to.
+const AllOwners int64 = -1
+
+// Database is the interface for database operations.
+// All methods except Close honour the cancellation and deadline of the context.
+//
+// Bills and their items belong to a user. Users share them with other users
+// in a role, one by one or filed in a ledger. Every bill, item, ledger and
+// share method takes the ID of the user making the request and only sees
+// what that user has a role on; anything else is reported as ErrNotFound.
+//
+// Operations the role of the user does not permit fail with a RoleError,
+// which matches ErrForbidden. Viewers read. Payers also record payments and
+// mark bills as paid. Editors also change bills and items. Owners also
+// delete them and manage their shares.
+//
+// Users see the default categories and their own, and only change their own.
+// Tags and merchants belong to the owner of the bills they are on. Recurring
+// bills generate bills of their user; GenerateRecurringBills covers those of
+// all users. Exchange rates are shared by all users. API keys are managed by
+// admins and not scoped to a user.
 type Database interface {
+	// Users
+	EnsureUser(ctx context.Context, user *models.UserInput) (*models.User, error)
+	GetUser(ctx context.Context, id int64) (*models.User, error)
+	FindUserBySubject(ctx context.Context, subject string) (*models.User, error)
+	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
+
+	// API keys
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error)
+	FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error)
+	CreateAPIKey(ctx context.Context, key *models.APIKey) (int64, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+	TouchAPIKey(ctx context.Context, id int64) error
+
 	// Bills
-	GetBills() ([]models.BillSummary, error)
-	GetBill(id int64) (*models.Bill, error)
-	CreateBill(bill *models.BillInput) (int64, error)
-	UpdateBill(id int64, bill *models.BillInput) error
-	DeleteBill(id int64) error
+	GetBills(ctx context.Context, userID int64, query *models.BillQuery) ([]models.BillSummary, int, error)
+	GetBill(ctx context.Context, userID, id int64) (*models.Bill, error)
+	CreateBill(ctx context.Context, userID int64, bill *models.BillInput) (int64, error)
+	UpdateBill(ctx context.Context, userID, id int64, bill *models.BillInput) error
+	SetBillPaid(ctx context.Context, userID, id int64, paid bool) error
+	DeleteBill(ctx context.Context, userID, id int64) error
+	GetBillTotals(ctx context.Context, userID int64, query *models.BillQuery) ([]models.CurrencyTotal, error)
 
 	// BillItems
-	GetBillItems(billID int64) ([]models.BillItem, error)
-	GetBillItem(id int64) (*models.BillItem, error)
-	CreateBillItem(billID int64, item *models.BillItemInput) (int64, error)
-	UpdateBillItem(id int64, item *models.BillItemInput) error
-	DeleteBillItem(id int64) error
+	GetBillItems(ctx context.Context, userID, billID int64) ([]models.BillItem, error)
+	GetBillItem(ctx context.Context, userID, id int64) (*models.BillItem, error)
+	CreateBillItem(ctx context.Context, userID, billID int64, item *models.BillItemInput) (int64, error)
+	UpdateBillItem(ctx context.Context, userID, id int64, item *models.BillItemInput) error
+	DeleteBillItem(ctx context.Context, userID, id int64) error
+
+	// Bill payments
+	GetBillPayments(ctx context.Context, userID, billID int64) ([]models.Payment, error)
+	GetBillPayment(ctx context.Context, userID, billID, id int64) (*models.Payment, error)
+	CreateBillPayment(ctx context.Context, userID, billID int64, payment *models.PaymentInput) (int64, error)
+	UpdateBillPayment(ctx context.Context, userID, billID, id int64, payment *models.PaymentInput) error
+	DeleteBillPayment(ctx context.Context, userID, billID, id int64) error
+
+	// Ledgers
+	GetLedgers(ctx context.Context, userID int64) ([]models.Ledger, error)
+	GetLedger(ctx context.Context, userID, id int64) (*models.Ledger, error)
+	CreateLedger(ctx context.Context, userID int64, ledger *models.LedgerInput) (int64, error)
+	UpdateLedger(ctx context.Context, userID, id int64, ledger *models.LedgerInput) error
+	DeleteLedger(ctx context.Context, userID, id int64) error
+
+	// Shares of bills and ledgers
+	GetShares(ctx context.Context, userID int64, target models.ShareTarget) ([]models.Share, error)
+	CreateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error
+	UpdateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error
+	DeleteShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64) error
+
+	// Categories
+	GetCategories(ctx context.Context, userID int64) ([]models.Category, error)
+	GetCategory(ctx context.Context, userID, id int64) (*models.Category, error)
+	CreateCategory(ctx context.Context, userID int64, category *models.CategoryInput) (int64, error)
+	UpdateCategory(ctx context.Context, userID, id int64, category *models.CategoryInput) error
+	DeleteCategory(ctx context.Context, userID, id int64) error
+
+	// Tags
+	GetTags(ctx context.Context, userID int64) ([]models.Tag, error)
+	GetTag(ctx context.Context, userID, id int64) (*models.Tag, error)
+	CreateTag(ctx context.Context, userID int64, tag *models.TagInput) (int64, error)
+	UpdateTag(ctx context.Context, userID, id int64, tag *models.TagInput) error
+	DeleteTag(ctx context.Context, userID, id int64) error
+
+	// Merchants
+	GetMerchants(ctx context.Context, userID int64) ([]models.Merchant, error)
+	GetMerchant(ctx context.Context, userID, id int64) (*models.Merchant, error)
+	CreateMerchant(ctx context.Context, userID int64, merchant *models.MerchantInput) (int64, error)
+	UpdateMerchant(ctx context.Context, userID, id int64, merchant *models.MerchantInput) error
+	DeleteMerchant(ctx context.Context, userID, id int64) error
+
+	// Recurring bills
+	GetRecurringBills(ctx context.Context, userID int64) ([]models.RecurringBill, error)
+	GetRecurringBill(ctx context.Context, userID, id int64) (*models.RecurringBill, error)
+	CreateRecurringBill(ctx context.Context, userID int64, recurring *models.RecurringBillInput) (int64, error)
+	UpdateRecurringBill(ctx context.Context, userID, id int64, recurring *models.RecurringBillInput) error
+	DeleteRecurringBill(ctx context.Context, userID, id int64) error
+	SetRecurringBillPaused(ctx context.Context, userID, id int64, paused bool) error
+	SkipRecurringBill(ctx context.Context, userID, id int64, date time.Time) error
+	EndRecurringBill(ctx context.Context, userID, id int64, date time.Time) error
+	GenerateRecurringBills(ctx context.Context, today, through time.Time) (int, error)
+
+	// FX rates
+	GetFXRates(ctx context.Context, base, quote string) ([]models.FXRate, error)
+	GetFXRate(ctx context.Context, id int64) (*models.FXRate, error)
+	FindFXRate(ctx context.Context, base, quote string, on time.Time) (*models.FXRate, error)
+	CreateFXRate(ctx context.Context, rate *models.FXRateInput) (int64, error)
+	UpdateFXRate(ctx context.Context, id int64, rate *models.FXRateInput) error
+	DeleteFXRate(ctx context.Context, id int64) error
 
 	// Database management
-	CreateTables() error
+	Ping(ctx context.Context) error
+	Migrate(ctx context.Context) error
 	Close() error
 }
 
+// Migratable is implemented by databases whose schema is managed by versioned migrations
+type Migratable interface {
+	Migrator() *migrations.Migrator
+}
+
+// Pooled is implemented by databases backed by a database/sql connection pool
+type Pooled interface {
+	DB() *sql.DB
+}
+
+// openDB opens a connection pool whose statements are traced with OpenTelemetry
+func openDB(driverName, dsn string, system attribute.KeyValue) (*sql.DB, error) {
+	return otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			OmitConnectorConnect: true,
+			DisableErrSkip:       true,
+		}),
+	)
+}
+
 // InitDB initializes the database based on the configuration
 func InitDB(cfg *config.Config) (Database, error) {
 	switch cfg.DBType {
 	case "mysql":
 		return NewMySQLDB(cfg)
+	case "postgres":
+		return NewPostgresDB(cfg)
+	case "memory":
+		return NewMemoryDB(), nil
 	case "sqlite":
 		return NewSQLiteDB(cfg)
 	default:
@@ -0,0 +1,32 @@
+package db_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+)
+
+// getTestEnv gets a test environment variable or returns the fallback value
+func getTestEnv(key, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// resetDB reverts all migrations of a shared test database and applies them
+// again, so that every test starts from an empty schema
+func resetDB(t *testing.T, database db.Database) {
+	t.Helper()
+
+	migrator := database.(db.Migratable).Migrator()
+	if _, err := migrator.Down(context.Background(), len(migrator.Migrations())); err != nil {
+		t.Fatalf("reverting migrations: %v", err)
+	}
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+}
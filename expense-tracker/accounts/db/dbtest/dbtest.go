@@ -0,0 +1,2470 @@
+// Package dbtest provides a conformance suite for implementations of
+// db.Database.
+//
+// A backend plugs into the suite by calling Run from its own test with a
+// function that opens an empty, fully migrated database:
+//
+//	func TestSQLiteConformance(t *testing.T) {
+//		dbtest.Run(t, func(t *testing.T) db.Database {
+//			...
+//		})
+//	}
+//
+// Every subtest opens its own database, so the open function must return a
+// database without any bills or exchange rates and is responsible for
+// closing it, e.g. with t.Cleanup.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// OpenFunc opens an empty, fully migrated database for a single test
+type OpenFunc func(t *testing.T) db.Database
+
+// missingID is an ID that no record in a fresh database has
+const missingID int64 = 999999
+
+// Run runs the conformance suite against the databases returned by open
+func Run(t *testing.T, open OpenFunc) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, database db.Database)
+	}{
+		{"Ping", testPing},
+		{"CreateAndGetBill", testCreateAndGetBill},
+		{"TotalRecalculation", testTotalRecalculation},
+		{"UpdateBillReplacesItems", testUpdateBillReplacesItems},
+		{"CascadeDelete", testCascadeDelete},
+		{"NotFound", testNotFound},
+		{"DueDates", testDueDates},
+		{"InvalidDueDate", testInvalidDueDate},
+		{"CheckConstraints", testCheckConstraints},
+		{"Rollback", testRollback},
+		{"ListBills", testListBills},
+		{"BillTotals", testBillTotals},
+		{"FXRates", testFXRates},
+		{"Users", testUsers},
+		{"APIKeys", testAPIKeys},
+		{"OwnerIsolation", testOwnerIsolation},
+		{"Ledgers", testLedgers},
+		{"BillSharing", testBillSharing},
+		{"LedgerSharing", testLedgerSharing},
+		{"Categories", testCategories},
+		{"BillCategories", testBillCategories},
+		{"Tags", testTags},
+		{"BillTags", testBillTags},
+		{"Merchants", testMerchants},
+		{"BillMerchants", testBillMerchants},
+		{"RecurringBills", testRecurringBills},
+		{"GenerateRecurringBills", testGenerateRecurringBills},
+		{"BillPayments", testBillPayments},
+		{"ConcurrentItemWrites", testConcurrentItemWrites},
+		{"CanceledContext", testCanceledContext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+func testPing(t *testing.T, database db.Database) {
+	if err := database.Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+}
+
+func testCreateAndGetBill(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	id := mustCreateBill(t, database, owner, &models.BillInput{
+		Title:       "Groceries",
+		Description: "Weekly shopping",
+		Currency:    "EUR",
+		DueDate:     "2024-03-15",
+		Paid:        true,
+		Items: []models.BillItemInput{
+			{Name: "Milk", Description: "2 litres", Amount: 199, Quantity: 2},
+			{Name: "Bread", Amount: 349, Quantity: 1},
+		},
+	})
+
+	bill := mustGetBill(t, database, owner, id)
+	if bill.ID != id {
+		t.Errorf("ID = %d, want %d", bill.ID, id)
+	}
+	if bill.OwnerID != owner {
+		t.Errorf("OwnerID = %d, want %d", bill.OwnerID, owner)
+	}
+	if bill.Title != "Groceries" || bill.Description != "Weekly shopping" {
+		t.Errorf("title/description = %q/%q, want Groceries/Weekly shopping", bill.Title, bill.Description)
+	}
+	if bill.Currency != "EUR" {
+		t.Errorf("Currency = %q, want EUR", bill.Currency)
+	}
+	if !bill.Paid {
+		t.Error("Paid = false, want true")
+	}
+	if bill.Total != 747 {
+		t.Errorf("Total = %s, want 7.47", bill.Total)
+	}
+	if bill.CreatedAt.IsZero() || bill.UpdatedAt.IsZero() {
+		t.Error("CreatedAt/UpdatedAt not set")
+	}
+
+	if len(bill.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(bill.Items))
+	}
+	for _, item := range bill.Items {
+		if item.BillID != id {
+			t.Errorf("item %d BillID = %d, want %d", item.ID, item.BillID, id)
+		}
+	}
+
+	item := mustGetBillItem(t, database, owner, bill.Items[0].ID)
+	if item.Name != bill.Items[0].Name || item.Amount != bill.Items[0].Amount || item.Quantity != bill.Items[0].Quantity {
+		t.Errorf("GetBillItem = %+v, want %+v", item, bill.Items[0])
+	}
+}
+
+func testTotalRecalculation(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	billID := mustCreateBill(t, database, owner, &models.BillInput{
+		Title:    "Utilities",
+		Currency: "USD",
+		Items: []models.BillItemInput{
+			{Name: "Water", Amount: 2500, Quantity: 1},
+		},
+	})
+	assertTotal(t, database, owner, billID, 2500)
+
+	// Adding an item adds amount * quantity
+	itemID, err := database.CreateBillItem(ctx, owner, billID, &models.BillItemInput{Name: "Power", Amount: 1999, Quantity: 3})
+	if err != nil {
+		t.Fatalf("CreateBillItem: %v", err)
+	}
+	assertTotal(t, database, owner, billID, 2500+5997)
+
+	// Updating an item replaces its contribution
+	err = database.UpdateBillItem(ctx, owner, itemID, &models.BillItemInput{Name: "Power", Amount: 1000, Quantity: 2})
+	if err != nil {
+		t.Fatalf("UpdateBillItem: %v", err)
+	}
+	assertTotal(t, database, owner, billID, 2500+2000)
+
+	// Deleting items removes their contribution, down to zero
+	if err := database.DeleteBillItem(ctx, owner, itemID); err != nil {
+		t.Fatalf("DeleteBillItem: %v", err)
+	}
+	assertTotal(t, database, owner, billID, 2500)
+
+	items, err := database.GetBillItems(ctx, owner, billID)
+	if err != nil {
+		t.Fatalf("GetBillItems: %v", err)
+	}
+	for _, item := range items {
+		if err := database.DeleteBillItem(ctx, owner, item.ID); err != nil {
+			t.Fatalf("DeleteBillItem: %v", err)
+		}
+	}
+	assertTotal(t, database, owner, billID, 0)
+
+	// Items of other bills are not counted
+	otherID := mustCreateBill(t, database, owner, &models.BillInput{
+		Title:    "Other",
+		Currency: "USD",
+		Items:    []models.BillItemInput{{Name: "Rent", Amount: 100000, Quantity: 1}},
+	})
+	if _, err := database.CreateBillItem(ctx, owner, billID, &models.BillItemInput{Name: "Gas", Amount: 5, Quantity: 7}); err != nil {
+		t.Fatalf("CreateBillItem: %v", err)
+	}
+	assertTotal(t, database, owner, billID, 35)
+	assertTotal(t, database, owner, otherID, 100000)
+}
+
+func testUpdateBillReplacesItems(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	id := mustCreateBill(t, database, owner, &models.BillInput{
+		Title:    "Before",
+		Currency: "USD",
+		Items: []models.BillItemInput{
+			{Name: "A", Amount: 100, Quantity: 1},
+			{Name: "B", Amount: 200, Quantity: 1},
+		},
+	})
+	before := mustGetBill(t, database, owner, id)
+
+	err := database.UpdateBill(ctx, owner, id, &models.BillInput{
+		Title:    "After",
+		Currency: "GBP",
+		Paid:     true,
+		Items: []models.BillItemInput{
+			{Name: "C", Amount: 1234, Quantity: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("UpdateBill: %v", err)
+	}
+
+	bill := mustGetBill(t, database, owner, id)
+	if bill.Title != "After" || bill.Currency != "GBP" || !bill.Paid {
+		t.Errorf("bill = %+v, want the updated fields", bill)
+	}
+	if bill.Total != 2468 {
+		t.Errorf("Total = %s, want 24.68", bill.Total)
+	}
+	if len(bill.Items) != 1 || bill.Items[0].Name != "C" {
+		t.Fatalf("items = %+v, want only C", bill.Items)
+	}
+
+	// The replaced items are gone
+	for _, item := range before.Items {
+		if _, err := database.GetBillItem(ctx, owner, item.ID); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("GetBillItem(%d) of a replaced item: err = %v, want ErrNotFound", item.ID, err)
+		}
+	}
+}
+
+func testCascadeDelete(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	id := mustCreateBill(t, database, owner, &models.BillInput{
+		Title:    "Doomed",
+		Currency: "USD",
+		Items: []models.BillItemInput{
+			{Name: "A", Amount: 100, Quantity: 1},
+			{Name: "B", Amount: 200, Quantity: 1},
+		},
+	})
+	keepID := mustCreateBill(t, database, owner, &models.BillInput{
+		Title:    "Kept",
+		Currency: "USD",
+		Items:    []models.BillItemInput{{Name: "C", Amount: 300, Quantity: 1}},
+	})
+	bill := mustGetBill(t, database, owner, id)
+
+	if err := database.DeleteBill(ctx, owner, id); err != nil {
+		t.Fatalf("DeleteBill: %v", err)
+	}
+
+	if _, err := database.GetBill(ctx, owner, id); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetBill after delete: err = %v, want ErrNotFound", err)
+	}
+	for _, item := range bill.Items {
+		if _, err := database.GetBillItem(ctx, owner, item.ID); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("GetBillItem(%d) after deleting its bill: err = %v, want ErrNotFound", item.ID, err)
+		}
+	}
+	items, err := database.GetBillItems(ctx, owner, id)
+	if err != nil {
+		t.Fatalf("GetBillItems: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("GetBillItems after delete returned %d items, want 0", len(items))
+	}
+
+	// Other bills keep their items
+	kept := mustGetBill(t, database, owner, keepID)
+	if len(kept.Items) != 1 {
+		t.Errorf("kept bill has %d items, want 1", len(kept.Items))
+	}
+}
+
+func testNotFound(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	billInput := &models.BillInput{
+		Title:    "Ghost",
+		Currency: "USD",
+		Items:    []models.BillItemInput{{Name: "A", Amount: 100, Quantity: 1}},
+	}
+	itemInput := &models.BillItemInput{Name: "A", Amount: 100, Quantity: 1}
+	rateInput := &models.FXRateInput{BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.1", EffectiveDate: "2024-01-01"}
+
+	checks := []struct {
+		name string
+		fn   func() error
+	}{
+		{"GetBill", func() error { _, err := database.GetBill(ctx, owner, missingID); return err }},
+		{"UpdateBill", func() error { return database.UpdateBill(ctx, owner, missingID, billInput) }},
+		{"DeleteBill", func() error { return database.DeleteBill(ctx, owner, missingID) }},
+		{"GetBillItem", func() error { _, err := database.GetBillItem(ctx, owner, missingID); return err }},
+		{"CreateBillItem", func() error { _, err := database.CreateBillItem(ctx, owner, missingID, itemInput); return err }},
+		{"UpdateBillItem", func() error { return database.UpdateBillItem(ctx, owner, missingID, itemInput) }},
+		{"DeleteBillItem", func() error { return database.DeleteBillItem(ctx, owner, missingID) }},
+		{"GetFXRate", func() error { _, err := database.GetFXRate(ctx, missingID); return err }},
+		{"FindFXRate", func() error { _, err := database.FindFXRate(ctx, "EUR", "USD", date(t, "2024-01-01")); return err }},
+		{"UpdateFXRate", func() error { return database.UpdateFXRate(ctx, missingID, rateInput) }},
+		{"DeleteFXRate", func() error { return database.DeleteFXRate(ctx, missingID) }},
+	}
+
+	for _, check := range checks {
+		if err := check.fn(); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("%s: err = %v, want ErrNotFound", check.name, err)
+		}
+	}
+
+	// Failed writes must not leave anything behind
+	items, err := database.GetBillItems(ctx, owner, missingID)
+	if err != nil {
+		t.Fatalf("GetBillItems: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("GetBillItems(%d) returned %d items, want 0", missingID, len(items))
+	}
+	bills, total, err := database.GetBills(ctx, owner, &models.BillQuery{})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 0 || len(bills) != 0 {
+		t.Errorf("GetBills returned %d bills (total %d), want none", len(bills), total)
+	}
+}
+
+func testDueDates(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	withDate := mustCreateBill(t, database, owner, &models.BillInput{Title: "Dated", Currency: "USD", DueDate: "2024-02-29"})
+	withoutDate := mustCreateBill(t, database, owner, &models.BillInput{Title: "Undated", Currency: "USD"})
+
+	bill := mustGetBill(t, database, owner, withDate)
+	assertDate(t, "GetBill", bill.DueDate, "2024-02-29")
+
+	bill = mustGetBill(t, database, owner, withoutDate)
+	if !bill.DueDate.IsZero() {
+		t.Errorf("GetBill without due date: DueDate = %v, want zero", bill.DueDate)
+	}
+
+	bills, _, err := database.GetBills(ctx, owner, &models.BillQuery{})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	for _, summary := range bills {
+		switch summary.ID {
+		case withDate:
+			assertDate(t, "GetBills", summary.DueDate, "2024-02-29")
+		case withoutDate:
+			if !summary.DueDate.IsZero() {
+				t.Errorf("GetBills without due date: DueDate = %v, want zero", summary.DueDate)
+			}
+		}
+	}
+
+	// Changing and clearing the due date
+	err = database.UpdateBill(ctx, owner, withDate, &models.BillInput{Title: "Dated", Currency: "USD", DueDate: "2025-12-31"})
+	if err != nil {
+		t.Fatalf("UpdateBill: %v", err)
+	}
+	assertDate(t, "GetBill after update", mustGetBill(t, database, owner, withDate).DueDate, "2025-12-31")
+
+	err = database.UpdateBill(ctx, owner, withDate, &models.BillInput{Title: "Dated", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("UpdateBill: %v", err)
+	}
+	if dueDate := mustGetBill(t, database, owner, withDate).DueDate; !dueDate.IsZero() {
+		t.Errorf("GetBill after clearing the due date: DueDate = %v, want zero", dueDate)
+	}
+
+	// Due date filters are inclusive
+	mustCreateBill(t, database, owner, &models.BillInput{Title: "Early", Currency: "USD", DueDate: "2024-01-01"})
+	mustCreateBill(t, database, owner, &models.BillInput{Title: "Late", Currency: "USD", DueDate: "2024-12-31"})
+	from, to := date(t, "2024-01-01"), date(t, "2024-06-30")
+	bills, total, err := database.GetBills(ctx, owner, &models.BillQuery{DueFrom: &from, DueTo: &to})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 1 || len(bills) != 1 || bills[0].Title != "Early" {
+		t.Errorf("GetBills due 2024-01-01..2024-06-30 = %+v (total %d), want only Early", bills, total)
+	}
+}
+
+func testInvalidDueDate(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	for _, dueDate := range []string{"2024-13-01", "2023-02-29", "15/03/2024", "tomorrow"} {
+		_, err := database.CreateBill(ctx, owner, &models.BillInput{Title: "Invalid", Currency: "USD", DueDate: dueDate})
+		var validationErr *db.ValidationError
+		if !errors.As(err, &validationErr) || !errors.Is(err, db.ErrValidation) {
+			t.Errorf("CreateBill with due date %q: err = %v, want a validation error", dueDate, err)
+		} else if len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "due_date" {
+			t.Errorf("CreateBill with due date %q: invalid fields = %+v, want due_date", dueDate, validationErr.Fields)
+		}
+	}
+
+	_, total, err := database.GetBills(ctx, owner, &models.BillQuery{})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 0 {
+		t.Errorf("%d bills created with invalid due dates, want 0", total)
+	}
+}
+
+func testCheckConstraints(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	id := mustCreateBill(t, database, owner, &models.BillInput{
+		Title:    "Valid",
+		Currency: "USD",
+		Items:    []models.BillItemInput{{Name: "A", Amount: 100, Quantity: 1}},
+	})
+	itemID := mustGetBill(t, database, owner, id).Items[0].ID
+
+	bills := []struct {
+		name  string
+		input models.BillInput
+	}{
+		{"blank title", models.BillInput{Title: "  ", Currency: "USD"}},
+		{"negative amount", models.BillInput{Title: "Refund", Currency: "USD", Items: []models.BillItemInput{{Name: "A", Amount: -100, Quantity: 1}}}},
+		{"zero quantity", models.BillInput{Title: "Nothing", Currency: "USD", Items: []models.BillItemInput{{Name: "A", Amount: 100, Quantity: 0}}}},
+		{"blank item name", models.BillInput{Title: "Unnamed", Currency: "USD", Items: []models.BillItemInput{{Name: "", Amount: 100, Quantity: 1}}}},
+	}
+	for _, tt := range bills {
+		if _, err := database.CreateBill(ctx, owner, &tt.input); !errors.Is(err, db.ErrConstraint) {
+			t.Errorf("CreateBill with %s: err = %v, want a constraint violation", tt.name, err)
+		}
+		if err := database.UpdateBill(ctx, owner, id, &tt.input); !errors.Is(err, db.ErrConstraint) {
+			t.Errorf("UpdateBill with %s: err = %v, want a constraint violation", tt.name, err)
+		}
+	}
+
+	items := []struct {
+		name  string
+		input models.BillItemInput
+	}{
+		{"negative amount", models.BillItemInput{Name: "A", Amount: -1, Quantity: 1}},
+		{"negative quantity", models.BillItemInput{Name: "A", Amount: 100, Quantity: -2}},
+		{"blank name", models.BillItemInput{Name: " ", Amount: 100, Quantity: 1}},
+	}
+	for _, tt := range items {
+		if _, err := database.CreateBillItem(ctx, owner, id, &tt.input); !errors.Is(err, db.ErrConstraint) {
+			t.Errorf("CreateBillItem with %s: err = %v, want a constraint violation", tt.name, err)
+		}
+		if err := database.UpdateBillItem(ctx, owner, itemID, &tt.input); !errors.Is(err, db.ErrConstraint) {
+			t.Errorf("UpdateBillItem with %s: err = %v, want a constraint violation", tt.name, err)
+		}
+	}
+
+	// Rejected writes change nothing
+	bill := mustGetBill(t, database, owner, id)
+	if bill.Title != "Valid" || bill.Total != 100 || len(bill.Items) != 1 || bill.Items[0].Amount != 100 {
+		t.Errorf("bill after rejected writes = %+v", bill)
+	}
+	if _, total, err := database.GetBills(ctx, owner, &models.BillQuery{}); err != nil || total != 1 {
+		t.Errorf("GetBills = %d bills, %v; want 1", total, err)
+	}
+}
+
+func testRollback(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	id := mustCreateBill(t, database, owner, &models.BillInput{
+		Title:    "Original",
+		Currency: "USD",
+		DueDate:  "2024-05-01",
+		Items:    []models.BillItemInput{{Name: "A", Amount: 100, Quantity: 1}},
+	})
+	before := mustGetBill(t, database, owner, id)
+
+	// A failed update leaves the bill and its items untouched
+	err := database.UpdateBill(ctx, owner, id, &models.BillInput{
+		Title:    "Changed",
+		Currency: "USD",
+		DueDate:  "not-a-date",
+		Items:    []models.BillItemInput{{Name: "B", Amount: 999, Quantity: 9}},
+	})
+	if err == nil {
+		t.Fatal("UpdateBill with an invalid due date succeeded, want an error")
+	}
+
+	after := mustGetBill(t, database, owner, id)
+	if after.Title != before.Title || after.Total != before.Total {
+		t.Errorf("bill after failed update = %q/%s, want %q/%s", after.Title, after.Total, before.Title, before.Total)
+	}
+	if len(after.Items) != 1 || after.Items[0].ID != before.Items[0].ID {
+		t.Errorf("items after failed update = %+v, want %+v", after.Items, before.Items)
+	}
+
+	// Repeated failures must not leak transactions: later writes still go through
+	for i := 0; i < 20; i++ {
+		if _, err := database.CreateBill(ctx, owner, &models.BillInput{Title: "Invalid", Currency: "USD", DueDate: "bad"}); err == nil {
+			t.Fatal("CreateBill with an invalid due date succeeded, want an error")
+		}
+		if err := database.UpdateBill(ctx, owner, id, &models.BillInput{Title: "Invalid", Currency: "USD", DueDate: "bad"}); err == nil {
+			t.Fatal("UpdateBill with an invalid due date succeeded, want an error")
+		}
+		if err := database.UpdateBillItem(ctx, owner, missingID, &models.BillItemInput{Name: "X", Amount: 1, Quantity: 1}); err == nil {
+			t.Fatal("UpdateBillItem of a missing item succeeded, want an error")
+		}
+	}
+
+	err = database.UpdateBill(ctx, owner, id, &models.BillInput{Title: "Updated", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("UpdateBill after failed writes: %v", err)
+	}
+	if title := mustGetBill(t, database, owner, id).Title; title != "Updated" {
+		t.Errorf("Title = %q, want Updated", title)
+	}
+}
+
+func testListBills(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	paid := true
+	mustCreateBill(t, database, owner, &models.BillInput{
+		Title: "Rent", Currency: "USD", DueDate: "2024-01-01", Paid: true,
+		Items: []models.BillItemInput{{Name: "Rent", Amount: 150000, Quantity: 1}},
+	})
+	mustCreateBill(t, database, owner, &models.BillInput{
+		Title: "Phone 50%", Currency: "EUR", DueDate: "2024-02-01",
+		Items: []models.BillItemInput{{Name: "Plan", Amount: 3000, Quantity: 1}, {Name: "Extra", Amount: 500, Quantity: 2}},
+	})
+	mustCreateBill(t, database, owner, &models.BillInput{Title: "Phone case", Currency: "USD", DueDate: "2024-03-01"})
+
+	bills, total, err := database.GetBills(ctx, owner, &models.BillQuery{SortBy: models.BillSortDueDate, SortOrder: models.SortAsc})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 3 || len(bills) != 3 {
+		t.Fatalf("GetBills returned %d bills (total %d), want 3", len(bills), total)
+	}
+	if bills[0].Title != "Rent" || bills[2].Title != "Phone case" {
+		t.Errorf("order = %s, %s, %s; want by due date", bills[0].Title, bills[1].Title, bills[2].Title)
+	}
+	if bills[1].ItemCount != 2 || bills[1].Total != 4000 {
+		t.Errorf("summary = %d items / %s, want 2 items / 40.00", bills[1].ItemCount, bills[1].Total)
+	}
+
+	// Pagination reports the total of all matching bills
+	bills, total, err = database.GetBills(ctx, owner, &models.BillQuery{SortBy: models.BillSortTotal, SortOrder: models.SortDesc, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 3 || len(bills) != 2 || bills[0].Title != "Phone 50%" || bills[1].Title != "Phone case" {
+		t.Errorf("page = %+v (total %d), want Phone 50%%, Phone case of 3", bills, total)
+	}
+
+	// Filters
+	bills, total, err = database.GetBills(ctx, owner, &models.BillQuery{Paid: &paid})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 1 || bills[0].Title != "Rent" {
+		t.Errorf("paid bills = %+v, want only Rent", bills)
+	}
+
+	// Title search is case-insensitive and treats LIKE wildcards literally
+	bills, total, err = database.GetBills(ctx, owner, &models.BillQuery{Title: "50%"})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 1 || bills[0].Title != "Phone 50%" {
+		t.Errorf("title search 50%% = %+v, want only Phone 50%%", bills)
+	}
+	_, total, err = database.GetBills(ctx, owner, &models.BillQuery{Title: "PHONE"})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 2 {
+		t.Errorf("title search PHONE matched %d bills, want 2", total)
+	}
+
+	minTotal := models.Money(3000)
+	_, total, err = database.GetBills(ctx, owner, &models.BillQuery{MinTotal: &minTotal, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("EUR bills of at least 30.00 = %d, want 1", total)
+	}
+}
+
+func testBillTotals(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	mustCreateBill(t, database, owner, &models.BillInput{
+		Title: "A", Currency: "USD", Paid: true,
+		Items: []models.BillItemInput{{Name: "A", Amount: 1000, Quantity: 1}},
+	})
+	mustCreateBill(t, database, owner, &models.BillInput{
+		Title: "B", Currency: "USD",
+		Items: []models.BillItemInput{{Name: "B", Amount: 250, Quantity: 2}},
+	})
+	mustCreateBill(t, database, owner, &models.BillInput{
+		Title: "C", Currency: "EUR",
+		Items: []models.BillItemInput{{Name: "C", Amount: 999, Quantity: 1}},
+	})
+
+	totals, err := database.GetBillTotals(ctx, owner, &models.BillQuery{})
+	if err != nil {
+		t.Fatalf("GetBillTotals: %v", err)
+	}
+
+	want := map[string]models.CurrencyTotal{
+		"EUR": {Currency: "EUR", Total: 999, PaidTotal: 0, UnpaidTotal: 999, BillCount: 1},
+		"USD": {Currency: "USD", Total: 1500, PaidTotal: 1000, UnpaidTotal: 500, BillCount: 2},
+	}
+	if len(totals) != len(want) {
+		t.Fatalf("got %d currency totals, want %d", len(totals), len(want))
+	}
+	for _, total := range totals {
+		if total != want[total.Currency] {
+			t.Errorf("totals for %s = %+v, want %+v", total.Currency, total, want[total.Currency])
+		}
+	}
+}
+
+func testFXRates(t *testing.T, database db.Database) {
+	ctx := context.Background()
+
+	janID, err := database.CreateFXRate(ctx, &models.FXRateInput{BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.1", EffectiveDate: "2024-01-01"})
+	if err != nil {
+		t.Fatalf("CreateFXRate: %v", err)
+	}
+	_, err = database.CreateFXRate(ctx, &models.FXRateInput{BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.0832", EffectiveDate: "2024-02-01"})
+	if err != nil {
+		t.Fatalf("CreateFXRate: %v", err)
+	}
+
+	rate, err := database.GetFXRate(ctx, janID)
+	if err != nil {
+		t.Fatalf("GetFXRate: %v", err)
+	}
+	if rate.Rate != "1.1" || rate.BaseCurrency != "EUR" || rate.QuoteCurrency != "USD" {
+		t.Errorf("GetFXRate = %+v, want EUR/USD 1.1", rate)
+	}
+	assertDate(t, "GetFXRate", rate.EffectiveDate, "2024-01-01")
+
+	// Only one rate per pair and date
+	_, err = database.CreateFXRate(ctx, &models.FXRateInput{BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "2", EffectiveDate: "2024-01-01"})
+	if !errors.Is(err, db.ErrConflict) {
+		t.Errorf("CreateFXRate duplicate: err = %v, want ErrConflict", err)
+	}
+	err = database.UpdateFXRate(ctx, janID, &models.FXRateInput{BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "2", EffectiveDate: "2024-02-01"})
+	if !errors.Is(err, db.ErrConflict) {
+		t.Errorf("UpdateFXRate onto an existing date: err = %v, want ErrConflict", err)
+	}
+
+	// The most recent rate effective on the date wins
+	lookups := []struct {
+		on   string
+		want models.Rate
+	}{
+		{"2024-01-01", "1.1"},
+		{"2024-01-31", "1.1"},
+		{"2024-02-01", "1.0832"},
+		{"2025-01-01", "1.0832"},
+	}
+	for _, lookup := range lookups {
+		rate, err := database.FindFXRate(ctx, "EUR", "USD", date(t, lookup.on))
+		if err != nil {
+			t.Errorf("FindFXRate on %s: %v", lookup.on, err)
+			continue
+		}
+		if rate.Rate != lookup.want {
+			t.Errorf("FindFXRate on %s = %s, want %s", lookup.on, rate.Rate, lookup.want)
+		}
+	}
+	if _, err := database.FindFXRate(ctx, "EUR", "USD", date(t, "2023-12-31")); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("FindFXRate before the first rate: err = %v, want ErrNotFound", err)
+	}
+
+	// Updating an unchanged rate is not a not-found
+	err = database.UpdateFXRate(ctx, janID, &models.FXRateInput{BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.1", EffectiveDate: "2024-01-01"})
+	if err != nil {
+		t.Errorf("UpdateFXRate without changes: %v", err)
+	}
+
+	rates, err := database.GetFXRates(ctx, "EUR", "")
+	if err != nil {
+		t.Fatalf("GetFXRates: %v", err)
+	}
+	if len(rates) != 2 {
+		t.Errorf("GetFXRates(EUR) returned %d rates, want 2", len(rates))
+	}
+
+	if err := database.DeleteFXRate(ctx, janID); err != nil {
+		t.Fatalf("DeleteFXRate: %v", err)
+	}
+	if _, err := database.GetFXRate(ctx, janID); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetFXRate after delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testUsers(t *testing.T, database db.Database) {
+	ctx := context.Background()
+
+	created, err := database.EnsureUser(ctx, &models.UserInput{Subject: "auth0|alice", Email: "alice@example.com", Name: "Alice"})
+	if err != nil {
+		t.Fatalf("EnsureUser: %v", err)
+	}
+	if created.ID == 0 || created.Subject != "auth0|alice" || created.Email != "alice@example.com" || created.Name != "Alice" {
+		t.Errorf("EnsureUser = %+v, want a new user with the input", created)
+	}
+	if created.CreatedAt.IsZero() || created.UpdatedAt.IsZero() {
+		t.Error("CreatedAt/UpdatedAt not set")
+	}
+
+	// The same subject resolves to the same user; empty fields keep their values
+	again, err := database.EnsureUser(ctx, &models.UserInput{Subject: "auth0|alice"})
+	if err != nil {
+		t.Fatalf("EnsureUser again: %v", err)
+	}
+	if again.ID != created.ID || again.Email != "alice@example.com" || again.Name != "Alice" {
+		t.Errorf("EnsureUser again = %+v, want %+v", again, created)
+	}
+
+	// Changed profile data is stored
+	if _, err := database.EnsureUser(ctx, &models.UserInput{Subject: "auth0|alice", Email: "alice@example.org"}); err != nil {
+		t.Fatalf("EnsureUser with new email: %v", err)
+	}
+	user, err := database.GetUser(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if user.Email != "alice@example.org" || user.Name != "Alice" {
+		t.Errorf("GetUser after email change = %+v, want the new email and the old name", user)
+	}
+
+	other := mustEnsureUser(t, database, "auth0|bob")
+	if other == created.ID {
+		t.Errorf("users with different subjects share ID %d", other)
+	}
+
+	// Users are found by subject without creating them
+	found, err := database.FindUserBySubject(ctx, "auth0|alice")
+	if err != nil || found.ID != created.ID {
+		t.Errorf("FindUserBySubject = %+v, %v, want user %d", found, err, created.ID)
+	}
+	if _, err := database.FindUserBySubject(ctx, "auth0|nobody"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("FindUserBySubject(missing): err = %v, want ErrNotFound", err)
+	}
+	if _, err := database.EnsureUser(ctx, &models.UserInput{Subject: "auth0|nobody"}); err != nil {
+		t.Fatalf("EnsureUser nobody: %v", err)
+	}
+
+	// Users are only found by an email they alone verified
+	if _, err := database.FindUserByEmail(ctx, "alice@example.org"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("FindUserByEmail(unverified): err = %v, want ErrNotFound", err)
+	}
+	verified, err := database.EnsureUser(ctx, &models.UserInput{Subject: "auth0|alice", Email: "alice@example.org", EmailVerified: true})
+	if err != nil {
+		t.Fatalf("EnsureUser with verified email: %v", err)
+	}
+	if !verified.EmailVerified || verified.Name != "Alice" {
+		t.Errorf("EnsureUser with verified email = %+v, want the email verified and the old name", verified)
+	}
+	found, err = database.FindUserByEmail(ctx, "alice@example.org")
+	if err != nil || found.ID != created.ID {
+		t.Errorf("FindUserByEmail = %+v, %v, want user %d", found, err, created.ID)
+	}
+	if _, err := database.EnsureUser(ctx, &models.UserInput{Subject: "auth0|bob", Email: "alice@example.org"}); err != nil {
+		t.Fatalf("EnsureUser bob with alice's unverified email: %v", err)
+	}
+	if found, err := database.FindUserByEmail(ctx, "alice@example.org"); err != nil || found.ID != created.ID {
+		t.Errorf("FindUserByEmail with an unverified duplicate = %+v, %v, want user %d", found, err, created.ID)
+	}
+	if _, err := database.EnsureUser(ctx, &models.UserInput{Subject: "auth0|bob", Email: "alice@example.org", EmailVerified: true}); err != nil {
+		t.Fatalf("EnsureUser bob with alice's verified email: %v", err)
+	}
+	if _, err := database.FindUserByEmail(ctx, "alice@example.org"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("FindUserByEmail verified by two users: err = %v, want ErrNotFound", err)
+	}
+
+	// An email given without verification is no longer verified
+	unverified, err := database.EnsureUser(ctx, &models.UserInput{Subject: "auth0|alice", Email: "alice@example.org"})
+	if err != nil {
+		t.Fatalf("EnsureUser with unverified email: %v", err)
+	}
+	if unverified.EmailVerified {
+		t.Errorf("EnsureUser with unverified email = %+v, want the email unverified", unverified)
+	}
+
+	if _, err := database.GetUser(ctx, missingID); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetUser(missing): err = %v, want ErrNotFound", err)
+	}
+	if _, err := database.CreateBill(ctx, missingID, &models.BillInput{Title: "Orphan", Currency: "USD"}); !errors.Is(err, db.ErrConstraint) {
+		t.Errorf("CreateBill for a missing user: err = %v, want ErrConstraint", err)
+	}
+}
+
+func testAPIKeys(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	owner := mustEnsureUser(t, database, "service:receipts")
+
+	input := &models.APIKey{UserID: owner, Name: "Receipts", Prefix: "acc_12345678", Hash: strings.Repeat("a", 64), Scopes: []string{"bills:read", "bills:write"}}
+	id, err := database.CreateAPIKey(ctx, input)
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	key, err := database.FindAPIKey(ctx, input.Hash)
+	if err != nil {
+		t.Fatalf("FindAPIKey: %v", err)
+	}
+	if key.ID != id || key.UserID != owner || key.Name != "Receipts" || key.Prefix != "acc_12345678" || !slices.Equal(key.Scopes, input.Scopes) {
+		t.Errorf("FindAPIKey = %+v, want the created key", key)
+	}
+	if key.CreatedAt.IsZero() || key.LastUsedAt != nil || key.RevokedAt != nil {
+		t.Errorf("new key = %+v, want a creation time and neither use nor revocation", key)
+	}
+
+	if err := database.TouchAPIKey(ctx, id); err != nil {
+		t.Fatalf("TouchAPIKey: %v", err)
+	}
+	if err := database.RevokeAPIKey(ctx, id); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	key, err = database.GetAPIKey(ctx, id)
+	if err != nil {
+		t.Fatalf("GetAPIKey: %v", err)
+	}
+	if key.LastUsedAt == nil || key.RevokedAt == nil {
+		t.Errorf("key after use and revocation = %+v, want both times", key)
+	}
+	revokedAt := *key.RevokedAt
+	if err := database.RevokeAPIKey(ctx, id); err != nil {
+		t.Fatalf("RevokeAPIKey again: %v", err)
+	}
+	if key, _ := database.GetAPIKey(ctx, id); key == nil || !key.RevokedAt.Equal(revokedAt) {
+		t.Errorf("revoking again changed the revocation time of %+v", key)
+	}
+
+	// Hashes are unique and keys need an existing user
+	if _, err := database.CreateAPIKey(ctx, input); !errors.Is(err, db.ErrConflict) {
+		t.Errorf("CreateAPIKey with a duplicate hash: err = %v, want ErrConflict", err)
+	}
+	orphan := *input
+	orphan.UserID, orphan.Hash = missingID, strings.Repeat("b", 64)
+	if _, err := database.CreateAPIKey(ctx, &orphan); !errors.Is(err, db.ErrConstraint) {
+		t.Errorf("CreateAPIKey for a missing user: err = %v, want ErrConstraint", err)
+	}
+
+	keys, err := database.GetAPIKeys(ctx)
+	if err != nil {
+		t.Fatalf("GetAPIKeys: %v", err)
+	}
+	if len(keys) != 1 || keys[0].ID != id {
+		t.Errorf("GetAPIKeys = %+v, want the revoked key", keys)
+	}
+
+	if _, err := database.FindAPIKey(ctx, strings.Repeat("c", 64)); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("FindAPIKey(unknown): err = %v, want ErrNotFound", err)
+	}
+	if err := database.RevokeAPIKey(ctx, missingID); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("RevokeAPIKey(missing): err = %v, want ErrNotFound", err)
+	}
+}
+
+func testOwnerIsolation(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+
+	aliceBill := mustCreateBill(t, database, alice, &models.BillInput{
+		Title:    "Rent",
+		Currency: "EUR",
+		Items:    []models.BillItemInput{{Name: "March", Amount: 90000, Quantity: 1}},
+	})
+	aliceItem := mustGetBill(t, database, alice, aliceBill).Items[0].ID
+	bobBill := mustCreateBill(t, database, bob, &models.BillInput{Title: "Gym", Currency: "EUR"})
+
+	// Listings and totals only cover the bills of the owner
+	bills, total, err := database.GetBills(ctx, bob, &models.BillQuery{})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 1 || len(bills) != 1 || bills[0].ID != bobBill {
+		t.Errorf("GetBills(bob) = %d bills (total %d), want only bill %d", len(bills), total, bobBill)
+	}
+	totals, err := database.GetBillTotals(ctx, bob, &models.BillQuery{})
+	if err != nil {
+		t.Fatalf("GetBillTotals: %v", err)
+	}
+	if len(totals) != 1 || totals[0].BillCount != 1 || totals[0].Total != 0 {
+		t.Errorf("GetBillTotals(bob) = %+v, want one empty bill", totals)
+	}
+	if _, total, err := database.GetBills(ctx, db.AllOwners, &models.BillQuery{}); err != nil || total != 2 {
+		t.Errorf("GetBills(AllOwners) total = %d, err = %v, want 2 bills", total, err)
+	}
+
+	// The bills and items of other users do not exist for bob
+	notFound := []struct {
+		name string
+		fn   func() error
+	}{
+		{"GetBill", func() error { _, err := database.GetBill(ctx, bob, aliceBill); return err }},
+		{"UpdateBill", func() error {
+			return database.UpdateBill(ctx, bob, aliceBill, &models.BillInput{Title: "Taken", Currency: "EUR"})
+		}},
+		{"DeleteBill", func() error { return database.DeleteBill(ctx, bob, aliceBill) }},
+		{"GetBillItem", func() error { _, err := database.GetBillItem(ctx, bob, aliceItem); return err }},
+		{"CreateBillItem", func() error {
+			_, err := database.CreateBillItem(ctx, bob, aliceBill, &models.BillItemInput{Name: "X", Amount: 1, Quantity: 1})
+			return err
+		}},
+		{"UpdateBillItem", func() error {
+			return database.UpdateBillItem(ctx, bob, aliceItem, &models.BillItemInput{Name: "X", Amount: 1, Quantity: 1})
+		}},
+		{"DeleteBillItem", func() error { return database.DeleteBillItem(ctx, bob, aliceItem) }},
+	}
+	for _, check := range notFound {
+		if err := check.fn(); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("%s of another user's bill: err = %v, want ErrNotFound", check.name, err)
+		}
+	}
+	if items, err := database.GetBillItems(ctx, bob, aliceBill); err != nil || len(items) != 0 {
+		t.Errorf("GetBillItems of another user's bill = %d items, err = %v, want none", len(items), err)
+	}
+
+	// Nothing of alice was changed
+	bill := mustGetBill(t, database, alice, aliceBill)
+	if bill.OwnerID != alice || bill.Title != "Rent" || len(bill.Items) != 1 || bill.Total != 90000 {
+		t.Errorf("alice's bill after bob's writes = %+v, want it unchanged", bill)
+	}
+}
+
+func testLedgers(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+
+	household := mustCreateLedger(t, database, alice, "Household")
+	mustCreateLedger(t, database, alice, "Car")
+	bill := mustCreateBill(t, database, alice, &models.BillInput{Title: "Rent", Currency: "EUR", LedgerID: &household})
+	mustCreateBill(t, database, alice, &models.BillInput{Title: "Gym", Currency: "EUR"})
+
+	ledgers, err := database.GetLedgers(ctx, alice)
+	if err != nil {
+		t.Fatalf("GetLedgers: %v", err)
+	}
+	if len(ledgers) != 2 || ledgers[0].Name != "Car" || ledgers[1].ID != household {
+		t.Fatalf("GetLedgers = %+v, want Car and Household by name", ledgers)
+	}
+	if ledgers[1].BillCount != 1 || ledgers[1].Role != models.RoleOwner || ledgers[1].OwnerID != alice {
+		t.Errorf("ledger = %+v, want 1 bill owned by alice", ledgers[1])
+	}
+	if got := mustGetBill(t, database, alice, bill); got.LedgerID == nil || *got.LedgerID != household {
+		t.Errorf("bill ledger = %v, want %d", got.LedgerID, household)
+	}
+
+	// Bills are filtered by ledger
+	bills, total, err := database.GetBills(ctx, alice, &models.BillQuery{LedgerID: &household})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 1 || len(bills) != 1 || bills[0].ID != bill {
+		t.Errorf("GetBills(ledger) = %d bills (total %d), want only bill %d", len(bills), total, bill)
+	}
+
+	if err := database.UpdateLedger(ctx, alice, household, &models.LedgerInput{Name: "Home"}); err != nil {
+		t.Fatalf("UpdateLedger: %v", err)
+	}
+	ledger, err := database.GetLedger(ctx, alice, household)
+	if err != nil {
+		t.Fatalf("GetLedger: %v", err)
+	}
+	if ledger.Name != "Home" {
+		t.Errorf("ledger name = %q, want Home", ledger.Name)
+	}
+
+	// Ledgers of other users do not exist, and bills cannot be filed in them
+	if _, err := database.GetLedger(ctx, bob, household); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetLedger of another user's ledger: err = %v, want ErrNotFound", err)
+	}
+	var verr *db.ValidationError
+	_, err = database.CreateBill(ctx, bob, &models.BillInput{Title: "Taken", Currency: "EUR", LedgerID: &household})
+	if !errors.As(err, &verr) {
+		t.Errorf("CreateBill in another user's ledger: err = %v, want ValidationError", err)
+	}
+
+	// Deleting a ledger keeps its bills
+	if err := database.DeleteLedger(ctx, alice, household); err != nil {
+		t.Fatalf("DeleteLedger: %v", err)
+	}
+	if _, err := database.GetLedger(ctx, alice, household); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetLedger after delete: err = %v, want ErrNotFound", err)
+	}
+	if got := mustGetBill(t, database, alice, bill); got.LedgerID != nil {
+		t.Errorf("bill ledger after deleting it = %d, want none", *got.LedgerID)
+	}
+}
+
+func testBillSharing(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+	carol := mustEnsureUser(t, database, "carol")
+
+	bill := mustCreateBill(t, database, alice, &models.BillInput{
+		Title:    "Dinner",
+		Currency: "EUR",
+		Items:    []models.BillItemInput{{Name: "Pizza", Amount: 1200, Quantity: 2}},
+	})
+	item := mustGetBill(t, database, alice, bill).Items[0].ID
+	target := models.ShareTarget{Type: models.ShareTypeBill, ID: bill}
+
+	// Only the owner shares, and not with themselves
+	var verr *db.ValidationError
+	if err := database.CreateShare(ctx, alice, target, alice, models.RoleViewer); !errors.As(err, &verr) {
+		t.Errorf("CreateShare with the owner: err = %v, want ValidationError", err)
+	}
+	if err := database.CreateShare(ctx, bob, target, bob, models.RoleOwner); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("CreateShare by a stranger: err = %v, want ErrNotFound", err)
+	}
+	if err := database.CreateShare(ctx, alice, target, bob, models.RoleViewer); err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+	if err := database.CreateShare(ctx, alice, target, bob, models.RoleEditor); !errors.Is(err, db.ErrConflict) {
+		t.Errorf("CreateShare twice: err = %v, want ErrConflict", err)
+	}
+
+	// Viewers read the bill and its items, and see the other members
+	got := mustGetBill(t, database, bob, bill)
+	if got.Role != models.RoleViewer || got.OwnerID != alice || len(got.Items) != 1 {
+		t.Errorf("shared bill = %+v, want it with the viewer role", got)
+	}
+	if _, err := database.GetBillItem(ctx, bob, item); err != nil {
+		t.Errorf("GetBillItem of shared bill: %v", err)
+	}
+	if bills, total, err := database.GetBills(ctx, bob, &models.BillQuery{}); err != nil || total != 1 || bills[0].ID != bill {
+		t.Errorf("GetBills(bob) = %d bills (total %d), err = %v, want the shared bill", len(bills), total, err)
+	}
+	shares, err := database.GetShares(ctx, bob, target)
+	if err != nil {
+		t.Fatalf("GetShares: %v", err)
+	}
+	if len(shares) != 1 || shares[0].UserID != bob || shares[0].Subject != "bob" || shares[0].Role != models.RoleViewer {
+		t.Errorf("GetShares = %+v, want bob as viewer", shares)
+	}
+
+	// ... but do not change anything
+	forbidden := []struct {
+		name string
+		fn   func() error
+	}{
+		{"SetBillPaid", func() error { return database.SetBillPaid(ctx, bob, bill, true) }},
+		{"UpdateBill", func() error {
+			return database.UpdateBill(ctx, bob, bill, &models.BillInput{Title: "Taken", Currency: "EUR"})
+		}},
+		{"DeleteBill", func() error { return database.DeleteBill(ctx, bob, bill) }},
+		{"CreateBillItem", func() error {
+			_, err := database.CreateBillItem(ctx, bob, bill, &models.BillItemInput{Name: "X", Amount: 1, Quantity: 1})
+			return err
+		}},
+		{"DeleteBillItem", func() error { return database.DeleteBillItem(ctx, bob, item) }},
+		{"CreateShare", func() error { return database.CreateShare(ctx, bob, target, carol, models.RoleViewer) }},
+	}
+	for _, check := range forbidden {
+		var roleErr *db.RoleError
+		if err := check.fn(); !errors.As(err, &roleErr) || !errors.Is(err, db.ErrForbidden) {
+			t.Errorf("%s by a viewer: err = %v, want RoleError", check.name, err)
+		}
+	}
+
+	// Payers may mark the bill as paid, editors change it
+	if err := database.UpdateShare(ctx, alice, target, bob, models.RolePayer); err != nil {
+		t.Fatalf("UpdateShare: %v", err)
+	}
+	if err := database.SetBillPaid(ctx, bob, bill, true); err != nil {
+		t.Fatalf("SetBillPaid by a payer: %v", err)
+	}
+	if !mustGetBill(t, database, alice, bill).Paid {
+		t.Error("bill not paid after SetBillPaid")
+	}
+	if err := database.UpdateShare(ctx, alice, target, bob, models.RoleEditor); err != nil {
+		t.Fatalf("UpdateShare: %v", err)
+	}
+	if err := database.UpdateBillItem(ctx, bob, item, &models.BillItemInput{Name: "Pizza", Amount: 1000, Quantity: 2}); err != nil {
+		t.Fatalf("UpdateBillItem by an editor: %v", err)
+	}
+	assertTotal(t, database, alice, bill, 2000)
+	if err := database.DeleteBill(ctx, bob, bill); !errors.Is(err, db.ErrForbidden) {
+		t.Errorf("DeleteBill by an editor: err = %v, want ErrForbidden", err)
+	}
+	if err := database.UpdateShare(ctx, alice, target, carol, models.RoleEditor); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("UpdateShare of a non-member: err = %v, want ErrNotFound", err)
+	}
+
+	// Members may leave, after which the bill does not exist for them
+	if err := database.DeleteShare(ctx, bob, target, bob); err != nil {
+		t.Fatalf("DeleteShare by the member: %v", err)
+	}
+	if _, err := database.GetBill(ctx, bob, bill); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetBill after leaving: err = %v, want ErrNotFound", err)
+	}
+	if err := database.DeleteShare(ctx, alice, target, bob); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("DeleteShare twice: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testLedgerSharing(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+
+	household := mustCreateLedger(t, database, alice, "Household")
+	other := mustCreateLedger(t, database, alice, "Other")
+	bill := mustCreateBill(t, database, alice, &models.BillInput{Title: "Rent", Currency: "EUR", LedgerID: &household})
+	target := models.ShareTarget{Type: models.ShareTypeLedger, ID: household}
+
+	// Members of a ledger have their role on all of its bills
+	if err := database.CreateShare(ctx, alice, target, bob, models.RoleEditor); err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+	ledgers, err := database.GetLedgers(ctx, bob)
+	if err != nil {
+		t.Fatalf("GetLedgers: %v", err)
+	}
+	if len(ledgers) != 1 || ledgers[0].ID != household || ledgers[0].Role != models.RoleEditor {
+		t.Errorf("GetLedgers(bob) = %+v, want the household ledger as editor", ledgers)
+	}
+	if got := mustGetBill(t, database, bob, bill); got.Role != models.RoleEditor {
+		t.Errorf("bill role = %q, want editor", got.Role)
+	}
+
+	// Editors file new bills in the ledger, but only owners move bills
+	// between ledgers or manage the ledger
+	bobBill, err := database.CreateBill(ctx, bob, &models.BillInput{Title: "Groceries", Currency: "EUR", LedgerID: &household})
+	if err != nil {
+		t.Fatalf("CreateBill in a shared ledger: %v", err)
+	}
+	if got := mustGetBill(t, database, alice, bobBill); got.OwnerID != bob || got.Role != models.RoleOwner {
+		t.Errorf("bill filed by bob = %+v, want it owned by bob and visible to alice", got)
+	}
+	err = database.UpdateBill(ctx, bob, bill, &models.BillInput{Title: "Rent", Currency: "EUR", LedgerID: &other})
+	if !errors.Is(err, db.ErrForbidden) {
+		t.Errorf("UpdateBill moving a bill by an editor: err = %v, want ErrForbidden", err)
+	}
+	if err := database.UpdateLedger(ctx, bob, household, &models.LedgerInput{Name: "Taken"}); !errors.Is(err, db.ErrForbidden) {
+		t.Errorf("UpdateLedger by an editor: err = %v, want ErrForbidden", err)
+	}
+	if err := database.DeleteLedger(ctx, bob, household); !errors.Is(err, db.ErrForbidden) {
+		t.Errorf("DeleteLedger by an editor: err = %v, want ErrForbidden", err)
+	}
+
+	// Revoking the share revokes access to the bills of the ledger
+	if err := database.DeleteShare(ctx, alice, target, bob); err != nil {
+		t.Fatalf("DeleteShare: %v", err)
+	}
+	if _, err := database.GetBill(ctx, bob, bill); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetBill after revoking: err = %v, want ErrNotFound", err)
+	}
+	if bills, total, err := database.GetBills(ctx, bob, &models.BillQuery{}); err != nil || total != 1 || bills[0].ID != bobBill {
+		t.Errorf("GetBills(bob) after revoking = %d bills (total %d), err = %v, want only bob's bill", len(bills), total, err)
+	}
+}
+
+func testCategories(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+
+	// Every user sees the default categories
+	food := mustFindCategory(t, database, alice, "Food")
+	groceries := mustFindCategory(t, database, bob, "Groceries")
+	if got, err := database.GetCategory(ctx, bob, groceries); err != nil || got.ParentID == nil || *got.ParentID != food || got.OwnerID != nil {
+		t.Errorf("GetCategory(Groceries) = %+v, err = %v, want a default subcategory of Food", got, err)
+	}
+
+	// Users nest their own categories, also under default ones
+	snacks, err := database.CreateCategory(ctx, alice, &models.CategoryInput{Name: "Snacks", ParentID: &groceries})
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	chips, err := database.CreateCategory(ctx, alice, &models.CategoryInput{Name: "Chips", ParentID: &snacks})
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	got, err := database.GetCategory(ctx, alice, chips)
+	if err != nil {
+		t.Fatalf("GetCategory: %v", err)
+	}
+	if got.Name != "Chips" || got.ParentID == nil || *got.ParentID != snacks || got.OwnerID == nil || *got.OwnerID != alice {
+		t.Errorf("GetCategory = %+v, want Chips of alice under Snacks", got)
+	}
+
+	// ... which other users do not see or nest under
+	if _, err := database.GetCategory(ctx, bob, snacks); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetCategory by another user: err = %v, want ErrNotFound", err)
+	}
+	categories, err := database.GetCategories(ctx, bob)
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+	for _, category := range categories {
+		if category.OwnerID != nil {
+			t.Errorf("GetCategories(bob) includes %+v of another user", category)
+		}
+	}
+	var verr *db.ValidationError
+	if _, err := database.CreateCategory(ctx, bob, &models.CategoryInput{Name: "Mine", ParentID: &snacks}); !errors.As(err, &verr) {
+		t.Errorf("CreateCategory under a category of another user: err = %v, want ValidationError", err)
+	}
+	if err := database.UpdateCategory(ctx, bob, snacks, &models.CategoryInput{Name: "Taken"}); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("UpdateCategory by another user: err = %v, want ErrNotFound", err)
+	}
+
+	// Categories cannot become their own ancestors
+	for _, parent := range []int64{snacks, chips} {
+		if err := database.UpdateCategory(ctx, alice, snacks, &models.CategoryInput{Name: "Snacks", ParentID: &parent}); !errors.As(err, &verr) {
+			t.Errorf("UpdateCategory with parent %d: err = %v, want ValidationError", parent, err)
+		}
+	}
+	if err := database.UpdateCategory(ctx, alice, chips, &models.CategoryInput{Name: "Crisps", ParentID: &food}); err != nil {
+		t.Fatalf("UpdateCategory: %v", err)
+	}
+	if got, err := database.GetCategory(ctx, alice, chips); err != nil || got.Name != "Crisps" || *got.ParentID != food {
+		t.Errorf("GetCategory after update = %+v, err = %v, want Crisps under Food", got, err)
+	}
+
+	// Default categories are read-only
+	if err := database.UpdateCategory(ctx, alice, food, &models.CategoryInput{Name: "Meals"}); !errors.Is(err, db.ErrDefaultCategory) || !errors.Is(err, db.ErrForbidden) {
+		t.Errorf("UpdateCategory of a default category: err = %v, want ErrDefaultCategory", err)
+	}
+	if err := database.DeleteCategory(ctx, alice, food); !errors.Is(err, db.ErrDefaultCategory) {
+		t.Errorf("DeleteCategory of a default category: err = %v, want ErrDefaultCategory", err)
+	}
+	if err := database.DeleteCategory(ctx, alice, missingID); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("DeleteCategory of a missing category: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testBillCategories(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+
+	food := mustFindCategory(t, database, alice, "Food")
+	groceries := mustFindCategory(t, database, alice, "Groceries")
+	housing := mustFindCategory(t, database, alice, "Housing")
+	snacks, err := database.CreateCategory(ctx, alice, &models.CategoryInput{Name: "Snacks", ParentID: &groceries})
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	gadgets, err := database.CreateCategory(ctx, bob, &models.CategoryInput{Name: "Gadgets"})
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+
+	// Bills and items are categorized separately
+	market := mustCreateBill(t, database, alice, &models.BillInput{
+		Title: "Market", Currency: "EUR", CategoryID: &groceries,
+		Items: []models.BillItemInput{{Name: "Chips", Amount: 250, Quantity: 1, CategoryID: &snacks}},
+	})
+	rent := mustCreateBill(t, database, alice, &models.BillInput{
+		Title: "Rent", Currency: "EUR", CategoryID: &housing,
+		Items: []models.BillItemInput{{Name: "Snacks for the move", Amount: 500, Quantity: 1, CategoryID: &snacks}},
+	})
+	mustCreateBill(t, database, alice, &models.BillInput{Title: "Misc", Currency: "EUR"})
+
+	bill := mustGetBill(t, database, alice, market)
+	if bill.CategoryID == nil || *bill.CategoryID != groceries || bill.Items[0].CategoryID == nil || *bill.Items[0].CategoryID != snacks {
+		t.Errorf("GetBill = %+v, want Groceries with an item in Snacks", bill)
+	}
+
+	// Categories of other users are rejected with the field
+	var verr *db.ValidationError
+	_, err = database.CreateBill(ctx, alice, &models.BillInput{
+		Title: "Phone", Currency: "EUR", CategoryID: &gadgets,
+		Items: []models.BillItemInput{{Name: "Case", Amount: 100, Quantity: 1, CategoryID: &gadgets}},
+	})
+	if !errors.As(err, &verr) || len(verr.Fields) != 2 || verr.Fields[0].Field != "category_id" || verr.Fields[1].Field != "items[0].category_id" {
+		t.Errorf("CreateBill with a category of another user: err = %v, want ValidationError of both fields", err)
+	}
+	item := bill.Items[0].ID
+	if err := database.UpdateBillItem(ctx, alice, item, &models.BillItemInput{Name: "Chips", Amount: 250, Quantity: 1, CategoryID: &gadgets}); !errors.As(err, &verr) {
+		t.Errorf("UpdateBillItem with a category of another user: err = %v, want ValidationError", err)
+	}
+
+	// Members of a shared bill use the categories of the owner
+	target := models.ShareTarget{Type: models.ShareTypeBill, ID: market}
+	if err := database.CreateShare(ctx, alice, target, bob, models.RoleEditor); err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+	if _, err := database.CreateBillItem(ctx, bob, market, &models.BillItemInput{Name: "Nuts", Amount: 300, Quantity: 1, CategoryID: &snacks}); err != nil {
+		t.Errorf("CreateBillItem by a member with a category of the owner: %v", err)
+	}
+
+	// The filter matches subcategories and the categories of items
+	filters := []struct {
+		category int64
+		want     []string
+	}{
+		{food, []string{"Market", "Rent"}},
+		{snacks, []string{"Market", "Rent"}},
+		{groceries, []string{"Market", "Rent"}},
+		{housing, []string{"Rent"}},
+		{gadgets, nil},
+	}
+	for _, filter := range filters {
+		bills, _, err := database.GetBills(ctx, alice, &models.BillQuery{CategoryID: &filter.category, SortBy: models.BillSortTitle, SortOrder: models.SortAsc})
+		if err != nil {
+			t.Fatalf("GetBills: %v", err)
+		}
+		var titles []string
+		for _, bill := range bills {
+			titles = append(titles, bill.Title)
+		}
+		if !slices.Equal(titles, filter.want) {
+			t.Errorf("bills in category %d = %v, want %v", filter.category, titles, filter.want)
+		}
+	}
+
+	// Deleting a category deletes its subcategories and keeps its bills and
+	// items uncategorized
+	nuts, err := database.CreateCategory(ctx, alice, &models.CategoryInput{Name: "Nuts", ParentID: &snacks})
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	if err := database.DeleteCategory(ctx, alice, snacks); err != nil {
+		t.Fatalf("DeleteCategory: %v", err)
+	}
+	for _, id := range []int64{snacks, nuts} {
+		if _, err := database.GetCategory(ctx, alice, id); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("GetCategory(%d) after delete: err = %v, want ErrNotFound", id, err)
+		}
+	}
+	if got := mustGetBillItem(t, database, alice, item); got.CategoryID != nil {
+		t.Errorf("item category after delete = %d, want none", *got.CategoryID)
+	}
+	if got := mustGetBill(t, database, alice, rent); got.CategoryID == nil || *got.CategoryID != housing {
+		t.Errorf("bill category after delete = %v, want Housing", got.CategoryID)
+	}
+}
+
+func testTags(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+
+	travel, err := database.CreateTag(ctx, alice, &models.TagInput{Name: "travel"})
+	if err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+	if _, err := database.CreateTag(ctx, alice, &models.TagInput{Name: "work"}); err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+	got, err := database.GetTag(ctx, alice, travel)
+	if err != nil {
+		t.Fatalf("GetTag: %v", err)
+	}
+	if got.Name != "travel" || got.OwnerID != alice || got.BillCount != 0 {
+		t.Errorf("GetTag = %+v, want unused travel of alice", got)
+	}
+
+	// Names are unique per user
+	if _, err := database.CreateTag(ctx, alice, &models.TagInput{Name: "travel"}); !errors.Is(err, db.ErrConflict) {
+		t.Errorf("CreateTag with a duplicate name: err = %v, want ErrConflict", err)
+	}
+	if _, err := database.CreateTag(ctx, bob, &models.TagInput{Name: "travel"}); err != nil {
+		t.Errorf("CreateTag with the name of a tag of another user: %v", err)
+	}
+	if err := database.UpdateTag(ctx, alice, travel, &models.TagInput{Name: "work"}); !errors.Is(err, db.ErrConflict) {
+		t.Errorf("UpdateTag to a taken name: err = %v, want ErrConflict", err)
+	}
+
+	// Users only see and change their own tags
+	tags, err := database.GetTags(ctx, bob)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if len(tags) != 1 || tags[0].OwnerID != bob {
+		t.Errorf("GetTags(bob) = %+v, want only the tag of bob", tags)
+	}
+	checks := []struct {
+		name string
+		fn   func() error
+	}{
+		{"GetTag", func() error { _, err := database.GetTag(ctx, bob, travel); return err }},
+		{"UpdateTag", func() error { return database.UpdateTag(ctx, bob, travel, &models.TagInput{Name: "mine"}) }},
+		{"DeleteTag", func() error { return database.DeleteTag(ctx, bob, travel) }},
+		{"DeleteTag missing", func() error { return database.DeleteTag(ctx, alice, missingID) }},
+	}
+	for _, check := range checks {
+		if err := check.fn(); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("%s: err = %v, want ErrNotFound", check.name, err)
+		}
+	}
+
+	// Renaming a tag renames it on its bills, deleting it removes it
+	bill := mustCreateBill(t, database, alice, &models.BillInput{Title: "Flight", Currency: "EUR", Tags: []string{"travel", "work"}})
+	if err := database.UpdateTag(ctx, alice, travel, &models.TagInput{Name: "trip-lisbon"}); err != nil {
+		t.Fatalf("UpdateTag: %v", err)
+	}
+	if got := mustGetBill(t, database, alice, bill).Tags; !slices.Equal(got, []string{"trip-lisbon", "work"}) {
+		t.Errorf("bill tags after rename = %v, want [trip-lisbon work]", got)
+	}
+	tags, err = database.GetTags(ctx, alice)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if len(tags) != 2 || tags[0].Name != "trip-lisbon" || tags[0].BillCount != 1 {
+		t.Errorf("GetTags(alice) = %+v, want trip-lisbon on one bill first", tags)
+	}
+	if err := database.DeleteTag(ctx, alice, travel); err != nil {
+		t.Fatalf("DeleteTag: %v", err)
+	}
+	if got := mustGetBill(t, database, alice, bill).Tags; !slices.Equal(got, []string{"work"}) {
+		t.Errorf("bill tags after delete = %v, want [work]", got)
+	}
+}
+
+func testBillTags(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+
+	// Tags are created on first use and set on bills and items separately
+	flight := mustCreateBill(t, database, alice, &models.BillInput{
+		Title: "Flight", Currency: "EUR", Tags: []string{"travel", "work"},
+		Items: []models.BillItemInput{{Name: "Seat", Amount: 20000, Quantity: 1, Tags: []string{"lisbon"}}},
+	})
+	hotel := mustCreateBill(t, database, alice, &models.BillInput{
+		Title: "Hotel", Currency: "EUR", Tags: []string{"travel"},
+		Items: []models.BillItemInput{{Name: "Room", Amount: 30000, Quantity: 1}},
+	})
+	mustCreateBill(t, database, alice, &models.BillInput{Title: "Groceries", Currency: "EUR"})
+
+	bill := mustGetBill(t, database, alice, flight)
+	if !slices.Equal(bill.Tags, []string{"travel", "work"}) || !slices.Equal(bill.Items[0].Tags, []string{"lisbon"}) {
+		t.Errorf("GetBill = %+v, want tags travel and work and an item tagged lisbon", bill)
+	}
+	room := mustGetBill(t, database, alice, hotel).Items[0]
+	if room.Tags == nil || len(room.Tags) != 0 {
+		t.Errorf("tags of an untagged item = %#v, want an empty list", room.Tags)
+	}
+	tags, err := database.GetTags(ctx, alice)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	if !slices.Equal(names, []string{"lisbon", "travel", "work"}) {
+		t.Errorf("tags of alice = %v, want [lisbon travel work]", names)
+	}
+
+	// Item tags are replaced by updates
+	if err := database.UpdateBillItem(ctx, alice, room.ID, &models.BillItemInput{Name: "Room", Amount: 30000, Quantity: 1, Tags: []string{"lisbon", "work"}}); err != nil {
+		t.Fatalf("UpdateBillItem: %v", err)
+	}
+	if got := mustGetBillItem(t, database, alice, room.ID).Tags; !slices.Equal(got, []string{"lisbon", "work"}) {
+		t.Errorf("item tags after update = %v, want [lisbon work]", got)
+	}
+
+	// Members of a shared bill tag it with tags of the owner
+	target := models.ShareTarget{Type: models.ShareTypeBill, ID: hotel}
+	if err := database.CreateShare(ctx, alice, target, bob, models.RoleEditor); err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+	if _, err := database.CreateBillItem(ctx, bob, hotel, &models.BillItemInput{Name: "Breakfast", Amount: 1500, Quantity: 2, Tags: []string{"food"}}); err != nil {
+		t.Fatalf("CreateBillItem: %v", err)
+	}
+	if tags, err := database.GetTags(ctx, bob); err != nil || len(tags) != 0 {
+		t.Errorf("GetTags(bob) = %+v, err = %v, want none", tags, err)
+	}
+
+	// The filter matches tags of bills and of their items
+	filters := []struct {
+		tags     []string
+		matchAll bool
+		want     []string
+	}{
+		{[]string{"travel"}, false, []string{"Flight", "Hotel"}},
+		{[]string{"lisbon"}, false, []string{"Flight", "Hotel"}},
+		{[]string{"food", "work"}, false, []string{"Flight", "Hotel"}},
+		{[]string{"food", "travel"}, true, []string{"Hotel"}},
+		{[]string{"lisbon", "travel", "work"}, true, []string{"Flight", "Hotel"}},
+		{[]string{"food", "missing"}, true, nil},
+		{[]string{"missing"}, false, nil},
+	}
+	for _, filter := range filters {
+		query := &models.BillQuery{Tags: filter.tags, TagMatch: models.TagMatchAny, SortBy: models.BillSortTitle, SortOrder: models.SortAsc}
+		if filter.matchAll {
+			query.TagMatch = models.TagMatchAll
+		}
+		bills, total, err := database.GetBills(ctx, alice, query)
+		if err != nil {
+			t.Fatalf("GetBills: %v", err)
+		}
+		var titles []string
+		for _, bill := range bills {
+			titles = append(titles, bill.Title)
+		}
+		if !slices.Equal(titles, filter.want) || total != len(filter.want) {
+			t.Errorf("bills with %s of tags %v = %v (total %d), want %v", query.TagMatch, filter.tags, titles, total, filter.want)
+		}
+	}
+
+	// Updating a bill replaces its tags and those of its items
+	err = database.UpdateBill(ctx, alice, flight, &models.BillInput{
+		Title: "Flight", Currency: "EUR", Tags: []string{"work"},
+		Items: []models.BillItemInput{{Name: "Seat", Amount: 20000, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateBill: %v", err)
+	}
+	bill = mustGetBill(t, database, alice, flight)
+	if !slices.Equal(bill.Tags, []string{"work"}) || len(bill.Items[0].Tags) != 0 {
+		t.Errorf("GetBill after update = %+v, want only the tag work", bill)
+	}
+	tag, err := database.GetTag(ctx, alice, tags[0].ID)
+	if err != nil {
+		t.Fatalf("GetTag: %v", err)
+	}
+	if tag.Name != "lisbon" || tag.BillCount != 1 {
+		t.Errorf("GetTag after update = %+v, want lisbon on one bill", tag)
+	}
+}
+
+func testMerchants(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+	shopping := mustFindCategory(t, database, alice, "Shopping")
+
+	amazon, err := database.CreateMerchant(ctx, alice, &models.MerchantInput{Name: "Amazon", Aliases: []string{"amzn mktp", "amazon com"}, CategoryID: &shopping})
+	if err != nil {
+		t.Fatalf("CreateMerchant: %v", err)
+	}
+	if _, err := database.CreateMerchant(ctx, alice, &models.MerchantInput{Name: "Lidl"}); err != nil {
+		t.Fatalf("CreateMerchant: %v", err)
+	}
+	got, err := database.GetMerchant(ctx, alice, amazon)
+	if err != nil {
+		t.Fatalf("GetMerchant: %v", err)
+	}
+	if got.Name != "Amazon" || got.OwnerID != alice || got.CategoryID == nil || *got.CategoryID != shopping || got.BillCount != 0 {
+		t.Errorf("GetMerchant = %+v, want unused Amazon of alice in Shopping", got)
+	}
+	if !slices.Equal(got.Aliases, []string{"amazon com", "amzn mktp"}) {
+		t.Errorf("aliases = %v, want [amazon com amzn mktp]", got.Aliases)
+	}
+
+	// Names and aliases are unique per user
+	conflicts := []struct {
+		name string
+		fn   func() error
+	}{
+		{"CreateMerchant with a duplicate name", func() error {
+			_, err := database.CreateMerchant(ctx, alice, &models.MerchantInput{Name: "Amazon"})
+			return err
+		}},
+		{"CreateMerchant with a taken alias", func() error {
+			_, err := database.CreateMerchant(ctx, alice, &models.MerchantInput{Name: "Marketplace", Aliases: []string{"amzn mktp"}})
+			return err
+		}},
+		{"UpdateMerchant to a taken name", func() error {
+			return database.UpdateMerchant(ctx, alice, amazon, &models.MerchantInput{Name: "Lidl"})
+		}},
+	}
+	for _, conflict := range conflicts {
+		if err := conflict.fn(); !errors.Is(err, db.ErrConflict) {
+			t.Errorf("%s: err = %v, want ErrConflict", conflict.name, err)
+		}
+	}
+	if _, err := database.CreateMerchant(ctx, bob, &models.MerchantInput{Name: "Amazon", Aliases: []string{"amzn mktp"}}); err != nil {
+		t.Errorf("CreateMerchant with the name and alias of a merchant of another user: %v", err)
+	}
+
+	// The default category must be visible to the user
+	private, err := database.CreateCategory(ctx, bob, &models.CategoryInput{Name: "Private"})
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	var verr *db.ValidationError
+	if _, err := database.CreateMerchant(ctx, alice, &models.MerchantInput{Name: "Ikea", CategoryID: &private}); !errors.As(err, &verr) {
+		t.Errorf("CreateMerchant with a category of another user: err = %v, want ValidationError", err)
+	}
+
+	// Users only see and change their own merchants
+	merchants, err := database.GetMerchants(ctx, bob)
+	if err != nil {
+		t.Fatalf("GetMerchants: %v", err)
+	}
+	if len(merchants) != 1 || merchants[0].OwnerID != bob {
+		t.Errorf("GetMerchants(bob) = %+v, want only the merchant of bob", merchants)
+	}
+	checks := []struct {
+		name string
+		fn   func() error
+	}{
+		{"GetMerchant", func() error { _, err := database.GetMerchant(ctx, bob, amazon); return err }},
+		{"UpdateMerchant", func() error {
+			return database.UpdateMerchant(ctx, bob, amazon, &models.MerchantInput{Name: "Mine"})
+		}},
+		{"DeleteMerchant", func() error { return database.DeleteMerchant(ctx, bob, amazon) }},
+		{"DeleteMerchant missing", func() error { return database.DeleteMerchant(ctx, alice, missingID) }},
+	}
+	for _, check := range checks {
+		if err := check.fn(); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("%s: err = %v, want ErrNotFound", check.name, err)
+		}
+	}
+
+	// Updates replace the aliases and the default category
+	err = database.UpdateMerchant(ctx, alice, amazon, &models.MerchantInput{Name: "Amazon EU", Aliases: []string{"amzn mktp", "amazon de"}})
+	if err != nil {
+		t.Fatalf("UpdateMerchant: %v", err)
+	}
+	got, err = database.GetMerchant(ctx, alice, amazon)
+	if err != nil {
+		t.Fatalf("GetMerchant: %v", err)
+	}
+	if got.Name != "Amazon EU" || got.CategoryID != nil || !slices.Equal(got.Aliases, []string{"amazon de", "amzn mktp"}) {
+		t.Errorf("GetMerchant after update = %+v, want Amazon EU without category and with the new aliases", got)
+	}
+
+	// Deleting a merchant keeps its bills
+	bill := mustCreateBill(t, database, alice, &models.BillInput{Title: "Books", Currency: "EUR", MerchantID: &amazon})
+	merchants, err = database.GetMerchants(ctx, alice)
+	if err != nil {
+		t.Fatalf("GetMerchants: %v", err)
+	}
+	if len(merchants) != 2 || merchants[0].Name != "Amazon EU" || merchants[0].BillCount != 1 || merchants[1].Aliases == nil {
+		t.Errorf("GetMerchants(alice) = %+v, want Amazon EU with one bill first", merchants)
+	}
+	if err := database.DeleteMerchant(ctx, alice, amazon); err != nil {
+		t.Fatalf("DeleteMerchant: %v", err)
+	}
+	if got := mustGetBill(t, database, alice, bill); got.MerchantID != nil {
+		t.Errorf("merchant of a bill after delete = %d, want none", *got.MerchantID)
+	}
+}
+
+func testBillMerchants(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+	shopping := mustFindCategory(t, database, alice, "Shopping")
+	groceries := mustFindCategory(t, database, alice, "Groceries")
+
+	amazon, err := database.CreateMerchant(ctx, alice, &models.MerchantInput{Name: "Amazon", Aliases: []string{"amzn mktp"}, CategoryID: &shopping})
+	if err != nil {
+		t.Fatalf("CreateMerchant: %v", err)
+	}
+	prime, err := database.CreateMerchant(ctx, alice, &models.MerchantInput{Name: "Amazon Prime"})
+	if err != nil {
+		t.Fatalf("CreateMerchant: %v", err)
+	}
+	bobs, err := database.CreateMerchant(ctx, bob, &models.MerchantInput{Name: "Corner Shop"})
+	if err != nil {
+		t.Fatalf("CreateMerchant: %v", err)
+	}
+
+	// Merchant names resolve by name or alias, and the merchant gives its
+	// default category unless the bill has one
+	resolutions := []struct {
+		merchant     string
+		categoryID   *int64
+		wantMerchant *int64
+		wantCategory *int64
+	}{
+		{"AMZN Mktp US*2K4", nil, &amazon, &shopping},
+		{"amazon.com", nil, &amazon, &shopping},
+		{"AMAZON PRIME*1234", nil, &prime, nil},
+		{"Amazon", &groceries, &amazon, &groceries},
+		{"Corner Shop", nil, nil, nil},
+		{"Amazonia", nil, nil, nil},
+	}
+	for _, resolution := range resolutions {
+		bill := mustGetBill(t, database, alice, mustCreateBill(t, database, alice, &models.BillInput{
+			Title: "Order", Currency: "EUR", Merchant: resolution.merchant, CategoryID: resolution.categoryID,
+		}))
+		if !sameID(bill.MerchantID, resolution.wantMerchant) || !sameID(bill.CategoryID, resolution.wantCategory) {
+			t.Errorf("bill of merchant %q: merchant %v and category %v, want %v and %v",
+				resolution.merchant, idString(bill.MerchantID), idString(bill.CategoryID), idString(resolution.wantMerchant), idString(resolution.wantCategory))
+		}
+	}
+
+	// An explicit merchant takes precedence over the name and must be one of
+	// the owner of the bill
+	bill := mustCreateBill(t, database, alice, &models.BillInput{Title: "Video", Currency: "EUR", MerchantID: &prime, Merchant: "AMZN MKTP"})
+	if got := mustGetBill(t, database, alice, bill); !sameID(got.MerchantID, &prime) {
+		t.Errorf("merchant of a bill with a merchant ID = %v, want %d", idString(got.MerchantID), prime)
+	}
+	var verr *db.ValidationError
+	if _, err := database.CreateBill(ctx, alice, &models.BillInput{Title: "Milk", Currency: "EUR", MerchantID: &bobs}); !errors.As(err, &verr) {
+		t.Errorf("CreateBill with a merchant of another user: err = %v, want ValidationError", err)
+	}
+	missing := missingID
+	if _, err := database.CreateBill(ctx, alice, &models.BillInput{Title: "Milk", Currency: "EUR", MerchantID: &missing}); !errors.As(err, &verr) {
+		t.Errorf("CreateBill with a missing merchant: err = %v, want ValidationError", err)
+	}
+
+	// Editors of a shared bill resolve merchants of its owner
+	target := models.ShareTarget{Type: models.ShareTypeBill, ID: bill}
+	if err := database.CreateShare(ctx, alice, target, bob, models.RoleEditor); err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+	if err := database.UpdateBill(ctx, bob, bill, &models.BillInput{Title: "Video", Currency: "EUR", Merchant: "amzn mktp"}); err != nil {
+		t.Fatalf("UpdateBill: %v", err)
+	}
+	if got := mustGetBill(t, database, alice, bill); !sameID(got.MerchantID, &amazon) || !sameID(got.CategoryID, &shopping) {
+		t.Errorf("bill updated by an editor = merchant %v and category %v, want %d and %d", idString(got.MerchantID), idString(got.CategoryID), amazon, shopping)
+	}
+	if err := database.UpdateBill(ctx, bob, bill, &models.BillInput{Title: "Video", Currency: "EUR", MerchantID: &bobs}); !errors.As(err, &verr) {
+		t.Errorf("UpdateBill with a merchant of the editor: err = %v, want ValidationError", err)
+	}
+
+	// The filter matches the merchant of bills
+	bills, total, err := database.GetBills(ctx, alice, &models.BillQuery{MerchantID: &amazon})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 4 || len(bills) != 4 {
+		t.Errorf("bills of Amazon = %d (total %d), want 4", len(bills), total)
+	}
+	for _, summary := range bills {
+		if !sameID(summary.MerchantID, &amazon) {
+			t.Errorf("bill %d in the Amazon filter has merchant %v", summary.ID, idString(summary.MerchantID))
+		}
+	}
+}
+
+func testRecurringBills(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+	housing := mustFindCategory(t, database, alice, "Housing")
+	landlord, err := database.CreateMerchant(ctx, alice, &models.MerchantInput{Name: "Landlord"})
+	if err != nil {
+		t.Fatalf("CreateMerchant: %v", err)
+	}
+	bobs, err := database.CreateMerchant(ctx, bob, &models.MerchantInput{Name: "Corner Shop"})
+	if err != nil {
+		t.Fatalf("CreateMerchant: %v", err)
+	}
+	ledger := mustCreateLedger(t, database, alice, "Flat")
+	bobsLedger := mustCreateLedger(t, database, bob, "Bob's")
+
+	rentInput := &models.RecurringBillInput{
+		Title: "Rent", Amount: 120000, Currency: "EUR", Rule: "FREQ=MONTHLY;BYMONTHDAY=31", StartDate: "2025-01-31",
+		LedgerID: &ledger, CategoryID: &housing, MerchantID: &landlord,
+	}
+	rent, err := database.CreateRecurringBill(ctx, alice, rentInput)
+	if err != nil {
+		t.Fatalf("CreateRecurringBill: %v", err)
+	}
+
+	recurring, err := database.GetRecurringBill(ctx, alice, rent)
+	if err != nil {
+		t.Fatalf("GetRecurringBill: %v", err)
+	}
+	if recurring.OwnerID != alice || recurring.Title != "Rent" || recurring.Amount != 120000 || recurring.Currency != "EUR" ||
+		recurring.Rule != "FREQ=MONTHLY;BYMONTHDAY=31" || recurring.Paused || recurring.EndDate != nil || recurring.GeneratedThrough != nil ||
+		!sameID(recurring.LedgerID, &ledger) || !sameID(recurring.CategoryID, &housing) || !sameID(recurring.MerchantID, &landlord) {
+		t.Errorf("GetRecurringBill = %+v", recurring)
+	}
+	assertDate(t, "start date", recurring.StartDate, "2025-01-31")
+	if recurring.NextDate == nil {
+		t.Fatal("next date of a new recurring bill is nil")
+	}
+	assertDate(t, "next date", *recurring.NextDate, "2025-01-31")
+	if len(recurring.SkippedDates) != 0 || recurring.BillCount != 0 {
+		t.Errorf("new recurring bill has skipped dates %v and %d bills, want none", recurring.SkippedDates, recurring.BillCount)
+	}
+
+	// The ledger, category and merchant are checked like those of bills
+	var verr *db.ValidationError
+	missing := missingID
+	invalid := []*models.RecurringBillInput{
+		{Title: "Rent", Currency: "EUR", Rule: "FREQ=MONTHLY", StartDate: "2025-01-01", MerchantID: &bobs},
+		{Title: "Rent", Currency: "EUR", Rule: "FREQ=MONTHLY", StartDate: "2025-01-01", CategoryID: &missing},
+		{Title: "Rent", Currency: "EUR", Rule: "FREQ=MONTHLY", StartDate: "2025-01-01", LedgerID: &bobsLedger},
+		{Title: "Rent", Currency: "EUR", Rule: "FREQ=MONTHLY", StartDate: "January"},
+	}
+	for _, input := range invalid {
+		if _, err := database.CreateRecurringBill(ctx, alice, input); !errors.As(err, &verr) {
+			t.Errorf("CreateRecurringBill(%+v): err = %v, want ValidationError", input, err)
+		}
+	}
+
+	// Recurring bills are private to their owner
+	if list, err := database.GetRecurringBills(ctx, bob); err != nil || len(list) != 0 {
+		t.Errorf("GetRecurringBills of another user = %d, %v; want none", len(list), err)
+	}
+	if _, err := database.GetRecurringBill(ctx, bob, rent); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetRecurringBill of another user: err = %v, want ErrNotFound", err)
+	}
+	if err := database.SetRecurringBillPaused(ctx, bob, rent, true); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("SetRecurringBillPaused of another user: err = %v, want ErrNotFound", err)
+	}
+	if err := database.DeleteRecurringBill(ctx, bob, rent); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("DeleteRecurringBill of another user: err = %v, want ErrNotFound", err)
+	}
+
+	// Only upcoming occurrences can be skipped, once
+	if err := database.SkipRecurringBill(ctx, alice, rent, date(t, "2025-02-28")); err != nil {
+		t.Fatalf("SkipRecurringBill: %v", err)
+	}
+	for _, day := range []string{"2025-02-28", "2025-02-27", "2024-12-31"} {
+		if err := database.SkipRecurringBill(ctx, alice, rent, date(t, day)); !errors.As(err, &verr) {
+			t.Errorf("SkipRecurringBill(%s): err = %v, want ValidationError", day, err)
+		}
+	}
+
+	// Updates keep the skipped occurrences
+	rentInput.Amount = 125000
+	if err := database.UpdateRecurringBill(ctx, alice, rent, rentInput); err != nil {
+		t.Fatalf("UpdateRecurringBill: %v", err)
+	}
+	if err := database.SetRecurringBillPaused(ctx, alice, rent, true); err != nil {
+		t.Fatalf("SetRecurringBillPaused: %v", err)
+	}
+	if err := database.EndRecurringBill(ctx, alice, rent, date(t, "2024-12-31")); !errors.As(err, &verr) {
+		t.Errorf("EndRecurringBill before the start: err = %v, want ValidationError", err)
+	}
+	if err := database.EndRecurringBill(ctx, alice, rent, date(t, "2025-04-30")); err != nil {
+		t.Fatalf("EndRecurringBill: %v", err)
+	}
+
+	list, err := database.GetRecurringBills(ctx, alice)
+	if err != nil {
+		t.Fatalf("GetRecurringBills: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("GetRecurringBills = %d recurring bills, want 1", len(list))
+	}
+	recurring = &list[0]
+	if recurring.Amount != 125000 || !recurring.Paused || recurring.EndDate == nil || len(recurring.SkippedDates) != 1 {
+		t.Fatalf("updated recurring bill = %+v", recurring)
+	}
+	assertDate(t, "end date", *recurring.EndDate, "2025-04-30")
+	assertDate(t, "skipped date", recurring.SkippedDates[0], "2025-02-28")
+
+	// Deleting the merchant keeps the recurring bill without it
+	if err := database.DeleteMerchant(ctx, alice, landlord); err != nil {
+		t.Fatalf("DeleteMerchant: %v", err)
+	}
+	if recurring, err := database.GetRecurringBill(ctx, alice, rent); err != nil || recurring.MerchantID != nil {
+		t.Errorf("recurring bill of a deleted merchant = %+v, %v; want no merchant", recurring, err)
+	}
+
+	if err := database.DeleteRecurringBill(ctx, alice, rent); err != nil {
+		t.Fatalf("DeleteRecurringBill: %v", err)
+	}
+	if _, err := database.GetRecurringBill(ctx, alice, rent); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetRecurringBill after delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testGenerateRecurringBills(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	housing := mustFindCategory(t, database, alice, "Housing")
+
+	create := func(input *models.RecurringBillInput) int64 {
+		t.Helper()
+		if input.Currency == "" {
+			input.Currency = "EUR"
+		}
+		id, err := database.CreateRecurringBill(ctx, alice, input)
+		if err != nil {
+			t.Fatalf("CreateRecurringBill(%s): %v", input.Title, err)
+		}
+		return id
+	}
+	get := func(id int64) *models.RecurringBill {
+		t.Helper()
+		recurring, err := database.GetRecurringBill(ctx, alice, id)
+		if err != nil {
+			t.Fatalf("GetRecurringBill: %v", err)
+		}
+		return recurring
+	}
+	generate := func(today, through string, want int) {
+		t.Helper()
+		generated, err := database.GenerateRecurringBills(ctx, date(t, today), date(t, through))
+		if err != nil {
+			t.Fatalf("GenerateRecurringBills: %v", err)
+		}
+		if generated != want {
+			t.Errorf("GenerateRecurringBills(%s, %s) = %d, want %d", today, through, generated, want)
+		}
+	}
+	dueDates := func(title string) []string {
+		t.Helper()
+		bills, _, err := database.GetBills(ctx, alice, &models.BillQuery{Title: title, SortBy: models.BillSortDueDate})
+		if err != nil {
+			t.Fatalf("GetBills: %v", err)
+		}
+		var dates []string
+		for _, bill := range bills {
+			dates = append(dates, bill.DueDate.Format("2006-01-02"))
+		}
+		return dates
+	}
+
+	// Days beyond the end of a month fall on its last day
+	rent := create(&models.RecurringBillInput{Title: "Rent", Amount: 120000, Rule: "FREQ=MONTHLY;BYMONTHDAY=31", StartDate: "2025-01-31", CategoryID: &housing})
+	cleaning := create(&models.RecurringBillInput{Title: "Cleaning", Amount: 4000, Rule: "FREQ=WEEKLY;INTERVAL=2", StartDate: "2025-01-06"})
+	insurance := create(&models.RecurringBillInput{Title: "Insurance", Amount: 30000, Rule: "FREQ=YEARLY", StartDate: "2024-02-29"})
+	gym := create(&models.RecurringBillInput{Title: "Gym", Amount: 2500, Rule: "FREQ=MONTHLY", StartDate: "2025-01-15"})
+	create(&models.RecurringBillInput{Title: "Trial", Amount: 100, Rule: "FREQ=DAILY;COUNT=2", StartDate: "2025-03-09"})
+
+	if err := database.SkipRecurringBill(ctx, alice, rent, date(t, "2025-02-28")); err != nil {
+		t.Fatalf("SkipRecurringBill: %v", err)
+	}
+	if err := database.SetRecurringBillPaused(ctx, alice, gym, true); err != nil {
+		t.Fatalf("SetRecurringBillPaused: %v", err)
+	}
+
+	// Bills are generated through the date, except for skipped occurrences,
+	// paused recurring bills and occurrences before the first generation
+	generate("2025-01-01", "2025-03-10", 1+5+1+2)
+	wants := map[string][]string{
+		"Rent":      {"2025-01-31"},
+		"Cleaning":  {"2025-01-06", "2025-01-20", "2025-02-03", "2025-02-17", "2025-03-03"},
+		"Insurance": {"2025-02-28"},
+		"Gym":       nil,
+		"Trial":     {"2025-03-09", "2025-03-10"},
+	}
+	for title, want := range wants {
+		if got := dueDates(title); !slices.Equal(got, want) {
+			t.Errorf("due dates of %s = %v, want %v", title, got, want)
+		}
+	}
+
+	bills, _, err := database.GetBills(ctx, alice, &models.BillQuery{Title: "Rent"})
+	if err != nil || len(bills) != 1 {
+		t.Fatalf("GetBills = %d bills, %v; want 1", len(bills), err)
+	}
+	bill := mustGetBill(t, database, alice, bills[0].ID)
+	if bill.Total != 120000 || bill.Currency != "EUR" || bill.Paid || !sameID(bill.CategoryID, &housing) ||
+		len(bill.Items) != 1 || bill.Items[0].Name != "Rent" || bill.Items[0].Amount != 120000 || bill.Items[0].Quantity != 1 {
+		t.Errorf("generated bill = %+v", bill)
+	}
+
+	recurring := get(rent)
+	if recurring.BillCount != 1 || recurring.GeneratedThrough == nil || recurring.NextDate == nil || len(recurring.SkippedDates) != 0 {
+		t.Fatalf("recurring bill after generating = %+v", recurring)
+	}
+	assertDate(t, "generated through", *recurring.GeneratedThrough, "2025-03-10")
+	assertDate(t, "next date", *recurring.NextDate, "2025-03-31")
+
+	// Generating again is idempotent, also for deleted bills
+	if err := database.DeleteBill(ctx, alice, bill.ID); err != nil {
+		t.Fatalf("DeleteBill: %v", err)
+	}
+	generate("2025-03-01", "2025-03-10", 0)
+	if recurring := get(rent); recurring.BillCount != 0 {
+		t.Errorf("bill count after deleting the bill = %d, want 0", recurring.BillCount)
+	}
+
+	// Occurrences that came due while paused stay passed over after
+	// resuming, and ended and counted series stop
+	if err := database.SetRecurringBillPaused(ctx, alice, gym, false); err != nil {
+		t.Fatalf("SetRecurringBillPaused: %v", err)
+	}
+	if recurring := get(gym); recurring.NextDate == nil || recurring.NextDate.Format("2006-01-02") != "2025-03-15" {
+		t.Errorf("next date of the resumed recurring bill = %v, want 2025-03-15", recurring.NextDate)
+	}
+	if err := database.EndRecurringBill(ctx, alice, cleaning, date(t, "2025-03-10")); err != nil {
+		t.Fatalf("EndRecurringBill: %v", err)
+	}
+	if recurring := get(cleaning); recurring.NextDate != nil {
+		t.Errorf("next date of the ended recurring bill = %v, want none", recurring.NextDate)
+	}
+	generate("2025-04-01", "2025-04-30", 2+2)
+	wants = map[string][]string{
+		"Rent":      {"2025-03-31", "2025-04-30"},
+		"Cleaning":  {"2025-01-06", "2025-01-20", "2025-02-03", "2025-02-17", "2025-03-03"},
+		"Insurance": {"2025-02-28"},
+		"Gym":       {"2025-03-15", "2025-04-15"},
+		"Trial":     {"2025-03-09", "2025-03-10"},
+	}
+	for title, want := range wants {
+		if got := dueDates(title); !slices.Equal(got, want) {
+			t.Errorf("due dates of %s = %v, want %v", title, got, want)
+		}
+	}
+	if recurring := get(insurance); recurring.NextDate == nil || recurring.NextDate.Format("2006-01-02") != "2026-02-28" {
+		t.Errorf("next date of the yearly recurring bill = %v, want 2026-02-28", recurring.NextDate)
+	}
+}
+
+func testBillPayments(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	alice := mustEnsureUser(t, database, "alice")
+	bob := mustEnsureUser(t, database, "bob")
+
+	bill := mustCreateBill(t, database, alice, &models.BillInput{
+		Title:    "Rent",
+		Currency: "EUR",
+		Items:    []models.BillItemInput{{Name: "Rent", Amount: 100000, Quantity: 1}},
+	})
+	pay := func(billID int64, input *models.PaymentInput) int64 {
+		t.Helper()
+		id, err := database.CreateBillPayment(ctx, alice, billID, input)
+		if err != nil {
+			t.Fatalf("CreateBillPayment(%s): %v", input.Amount, err)
+		}
+		return id
+	}
+	assertStatus := func(id int64, amountPaid, balance models.Money, status string, paid bool) {
+		t.Helper()
+		got := mustGetBill(t, database, alice, id)
+		if got.AmountPaid != amountPaid || got.Balance != balance || got.PaymentStatus != status || got.Paid != paid {
+			t.Errorf("bill = paid %s, balance %s, %s, paid %v; want paid %s, balance %s, %s, paid %v",
+				got.AmountPaid, got.Balance, got.PaymentStatus, got.Paid, amountPaid, balance, status, paid)
+		}
+		bills, _, err := database.GetBills(ctx, alice, &models.BillQuery{PaymentStatus: status})
+		if err != nil {
+			t.Fatalf("GetBills: %v", err)
+		}
+		if len(bills) != 1 || bills[0].ID != id || bills[0].AmountPaid != amountPaid || bills[0].Balance != balance {
+			t.Errorf("GetBills with payment status %s = %+v, want the bill", status, bills)
+		}
+	}
+
+	assertStatus(bill, 0, 100000, models.PaymentStatusUnpaid, false)
+	if payments := mustGetBill(t, database, alice, bill).Payments; payments == nil || len(payments) != 0 {
+		t.Errorf("payments of a new bill = %#v, want an empty list", payments)
+	}
+
+	// Partial payments add up until the bill is paid
+	first := pay(bill, &models.PaymentInput{Amount: 40000, Date: "2024-03-01", Method: models.PaymentMethodBankTransfer, Reference: "TX-1"})
+	assertStatus(bill, 40000, 60000, models.PaymentStatusPartial, false)
+	second := pay(bill, &models.PaymentInput{Amount: 60000, Date: "2024-02-15", Method: models.PaymentMethodCash})
+	assertStatus(bill, 100000, 0, models.PaymentStatusPaid, true)
+
+	payments, err := database.GetBillPayments(ctx, alice, bill)
+	if err != nil {
+		t.Fatalf("GetBillPayments: %v", err)
+	}
+	if len(payments) != 2 || payments[0].ID != second || payments[1].ID != first {
+		t.Fatalf("GetBillPayments = %+v, want both payments by date", payments)
+	}
+	payment, err := database.GetBillPayment(ctx, alice, bill, first)
+	if err != nil {
+		t.Fatalf("GetBillPayment: %v", err)
+	}
+	if payment.BillID != bill || payment.Amount != 40000 || payment.Method != models.PaymentMethodBankTransfer || payment.Reference != "TX-1" {
+		t.Errorf("GetBillPayment = %+v, want the first payment", payment)
+	}
+	assertDate(t, "payment date", payment.Date, "2024-03-01")
+
+	// Payments over the total make the bill overpaid
+	if err := database.UpdateBillPayment(ctx, alice, bill, first, &models.PaymentInput{Amount: 50000, Date: "2024-03-02", Method: models.PaymentMethodCard}); err != nil {
+		t.Fatalf("UpdateBillPayment: %v", err)
+	}
+	assertStatus(bill, 110000, -10000, models.PaymentStatusOverpaid, true)
+	payment, err = database.GetBillPayment(ctx, alice, bill, first)
+	if err != nil {
+		t.Fatalf("GetBillPayment: %v", err)
+	}
+	if payment.Method != models.PaymentMethodCard || payment.Reference != "" {
+		t.Errorf("updated payment = %+v, want it paid by card without reference", payment)
+	}
+	assertDate(t, "updated payment date", payment.Date, "2024-03-02")
+
+	// A higher total leaves the bill partially paid
+	if _, err := database.CreateBillItem(ctx, alice, bill, &models.BillItemInput{Name: "Parking", Amount: 20000, Quantity: 1}); err != nil {
+		t.Fatalf("CreateBillItem: %v", err)
+	}
+	assertStatus(bill, 110000, 10000, models.PaymentStatusPartial, false)
+
+	// A bill with payments cannot be marked as unpaid; marking it as paid
+	// records a payment of its balance
+	if err := database.SetBillPaid(ctx, alice, bill, false); !errors.Is(err, db.ErrBillHasPayments) || !errors.Is(err, db.ErrConflict) {
+		t.Errorf("SetBillPaid(false) with payments: err = %v, want ErrBillHasPayments", err)
+	}
+	if err := database.SetBillPaid(ctx, alice, bill, true); err != nil {
+		t.Fatalf("SetBillPaid: %v", err)
+	}
+	assertStatus(bill, 120000, 0, models.PaymentStatusPaid, true)
+	payments, err = database.GetBillPayments(ctx, alice, bill)
+	if err != nil {
+		t.Fatalf("GetBillPayments: %v", err)
+	}
+	if len(payments) != 3 || payments[2].Amount != 10000 || payments[2].Method != models.PaymentMethodOther {
+		t.Errorf("payments after SetBillPaid = %+v, want a payment of the balance", payments)
+	}
+
+	// Updates of a bill with payments derive whether it is paid from them;
+	// updating it as paid records a payment of its balance
+	update := func(paid bool, items ...models.BillItemInput) error {
+		return database.UpdateBill(ctx, alice, bill, &models.BillInput{Title: "Rent and parking", Currency: "EUR", Paid: paid, Items: items})
+	}
+	rent := models.BillItemInput{Name: "Rent", Amount: 100000, Quantity: 1}
+	parking := models.BillItemInput{Name: "Parking", Amount: 20000, Quantity: 1}
+	if err := update(false, rent, parking); err != nil {
+		t.Fatalf("UpdateBill(paid false) with payments of the total: %v", err)
+	}
+	assertStatus(bill, 120000, 0, models.PaymentStatusPaid, true)
+	if err := update(false, rent, parking, parking); err != nil {
+		t.Fatalf("UpdateBill(paid false) with payments below the total: %v", err)
+	}
+	assertStatus(bill, 120000, 20000, models.PaymentStatusPartial, false)
+	if err := update(true, rent, parking, parking); err != nil {
+		t.Fatalf("UpdateBill(paid true) with payments below the total: %v", err)
+	}
+	assertStatus(bill, 140000, 0, models.PaymentStatusPaid, true)
+	settled, err := database.GetBillPayments(ctx, alice, bill)
+	if err != nil {
+		t.Fatalf("GetBillPayments: %v", err)
+	}
+	if len(settled) != 4 || settled[3].Amount != 20000 || settled[3].Method != models.PaymentMethodOther {
+		t.Fatalf("payments after UpdateBill(paid true) = %+v, want a payment of the balance", settled)
+	}
+	if err := database.DeleteBillPayment(ctx, alice, bill, settled[3].ID); err != nil {
+		t.Fatalf("DeleteBillPayment: %v", err)
+	}
+	if err := update(true, rent, parking); err != nil {
+		t.Fatalf("UpdateBill back to the paid total: %v", err)
+	}
+	assertStatus(bill, 120000, 0, models.PaymentStatusPaid, true)
+
+	// Deleting all payments leaves the bill unpaid
+	for _, payment := range payments {
+		if err := database.DeleteBillPayment(ctx, alice, bill, payment.ID); err != nil {
+			t.Fatalf("DeleteBillPayment: %v", err)
+		}
+	}
+	assertStatus(bill, 0, 120000, models.PaymentStatusUnpaid, false)
+	if _, err := database.GetBillPayment(ctx, alice, bill, first); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetBillPayment after delete: err = %v, want ErrNotFound", err)
+	}
+
+	// Bills without payments keep their paid flag
+	legacy := mustCreateBill(t, database, alice, &models.BillInput{
+		Title: "Phone", Currency: "EUR", Paid: true,
+		Items: []models.BillItemInput{{Name: "Plan", Amount: 3000, Quantity: 1}},
+	})
+	got := mustGetBill(t, database, alice, legacy)
+	if !got.Paid || got.AmountPaid != 0 || got.Balance != 0 || got.PaymentStatus != models.PaymentStatusPaid {
+		t.Errorf("bill marked as paid = %+v, want it paid without payments", got)
+	}
+	pay(bill, &models.PaymentInput{Amount: 20000, Date: "2024-04-01", Method: models.PaymentMethodCash})
+
+	totals, err := database.GetBillTotals(ctx, alice, &models.BillQuery{})
+	if err != nil {
+		t.Fatalf("GetBillTotals: %v", err)
+	}
+	want := models.CurrencyTotal{Currency: "EUR", Total: 123000, PaidTotal: 23000, UnpaidTotal: 100000, BillCount: 2}
+	if len(totals) != 1 || totals[0] != want {
+		t.Errorf("GetBillTotals = %+v, want %+v", totals, want)
+	}
+
+	// Payments of other bills are not found, and the amount is checked
+	other := mustCreateBill(t, database, alice, &models.BillInput{Title: "Other", Currency: "EUR"})
+	payments, err = database.GetBillPayments(ctx, alice, bill)
+	if err != nil {
+		t.Fatalf("GetBillPayments: %v", err)
+	}
+	if _, err := database.GetBillPayment(ctx, alice, other, payments[0].ID); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetBillPayment of another bill: err = %v, want ErrNotFound", err)
+	}
+	if err := database.DeleteBillPayment(ctx, alice, other, payments[0].ID); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("DeleteBillPayment of another bill: err = %v, want ErrNotFound", err)
+	}
+	if _, err := database.CreateBillPayment(ctx, alice, bill, &models.PaymentInput{Amount: 0, Date: "2024-04-01", Method: models.PaymentMethodCash}); !errors.Is(err, db.ErrConstraint) {
+		t.Errorf("CreateBillPayment of zero: err = %v, want ErrConstraint", err)
+	}
+	var verr *db.ValidationError
+	if _, err := database.CreateBillPayment(ctx, alice, bill, &models.PaymentInput{Amount: 100, Date: "April 1st", Method: models.PaymentMethodCash}); !errors.As(err, &verr) {
+		t.Errorf("CreateBillPayment with an invalid date: err = %v, want ValidationError", err)
+	}
+
+	// Strangers do not see the payments, viewers only read them
+	if _, err := database.GetBillPayments(ctx, bob, bill); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetBillPayments by a stranger: err = %v, want ErrNotFound", err)
+	}
+	target := models.ShareTarget{Type: models.ShareTypeBill, ID: bill}
+	if err := database.CreateShare(ctx, alice, target, bob, models.RoleViewer); err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+	if payments, err := database.GetBillPayments(ctx, bob, bill); err != nil || len(payments) != 1 {
+		t.Errorf("GetBillPayments by a viewer = %d payments, err = %v, want 1", len(payments), err)
+	}
+	input := &models.PaymentInput{Amount: 100, Date: "2024-04-02", Method: models.PaymentMethodCash}
+	var roleErr *db.RoleError
+	if _, err := database.CreateBillPayment(ctx, bob, bill, input); !errors.As(err, &roleErr) {
+		t.Errorf("CreateBillPayment by a viewer: err = %v, want RoleError", err)
+	}
+	if err := database.UpdateShare(ctx, alice, target, bob, models.RolePayer); err != nil {
+		t.Fatalf("UpdateShare: %v", err)
+	}
+	if _, err := database.CreateBillPayment(ctx, bob, bill, input); err != nil {
+		t.Errorf("CreateBillPayment by a payer: %v", err)
+	}
+
+	// Deleting the bill deletes its payments
+	if err := database.DeleteBill(ctx, alice, bill); err != nil {
+		t.Fatalf("DeleteBill: %v", err)
+	}
+	if _, err := database.GetBillPayments(ctx, alice, bill); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetBillPayments of a deleted bill: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testConcurrentItemWrites(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	ctx := context.Background()
+
+	billID := mustCreateBill(t, database, owner, &models.BillInput{Title: "Shared", Currency: "USD"})
+
+	const writers = 8
+	const itemsPerWriter = 5
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*itemsPerWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < itemsPerWriter; i++ {
+				_, err := database.CreateBillItem(ctx, owner, billID, &models.BillItemInput{Name: "Item", Amount: 100, Quantity: 1})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("CreateBillItem: %v", err)
+	}
+
+	// Every item got its own ID and counts towards the total exactly once
+	items, err := database.GetBillItems(ctx, owner, billID)
+	if err != nil {
+		t.Fatalf("GetBillItems: %v", err)
+	}
+	seen := make(map[int64]bool)
+	for _, item := range items {
+		if seen[item.ID] {
+			t.Errorf("item ID %d assigned twice", item.ID)
+		}
+		seen[item.ID] = true
+	}
+	if len(items) != writers*itemsPerWriter {
+		t.Errorf("got %d items, want %d", len(items), writers*itemsPerWriter)
+	}
+	assertTotal(t, database, owner, billID, models.Money(100*writers*itemsPerWriter))
+}
+
+func testCanceledContext(t *testing.T, database db.Database) {
+	owner := mustEnsureUser(t, database, "alice")
+
+	id := mustCreateBill(t, database, owner, &models.BillInput{
+		Title:    "Existing",
+		Currency: "USD",
+		Items:    []models.BillItemInput{{Name: "A", Amount: 100, Quantity: 1}},
+	})
+	itemID := mustGetBill(t, database, owner, id).Items[0].ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	billInput := &models.BillInput{Title: "Changed", Currency: "USD"}
+	itemInput := &models.BillItemInput{Name: "B", Amount: 200, Quantity: 1}
+	rateInput := &models.FXRateInput{BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.1", EffectiveDate: "2024-01-01"}
+	paymentInput := &models.PaymentInput{Amount: 100, Date: "2024-01-01", Method: models.PaymentMethodCash}
+
+	checks := []struct {
+		name string
+		fn   func() error
+	}{
+		{"GetBills", func() error { _, _, err := database.GetBills(ctx, owner, &models.BillQuery{}); return err }},
+		{"GetBill", func() error { _, err := database.GetBill(ctx, owner, id); return err }},
+		{"CreateBill", func() error { _, err := database.CreateBill(ctx, owner, billInput); return err }},
+		{"UpdateBill", func() error { return database.UpdateBill(ctx, owner, id, billInput) }},
+		{"DeleteBill", func() error { return database.DeleteBill(ctx, owner, id) }},
+		{"GetBillTotals", func() error { _, err := database.GetBillTotals(ctx, owner, &models.BillQuery{}); return err }},
+		{"GetBillItems", func() error { _, err := database.GetBillItems(ctx, owner, id); return err }},
+		{"GetBillItem", func() error { _, err := database.GetBillItem(ctx, owner, itemID); return err }},
+		{"CreateBillItem", func() error { _, err := database.CreateBillItem(ctx, owner, id, itemInput); return err }},
+		{"UpdateBillItem", func() error { return database.UpdateBillItem(ctx, owner, itemID, itemInput) }},
+		{"DeleteBillItem", func() error { return database.DeleteBillItem(ctx, owner, itemID) }},
+		{"GetBillPayments", func() error { _, err := database.GetBillPayments(ctx, owner, id); return err }},
+		{"CreateBillPayment", func() error { _, err := database.CreateBillPayment(ctx, owner, id, paymentInput); return err }},
+		{"GetFXRates", func() error { _, err := database.GetFXRates(ctx, "", ""); return err }},
+		{"FindFXRate", func() error { _, err := database.FindFXRate(ctx, "EUR", "USD", date(t, "2024-01-01")); return err }},
+		{"CreateFXRate", func() error { _, err := database.CreateFXRate(ctx, rateInput); return err }},
+		{"GetRecurringBills", func() error { _, err := database.GetRecurringBills(ctx, owner); return err }},
+		{"GenerateRecurringBills", func() error {
+			_, err := database.GenerateRecurringBills(ctx, date(t, "2024-01-01"), date(t, "2024-01-31"))
+			return err
+		}},
+		{"Ping", func() error { return database.Ping(ctx) }},
+	}
+
+	for _, check := range checks {
+		if err := check.fn(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s with a cancelled context: err = %v, want context.Canceled", check.name, err)
+		}
+	}
+
+	// Nothing was changed
+	bill := mustGetBill(t, database, owner, id)
+	if bill.Title != "Existing" || len(bill.Items) != 1 || bill.Total != 100 {
+		t.Errorf("bill after cancelled writes = %+v, want it unchanged", bill)
+	}
+	_, total, err := database.GetBills(context.Background(), owner, &models.BillQuery{})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("%d bills after cancelled writes, want 1", total)
+	}
+}
+
+func mustEnsureUser(t *testing.T, database db.Database, subject string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	user, err := database.EnsureUser(ctx, &models.UserInput{Subject: subject})
+	if err != nil {
+		t.Fatalf("EnsureUser(%q): %v", subject, err)
+	}
+	return user.ID
+}
+
+func mustCreateBill(t *testing.T, database db.Database, owner int64, billInput *models.BillInput) int64 {
+	t.Helper()
+	ctx := context.Background()
+	id, err := database.CreateBill(ctx, owner, billInput)
+	if err != nil {
+		t.Fatalf("CreateBill(%q): %v", billInput.Title, err)
+	}
+	return id
+}
+
+func mustCreateLedger(t *testing.T, database db.Database, owner int64, name string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	id, err := database.CreateLedger(ctx, owner, &models.LedgerInput{Name: name})
+	if err != nil {
+		t.Fatalf("CreateLedger(%q): %v", name, err)
+	}
+	return id
+}
+
+func mustFindCategory(t *testing.T, database db.Database, userID int64, name string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	categories, err := database.GetCategories(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+	for _, category := range categories {
+		if category.Name == name {
+			return category.ID
+		}
+	}
+	t.Fatalf("category %q not found", name)
+	return 0
+}
+
+func mustGetBill(t *testing.T, database db.Database, owner, id int64) *models.Bill {
+	t.Helper()
+	ctx := context.Background()
+	bill, err := database.GetBill(ctx, owner, id)
+	if err != nil {
+		t.Fatalf("GetBill(%d): %v", id, err)
+	}
+	return bill
+}
+
+func mustGetBillItem(t *testing.T, database db.Database, owner, id int64) *models.BillItem {
+	t.Helper()
+	ctx := context.Background()
+	item, err := database.GetBillItem(ctx, owner, id)
+	if err != nil {
+		t.Fatalf("GetBillItem(%d): %v", id, err)
+	}
+	return item
+}
+
+// assertTotal checks the stored total of a bill in both GetBill and GetBills
+func assertTotal(t *testing.T, database db.Database, owner, id int64, want models.Money) {
+	t.Helper()
+	ctx := context.Background()
+	if total := mustGetBill(t, database, owner, id).Total; total != want {
+		t.Errorf("GetBill(%d) total = %s, want %s", id, total, want)
+	}
+
+	bills, _, err := database.GetBills(ctx, owner, &models.BillQuery{})
+	if err != nil {
+		t.Fatalf("GetBills: %v", err)
+	}
+	for _, bill := range bills {
+		if bill.ID == id && bill.Total != want {
+			t.Errorf("GetBills total of bill %d = %s, want %s", id, bill.Total, want)
+		}
+	}
+}
+
+// assertDate checks that a date read back from the database is the given calendar date
+func assertDate(t *testing.T, what string, got time.Time, want string) {
+	t.Helper()
+	if got.IsZero() {
+		t.Errorf("%s: date is zero, want %s", what, want)
+		return
+	}
+	if s := got.Format("2006-01-02"); s != want {
+		t.Errorf("%s: date = %s, want %s", what, s, want)
+	}
+}
+
+// sameID reports whether two optional IDs are equal
+func sameID(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// idString formats an optional ID for messages
+func idString(id *int64) string {
+	if id == nil {
+		return "none"
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jo/choreo-tutorial/accounts/models"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Common errors. Every backend reports failures that are caused by the input
+// or the stored data with one of them, so callers can tell them apart from
+// internal errors with errors.Is.
+var (
+	ErrNotFound   = errors.New("record not found")
+	ErrConflict   = errors.New("record already exists")
+	ErrValidation = errors.New("validation failed")
+	ErrConstraint = errors.New("constraint violation")
+	ErrForbidden  = errors.New("forbidden")
+)
+
+// ErrDefaultCategory is returned when a user changes or deletes one of the
+// default categories, which are shared by all users. It matches ErrForbidden.
+var ErrDefaultCategory = fmt.Errorf("default categories cannot be changed: %w", ErrForbidden)
+
+// ErrBillHasPayments is returned when a bill with payments is marked as
+// unpaid; its payments decide whether it is paid. It matches ErrConflict.
+var ErrBillHasPayments = fmt.Errorf("bill has payments: %w", ErrConflict)
+
+// ValidationError reports one or more invalid input fields. It matches ErrValidation.
+type ValidationError struct {
+	Fields []models.FieldError
+}
+
+// NewValidationError creates a validation error for a single field
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{Fields: []models.FieldError{{Field: field, Message: message}}}
+}
+
+// Add records an invalid field
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, models.FieldError{Field: field, Message: message})
+}
+
+// Err returns e if any field is invalid, and nil otherwise
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = field.Field + ": " + field.Message
+	}
+	return "validation failed: " + strings.Join(fields, "; ")
+}
+
+// Is reports whether target is ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// ConstraintError reports a statement rejected by a foreign key, check or
+// not null constraint. It matches ErrConstraint and wraps the driver error,
+// whose message should not be shown to clients.
+type ConstraintError struct {
+	Err error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrConstraint, e.Err)
+}
+
+// Is reports whether target is ErrConstraint
+func (e *ConstraintError) Is(target error) bool {
+	return target == ErrConstraint
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// RoleError reports that the role of a user on a bill or ledger does not
+// permit an operation. It matches ErrForbidden. Users without any role get
+// ErrNotFound instead, so they cannot tell whether the record exists.
+type RoleError struct {
+	Type     string // models.ShareTypeBill or models.ShareTypeLedger
+	Role     models.Role
+	Required models.Role
+}
+
+func (e *RoleError) Error() string {
+	return fmt.Sprintf("the %s role on the %s does not permit this; %s is required", e.Role, e.Type, e.Required)
+}
+
+// Is reports whether target is ErrForbidden
+func (e *RoleError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// MySQL error numbers of constraint violations
+const (
+	mysqlBadNull         = 1048
+	mysqlDuplicateEntry  = 1062
+	mysqlRowIsReferenced = 1451
+	mysqlNoReferencedRow = 1452
+	mysqlCheckViolated   = 3819
+)
+
+// PostgreSQL error codes of constraint violations
+const (
+	postgresIntegrityClass     = "23"
+	postgresUniqueViolation    = "23505"
+	postgresExclusionViolation = "23P01"
+)
+
+// translateError maps constraint violations reported by the SQL drivers to
+// ErrConflict for unique constraints and to a ConstraintError for all
+// others. Other errors are returned unchanged.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+		return &ConstraintError{Err: err}
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDuplicateEntry:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case mysqlBadNull, mysqlRowIsReferenced, mysqlNoReferencedRow, mysqlCheckViolated:
+			return &ConstraintError{Err: err}
+		}
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Class() == postgresIntegrityClass {
+		switch pqErr.Code {
+		case postgresUniqueViolation, postgresExclusionViolation:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+		return &ConstraintError{Err: err}
+	}
+
+	return err
+}
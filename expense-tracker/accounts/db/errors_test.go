@@ -0,0 +1,60 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestTranslateSQLiteErrors(t *testing.T) {
+	conn, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`
+	CREATE TABLE parents (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE);
+	CREATE TABLE children (
+		id INTEGER PRIMARY KEY,
+		parent_id INTEGER NOT NULL REFERENCES parents(id),
+		amount INTEGER NOT NULL CHECK (amount >= 0)
+	);
+	INSERT INTO parents (id, name) VALUES (1, 'a');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		statement string
+		want      error
+	}{
+		{"unique", "INSERT INTO parents (name) VALUES ('a')", ErrConflict},
+		{"primary key", "INSERT INTO parents (id, name) VALUES (1, 'b')", ErrConflict},
+		{"foreign key", "INSERT INTO children (parent_id, amount) VALUES (99, 1)", ErrConstraint},
+		{"check", "INSERT INTO children (parent_id, amount) VALUES (1, -1)", ErrConstraint},
+		{"not null", "INSERT INTO parents (name) VALUES (NULL)", ErrConstraint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := conn.Exec(tt.statement)
+			if err == nil {
+				t.Fatal("statement succeeded, want a constraint violation")
+			}
+			translated := translateError(err)
+			if !errors.Is(translated, tt.want) {
+				t.Errorf("translateError(%v) = %v, want %v", err, translated, tt.want)
+			}
+		})
+	}
+
+	// Other errors are returned unchanged
+	_, err = conn.Exec("SELECT * FROM missing")
+	if translated := translateError(err); translated != err {
+		t.Errorf("translateError(%v) = %v, want it unchanged", err, translated)
+	}
+}
@@ -0,0 +1,718 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// MemoryDB implements the Database interface in memory, for tests and demos.
+// It is safe for concurrent use. All data is lost when the process exits.
+// Operations never block on I/O, so the context is only checked on entry.
+type MemoryDB struct {
+	mu sync.RWMutex
+
+	users        map[int64]*models.User
+	apiKeys      map[int64]*models.APIKey
+	bills        map[int64]*models.Bill
+	items        map[int64]*models.BillItem
+	ledgers      map[int64]*models.Ledger
+	billShares   map[memoryShareKey]*models.Share
+	ledgerShares map[memoryShareKey]*models.Share
+	categories   map[int64]*models.Category
+	tags         map[int64]*models.Tag
+	billTags     map[int64][]int64 // tag IDs by bill ID
+	itemTags     map[int64][]int64 // tag IDs by item ID
+	merchants    map[int64]*models.Merchant
+	fxRates      map[int64]*models.FXRate
+	payments     map[int64]*models.Payment
+
+	recurringBills map[int64]*models.RecurringBill
+	occurrences    map[memoryOccurrenceKey]*memoryOccurrence
+
+	// Last assigned IDs; like AUTOINCREMENT, IDs are never reused
+	lastUserID     int64
+	lastAPIKeyID   int64
+	lastBillID     int64
+	lastItemID     int64
+	lastLedgerID   int64
+	lastCategoryID int64
+	lastTagID      int64
+	lastMerchantID int64
+	lastFXRateID   int64
+	lastPaymentID  int64
+
+	lastRecurringBillID int64
+}
+
+// NewMemoryDB creates a new in-memory database with only the default categories
+func NewMemoryDB() *MemoryDB {
+	m := &MemoryDB{
+		users:        make(map[int64]*models.User),
+		apiKeys:      make(map[int64]*models.APIKey),
+		bills:        make(map[int64]*models.Bill),
+		items:        make(map[int64]*models.BillItem),
+		ledgers:      make(map[int64]*models.Ledger),
+		billShares:   make(map[memoryShareKey]*models.Share),
+		ledgerShares: make(map[memoryShareKey]*models.Share),
+		categories:   make(map[int64]*models.Category),
+		tags:         make(map[int64]*models.Tag),
+		billTags:     make(map[int64][]int64),
+		itemTags:     make(map[int64][]int64),
+		merchants:    make(map[int64]*models.Merchant),
+		fxRates:      make(map[int64]*models.FXRate),
+		payments:     make(map[int64]*models.Payment),
+
+		recurringBills: make(map[int64]*models.RecurringBill),
+		occurrences:    make(map[memoryOccurrenceKey]*memoryOccurrence),
+	}
+	m.seedCategories()
+	return m
+}
+
+// Migrate does nothing; the in-memory database has no schema
+func (m *MemoryDB) Migrate(ctx context.Context) error {
+	return nil
+}
+
+// Ping only reports whether the context is done; the in-memory database is always reachable
+func (m *MemoryDB) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Close does nothing; the data stays available until the process exits
+func (m *MemoryDB) Close() error {
+	return nil
+}
+
+// GetBills returns the bills matching the query with summary information,
+// together with the total number of matching bills
+func (m *MemoryDB) GetBills(ctx context.Context, userID int64, query *models.BillQuery) ([]models.BillSummary, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bills := m.filterBills(userID, query)
+	sortBills(bills, query)
+
+	total := len(bills)
+	if query.Limit > 0 {
+		start := min(query.Offset, total)
+		end := min(start+query.Limit, total)
+		bills = bills[start:end]
+	}
+
+	var summaries []models.BillSummary
+	for _, bill := range bills {
+		summary := models.BillSummary{
+			ID:          bill.ID,
+			LedgerID:    copyID(bill.LedgerID),
+			CategoryID:  copyID(bill.CategoryID),
+			MerchantID:  copyID(bill.MerchantID),
+			Title:       bill.Title,
+			Description: bill.Description,
+			Total:       bill.Total,
+			Currency:    bill.Currency,
+			DueDate:     bill.DueDate,
+			Paid:        bill.Paid,
+			AmountPaid:  bill.AmountPaid,
+			ItemCount:   len(m.billItems(bill.ID)),
+			CreatedAt:   bill.CreatedAt,
+			UpdatedAt:   bill.UpdatedAt,
+		}
+		summary.SetPaymentStatus()
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, total, nil
+}
+
+// GetBill returns a single bill with all its items
+func (m *MemoryDB) GetBill(ctx context.Context, userID, id int64) (*models.Bill, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, role, err := m.billRole(userID, id, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	bill := *stored
+	bill.LedgerID = copyID(stored.LedgerID)
+	bill.CategoryID = copyID(stored.CategoryID)
+	bill.MerchantID = copyID(stored.MerchantID)
+	bill.Role = role
+	bill.Items = m.billItems(id)
+	bill.Tags = m.tagNames(m.billTags[id])
+	bill.Payments = m.billPayments(id)
+	bill.SetPaymentStatus()
+	return &bill, nil
+}
+
+// CreateBill creates a new bill and its items
+func (m *MemoryDB) CreateBill(ctx context.Context, userID int64, billInput *models.BillInput) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// Parse due date
+	dueDate, err := parseMemoryDueDate(billInput.DueDate)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkMemoryBill(billInput); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like the foreign key of the SQL schemas, the owner must exist
+	if _, ok := m.users[userID]; !ok {
+		return 0, &ConstraintError{Err: errors.New("foreign key constraint failed: bills.owner_id")}
+	}
+	if err := m.checkBillLedger(userID, billInput); err != nil {
+		return 0, err
+	}
+	if err := m.checkBillCategories(userID, userID, billInput); err != nil {
+		return 0, err
+	}
+	merchantID, categoryID, err := m.billMerchant(userID, billInput)
+	if err != nil {
+		return 0, err
+	}
+
+	now := memoryNow()
+	m.lastBillID++
+	bill := &models.Bill{
+		ID:          m.lastBillID,
+		OwnerID:     userID,
+		LedgerID:    copyID(billInput.LedgerID),
+		CategoryID:  categoryID,
+		MerchantID:  merchantID,
+		Title:       billInput.Title,
+		Description: billInput.Description,
+		Total:       billInput.CalculateTotal(),
+		Currency:    billInput.Currency,
+		DueDate:     dueDate,
+		Paid:        billInput.Paid,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	m.bills[bill.ID] = bill
+
+	m.billTags[bill.ID] = m.ensureTags(userID, billInput.Tags)
+	for i := range billInput.Items {
+		m.insertItem(bill.ID, &billInput.Items[i], now)
+	}
+
+	return bill.ID, nil
+}
+
+// UpdateBill updates an existing bill and its items
+func (m *MemoryDB) UpdateBill(ctx context.Context, userID, id int64, billInput *models.BillInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Parse due date
+	dueDate, err := parseMemoryDueDate(billInput.DueDate)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bill, role, err := m.billRole(userID, id, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+	// Only owners move bills to another ledger
+	if !sameID(bill.LedgerID, billInput.LedgerID) {
+		if !role.Allows(models.RoleOwner) {
+			return &RoleError{Type: models.ShareTypeBill, Role: role, Required: models.RoleOwner}
+		}
+		if err := m.checkBillLedger(userID, billInput); err != nil {
+			return err
+		}
+	}
+	if err := m.checkBillCategories(userID, bill.OwnerID, billInput); err != nil {
+		return err
+	}
+	merchantID, categoryID, err := m.billMerchant(bill.OwnerID, billInput)
+	if err != nil {
+		return err
+	}
+	if err := checkMemoryBill(billInput); err != nil {
+		return err
+	}
+
+	now := memoryNow()
+	bill.LedgerID = copyID(billInput.LedgerID)
+	bill.CategoryID = categoryID
+	bill.MerchantID = merchantID
+	bill.Title = billInput.Title
+	bill.Description = billInput.Description
+	bill.Total = billInput.CalculateTotal()
+	bill.Currency = billInput.Currency
+	bill.DueDate = dueDate
+	bill.Paid = billInput.Paid
+	bill.UpdatedAt = now
+
+	// Replace the tags and items
+	m.billTags[id] = m.ensureTags(bill.OwnerID, billInput.Tags)
+	m.deleteItems(id)
+	for i := range billInput.Items {
+		m.insertItem(id, &billInput.Items[i], now)
+	}
+
+	// Bills with payments are paid once they cover the total; updating
+	// them as paid pays the balance, like settleBill of the SQL databases
+	m.syncBillPaid(bill, now)
+	if billInput.Paid {
+		return m.setBillPaid(bill, true, now)
+	}
+
+	return nil
+}
+
+// SetBillPaid marks a bill as paid or unpaid
+func (m *MemoryDB) SetBillPaid(ctx context.Context, userID, id int64, paid bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bill, _, err := m.billRole(userID, id, models.RolePayer)
+	if err != nil {
+		return err
+	}
+	return m.setBillPaid(bill, paid, memoryNow())
+}
+
+// DeleteBill deletes a bill, its items, its payments and its shares
+func (m *MemoryDB) DeleteBill(ctx context.Context, userID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, _, err := m.billRole(userID, id, models.RoleOwner); err != nil {
+		return err
+	}
+
+	delete(m.bills, id)
+	delete(m.billTags, id)
+	m.deleteItems(id)
+	m.deletePayments(id)
+	for key := range m.billShares {
+		if key.targetID == id {
+			delete(m.billShares, key)
+		}
+	}
+	for _, occurrence := range m.occurrences {
+		if sameID(occurrence.billID, &id) {
+			occurrence.billID = nil
+		}
+	}
+	return nil
+}
+
+// GetBillTotals returns the totals of the bills matching the query grouped by currency
+func (m *MemoryDB) GetBillTotals(ctx context.Context, userID int64, query *models.BillQuery) ([]models.CurrencyTotal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byCurrency := make(map[string]*models.CurrencyTotal)
+	for _, bill := range m.filterBills(userID, query) {
+		total, ok := byCurrency[bill.Currency]
+		if !ok {
+			total = &models.CurrencyTotal{Currency: bill.Currency}
+			byCurrency[bill.Currency] = total
+		}
+
+		total.Total += bill.Total
+		if bill.Paid {
+			total.PaidTotal += bill.Total
+		} else {
+			total.PaidTotal += bill.AmountPaid
+			total.UnpaidTotal += bill.Total - bill.AmountPaid
+		}
+		total.BillCount++
+	}
+
+	var totals []models.CurrencyTotal
+	for _, total := range byCurrency {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Currency < totals[j].Currency
+	})
+
+	return totals, nil
+}
+
+// GetBillItems returns all items for a bill
+func (m *MemoryDB) GetBillItems(ctx context.Context, userID, billID int64) ([]models.BillItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, _, err := m.billRole(userID, billID, models.RoleViewer); err != nil {
+		return nil, nil
+	}
+	return m.billItems(billID), nil
+}
+
+// GetBillItem returns a single bill item
+func (m *MemoryDB) GetBillItem(ctx context.Context, userID, id int64) (*models.BillItem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, err := m.accessibleItem(userID, id, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	item := *stored
+	item.CategoryID = copyID(stored.CategoryID)
+	item.Tags = m.tagNames(m.itemTags[id])
+	return &item, nil
+}
+
+// CreateBillItem creates a new bill item
+func (m *MemoryDB) CreateBillItem(ctx context.Context, userID, billID int64, itemInput *models.BillItemInput) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check if the bill exists and the user may edit it
+	bill, _, err := m.billRole(userID, billID, models.RoleEditor)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.checkItemCategory(userID, bill.OwnerID, itemInput); err != nil {
+		return 0, err
+	}
+	if err := checkMemoryItem(itemInput); err != nil {
+		return 0, err
+	}
+
+	now := memoryNow()
+	itemID := m.insertItem(billID, itemInput, now)
+
+	// Update bill total
+	m.recalculateTotal(bill, now)
+
+	return itemID, nil
+}
+
+// UpdateBillItem updates an existing bill item
+func (m *MemoryDB) UpdateBillItem(ctx context.Context, userID, id int64, itemInput *models.BillItemInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.accessibleItem(userID, id, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if err := m.checkItemCategory(userID, m.bills[item.BillID].OwnerID, itemInput); err != nil {
+		return err
+	}
+	if err := checkMemoryItem(itemInput); err != nil {
+		return err
+	}
+
+	now := memoryNow()
+	item.CategoryID = copyID(itemInput.CategoryID)
+	m.itemTags[id] = m.ensureTags(m.bills[item.BillID].OwnerID, itemInput.Tags)
+	item.Name = itemInput.Name
+	item.Description = itemInput.Description
+	item.Amount = itemInput.Amount
+	item.Quantity = itemInput.Quantity
+	item.UpdatedAt = now
+
+	// Update bill total
+	m.recalculateTotal(m.bills[item.BillID], now)
+
+	return nil
+}
+
+// DeleteBillItem deletes a bill item
+func (m *MemoryDB) DeleteBillItem(ctx context.Context, userID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.accessibleItem(userID, id, models.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	delete(m.items, id)
+	delete(m.itemTags, id)
+
+	// Update bill total
+	m.recalculateTotal(m.bills[item.BillID], memoryNow())
+
+	return nil
+}
+
+// accessibleItem returns the item with the ID if the role of the user on
+// its bill includes the required one. The caller must hold the lock.
+func (m *MemoryDB) accessibleItem(userID, id int64, required models.Role) (*models.BillItem, error) {
+	item, ok := m.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if _, _, err := m.billRole(userID, item.BillID, required); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// filterBills returns the bills a user has a role on, or of all users for
+// AllOwners, matching the filters of the query. The caller must hold the lock.
+func (m *MemoryDB) filterBills(userID int64, query *models.BillQuery) []*models.Bill {
+	title := strings.ToLower(query.Title)
+
+	var bills []*models.Bill
+	for _, bill := range m.bills {
+		if userID != AllOwners && !m.memberRole(userID, bill).Valid() {
+			continue
+		}
+		if query.LedgerID != nil && !sameID(bill.LedgerID, query.LedgerID) {
+			continue
+		}
+		if query.CategoryID != nil && !m.inCategory(bill, *query.CategoryID) {
+			continue
+		}
+		if query.MerchantID != nil && !sameID(bill.MerchantID, query.MerchantID) {
+			continue
+		}
+		if len(query.Tags) > 0 && !m.hasTags(bill, query.Tags, query.TagMatch == models.TagMatchAll) {
+			continue
+		}
+		if query.Paid != nil && bill.Paid != *query.Paid {
+			continue
+		}
+		if query.PaymentStatus != "" {
+			if _, status := models.PaymentStatus(bill.Total, bill.AmountPaid, bill.Paid); status != query.PaymentStatus {
+				continue
+			}
+		}
+		// Like NULL in SQL, a missing due date never matches a date range
+		if query.DueFrom != nil && (bill.DueDate.IsZero() || bill.DueDate.Before(*query.DueFrom)) {
+			continue
+		}
+		if query.DueTo != nil && (bill.DueDate.IsZero() || bill.DueDate.After(*query.DueTo)) {
+			continue
+		}
+		if query.MinTotal != nil && bill.Total < *query.MinTotal {
+			continue
+		}
+		if query.MaxTotal != nil && bill.Total > *query.MaxTotal {
+			continue
+		}
+		if query.Currency != "" && bill.Currency != query.Currency {
+			continue
+		}
+		if title != "" && !strings.Contains(strings.ToLower(bill.Title), title) {
+			continue
+		}
+		bills = append(bills, bill)
+	}
+	return bills
+}
+
+// billItems returns copies of the items of a bill in creation order.
+// The caller must hold the lock.
+func (m *MemoryDB) billItems(billID int64) []models.BillItem {
+	var items []models.BillItem
+	for _, item := range m.items {
+		if item.BillID == billID {
+			copied := *item
+			copied.CategoryID = copyID(item.CategoryID)
+			copied.Tags = m.tagNames(m.itemTags[item.ID])
+			items = append(items, copied)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// insertItem stores a new item of a bill with its tags and returns its ID.
+// The caller must hold the write lock.
+func (m *MemoryDB) insertItem(billID int64, itemInput *models.BillItemInput, now time.Time) int64 {
+	m.lastItemID++
+	m.itemTags[m.lastItemID] = m.ensureTags(m.bills[billID].OwnerID, itemInput.Tags)
+	m.items[m.lastItemID] = &models.BillItem{
+		ID:          m.lastItemID,
+		BillID:      billID,
+		CategoryID:  copyID(itemInput.CategoryID),
+		Name:        itemInput.Name,
+		Description: itemInput.Description,
+		Amount:      itemInput.Amount,
+		Quantity:    itemInput.Quantity,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	return m.lastItemID
+}
+
+// deleteItems deletes all items of a bill and their tags.
+// The caller must hold the write lock.
+func (m *MemoryDB) deleteItems(billID int64) {
+	for id, item := range m.items {
+		if item.BillID == billID {
+			delete(m.items, id)
+			delete(m.itemTags, id)
+		}
+	}
+}
+
+// recalculateTotal sets the total of a bill to the sum of its items and
+// whether it is paid. The caller must hold the write lock.
+func (m *MemoryDB) recalculateTotal(bill *models.Bill, now time.Time) {
+	var total models.Money
+	for _, item := range m.items {
+		if item.BillID == bill.ID {
+			total += item.Amount.Mul(item.Quantity)
+		}
+	}
+	bill.Total = total
+	m.syncBillPaid(bill, now)
+}
+
+// sortBills sorts bills like buildBillOrder, with the bill ID as tie-breaker
+func sortBills(bills []*models.Bill, query *models.BillQuery) {
+	compareBy := func(a, b *models.Bill) int {
+		switch query.SortBy {
+		case models.BillSortTotal:
+			return cmp.Compare(a.Total, b.Total)
+		case models.BillSortTitle:
+			return strings.Compare(a.Title, b.Title)
+		case models.BillSortCreatedAt:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		case models.BillSortUpdatedAt:
+			return a.UpdatedAt.Compare(b.UpdatedAt)
+		default:
+			return a.DueDate.Compare(b.DueDate)
+		}
+	}
+
+	sort.Slice(bills, func(i, j int) bool {
+		c := compareBy(bills[i], bills[j])
+		if c == 0 {
+			c = cmp.Compare(bills[i].ID, bills[j].ID)
+		}
+		if query.SortOrder == models.SortDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// memoryNow returns the current time with the second precision of SQL timestamps
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// checkMemoryBill enforces the CHECK constraints of the bills, bill_items and
+// tags tables of the SQL schemas
+func checkMemoryBill(billInput *models.BillInput) error {
+	switch {
+	case strings.TrimSpace(billInput.Title) == "" || utf8.RuneCountInString(billInput.Title) > models.MaxTitleLength:
+		return &ConstraintError{Err: errors.New("check constraint failed: bills_title_check")}
+	case utf8.RuneCountInString(billInput.Currency) != 3:
+		return &ConstraintError{Err: errors.New("check constraint failed: bills_currency_check")}
+	}
+	for _, name := range billInput.Tags {
+		if err := checkMemoryTag(name); err != nil {
+			return err
+		}
+	}
+	for i := range billInput.Items {
+		if err := checkMemoryItem(&billInput.Items[i]); err != nil {
+			return err
+		}
+	}
+	if billInput.CalculateTotal() < 0 {
+		return &ConstraintError{Err: errors.New("check constraint failed: bills_total_check")}
+	}
+	return nil
+}
+
+// checkMemoryItem enforces the CHECK constraints of the bill_items and tags
+// tables
+func checkMemoryItem(itemInput *models.BillItemInput) error {
+	switch {
+	case strings.TrimSpace(itemInput.Name) == "" || utf8.RuneCountInString(itemInput.Name) > models.MaxNameLength:
+		return &ConstraintError{Err: errors.New("check constraint failed: bill_items_name_check")}
+	case itemInput.Amount < 0:
+		return &ConstraintError{Err: errors.New("check constraint failed: bill_items_amount_check")}
+	case itemInput.Quantity < 1:
+		return &ConstraintError{Err: errors.New("check constraint failed: bill_items_quantity_check")}
+	}
+	for _, name := range itemInput.Tags {
+		if err := checkMemoryTag(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseMemoryDueDate parses an optional due date in ISO format (YYYY-MM-DD)
+func parseMemoryDueDate(dueDate string) (time.Time, error) {
+	if dueDate == "" {
+		return time.Time{}, nil
+	}
+	parsedDate, err := time.Parse("2006-01-02", dueDate)
+	if err != nil {
+		return time.Time{}, NewValidationError("due_date", "must be a date in YYYY-MM-DD format")
+	}
+	return parsedDate, nil
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetAPIKeys returns all API keys, including revoked ones
+func (m *MemoryDB) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []models.APIKey
+	for _, key := range m.apiKeys {
+		keys = append(keys, copyAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+// GetAPIKey returns a single API key
+func (m *MemoryDB) GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	stored := copyAPIKey(key)
+	return &stored, nil
+}
+
+// FindAPIKey returns the API key with the hash, including revoked keys
+func (m *MemoryDB) FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.apiKeys {
+		if key.Hash == hash {
+			stored := copyAPIKey(key)
+			return &stored, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CreateAPIKey stores a new API key
+func (m *MemoryDB) CreateAPIKey(ctx context.Context, key *models.APIKey) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[key.UserID]; !ok {
+		return 0, &ConstraintError{Err: errors.New("foreign key constraint failed: api_keys.user_id")}
+	}
+	for _, existing := range m.apiKeys {
+		if existing.Hash == key.Hash {
+			return 0, ErrConflict
+		}
+	}
+
+	m.lastAPIKeyID++
+	stored := copyAPIKey(key)
+	stored.ID = m.lastAPIKeyID
+	stored.CreatedAt = memoryNow()
+	stored.LastUsedAt = nil
+	stored.RevokedAt = nil
+	m.apiKeys[stored.ID] = &stored
+	return stored.ID, nil
+}
+
+// RevokeAPIKey marks an API key as revoked
+func (m *MemoryDB) RevokeAPIKey(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		now := memoryNow()
+		key.RevokedAt = &now
+	}
+	return nil
+}
+
+// TouchAPIKey records that an API key was used now
+func (m *MemoryDB) TouchAPIKey(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key, ok := m.apiKeys[id]; ok {
+		now := memoryNow()
+		key.LastUsedAt = &now
+	}
+	return nil
+}
+
+// copyAPIKey copies a key, so callers cannot modify the stored one
+func copyAPIKey(key *models.APIKey) models.APIKey {
+	stored := *key
+	stored.Scopes = slices.Clone(key.Scopes)
+	if key.LastUsedAt != nil {
+		lastUsedAt := *key.LastUsedAt
+		stored.LastUsedAt = &lastUsedAt
+	}
+	if key.RevokedAt != nil {
+		revokedAt := *key.RevokedAt
+		stored.RevokedAt = &revokedAt
+	}
+	return stored
+}
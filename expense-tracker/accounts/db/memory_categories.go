@@ -0,0 +1,307 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// defaultCategories are the default categories and their subcategories, as
+// seeded by the categories migrations
+var defaultCategories = []struct {
+	name     string
+	children []string
+}{
+	{"Housing", []string{"Rent", "Utilities", "Maintenance"}},
+	{"Food", []string{"Groceries", "Dining Out"}},
+	{"Transport", []string{"Fuel", "Public Transport"}},
+	{"Health", nil},
+	{"Insurance", nil},
+	{"Entertainment", nil},
+	{"Shopping", nil},
+	{"Travel", nil},
+}
+
+// GetCategories returns the default categories and those of the user
+func (m *MemoryDB) GetCategories(ctx context.Context, userID int64) ([]models.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var categories []models.Category
+	for _, stored := range m.categories {
+		if visibleCategory(userID, stored) {
+			categories = append(categories, copyCategory(stored))
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Name != categories[j].Name {
+			return categories[i].Name < categories[j].Name
+		}
+		return categories[i].ID < categories[j].ID
+	})
+	return categories, nil
+}
+
+// GetCategory returns a single category
+func (m *MemoryDB) GetCategory(ctx context.Context, userID, id int64) (*models.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.categories[id]
+	if !ok || !visibleCategory(userID, stored) {
+		return nil, ErrNotFound
+	}
+	category := copyCategory(stored)
+	return &category, nil
+}
+
+// CreateCategory creates a new category owned by the user
+func (m *MemoryDB) CreateCategory(ctx context.Context, userID int64, input *models.CategoryInput) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkCategoryParent(userID, 0, input.ParentID); err != nil {
+		return 0, err
+	}
+	if err := checkMemoryCategory(input); err != nil {
+		return 0, err
+	}
+	if _, ok := m.users[userID]; !ok {
+		return 0, &ConstraintError{Err: errors.New("foreign key constraint failed: categories.owner_id")}
+	}
+
+	ownerID := userID
+	m.lastCategoryID++
+	m.categories[m.lastCategoryID] = m.newCategory(&ownerID, input.ParentID, input.Name)
+	return m.lastCategoryID, nil
+}
+
+// UpdateCategory renames and moves a category of the user
+func (m *MemoryDB) UpdateCategory(ctx context.Context, userID, id int64, input *models.CategoryInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	category, err := m.ownCategory(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := m.checkCategoryParent(userID, id, input.ParentID); err != nil {
+		return err
+	}
+	if err := checkMemoryCategory(input); err != nil {
+		return err
+	}
+
+	category.ParentID = copyID(input.ParentID)
+	category.Name = input.Name
+	category.UpdatedAt = memoryNow()
+	return nil
+}
+
+// DeleteCategory deletes a category of the user and its subcategories,
+// keeping their bills, items and merchants uncategorized
+func (m *MemoryDB) DeleteCategory(ctx context.Context, userID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.ownCategory(userID, id); err != nil {
+		return err
+	}
+
+	deleted := make(map[int64]bool)
+	for categoryID := range m.categories {
+		if m.inSubtree(&categoryID, id) {
+			deleted[categoryID] = true
+		}
+	}
+	for categoryID := range deleted {
+		delete(m.categories, categoryID)
+	}
+	for _, bill := range m.bills {
+		if bill.CategoryID != nil && deleted[*bill.CategoryID] {
+			bill.CategoryID = nil
+		}
+	}
+	for _, item := range m.items {
+		if item.CategoryID != nil && deleted[*item.CategoryID] {
+			item.CategoryID = nil
+		}
+	}
+	for _, merchant := range m.merchants {
+		if merchant.CategoryID != nil && deleted[*merchant.CategoryID] {
+			merchant.CategoryID = nil
+		}
+	}
+	for _, recurring := range m.recurringBills {
+		if recurring.CategoryID != nil && deleted[*recurring.CategoryID] {
+			recurring.CategoryID = nil
+		}
+	}
+	return nil
+}
+
+// seedCategories adds the default categories like the categories migrations
+func (m *MemoryDB) seedCategories() {
+	for _, parent := range defaultCategories {
+		m.lastCategoryID++
+		parentID := m.lastCategoryID
+		m.categories[parentID] = m.newCategory(nil, nil, parent.name)
+		for _, name := range parent.children {
+			m.lastCategoryID++
+			m.categories[m.lastCategoryID] = m.newCategory(nil, &parentID, name)
+		}
+	}
+}
+
+// newCategory returns a category with the next ID. The caller must hold the
+// lock.
+func (m *MemoryDB) newCategory(ownerID, parentID *int64, name string) *models.Category {
+	now := memoryNow()
+	return &models.Category{
+		ID:        m.lastCategoryID,
+		OwnerID:   copyID(ownerID),
+		ParentID:  copyID(parentID),
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// ownCategory returns the category with the ID if it is one of the user,
+// like ownCategory of the SQL databases. The caller must hold the lock.
+func (m *MemoryDB) ownCategory(userID, id int64) (*models.Category, error) {
+	category, ok := m.categories[id]
+	if !ok || !visibleCategory(userID, category) {
+		return nil, ErrNotFound
+	}
+	if category.OwnerID == nil {
+		return nil, ErrDefaultCategory
+	}
+	return category, nil
+}
+
+// checkCategoryParent checks the parent of the category with the ID, 0 for a
+// new one, like checkCategoryParent of the SQL databases. The caller must
+// hold the lock.
+func (m *MemoryDB) checkCategoryParent(userID, id int64, parentID *int64) error {
+	if parentID == nil {
+		return nil
+	}
+	if parent, ok := m.categories[*parentID]; !ok || !visibleCategory(userID, parent) {
+		return NewValidationError("parent_id", "must be a category you have access to")
+	}
+	if m.inSubtree(parentID, id) {
+		return NewValidationError("parent_id", "must not be the category itself or one of its subcategories")
+	}
+	return nil
+}
+
+// checkBillCategories checks the categories of a bill of the owner and its
+// items, like checkBillCategories of the SQL databases. The caller must hold
+// the lock.
+func (m *MemoryDB) checkBillCategories(userID, ownerID int64, billInput *models.BillInput) error {
+	verr := &ValidationError{}
+	m.checkCategory(userID, ownerID, billInput.CategoryID, "category_id", verr)
+	for i, item := range billInput.Items {
+		m.checkCategory(userID, ownerID, item.CategoryID, fmt.Sprintf("items[%d].category_id", i), verr)
+	}
+	return verr.Err()
+}
+
+// checkItemCategory checks the category of an item of a bill of the owner
+// like checkBillCategories. The caller must hold the lock.
+func (m *MemoryDB) checkItemCategory(userID, ownerID int64, itemInput *models.BillItemInput) error {
+	verr := &ValidationError{}
+	m.checkCategory(userID, ownerID, itemInput.CategoryID, "category_id", verr)
+	return verr.Err()
+}
+
+// checkCategory adds the field to verr unless the category is a default one
+// or one of the user or of the owner of the bill. The caller must hold the
+// lock.
+func (m *MemoryDB) checkCategory(userID, ownerID int64, categoryID *int64, field string, verr *ValidationError) {
+	if categoryID == nil {
+		return
+	}
+	category, ok := m.categories[*categoryID]
+	if !ok || !(visibleCategory(userID, category) || visibleCategory(ownerID, category)) {
+		verr.Add(field, "must be a category you have access to")
+	}
+}
+
+// inCategory reports whether the bill or one of its items is in the
+// category or one of its subcategories. The caller must hold the lock.
+func (m *MemoryDB) inCategory(bill *models.Bill, categoryID int64) bool {
+	if m.inSubtree(bill.CategoryID, categoryID) {
+		return true
+	}
+	for _, item := range m.items {
+		if item.BillID == bill.ID && m.inSubtree(item.CategoryID, categoryID) {
+			return true
+		}
+	}
+	return false
+}
+
+// inSubtree reports whether the category with the ID is the root or one of
+// its subcategories. The caller must hold the lock.
+func (m *MemoryDB) inSubtree(id *int64, root int64) bool {
+	for id != nil {
+		if *id == root {
+			return true
+		}
+		category, ok := m.categories[*id]
+		if !ok {
+			return false
+		}
+		id = category.ParentID
+	}
+	return false
+}
+
+// visibleCategory reports whether the category is a default one or one of
+// the user
+func visibleCategory(userID int64, category *models.Category) bool {
+	return category.OwnerID == nil || *category.OwnerID == userID
+}
+
+// copyCategory returns a copy of the category that does not alias it
+func copyCategory(stored *models.Category) models.Category {
+	category := *stored
+	category.OwnerID = copyID(stored.OwnerID)
+	category.ParentID = copyID(stored.ParentID)
+	return category
+}
+
+// checkMemoryCategory enforces the CHECK constraints of the categories table
+func checkMemoryCategory(input *models.CategoryInput) error {
+	if strings.TrimSpace(input.Name) == "" || utf8.RuneCountInString(input.Name) > models.MaxNameLength {
+		return &ConstraintError{Err: errors.New("check constraint failed: categories_name_check")}
+	}
+	return nil
+}
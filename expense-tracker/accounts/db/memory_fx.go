@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetFXRates returns the exchange rates, optionally filtered by base and quote currency
+func (m *MemoryDB) GetFXRates(ctx context.Context, base, quote string) ([]models.FXRate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rates []models.FXRate
+	for _, rate := range m.fxRates {
+		if base != "" && rate.BaseCurrency != base {
+			continue
+		}
+		if quote != "" && rate.QuoteCurrency != quote {
+			continue
+		}
+		rates = append(rates, *rate)
+	}
+
+	sort.Slice(rates, func(i, j int) bool {
+		a, b := rates[i], rates[j]
+		if a.BaseCurrency != b.BaseCurrency {
+			return a.BaseCurrency < b.BaseCurrency
+		}
+		if a.QuoteCurrency != b.QuoteCurrency {
+			return a.QuoteCurrency < b.QuoteCurrency
+		}
+		return a.EffectiveDate.After(b.EffectiveDate)
+	})
+
+	return rates, nil
+}
+
+// GetFXRate returns a single exchange rate
+func (m *MemoryDB) GetFXRate(ctx context.Context, id int64) (*models.FXRate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.fxRates[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	rate := *stored
+	return &rate, nil
+}
+
+// FindFXRate returns the most recent rate for a currency pair that is effective on the given date
+func (m *MemoryDB) FindFXRate(ctx context.Context, base, quote string, on time.Time) (*models.FXRate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	day := on.Format("2006-01-02")
+
+	var found *models.FXRate
+	for _, rate := range m.fxRates {
+		if rate.BaseCurrency != base || rate.QuoteCurrency != quote {
+			continue
+		}
+		if rate.EffectiveDate.Format("2006-01-02") > day {
+			continue
+		}
+		if found == nil || rate.EffectiveDate.After(found.EffectiveDate) {
+			found = rate
+		}
+	}
+
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	rate := *found
+	return &rate, nil
+}
+
+// CreateFXRate creates a new exchange rate
+func (m *MemoryDB) CreateFXRate(ctx context.Context, rateInput *models.FXRateInput) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	effectiveDate, err := parseMemoryEffectiveDate(rateInput.EffectiveDate)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Only one rate per currency pair and date
+	if m.hasFXRate(rateInput.BaseCurrency, rateInput.QuoteCurrency, effectiveDate, 0) {
+		return 0, ErrConflict
+	}
+
+	now := memoryNow()
+	m.lastFXRateID++
+	m.fxRates[m.lastFXRateID] = &models.FXRate{
+		ID:            m.lastFXRateID,
+		BaseCurrency:  rateInput.BaseCurrency,
+		QuoteCurrency: rateInput.QuoteCurrency,
+		Rate:          rateInput.Rate,
+		EffectiveDate: effectiveDate,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	return m.lastFXRateID, nil
+}
+
+// UpdateFXRate updates an existing exchange rate
+func (m *MemoryDB) UpdateFXRate(ctx context.Context, id int64, rateInput *models.FXRateInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	effectiveDate, err := parseMemoryEffectiveDate(rateInput.EffectiveDate)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rate, ok := m.fxRates[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	// Only one rate per currency pair and date
+	if m.hasFXRate(rateInput.BaseCurrency, rateInput.QuoteCurrency, effectiveDate, id) {
+		return ErrConflict
+	}
+
+	rate.BaseCurrency = rateInput.BaseCurrency
+	rate.QuoteCurrency = rateInput.QuoteCurrency
+	rate.Rate = rateInput.Rate
+	rate.EffectiveDate = effectiveDate
+	rate.UpdatedAt = memoryNow()
+
+	return nil
+}
+
+// DeleteFXRate deletes an exchange rate
+func (m *MemoryDB) DeleteFXRate(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.fxRates[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.fxRates, id)
+	return nil
+}
+
+// hasFXRate reports whether a rate other than the excluded one exists for the pair and date.
+// The caller must hold the lock.
+func (m *MemoryDB) hasFXRate(base, quote string, effectiveDate time.Time, excludeID int64) bool {
+	for _, rate := range m.fxRates {
+		if rate.ID != excludeID && rate.BaseCurrency == base && rate.QuoteCurrency == quote &&
+			rate.EffectiveDate.Equal(effectiveDate) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMemoryEffectiveDate parses an effective date in ISO format (YYYY-MM-DD)
+func parseMemoryEffectiveDate(effectiveDate string) (time.Time, error) {
+	parsedDate, err := time.Parse("2006-01-02", effectiveDate)
+	if err != nil {
+		return time.Time{}, NewValidationError("effective_date", "must be a date in YYYY-MM-DD format")
+	}
+	return parsedDate, nil
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetMerchants returns the merchants of the user
+func (m *MemoryDB) GetMerchants(ctx context.Context, userID int64) ([]models.Merchant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var merchants []models.Merchant
+	for _, stored := range m.merchants {
+		if stored.OwnerID == userID {
+			merchants = append(merchants, m.merchantView(stored))
+		}
+	}
+	sort.Slice(merchants, func(i, j int) bool {
+		return merchants[i].Name < merchants[j].Name
+	})
+	return merchants, nil
+}
+
+// GetMerchant returns a single merchant
+func (m *MemoryDB) GetMerchant(ctx context.Context, userID, id int64) (*models.Merchant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, err := m.ownMerchant(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	merchant := m.merchantView(stored)
+	return &merchant, nil
+}
+
+// CreateMerchant creates a new merchant owned by the user
+func (m *MemoryDB) CreateMerchant(ctx context.Context, userID int64, input *models.MerchantInput) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := checkMemoryMerchant(input); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return 0, &ConstraintError{Err: errors.New("foreign key constraint failed: merchants.owner_id")}
+	}
+	if err := m.checkMerchantCategory(userID, input); err != nil {
+		return 0, err
+	}
+	if m.merchantConflict(userID, 0, input) {
+		return 0, ErrConflict
+	}
+
+	now := memoryNow()
+	m.lastMerchantID++
+	merchant := &models.Merchant{
+		ID:         m.lastMerchantID,
+		OwnerID:    userID,
+		CategoryID: copyID(input.CategoryID),
+		Name:       input.Name,
+		Aliases:    sortedAliases(input.Aliases),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	m.merchants[merchant.ID] = merchant
+	return merchant.ID, nil
+}
+
+// UpdateMerchant updates a merchant of the user
+func (m *MemoryDB) UpdateMerchant(ctx context.Context, userID, id int64, input *models.MerchantInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	merchant, err := m.ownMerchant(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := m.checkMerchantCategory(userID, input); err != nil {
+		return err
+	}
+	if err := checkMemoryMerchant(input); err != nil {
+		return err
+	}
+	if m.merchantConflict(userID, id, input) {
+		return ErrConflict
+	}
+
+	merchant.CategoryID = copyID(input.CategoryID)
+	merchant.Name = input.Name
+	merchant.Aliases = sortedAliases(input.Aliases)
+	merchant.UpdatedAt = memoryNow()
+	return nil
+}
+
+// DeleteMerchant deletes a merchant of the user, keeping its bills
+func (m *MemoryDB) DeleteMerchant(ctx context.Context, userID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.ownMerchant(userID, id); err != nil {
+		return err
+	}
+
+	delete(m.merchants, id)
+	for _, bill := range m.bills {
+		if sameID(bill.MerchantID, &id) {
+			bill.MerchantID = nil
+		}
+	}
+	for _, recurring := range m.recurringBills {
+		if sameID(recurring.MerchantID, &id) {
+			recurring.MerchantID = nil
+		}
+	}
+	return nil
+}
+
+// ownMerchant returns the merchant with the ID if it is one of the user. The
+// caller must hold the lock.
+func (m *MemoryDB) ownMerchant(userID, id int64) (*models.Merchant, error) {
+	merchant, ok := m.merchants[id]
+	if !ok || merchant.OwnerID != userID {
+		return nil, ErrNotFound
+	}
+	return merchant, nil
+}
+
+// checkMerchantCategory checks the default category of a merchant of the
+// user like checkMerchantCategory of the SQL databases. The caller must hold
+// the lock.
+func (m *MemoryDB) checkMerchantCategory(userID int64, input *models.MerchantInput) error {
+	verr := &ValidationError{}
+	m.checkCategory(userID, userID, input.CategoryID, "category_id", verr)
+	return verr.Err()
+}
+
+// merchantConflict reports whether another merchant of the owner than the
+// one with the ID has the name or one of the aliases of the input, like the
+// unique constraints of the SQL schemas. The caller must hold the lock.
+func (m *MemoryDB) merchantConflict(ownerID, id int64, input *models.MerchantInput) bool {
+	for _, merchant := range m.merchants {
+		if merchant.OwnerID != ownerID || merchant.ID == id {
+			continue
+		}
+		if merchant.Name == input.Name {
+			return true
+		}
+		for _, alias := range input.Aliases {
+			if slices.Contains(merchant.Aliases, alias) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// billMerchant returns the merchant and the category of a bill of the owner
+// like billMerchant of the SQL databases. The caller must hold the lock.
+func (m *MemoryDB) billMerchant(ownerID int64, billInput *models.BillInput) (merchantID, categoryID *int64, err error) {
+	if billInput.MerchantID == nil && billInput.Merchant == "" {
+		return nil, copyID(billInput.CategoryID), nil
+	}
+
+	var merchants []models.Merchant
+	for _, merchant := range m.merchants {
+		if merchant.OwnerID == ownerID {
+			merchants = append(merchants, *merchant)
+		}
+	}
+	sort.Slice(merchants, func(i, j int) bool {
+		return merchants[i].ID < merchants[j].ID
+	})
+
+	var merchant *models.Merchant
+	if billInput.MerchantID != nil {
+		for i := range merchants {
+			if merchants[i].ID == *billInput.MerchantID {
+				merchant = &merchants[i]
+			}
+		}
+		if merchant == nil {
+			return nil, nil, NewValidationError("merchant_id", "must be a merchant of the owner of the bill")
+		}
+	} else {
+		merchant = models.MatchMerchant(billInput.Merchant, merchants)
+	}
+
+	categoryID = copyID(billInput.CategoryID)
+	if merchant == nil {
+		return nil, categoryID, nil
+	}
+	if categoryID == nil {
+		categoryID = copyID(merchant.CategoryID)
+	}
+	return copyID(&merchant.ID), categoryID, nil
+}
+
+// merchantView returns a copy of the merchant with the number of its bills.
+// The caller must hold the lock.
+func (m *MemoryDB) merchantView(stored *models.Merchant) models.Merchant {
+	merchant := *stored
+	merchant.CategoryID = copyID(stored.CategoryID)
+	merchant.Aliases = append([]string{}, stored.Aliases...)
+	for _, bill := range m.bills {
+		if sameID(bill.MerchantID, &merchant.ID) {
+			merchant.BillCount++
+		}
+	}
+	return merchant
+}
+
+// sortedAliases returns a sorted copy of the aliases, which is never nil
+func sortedAliases(aliases []string) []string {
+	sorted := append([]string{}, aliases...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// checkMemoryMerchant enforces the CHECK constraints of the merchants and
+// merchant_aliases tables
+func checkMemoryMerchant(input *models.MerchantInput) error {
+	if strings.TrimSpace(input.Name) == "" || utf8.RuneCountInString(input.Name) > models.MaxNameLength {
+		return &ConstraintError{Err: errors.New("check constraint failed: merchants_name_check")}
+	}
+	for _, alias := range input.Aliases {
+		if strings.TrimSpace(alias) == "" || utf8.RuneCountInString(alias) > models.MaxNameLength {
+			return &ConstraintError{Err: errors.New("check constraint failed: merchant_aliases_alias_check")}
+		}
+	}
+	return nil
+}
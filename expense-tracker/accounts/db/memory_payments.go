@@ -0,0 +1,234 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetBillPayments returns the payments of a bill by date
+func (m *MemoryDB) GetBillPayments(ctx context.Context, userID, billID int64) ([]models.Payment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, _, err := m.billRole(userID, billID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return m.billPayments(billID), nil
+}
+
+// GetBillPayment returns a single payment of a bill
+func (m *MemoryDB) GetBillPayment(ctx context.Context, userID, billID, id int64) (*models.Payment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, _, err := m.billRole(userID, billID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	stored, err := m.billPayment(billID, id)
+	if err != nil {
+		return nil, err
+	}
+	payment := *stored
+	return &payment, nil
+}
+
+// CreateBillPayment records a payment of a bill
+func (m *MemoryDB) CreateBillPayment(ctx context.Context, userID, billID int64, input *models.PaymentInput) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	date, err := parsePaymentDate(input.Date)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bill, _, err := m.billRole(userID, billID, models.RolePayer)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkMemoryPayment(input); err != nil {
+		return 0, err
+	}
+
+	now := memoryNow()
+	id := m.insertPayment(billID, input, date, now)
+	m.syncBillPaid(bill, now)
+	return id, nil
+}
+
+// UpdateBillPayment updates a payment of a bill
+func (m *MemoryDB) UpdateBillPayment(ctx context.Context, userID, billID, id int64, input *models.PaymentInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	date, err := parsePaymentDate(input.Date)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bill, _, err := m.billRole(userID, billID, models.RolePayer)
+	if err != nil {
+		return err
+	}
+	payment, err := m.billPayment(billID, id)
+	if err != nil {
+		return err
+	}
+	if err := checkMemoryPayment(input); err != nil {
+		return err
+	}
+
+	now := memoryNow()
+	payment.Amount = input.Amount
+	payment.Date = date
+	payment.Method = input.Method
+	payment.Reference = input.Reference
+	payment.UpdatedAt = now
+	m.syncBillPaid(bill, now)
+	return nil
+}
+
+// DeleteBillPayment deletes a payment of a bill. A bill whose last payment
+// is deleted is unpaid.
+func (m *MemoryDB) DeleteBillPayment(ctx context.Context, userID, billID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bill, _, err := m.billRole(userID, billID, models.RolePayer)
+	if err != nil {
+		return err
+	}
+	if _, err := m.billPayment(billID, id); err != nil {
+		return err
+	}
+
+	delete(m.payments, id)
+	now := memoryNow()
+	m.syncBillPaid(bill, now)
+	if bill.AmountPaid == 0 {
+		bill.Paid = false
+	}
+	return nil
+}
+
+// billPayment returns the payment with the ID if it belongs to the bill.
+// The caller must hold the lock.
+func (m *MemoryDB) billPayment(billID, id int64) (*models.Payment, error) {
+	payment, ok := m.payments[id]
+	if !ok || payment.BillID != billID {
+		return nil, ErrNotFound
+	}
+	return payment, nil
+}
+
+// billPayments returns copies of the payments of a bill by date, like
+// billPayments of the SQL databases. The caller must hold the lock.
+func (m *MemoryDB) billPayments(billID int64) []models.Payment {
+	payments := []models.Payment{}
+	for _, payment := range m.payments {
+		if payment.BillID == billID {
+			payments = append(payments, *payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		if !payments[i].Date.Equal(payments[j].Date) {
+			return payments[i].Date.Before(payments[j].Date)
+		}
+		return payments[i].ID < payments[j].ID
+	})
+	return payments
+}
+
+// insertPayment stores a new payment of a bill and returns its ID.
+// The caller must hold the write lock.
+func (m *MemoryDB) insertPayment(billID int64, input *models.PaymentInput, date, now time.Time) int64 {
+	m.lastPaymentID++
+	m.payments[m.lastPaymentID] = &models.Payment{
+		ID:        m.lastPaymentID,
+		BillID:    billID,
+		Amount:    input.Amount,
+		Date:      date,
+		Method:    input.Method,
+		Reference: input.Reference,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return m.lastPaymentID
+}
+
+// syncBillPaid sets the amount paid of a bill to the sum of its payments
+// and, like syncBillPaid of the SQL databases, marks a bill with payments
+// as paid if they cover its total. The caller must hold the write lock.
+func (m *MemoryDB) syncBillPaid(bill *models.Bill, now time.Time) {
+	var amountPaid models.Money
+	for _, payment := range m.payments {
+		if payment.BillID == bill.ID {
+			amountPaid += payment.Amount
+		}
+	}
+	bill.AmountPaid = amountPaid
+	if amountPaid > 0 {
+		bill.Paid = amountPaid >= bill.Total
+	}
+	bill.UpdatedAt = now
+}
+
+// setBillPaid marks a bill as paid or unpaid like setBillPaid of the SQL
+// databases. The caller must hold the write lock.
+func (m *MemoryDB) setBillPaid(bill *models.Bill, paid bool, now time.Time) error {
+	switch {
+	case bill.AmountPaid == 0:
+		bill.Paid = paid
+		bill.UpdatedAt = now
+	case !paid:
+		return ErrBillHasPayments
+	case bill.AmountPaid < bill.Total:
+		today := now.Truncate(24 * time.Hour)
+		m.insertPayment(bill.ID, &models.PaymentInput{
+			Amount: bill.Total - bill.AmountPaid,
+			Method: models.PaymentMethodOther,
+		}, today, now)
+		m.syncBillPaid(bill, now)
+	}
+	return nil
+}
+
+// deletePayments deletes all payments of a bill.
+// The caller must hold the write lock.
+func (m *MemoryDB) deletePayments(billID int64) {
+	for id, payment := range m.payments {
+		if payment.BillID == billID {
+			delete(m.payments, id)
+		}
+	}
+}
+
+// checkMemoryPayment enforces the CHECK constraints of the bill_payments table
+func checkMemoryPayment(input *models.PaymentInput) error {
+	if input.Amount <= 0 {
+		return &ConstraintError{Err: errors.New("check constraint failed: bill_payments_amount_check")}
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// memoryOccurrenceKey identifies an occurrence of a recurring bill by date
+type memoryOccurrenceKey struct {
+	recurringID int64
+	date        string // YYYY-MM-DD
+}
+
+// memoryOccurrence is an occurrence that a bill was generated for or that
+// was skipped, like a row of recurring_bill_occurrences
+type memoryOccurrence struct {
+	billID  *int64
+	skipped bool
+}
+
+// GetRecurringBills returns the recurring bills of the user
+func (m *MemoryDB) GetRecurringBills(ctx context.Context, userID int64) ([]models.RecurringBill, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var recurring []models.RecurringBill
+	for _, stored := range m.recurringBills {
+		if stored.OwnerID == userID {
+			recurring = append(recurring, m.recurringBillView(stored))
+		}
+	}
+	sort.Slice(recurring, func(i, j int) bool {
+		if recurring[i].Title != recurring[j].Title {
+			return recurring[i].Title < recurring[j].Title
+		}
+		return recurring[i].ID < recurring[j].ID
+	})
+	return recurring, nil
+}
+
+// GetRecurringBill returns a single recurring bill
+func (m *MemoryDB) GetRecurringBill(ctx context.Context, userID, id int64) (*models.RecurringBill, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, err := m.ownRecurringBill(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	recurring := m.recurringBillView(stored)
+	return &recurring, nil
+}
+
+// CreateRecurringBill creates a new recurring bill owned by the user
+func (m *MemoryDB) CreateRecurringBill(ctx context.Context, userID int64, input *models.RecurringBillInput) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	startDate, endDate, err := parseRecurringBillDates(input)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkMemoryRecurringBill(input, startDate, endDate); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return 0, &ConstraintError{Err: errors.New("foreign key constraint failed: recurring_bills.owner_id")}
+	}
+	if err := m.checkRecurringBill(userID, input); err != nil {
+		return 0, err
+	}
+
+	now := memoryNow()
+	m.lastRecurringBillID++
+	recurring := &models.RecurringBill{
+		ID:        m.lastRecurringBillID,
+		OwnerID:   userID,
+		CreatedAt: now,
+	}
+	setMemoryRecurringBill(recurring, input, startDate, endDate, now)
+	m.recurringBills[recurring.ID] = recurring
+	return recurring.ID, nil
+}
+
+// UpdateRecurringBill updates a recurring bill of the user
+func (m *MemoryDB) UpdateRecurringBill(ctx context.Context, userID, id int64, input *models.RecurringBillInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	startDate, endDate, err := parseRecurringBillDates(input)
+	if err != nil {
+		return err
+	}
+	if err := checkMemoryRecurringBill(input, startDate, endDate); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recurring, err := m.ownRecurringBill(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := m.checkRecurringBill(userID, input); err != nil {
+		return err
+	}
+
+	setMemoryRecurringBill(recurring, input, startDate, endDate, memoryNow())
+	return nil
+}
+
+// DeleteRecurringBill deletes a recurring bill of the user, keeping its bills
+func (m *MemoryDB) DeleteRecurringBill(ctx context.Context, userID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.ownRecurringBill(userID, id); err != nil {
+		return err
+	}
+
+	delete(m.recurringBills, id)
+	for key := range m.occurrences {
+		if key.recurringID == id {
+			delete(m.occurrences, key)
+		}
+	}
+	return nil
+}
+
+// SetRecurringBillPaused pauses or resumes a recurring bill of the user
+func (m *MemoryDB) SetRecurringBillPaused(ctx context.Context, userID, id int64, paused bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recurring, err := m.ownRecurringBill(userID, id)
+	if err != nil {
+		return err
+	}
+
+	recurring.Paused = paused
+	recurring.UpdatedAt = memoryNow()
+	return nil
+}
+
+// SkipRecurringBill skips an upcoming occurrence of a recurring bill of the user
+func (m *MemoryDB) SkipRecurringBill(ctx context.Context, userID, id int64, date time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, err := m.ownRecurringBill(userID, id)
+	if err != nil {
+		return err
+	}
+	recurring := m.recurringBillView(stored)
+	if err := checkOccurrence(&recurring, date); err != nil {
+		return err
+	}
+
+	m.occurrences[memoryOccurrenceKey{id, date.Format("2006-01-02")}] = &memoryOccurrence{skipped: true}
+	return nil
+}
+
+// EndRecurringBill ends a recurring bill of the user on the date
+func (m *MemoryDB) EndRecurringBill(ctx context.Context, userID, id int64, date time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	recurring, err := m.ownRecurringBill(userID, id)
+	if err != nil {
+		return err
+	}
+	if date.Before(recurring.StartDate) {
+		return NewValidationError("end_date", "must not be before the start date")
+	}
+
+	endDate := date.UTC()
+	recurring.EndDate = &endDate
+	recurring.UpdatedAt = memoryNow()
+	return nil
+}
+
+// GenerateRecurringBills generates the bills of all recurring bills through the date
+func (m *MemoryDB) GenerateRecurringBills(ctx context.Context, today, through time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]int64, 0, len(m.recurringBills))
+	for id := range m.recurringBills {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	now := memoryNow()
+	generated := 0
+	for _, id := range ids {
+		stored := m.recurringBills[id]
+		recurring := m.recurringBillView(stored)
+		pending, dueThrough, ok := recurringBillDue(&recurring, today, through)
+		if !ok {
+			continue
+		}
+
+		if len(pending) > 0 {
+			// Bills are only filed in the ledger while the owner may edit it
+			ledgerID := copyID(recurring.LedgerID)
+			if m.checkBillLedger(recurring.OwnerID, &models.BillInput{LedgerID: ledgerID}) != nil {
+				ledgerID = nil
+			}
+
+			for _, date := range pending {
+				billInput := recurring.BillInput(date)
+				m.lastBillID++
+				bill := &models.Bill{
+					ID:          m.lastBillID,
+					OwnerID:     recurring.OwnerID,
+					LedgerID:    ledgerID,
+					CategoryID:  copyID(billInput.CategoryID),
+					MerchantID:  copyID(billInput.MerchantID),
+					Title:       billInput.Title,
+					Description: billInput.Description,
+					Total:       billInput.CalculateTotal(),
+					Currency:    billInput.Currency,
+					DueDate:     date,
+					CreatedAt:   now,
+					UpdatedAt:   now,
+				}
+				m.bills[bill.ID] = bill
+				for i := range billInput.Items {
+					m.insertItem(bill.ID, &billInput.Items[i], now)
+				}
+				m.occurrences[memoryOccurrenceKey{id, billInput.DueDate}] = &memoryOccurrence{billID: copyID(&bill.ID)}
+			}
+			generated += len(pending)
+		}
+
+		stored.GeneratedThrough = &dueThrough
+		stored.UpdatedAt = now
+	}
+	return generated, nil
+}
+
+// ownRecurringBill returns the recurring bill with the ID if it is one of
+// the user. The caller must hold the lock.
+func (m *MemoryDB) ownRecurringBill(userID, id int64) (*models.RecurringBill, error) {
+	recurring, ok := m.recurringBills[id]
+	if !ok || recurring.OwnerID != userID {
+		return nil, ErrNotFound
+	}
+	return recurring, nil
+}
+
+// checkRecurringBill checks the ledger, category and merchant of a
+// recurring bill of the user like checkRecurringBill of the SQL databases.
+// The caller must hold the lock.
+func (m *MemoryDB) checkRecurringBill(userID int64, input *models.RecurringBillInput) error {
+	if err := m.checkBillLedger(userID, &models.BillInput{LedgerID: input.LedgerID}); err != nil {
+		return err
+	}
+	verr := &ValidationError{}
+	m.checkCategory(userID, userID, input.CategoryID, "category_id", verr)
+	if input.MerchantID != nil {
+		if _, err := m.ownMerchant(userID, *input.MerchantID); err != nil {
+			verr.Add("merchant_id", "must be a merchant of the user")
+		}
+	}
+	return verr.Err()
+}
+
+// recurringBillView returns a copy of the recurring bill with its upcoming
+// skipped dates, next date and number of bills. The caller must hold the
+// lock.
+func (m *MemoryDB) recurringBillView(stored *models.RecurringBill) models.RecurringBill {
+	recurring := *stored
+	recurring.LedgerID = copyID(stored.LedgerID)
+	recurring.CategoryID = copyID(stored.CategoryID)
+	recurring.MerchantID = copyID(stored.MerchantID)
+	recurring.SkippedDates = []time.Time{}
+	for key, occurrence := range m.occurrences {
+		if key.recurringID != stored.ID {
+			continue
+		}
+		if occurrence.billID != nil {
+			recurring.BillCount++
+		}
+		date, _ := time.Parse("2006-01-02", key.date)
+		if occurrence.skipped && (stored.GeneratedThrough == nil || date.After(*stored.GeneratedThrough)) {
+			recurring.SkippedDates = append(recurring.SkippedDates, date)
+		}
+	}
+	sort.Slice(recurring.SkippedDates, func(i, j int) bool {
+		return recurring.SkippedDates[i].Before(recurring.SkippedDates[j])
+	})
+	recurring.SetNextDate()
+	return recurring
+}
+
+// setMemoryRecurringBill sets the fields of a recurring bill from the input
+func setMemoryRecurringBill(recurring *models.RecurringBill, input *models.RecurringBillInput, startDate time.Time, endDate *time.Time, now time.Time) {
+	recurring.LedgerID = copyID(input.LedgerID)
+	recurring.CategoryID = copyID(input.CategoryID)
+	recurring.MerchantID = copyID(input.MerchantID)
+	recurring.Title = input.Title
+	recurring.Description = input.Description
+	recurring.Amount = input.Amount
+	recurring.Currency = input.Currency
+	recurring.Rule = input.Rule
+	recurring.StartDate = startDate
+	recurring.EndDate = endDate
+	recurring.UpdatedAt = now
+}
+
+// checkMemoryRecurringBill enforces the CHECK constraints of the
+// recurring_bills table
+func checkMemoryRecurringBill(input *models.RecurringBillInput, startDate time.Time, endDate *time.Time) error {
+	switch {
+	case strings.TrimSpace(input.Title) == "" || utf8.RuneCountInString(input.Title) > models.MaxTitleLength:
+		return &ConstraintError{Err: errors.New("check constraint failed: recurring_bills_title_check")}
+	case input.Amount < 0:
+		return &ConstraintError{Err: errors.New("check constraint failed: recurring_bills_amount_check")}
+	case utf8.RuneCountInString(input.Currency) != 3:
+		return &ConstraintError{Err: errors.New("check constraint failed: recurring_bills_currency_check")}
+	case endDate != nil && endDate.Before(startDate):
+		return &ConstraintError{Err: errors.New("check constraint failed: recurring_bills_dates_check")}
+	}
+	return nil
+}
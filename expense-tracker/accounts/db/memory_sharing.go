@@ -0,0 +1,395 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// memoryShareKey identifies the share of a bill or ledger with a member,
+// like the primary keys of the bill_shares and ledger_shares tables
+type memoryShareKey struct {
+	targetID int64
+	userID   int64
+}
+
+// GetLedgers returns the ledgers the user has a role on
+func (m *MemoryDB) GetLedgers(ctx context.Context, userID int64) ([]models.Ledger, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ledgers []models.Ledger
+	for _, stored := range m.ledgers {
+		if role := m.ledgerMemberRole(userID, stored); role.Valid() {
+			ledgers = append(ledgers, m.ledgerView(stored, role))
+		}
+	}
+	sort.Slice(ledgers, func(i, j int) bool {
+		if ledgers[i].Name != ledgers[j].Name {
+			return ledgers[i].Name < ledgers[j].Name
+		}
+		return ledgers[i].ID < ledgers[j].ID
+	})
+	return ledgers, nil
+}
+
+// GetLedger returns a single ledger
+func (m *MemoryDB) GetLedger(ctx context.Context, userID, id int64) (*models.Ledger, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, role, err := m.ledgerRole(userID, id, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	ledger := m.ledgerView(stored, role)
+	return &ledger, nil
+}
+
+// CreateLedger creates a new ledger owned by the user
+func (m *MemoryDB) CreateLedger(ctx context.Context, userID int64, input *models.LedgerInput) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := checkMemoryLedger(input); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return 0, &ConstraintError{Err: errors.New("foreign key constraint failed: ledgers.owner_id")}
+	}
+
+	now := memoryNow()
+	m.lastLedgerID++
+	m.ledgers[m.lastLedgerID] = &models.Ledger{
+		ID:        m.lastLedgerID,
+		OwnerID:   userID,
+		Name:      input.Name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return m.lastLedgerID, nil
+}
+
+// UpdateLedger renames a ledger
+func (m *MemoryDB) UpdateLedger(ctx context.Context, userID, id int64, input *models.LedgerInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ledger, _, err := m.ledgerRole(userID, id, models.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if err := checkMemoryLedger(input); err != nil {
+		return err
+	}
+
+	ledger.Name = input.Name
+	ledger.UpdatedAt = memoryNow()
+	return nil
+}
+
+// DeleteLedger deletes a ledger and its shares, keeping its bills outside of ledgers
+func (m *MemoryDB) DeleteLedger(ctx context.Context, userID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, _, err := m.ledgerRole(userID, id, models.RoleOwner); err != nil {
+		return err
+	}
+
+	delete(m.ledgers, id)
+	for key := range m.ledgerShares {
+		if key.targetID == id {
+			delete(m.ledgerShares, key)
+		}
+	}
+	for _, bill := range m.bills {
+		if bill.LedgerID != nil && *bill.LedgerID == id {
+			bill.LedgerID = nil
+		}
+	}
+	for _, recurring := range m.recurringBills {
+		if sameID(recurring.LedgerID, &id) {
+			recurring.LedgerID = nil
+		}
+	}
+	return nil
+}
+
+// GetShares returns the shares of a bill or ledger
+func (m *MemoryDB) GetShares(ctx context.Context, userID int64, target models.ShareTarget) ([]models.Share, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	shares, _, err := m.targetShares(userID, target, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.Share
+	for key, stored := range shares {
+		if key.targetID != target.ID {
+			continue
+		}
+		share := *stored
+		if user, ok := m.users[key.userID]; ok {
+			share.Subject = user.Subject
+			share.Email = user.Email
+			share.Name = user.Name
+		}
+		result = append(result, share)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Subject < result[j].Subject
+	})
+	return result, nil
+}
+
+// CreateShare shares a bill or ledger with a member in a role
+func (m *MemoryDB) CreateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shares, ownerID, err := m.targetShares(userID, target, models.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if ownerID == memberID {
+		return NewValidationError("user", fmt.Sprintf("must not be the owner of the %s", target.Type))
+	}
+	if !role.Valid() {
+		return &ConstraintError{Err: fmt.Errorf("check constraint failed: %s_shares_role_check", target.Type)}
+	}
+	if _, ok := m.users[memberID]; !ok {
+		return &ConstraintError{Err: fmt.Errorf("foreign key constraint failed: %s_shares.user_id", target.Type)}
+	}
+	key := memoryShareKey{target.ID, memberID}
+	if _, ok := shares[key]; ok {
+		return ErrConflict
+	}
+
+	now := memoryNow()
+	shares[key] = &models.Share{UserID: memberID, Role: role, CreatedAt: now, UpdatedAt: now}
+	return nil
+}
+
+// UpdateShare changes the role of a member of a bill or ledger
+func (m *MemoryDB) UpdateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shares, _, err := m.targetShares(userID, target, models.RoleOwner)
+	if err != nil {
+		return err
+	}
+	share, ok := shares[memoryShareKey{target.ID, memberID}]
+	if !ok {
+		return ErrNotFound
+	}
+	if !role.Valid() {
+		return &ConstraintError{Err: fmt.Errorf("check constraint failed: %s_shares_role_check", target.Type)}
+	}
+
+	share.Role = role
+	share.UpdatedAt = memoryNow()
+	return nil
+}
+
+// DeleteShare revokes the share of a member of a bill or ledger. Members
+// may revoke their own share.
+func (m *MemoryDB) DeleteShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	required := models.RoleOwner
+	if memberID == userID {
+		required = models.RoleViewer
+	}
+	shares, _, err := m.targetShares(userID, target, required)
+	if err != nil {
+		return err
+	}
+	key := memoryShareKey{target.ID, memberID}
+	if _, ok := shares[key]; !ok {
+		return ErrNotFound
+	}
+
+	delete(shares, key)
+	return nil
+}
+
+// billRole returns the bill with the ID and the role of the user on it if
+// the role includes the required one, like requireBillRole.
+// The caller must hold the lock.
+func (m *MemoryDB) billRole(userID, id int64, required models.Role) (*models.Bill, models.Role, error) {
+	bill, ok := m.bills[id]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	role := m.memberRole(userID, bill)
+	if !role.Valid() {
+		return nil, "", ErrNotFound
+	}
+	if !role.Allows(required) {
+		return nil, "", &RoleError{Type: models.ShareTypeBill, Role: role, Required: required}
+	}
+	return bill, role, nil
+}
+
+// memberRole returns the role of the user on the bill, or an invalid role
+// if the user has none, like billRole of the SQL databases.
+// The caller must hold the lock.
+func (m *MemoryDB) memberRole(userID int64, bill *models.Bill) models.Role {
+	var role models.Role
+	if bill.OwnerID == userID {
+		role = models.RoleOwner
+	}
+	if share, ok := m.billShares[memoryShareKey{bill.ID, userID}]; ok {
+		role = role.Max(share.Role)
+	}
+	if bill.LedgerID != nil {
+		if ledger, ok := m.ledgers[*bill.LedgerID]; ok {
+			role = role.Max(m.ledgerMemberRole(userID, ledger))
+		}
+	}
+	return role
+}
+
+// ledgerRole returns the ledger with the ID and the role of the user on it
+// if the role includes the required one, like requireLedgerRole.
+// The caller must hold the lock.
+func (m *MemoryDB) ledgerRole(userID, id int64, required models.Role) (*models.Ledger, models.Role, error) {
+	ledger, ok := m.ledgers[id]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	role := m.ledgerMemberRole(userID, ledger)
+	if !role.Valid() {
+		return nil, "", ErrNotFound
+	}
+	if !role.Allows(required) {
+		return nil, "", &RoleError{Type: models.ShareTypeLedger, Role: role, Required: required}
+	}
+	return ledger, role, nil
+}
+
+// ledgerMemberRole returns the role of the user on the ledger, or an
+// invalid role if the user has none. The caller must hold the lock.
+func (m *MemoryDB) ledgerMemberRole(userID int64, ledger *models.Ledger) models.Role {
+	if ledger.OwnerID == userID {
+		return models.RoleOwner
+	}
+	if share, ok := m.ledgerShares[memoryShareKey{ledger.ID, userID}]; ok {
+		return share.Role
+	}
+	return ""
+}
+
+// ledgerView returns a copy of the ledger as seen by a user with the role.
+// The caller must hold the lock.
+func (m *MemoryDB) ledgerView(stored *models.Ledger, role models.Role) models.Ledger {
+	ledger := *stored
+	ledger.Role = role
+	for _, bill := range m.bills {
+		if bill.LedgerID != nil && *bill.LedgerID == ledger.ID {
+			ledger.BillCount++
+		}
+	}
+	return ledger
+}
+
+// checkBillLedger checks that the user may file a bill in the ledger of the
+// input, like checkBillLedger of the SQL databases. The caller must hold
+// the lock.
+func (m *MemoryDB) checkBillLedger(userID int64, billInput *models.BillInput) error {
+	if billInput.LedgerID == nil {
+		return nil
+	}
+	_, _, err := m.ledgerRole(userID, *billInput.LedgerID, models.RoleEditor)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return NewValidationError("ledger_id", "must be a ledger you have access to")
+	case errors.Is(err, ErrForbidden):
+		return NewValidationError("ledger_id", "must be a ledger you are an editor of")
+	}
+	return err
+}
+
+// targetShares returns the shares of the kind of the target and the owner
+// of the target if the role of the user on it includes the required one.
+// The caller must hold the lock.
+func (m *MemoryDB) targetShares(userID int64, target models.ShareTarget, required models.Role) (map[memoryShareKey]*models.Share, int64, error) {
+	switch target.Type {
+	case models.ShareTypeBill:
+		bill, _, err := m.billRole(userID, target.ID, required)
+		if err != nil {
+			return nil, 0, err
+		}
+		return m.billShares, bill.OwnerID, nil
+	case models.ShareTypeLedger:
+		ledger, _, err := m.ledgerRole(userID, target.ID, required)
+		if err != nil {
+			return nil, 0, err
+		}
+		return m.ledgerShares, ledger.OwnerID, nil
+	}
+	return nil, 0, fmt.Errorf("unknown share type %q", target.Type)
+}
+
+// checkMemoryLedger enforces the CHECK constraints of the ledgers table
+func checkMemoryLedger(input *models.LedgerInput) error {
+	if strings.TrimSpace(input.Name) == "" || utf8.RuneCountInString(input.Name) > models.MaxNameLength {
+		return &ConstraintError{Err: errors.New("check constraint failed: ledgers_name_check")}
+	}
+	return nil
+}
+
+// copyID returns a copy of an optional ID that does not alias it
+func copyID(id *int64) *int64 {
+	if id == nil {
+		return nil
+	}
+	copied := *id
+	return &copied
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetTags returns the tags of the user
+func (m *MemoryDB) GetTags(ctx context.Context, userID int64) ([]models.Tag, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var tags []models.Tag
+	for _, stored := range m.tags {
+		if stored.OwnerID == userID {
+			tags = append(tags, m.tagView(stored))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// GetTag returns a single tag
+func (m *MemoryDB) GetTag(ctx context.Context, userID, id int64) (*models.Tag, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, err := m.ownTag(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	tag := m.tagView(stored)
+	return &tag, nil
+}
+
+// CreateTag creates a new tag owned by the user
+func (m *MemoryDB) CreateTag(ctx context.Context, userID int64, input *models.TagInput) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := checkMemoryTag(input.Name); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return 0, &ConstraintError{Err: errors.New("foreign key constraint failed: tags.owner_id")}
+	}
+	if m.findTag(userID, input.Name) != nil {
+		return 0, ErrConflict
+	}
+	return m.insertTag(userID, input.Name).ID, nil
+}
+
+// UpdateTag renames a tag of the user
+func (m *MemoryDB) UpdateTag(ctx context.Context, userID, id int64, input *models.TagInput) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tag, err := m.ownTag(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := checkMemoryTag(input.Name); err != nil {
+		return err
+	}
+	if other := m.findTag(userID, input.Name); other != nil && other.ID != id {
+		return ErrConflict
+	}
+
+	tag.Name = input.Name
+	tag.UpdatedAt = memoryNow()
+	return nil
+}
+
+// DeleteTag deletes a tag of the user, removing it from its bills and items
+func (m *MemoryDB) DeleteTag(ctx context.Context, userID, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.ownTag(userID, id); err != nil {
+		return err
+	}
+
+	delete(m.tags, id)
+	for billID, tagIDs := range m.billTags {
+		m.billTags[billID] = removeID(tagIDs, id)
+	}
+	for itemID, tagIDs := range m.itemTags {
+		m.itemTags[itemID] = removeID(tagIDs, id)
+	}
+	return nil
+}
+
+// ownTag returns the tag with the ID if it is one of the user. The caller
+// must hold the lock.
+func (m *MemoryDB) ownTag(userID, id int64) (*models.Tag, error) {
+	tag, ok := m.tags[id]
+	if !ok || tag.OwnerID != userID {
+		return nil, ErrNotFound
+	}
+	return tag, nil
+}
+
+// findTag returns the tag of the owner with the name, or nil. The caller
+// must hold the lock.
+func (m *MemoryDB) findTag(ownerID int64, name string) *models.Tag {
+	for _, tag := range m.tags {
+		if tag.OwnerID == ownerID && tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+// insertTag stores a new tag of the owner. The caller must hold the write
+// lock.
+func (m *MemoryDB) insertTag(ownerID int64, name string) *models.Tag {
+	now := memoryNow()
+	m.lastTagID++
+	tag := &models.Tag{
+		ID:        m.lastTagID,
+		OwnerID:   ownerID,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	m.tags[tag.ID] = tag
+	return tag
+}
+
+// ensureTags returns the IDs of the named tags of the owner, creating
+// missing ones like ensureTag of the SQL databases. The caller must hold the
+// write lock.
+func (m *MemoryDB) ensureTags(ownerID int64, names []string) []int64 {
+	var tagIDs []int64
+	for _, name := range names {
+		tag := m.findTag(ownerID, name)
+		if tag == nil {
+			tag = m.insertTag(ownerID, name)
+		}
+		tagIDs = append(tagIDs, tag.ID)
+	}
+	return tagIDs
+}
+
+// tagView returns a copy of the tag with the number of its bills. The caller
+// must hold the lock.
+func (m *MemoryDB) tagView(stored *models.Tag) models.Tag {
+	tag := *stored
+	for billID := range m.bills {
+		if containsID(m.billTags[billID], tag.ID) || m.itemTagged(billID, tag.ID) {
+			tag.BillCount++
+		}
+	}
+	return tag
+}
+
+// itemTagged reports whether one of the items of the bill has the tag. The
+// caller must hold the lock.
+func (m *MemoryDB) itemTagged(billID, tagID int64) bool {
+	for _, item := range m.items {
+		if item.BillID == billID && containsID(m.itemTags[item.ID], tagID) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTags reports whether the bill or one of its items has any or, with
+// matchAll, all of the named tags, like tagCondition. The caller must hold
+// the lock.
+func (m *MemoryDB) hasTags(bill *models.Bill, names []string, matchAll bool) bool {
+	found := make(map[string]bool)
+	for _, name := range m.tagNames(m.billTags[bill.ID]) {
+		found[name] = true
+	}
+	for _, item := range m.items {
+		if item.BillID == bill.ID {
+			for _, name := range m.tagNames(m.itemTags[item.ID]) {
+				found[name] = true
+			}
+		}
+	}
+
+	matched := 0
+	for _, name := range names {
+		if found[name] {
+			matched++
+		}
+	}
+	if matchAll {
+		return matched == len(names)
+	}
+	return matched > 0
+}
+
+// tagNames returns the names of the tags with the IDs by name, or an empty
+// list like tagNames of the SQL databases. The caller must hold the lock.
+func (m *MemoryDB) tagNames(tagIDs []int64) []string {
+	names := []string{}
+	for _, id := range tagIDs {
+		if tag, ok := m.tags[id]; ok {
+			names = append(names, tag.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// containsID reports whether the IDs contain the ID
+func containsID(ids []int64, id int64) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// removeID returns the IDs without the ID
+func removeID(ids []int64, id int64) []int64 {
+	var kept []int64
+	for _, other := range ids {
+		if other != id {
+			kept = append(kept, other)
+		}
+	}
+	return kept
+}
+
+// checkMemoryTag enforces the CHECK constraints of the tags table
+func checkMemoryTag(name string) error {
+	if strings.TrimSpace(name) == "" || utf8.RuneCountInString(name) > models.MaxTagLength {
+		return &ConstraintError{Err: errors.New("check constraint failed: tags_name_check")}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/db/dbtest"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Database {
+		return db.NewMemoryDB()
+	})
+}
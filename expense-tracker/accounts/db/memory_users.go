@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// EnsureUser returns the user with the subject of the input, creating it on first use
+func (m *MemoryDB) EnsureUser(ctx context.Context, input *models.UserInput) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := memoryNow()
+	for _, user := range m.users {
+		if user.Subject != input.Subject {
+			continue
+		}
+		if input.Email != "" && (input.Email != user.Email || input.EmailVerified != user.EmailVerified) {
+			user.Email = input.Email
+			user.EmailVerified = input.EmailVerified
+			user.UpdatedAt = now
+		}
+		if input.Name != "" && input.Name != user.Name {
+			user.Name = input.Name
+			user.UpdatedAt = now
+		}
+		stored := *user
+		return &stored, nil
+	}
+
+	m.lastUserID++
+	user := &models.User{
+		ID:            m.lastUserID,
+		Subject:       input.Subject,
+		Email:         input.Email,
+		EmailVerified: input.EmailVerified,
+		Name:          input.Name,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	m.users[user.ID] = user
+
+	stored := *user
+	return &stored, nil
+}
+
+// GetUser returns a single user
+func (m *MemoryDB) GetUser(ctx context.Context, id int64) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	stored := *user
+	return &stored, nil
+}
+
+// FindUserBySubject returns the user with the subject without creating it
+func (m *MemoryDB) FindUserBySubject(ctx context.Context, subject string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Subject == subject {
+			stored := *user
+			return &stored, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// FindUserByEmail returns the only user with the verified email
+func (m *MemoryDB) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found *models.User
+	for _, user := range m.users {
+		if user.Email != email || !user.EmailVerified {
+			continue
+		}
+		if found != nil {
+			return nil, ErrNotFound
+		}
+		found = user
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	stored := *found
+	return &stored, nil
+}
@@ -0,0 +1,307 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// sqlMerchants implements the merchant methods on a connection pool, like
+// sqlSharing. Users manage their own merchants; bills refer to merchants of
+// their owner.
+type sqlMerchants struct {
+	db          *sql.DB
+	bind        func(string) string
+	returningID bool
+}
+
+const merchantColumns = `
+	SELECT m.id, m.owner_id, m.category_id, m.name, m.created_at, m.updated_at,
+		(SELECT COUNT(*) FROM bills b WHERE b.merchant_id = m.id)
+	FROM merchants m
+	WHERE m.owner_id = ?`
+
+// getMerchants returns the merchants of the user by name
+func (s sqlMerchants) getMerchants(ctx context.Context, userID int64) ([]models.Merchant, error) {
+	rows, err := s.db.QueryContext(ctx, s.bind(merchantColumns+`
+	ORDER BY m.name
+	`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var merchants []models.Merchant
+	for rows.Next() {
+		merchant, err := scanMerchant(rows)
+		if err != nil {
+			return nil, err
+		}
+		merchants = append(merchants, *merchant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	aliases, err := loadNames(ctx, s.db, s.bind(`
+	SELECT merchant_id, alias FROM merchant_aliases WHERE owner_id = ? ORDER BY alias
+	`), userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range merchants {
+		merchants[i].Aliases = aliasList(aliases[merchants[i].ID])
+	}
+	return merchants, nil
+}
+
+// getMerchant returns a single merchant of the user
+func (s sqlMerchants) getMerchant(ctx context.Context, userID, id int64) (*models.Merchant, error) {
+	merchant, err := scanMerchant(s.db.QueryRowContext(ctx, s.bind(merchantColumns+`
+	AND m.id = ?
+	`), userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := loadNames(ctx, s.db, s.bind(`
+	SELECT merchant_id, alias FROM merchant_aliases WHERE merchant_id = ? ORDER BY alias
+	`), id)
+	if err != nil {
+		return nil, err
+	}
+	merchant.Aliases = aliasList(aliases[id])
+	return merchant, nil
+}
+
+// createMerchant creates a merchant of the user with its aliases
+func (s sqlMerchants) createMerchant(ctx context.Context, userID int64, input *models.MerchantInput) (id int64, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = checkMerchantCategory(ctx, tx, s.bind, userID, input); err != nil {
+		return 0, err
+	}
+
+	query := "INSERT INTO merchants (owner_id, category_id, name) VALUES (?, ?, ?)"
+	if s.returningID {
+		err = tx.QueryRowContext(ctx, s.bind(query+" RETURNING id"), userID, input.CategoryID, input.Name).Scan(&id)
+		if err = translateError(err); err != nil {
+			return 0, err
+		}
+	} else {
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, s.bind(query), userID, input.CategoryID, input.Name)
+		if err = translateError(err); err != nil {
+			return 0, err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = setMerchantAliases(ctx, tx, s.bind, userID, id, input.Aliases); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// updateMerchant renames a merchant of the user and replaces its default
+// category and aliases
+func (s sqlMerchants) updateMerchant(ctx context.Context, userID, id int64, input *models.MerchantInput) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = findMerchant(ctx, tx, s.bind, userID, id); err != nil {
+		return err
+	}
+	if err = checkMerchantCategory(ctx, tx, s.bind, userID, input); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind("UPDATE merchants SET category_id = ?, name = ? WHERE id = ?"), input.CategoryID, input.Name, id)
+	if err = translateError(err); err != nil {
+		return err
+	}
+	if err = setMerchantAliases(ctx, tx, s.bind, userID, id, input.Aliases); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteMerchant deletes a merchant of the user; its bills are kept without
+// a merchant
+func (s sqlMerchants) deleteMerchant(ctx context.Context, userID, id int64) error {
+	if err := findMerchant(ctx, s.db, s.bind, userID, id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.bind("DELETE FROM merchants WHERE id = ?"), id)
+	return translateError(err)
+}
+
+// findMerchant returns ErrNotFound unless the user owns the merchant
+func findMerchant(ctx context.Context, q querier, bind func(string) string, userID, id int64) error {
+	var exists int
+	err := q.QueryRowContext(ctx, bind(`
+	SELECT COUNT(*) FROM merchants WHERE id = ? AND owner_id = ?
+	`), id, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// checkMerchantCategory checks that the default category of a merchant of
+// the user is a default category or one of the user
+func checkMerchantCategory(ctx context.Context, q querier, bind func(string) string, userID int64, input *models.MerchantInput) error {
+	verr := &ValidationError{}
+	if err := checkCategory(ctx, q, bind, userID, 0, input.CategoryID, "category_id", verr); err != nil {
+		return err
+	}
+	return verr.Err()
+}
+
+// setMerchantAliases replaces the aliases of a merchant of the owner. Aliases
+// of other merchants of the owner fail with ErrConflict.
+func setMerchantAliases(ctx context.Context, q querier, bind func(string) string, ownerID, merchantID int64, aliases []string) error {
+	if _, err := q.ExecContext(ctx, bind("DELETE FROM merchant_aliases WHERE merchant_id = ?"), merchantID); err != nil {
+		return translateError(err)
+	}
+	for _, alias := range aliases {
+		_, err := q.ExecContext(ctx, bind(`
+		INSERT INTO merchant_aliases (merchant_id, owner_id, alias) VALUES (?, ?, ?)
+		`), merchantID, ownerID, alias)
+		if err != nil {
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+// billMerchant returns the merchant and the category of a bill: the
+// merchant with the MerchantID of the input, which must be one of the owner
+// of the bill, or else the merchant of the owner matching the Merchant name
+// of the input, if any. The category is that of the input, or else the
+// default category of the merchant. The bill ID is 0 for a new bill of the
+// user.
+func billMerchant(ctx context.Context, q querier, bind func(string) string, userID, billID int64, billInput *models.BillInput) (merchantID, categoryID *int64, err error) {
+	if billInput.MerchantID == nil && billInput.Merchant == "" {
+		return nil, billInput.CategoryID, nil
+	}
+
+	ownerID := userID
+	if billID != 0 {
+		err := q.QueryRowContext(ctx, bind("SELECT owner_id FROM bills WHERE id = ?"), billID).Scan(&ownerID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	merchants, err := ownerMerchants(ctx, q, bind, ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var merchant *models.Merchant
+	if billInput.MerchantID != nil {
+		for i := range merchants {
+			if merchants[i].ID == *billInput.MerchantID {
+				merchant = &merchants[i]
+			}
+		}
+		if merchant == nil {
+			return nil, nil, NewValidationError("merchant_id", "must be a merchant of the owner of the bill")
+		}
+	} else {
+		merchant = models.MatchMerchant(billInput.Merchant, merchants)
+	}
+
+	categoryID = billInput.CategoryID
+	if merchant == nil {
+		return nil, categoryID, nil
+	}
+	if categoryID == nil {
+		categoryID = merchant.CategoryID
+	}
+	return &merchant.ID, categoryID, nil
+}
+
+// ownerMerchants returns the merchants of the owner with their aliases, by
+// ID and without bill counts
+func ownerMerchants(ctx context.Context, q querier, bind func(string) string, ownerID int64) ([]models.Merchant, error) {
+	rows, err := q.QueryContext(ctx, bind(`
+	SELECT id, category_id, name FROM merchants WHERE owner_id = ? ORDER BY id
+	`), ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var merchants []models.Merchant
+	for rows.Next() {
+		merchant := models.Merchant{OwnerID: ownerID}
+		var categoryID sql.NullInt64
+		if err := rows.Scan(&merchant.ID, &categoryID, &merchant.Name); err != nil {
+			return nil, err
+		}
+		merchant.CategoryID = nullInt64(categoryID)
+		merchants = append(merchants, merchant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	aliases, err := loadNames(ctx, q, bind(`
+	SELECT merchant_id, alias FROM merchant_aliases WHERE owner_id = ?
+	`), ownerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range merchants {
+		merchants[i].Aliases = aliases[merchants[i].ID]
+	}
+	return merchants, nil
+}
+
+// aliasList returns the aliases, or an empty list instead of nil like
+// tagNames
+func aliasList(aliases []string) []string {
+	if aliases == nil {
+		return []string{}
+	}
+	return aliases
+}
+
+// scanMerchant scans a row of merchantColumns
+func scanMerchant(row interface{ Scan(...interface{}) error }) (*models.Merchant, error) {
+	var merchant models.Merchant
+	var categoryID sql.NullInt64
+	err := row.Scan(&merchant.ID, &merchant.OwnerID, &categoryID, &merchant.Name, &merchant.CreatedAt, &merchant.UpdatedAt, &merchant.BillCount)
+	if err != nil {
+		return nil, err
+	}
+	merchant.CategoryID = nullInt64(categoryID)
+	return &merchant, nil
+}
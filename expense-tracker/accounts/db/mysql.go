@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -8,12 +9,15 @@ import (
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jo/choreo-tutorial/accounts/config"
+	"github.com/jo/choreo-tutorial/accounts/migrations"
 	"github.com/jo/choreo-tutorial/accounts/models"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // MySQLDB implements the Database interface for MySQL
 type MySQLDB struct {
-	db *sql.DB
+	db       *sql.DB
+	migrator *migrations.Migrator
 }
 
 // NewMySQLDB creates a new MySQL database connection
@@ -21,7 +25,7 @@ func NewMySQLDB(cfg *config.Config) (*MySQLDB, error) {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
 		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := openDB("mysql", dsn, semconv.DBSystemMySQL)
 	if err != nil {
 		return nil, err
 	}
@@ -32,111 +36,144 @@ func NewMySQLDB(cfg *config.Config) (*MySQLDB, error) {
 		return nil, err
 	}
 
-	return &MySQLDB{db: db}, nil
-}
-
-// CreateTables creates the necessary tables if they don't exist
-func (m *MySQLDB) CreateTables() error {
-	// Create bills table
-	_, err := m.db.Exec(`
-	CREATE TABLE IF NOT EXISTS bills (
-		id BIGINT PRIMARY KEY AUTO_INCREMENT,
-		title VARCHAR(255) NOT NULL,
-		description TEXT,
-		total DECIMAL(10, 2) NOT NULL DEFAULT 0,
-		due_date DATE,
-		paid BOOLEAN NOT NULL DEFAULT FALSE,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
-	)
-	`)
+	migrator, err := migrations.New(db, "mysql")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Create bill_items table
-	_, err = m.db.Exec(`
-	CREATE TABLE IF NOT EXISTS bill_items (
-		id BIGINT PRIMARY KEY AUTO_INCREMENT,
-		bill_id BIGINT NOT NULL,
-		name VARCHAR(255) NOT NULL,
-		description TEXT,
-		amount DECIMAL(10, 2) NOT NULL,
-		quantity INT NOT NULL DEFAULT 1,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-		FOREIGN KEY (bill_id) REFERENCES bills(id) ON DELETE CASCADE
-	)
-	`)
+	return &MySQLDB{db: db, migrator: migrator}, nil
+}
+
+// Migrate applies all pending schema migrations
+func (m *MySQLDB) Migrate(ctx context.Context) error {
+	_, err := m.migrator.Up(ctx)
 	return err
 }
 
+// Migrator returns the schema migrator of the database
+func (m *MySQLDB) Migrator() *migrations.Migrator {
+	return m.migrator
+}
+
+// DB returns the underlying connection pool
+func (m *MySQLDB) DB() *sql.DB {
+	return m.db
+}
+
+// Ping checks that the database is reachable
+func (m *MySQLDB) Ping(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
 // Close closes the database connection
 func (m *MySQLDB) Close() error {
 	return m.db.Close()
 }
 
-// GetBills returns all bills with summary information
-func (m *MySQLDB) GetBills() ([]models.BillSummary, error) {
-	rows, err := m.db.Query(`
-	SELECT b.id, b.title, b.description, b.total, b.due_date, b.paid, b.created_at, b.updated_at, COUNT(i.id) as item_count
+// GetBills returns the bills matching the query with summary information,
+// together with the total number of matching bills
+func (m *MySQLDB) GetBills(ctx context.Context, userID int64, query *models.BillQuery) ([]models.BillSummary, int, error) {
+	where, args := buildBillFilter(userID, query)
+
+	// Count all matching bills
+	var total int
+	err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM bills b "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	listQuery := `
+	SELECT b.id, b.ledger_id, b.category_id, b.merchant_id, b.title, b.description, b.total_cents, b.currency, b.due_date, b.paid, ` + billAmountPaid + `, b.created_at, b.updated_at, COUNT(i.id) as item_count
 	FROM bills b
 	LEFT JOIN bill_items i ON b.id = i.bill_id
+	` + where + `
 	GROUP BY b.id
-	ORDER BY b.due_date ASC
-	`)
+	` + buildBillOrder(query)
+	if query.Limit > 0 {
+		listQuery += " LIMIT ? OFFSET ?"
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := m.db.QueryContext(ctx, listQuery, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var bills []models.BillSummary
 	for rows.Next() {
 		var bill models.BillSummary
+		var ledgerID, categoryID, merchantID sql.NullInt64
 		var dueDate sql.NullTime
 
 		err := rows.Scan(
 			&bill.ID,
+			&ledgerID,
+			&categoryID,
+			&merchantID,
 			&bill.Title,
 			&bill.Description,
 			&bill.Total,
+			&bill.Currency,
 			&dueDate,
 			&bill.Paid,
+			&bill.AmountPaid,
 			&bill.CreatedAt,
 			&bill.UpdatedAt,
 			&bill.ItemCount,
 		)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
+		bill.LedgerID = nullInt64(ledgerID)
+		bill.CategoryID = nullInt64(categoryID)
+		bill.MerchantID = nullInt64(merchantID)
+
 		if dueDate.Valid {
 			bill.DueDate = dueDate.Time
 		}
 
+		bill.SetPaymentStatus()
 		bills = append(bills, bill)
 	}
 
-	return bills, nil
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return bills, total, nil
 }
 
 // GetBill returns a single bill with all its items
-func (m *MySQLDB) GetBill(id int64) (*models.Bill, error) {
+func (m *MySQLDB) GetBill(ctx context.Context, userID, id int64) (*models.Bill, error) {
+	role, err := billRole(ctx, m.db, noBind, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the bill
-	var bill models.Bill
+	bill := models.Bill{Role: role}
+	var ledgerID, categoryID, merchantID sql.NullInt64
 	var dueDate sql.NullTime
 
-	err := m.db.QueryRow(`
-	SELECT id, title, description, total, due_date, paid, created_at, updated_at
-	FROM bills
+	err = m.db.QueryRowContext(ctx, `
+	SELECT id, owner_id, ledger_id, category_id, merchant_id, title, description, total_cents, currency, due_date, paid, `+billAmountPaid+`, created_at, updated_at
+	FROM bills b
 	WHERE id = ?
 	`, id).Scan(
 		&bill.ID,
+		&bill.OwnerID,
+		&ledgerID,
+		&categoryID,
+		&merchantID,
 		&bill.Title,
 		&bill.Description,
 		&bill.Total,
+		&bill.Currency,
 		&dueDate,
 		&bill.Paid,
+		&bill.AmountPaid,
 		&bill.CreatedAt,
 		&bill.UpdatedAt,
 	)
@@ -147,24 +184,51 @@ func (m *MySQLDB) GetBill(id int64) (*models.Bill, error) {
 		return nil, err
 	}
 
+	bill.LedgerID = nullInt64(ledgerID)
+	bill.CategoryID = nullInt64(categoryID)
+	bill.MerchantID = nullInt64(merchantID)
+
 	if dueDate.Valid {
 		bill.DueDate = dueDate.Time
 	}
 
 	// Get the bill items
-	items, err := m.GetBillItems(id)
+	items, err := m.GetBillItems(ctx, userID, id)
 	if err != nil {
 		return nil, err
 	}
 	bill.Items = items
 
+	// Get the tags of the bill
+	bill.Tags, err = billTags(ctx, m.db, noBind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the payments of the bill
+	bill.Payments, err = billPayments(ctx, m.db, noBind, id)
+	if err != nil {
+		return nil, err
+	}
+	bill.SetPaymentStatus()
+
 	return &bill, nil
 }
 
 // CreateBill creates a new bill and its items
-func (m *MySQLDB) CreateBill(billInput *models.BillInput) (int64, error) {
+func (m *MySQLDB) CreateBill(ctx context.Context, userID int64, billInput *models.BillInput) (int64, error) {
+	// Parse due date
+	var dueDate *time.Time
+	if billInput.DueDate != "" {
+		parsedDate, err := time.Parse("2006-01-02", billInput.DueDate)
+		if err != nil {
+			return 0, NewValidationError("due_date", "must be a date in YYYY-MM-DD format")
+		}
+		dueDate = &parsedDate
+	}
+
 	// Start a transaction
-	tx, err := m.db.Begin()
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -174,29 +238,28 @@ func (m *MySQLDB) CreateBill(billInput *models.BillInput) (int64, error) {
 		}
 	}()
 
-	// Parse due date
-	var dueDate *time.Time
-	if billInput.DueDate != "" {
-		parsedDate, err := time.Parse("2006-01-02", billInput.DueDate)
-		if err != nil {
-			return 0, fmt.Errorf("invalid due date format: %v", err)
-		}
-		dueDate = &parsedDate
+	// Check the ledger to file the bill in, the categories and the merchant
+	if err = checkBillLedger(ctx, tx, noBind, userID, billInput); err != nil {
+		return 0, err
+	}
+	if err = checkBillCategories(ctx, tx, noBind, userID, 0, billInput); err != nil {
+		return 0, err
+	}
+	merchantID, categoryID, err := billMerchant(ctx, tx, noBind, userID, 0, billInput)
+	if err != nil {
+		return 0, err
 	}
 
 	// Calculate total from items
-	var total float64
-	for _, item := range billInput.Items {
-		total += item.Amount * float64(item.Quantity)
-	}
+	total := billInput.CalculateTotal()
 
 	// Insert bill
-	result, err := tx.Exec(`
-	INSERT INTO bills (title, description, total, due_date, paid)
-	VALUES (?, ?, ?, ?, ?)
-	`, billInput.Title, billInput.Description, total, dueDate, billInput.Paid)
+	result, err := tx.ExecContext(ctx, `
+	INSERT INTO bills (owner_id, ledger_id, category_id, merchant_id, title, description, total_cents, currency, due_date, paid)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, billInput.LedgerID, categoryID, merchantID, billInput.Title, billInput.Description, total, billInput.Currency, dueDate, billInput.Paid)
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 
 	// Get the bill ID
@@ -205,13 +268,24 @@ func (m *MySQLDB) CreateBill(billInput *models.BillInput) (int64, error) {
 		return 0, err
 	}
 
-	// Insert bill items
+	// Set the tags and insert the bill items
+	if err = setBillTags(ctx, tx, noBind, billID, billInput.Tags); err != nil {
+		return 0, err
+	}
 	for _, item := range billInput.Items {
-		_, err = tx.Exec(`
-		INSERT INTO bill_items (bill_id, name, description, amount, quantity)
-		VALUES (?, ?, ?, ?, ?)
-		`, billID, item.Name, item.Description, item.Amount, item.Quantity)
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, `
+		INSERT INTO bill_items (bill_id, category_id, name, description, amount_cents, quantity)
+		VALUES (?, ?, ?, ?, ?, ?)
+		`, billID, item.CategoryID, item.Name, item.Description, item.Amount, item.Quantity)
 		if err != nil {
+			return 0, translateError(err)
+		}
+		var itemID int64
+		if itemID, err = result.LastInsertId(); err != nil {
+			return 0, err
+		}
+		if err = setItemTags(ctx, tx, noBind, billID, itemID, item.Tags); err != nil {
 			return 0, err
 		}
 	}
@@ -222,9 +296,19 @@ func (m *MySQLDB) CreateBill(billInput *models.BillInput) (int64, error) {
 }
 
 // UpdateBill updates an existing bill and its items
-func (m *MySQLDB) UpdateBill(id int64, billInput *models.BillInput) error {
+func (m *MySQLDB) UpdateBill(ctx context.Context, userID, id int64, billInput *models.BillInput) error {
+	// Parse due date
+	var dueDate *time.Time
+	if billInput.DueDate != "" {
+		parsedDate, err := time.Parse("2006-01-02", billInput.DueDate)
+		if err != nil {
+			return NewValidationError("due_date", "must be a date in YYYY-MM-DD format")
+		}
+		dueDate = &parsedDate
+	}
+
 	// Start a transaction
-	tx, err := m.db.Begin()
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -234,66 +318,131 @@ func (m *MySQLDB) UpdateBill(id int64, billInput *models.BillInput) error {
 		}
 	}()
 
-	// Parse due date
-	var dueDate *time.Time
-	if billInput.DueDate != "" {
-		parsedDate, err := time.Parse("2006-01-02", billInput.DueDate)
-		if err != nil {
-			return fmt.Errorf("invalid due date format: %v", err)
-		}
-		dueDate = &parsedDate
+	// Check the role of the user, the ledger to file the bill in, the
+	// categories and the merchant; this also checks if the bill exists, for which RowsAffected is not usable
+	// in MySQL because unchanged rows are not counted
+	if err = checkBillUpdate(ctx, tx, noBind, userID, id, billInput); err != nil {
+		return err
+	}
+	if err = checkBillCategories(ctx, tx, noBind, userID, id, billInput); err != nil {
+		return err
+	}
+	merchantID, categoryID, err := billMerchant(ctx, tx, noBind, userID, id, billInput)
+	if err != nil {
+		return err
 	}
 
 	// Calculate total from items
-	var total float64
-	for _, item := range billInput.Items {
-		total += item.Amount * float64(item.Quantity)
-	}
+	total := billInput.CalculateTotal()
 
 	// Update bill
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bills
-	SET title = ?, description = ?, total = ?, due_date = ?, paid = ?
+	SET ledger_id = ?, category_id = ?, merchant_id = ?, title = ?, description = ?, total_cents = ?, currency = ?, due_date = ?, paid = ?
 	WHERE id = ?
-	`, billInput.Title, billInput.Description, total, dueDate, billInput.Paid, id)
+	`, billInput.LedgerID, categoryID, merchantID, billInput.Title, billInput.Description, total, billInput.Currency, dueDate, billInput.Paid, id)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	// Delete existing items
-	_, err = tx.Exec("DELETE FROM bill_items WHERE bill_id = ?", id)
+	_, err = tx.ExecContext(ctx, "DELETE FROM bill_items WHERE bill_id = ?", id)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
-	// Insert new items
+	// Set the tags and insert the new items
+	if err = setBillTags(ctx, tx, noBind, id, billInput.Tags); err != nil {
+		return err
+	}
 	for _, item := range billInput.Items {
-		_, err = tx.Exec(`
-		INSERT INTO bill_items (bill_id, name, description, amount, quantity)
-		VALUES (?, ?, ?, ?, ?)
-		`, id, item.Name, item.Description, item.Amount, item.Quantity)
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, `
+		INSERT INTO bill_items (bill_id, category_id, name, description, amount_cents, quantity)
+		VALUES (?, ?, ?, ?, ?, ?)
+		`, id, item.CategoryID, item.Name, item.Description, item.Amount, item.Quantity)
 		if err != nil {
+			return translateError(err)
+		}
+		var itemID int64
+		if itemID, err = result.LastInsertId(); err != nil {
 			return err
 		}
+		if err = setItemTags(ctx, tx, noBind, id, itemID, item.Tags); err != nil {
+			return err
+		}
+	}
+
+	// Bills with payments are paid once they cover the total; updating
+	// them as paid pays the balance
+	if err = (sqlPayments{m.db, noBind, false}).settleBill(ctx, tx, id, billInput.Paid); err != nil {
+		return err
+	}
+	if err = syncBillPaid(ctx, tx, noBind, id); err != nil {
+		return err
 	}
 
 	// Commit the transaction
 	return tx.Commit()
 }
 
+// SetBillPaid marks a bill as paid or unpaid
+func (m *MySQLDB) SetBillPaid(ctx context.Context, userID, id int64, paid bool) error {
+	return sqlPayments{m.db, noBind, false}.setBillPaid(ctx, userID, id, paid)
+}
+
 // DeleteBill deletes a bill and its items
-func (m *MySQLDB) DeleteBill(id int64) error {
-	_, err := m.db.Exec("DELETE FROM bills WHERE id = ?", id)
-	return err
+func (m *MySQLDB) DeleteBill(ctx context.Context, userID, id int64) error {
+	if _, err := requireBillRole(ctx, m.db, noBind, userID, id, models.RoleOwner); err != nil {
+		return err
+	}
+
+	_, err := m.db.ExecContext(ctx, "DELETE FROM bills WHERE id = ?", id)
+	return translateError(err)
+}
+
+// GetBillTotals returns the totals of the bills matching the query grouped by currency
+func (m *MySQLDB) GetBillTotals(ctx context.Context, userID int64, query *models.BillQuery) ([]models.CurrencyTotal, error) {
+	where, args := buildBillFilter(userID, query)
+
+	rows, err := m.db.QueryContext(ctx, `
+	SELECT b.currency,
+		COALESCE(SUM(b.total_cents), 0),
+		COALESCE(SUM(CASE WHEN b.paid THEN b.total_cents ELSE `+billAmountPaid+` END), 0),
+		COUNT(*)
+	FROM bills b
+	`+where+`
+	GROUP BY b.currency
+	ORDER BY b.currency
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []models.CurrencyTotal
+	for rows.Next() {
+		var total models.CurrencyTotal
+		err := rows.Scan(&total.Currency, &total.Total, &total.PaidTotal, &total.BillCount)
+		if err != nil {
+			return nil, err
+		}
+		total.UnpaidTotal = total.Total - total.PaidTotal
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
 }
 
 // GetBillItems returns all items for a bill
-func (m *MySQLDB) GetBillItems(billID int64) ([]models.BillItem, error) {
-	rows, err := m.db.Query(`
-	SELECT id, bill_id, name, description, amount, quantity, created_at, updated_at
-	FROM bill_items
-	WHERE bill_id = ?
-	`, billID)
+func (m *MySQLDB) GetBillItems(ctx context.Context, userID, billID int64) ([]models.BillItem, error) {
+	access, args := billAccessCondition(userID)
+	rows, err := m.db.QueryContext(ctx, `
+	SELECT i.id, i.bill_id, i.category_id, i.name, i.description, i.amount_cents, i.quantity, i.created_at, i.updated_at
+	FROM bill_items i
+	JOIN bills b ON b.id = i.bill_id
+	WHERE i.bill_id = ? AND `+access+`
+	`, append([]interface{}{billID}, args...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -302,9 +451,11 @@ func (m *MySQLDB) GetBillItems(billID int64) ([]models.BillItem, error) {
 	var items []models.BillItem
 	for rows.Next() {
 		var item models.BillItem
+		var categoryID sql.NullInt64
 		err := rows.Scan(
 			&item.ID,
 			&item.BillID,
+			&categoryID,
 			&item.Name,
 			&item.Description,
 			&item.Amount,
@@ -315,22 +466,38 @@ func (m *MySQLDB) GetBillItems(billID int64) ([]models.BillItem, error) {
 		if err != nil {
 			return nil, err
 		}
+		item.CategoryID = nullInt64(categoryID)
 		items = append(items, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Get the tags of the items
+	tags, err := billItemTags(ctx, m.db, noBind, billID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Tags = tagNames(tags[items[i].ID])
+	}
 
 	return items, nil
 }
 
 // GetBillItem returns a single bill item
-func (m *MySQLDB) GetBillItem(id int64) (*models.BillItem, error) {
+func (m *MySQLDB) GetBillItem(ctx context.Context, userID, id int64) (*models.BillItem, error) {
 	var item models.BillItem
-	err := m.db.QueryRow(`
-	SELECT id, bill_id, name, description, amount, quantity, created_at, updated_at
+	var categoryID sql.NullInt64
+	err := m.db.QueryRowContext(ctx, `
+	SELECT id, bill_id, category_id, name, description, amount_cents, quantity, created_at, updated_at
 	FROM bill_items
 	WHERE id = ?
 	`, id).Scan(
 		&item.ID,
 		&item.BillID,
+		&categoryID,
 		&item.Name,
 		&item.Description,
 		&item.Amount,
@@ -344,13 +511,27 @@ func (m *MySQLDB) GetBillItem(id int64) (*models.BillItem, error) {
 		}
 		return nil, err
 	}
+
+	item.CategoryID = nullInt64(categoryID)
+
+	// Items of bills the user has no role on do not exist for them
+	if _, err := billRole(ctx, m.db, noBind, userID, item.BillID); err != nil {
+		return nil, err
+	}
+
+	// Get the tags of the item
+	tags, err := billItemTags(ctx, m.db, noBind, item.BillID)
+	if err != nil {
+		return nil, err
+	}
+	item.Tags = tagNames(tags[item.ID])
 	return &item, nil
 }
 
 // CreateBillItem creates a new bill item
-func (m *MySQLDB) CreateBillItem(billID int64, itemInput *models.BillItemInput) (int64, error) {
+func (m *MySQLDB) CreateBillItem(ctx context.Context, userID, billID int64, itemInput *models.BillItemInput) (int64, error) {
 	// Start a transaction
-	tx, err := m.db.Begin()
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -360,13 +541,21 @@ func (m *MySQLDB) CreateBillItem(billID int64, itemInput *models.BillItemInput)
 		}
 	}()
 
+	// Check if the bill exists and the user may edit it
+	if _, err = requireBillRole(ctx, tx, noBind, userID, billID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	if err = checkItemCategory(ctx, tx, noBind, userID, billID, itemInput); err != nil {
+		return 0, err
+	}
+
 	// Insert item
-	result, err := tx.Exec(`
-	INSERT INTO bill_items (bill_id, name, description, amount, quantity)
-	VALUES (?, ?, ?, ?, ?)
-	`, billID, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity)
+	result, err := tx.ExecContext(ctx, `
+	INSERT INTO bill_items (bill_id, category_id, name, description, amount_cents, quantity)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`, billID, itemInput.CategoryID, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity)
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 
 	// Get the item ID
@@ -375,13 +564,21 @@ func (m *MySQLDB) CreateBillItem(billID int64, itemInput *models.BillItemInput)
 		return 0, err
 	}
 
-	// Update bill total
-	_, err = tx.Exec(`
+	// Tag the item
+	if err = setItemTags(ctx, tx, noBind, billID, itemID, itemInput.Tags); err != nil {
+		return 0, err
+	}
+
+	// Update bill total and whether it is paid
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bills
-	SET total = (SELECT SUM(amount * quantity) FROM bill_items WHERE bill_id = ?)
+	SET total_cents = (SELECT COALESCE(SUM(amount_cents * quantity), 0) FROM bill_items WHERE bill_id = ?)
 	WHERE id = ?
 	`, billID, billID)
 	if err != nil {
+		return 0, translateError(err)
+	}
+	if err = syncBillPaid(ctx, tx, noBind, billID); err != nil {
 		return 0, err
 	}
 
@@ -391,9 +588,9 @@ func (m *MySQLDB) CreateBillItem(billID int64, itemInput *models.BillItemInput)
 }
 
 // UpdateBillItem updates an existing bill item
-func (m *MySQLDB) UpdateBillItem(id int64, itemInput *models.BillItemInput) error {
+func (m *MySQLDB) UpdateBillItem(ctx context.Context, userID, id int64, itemInput *models.BillItemInput) error {
 	// Start a transaction
-	tx, err := m.db.Begin()
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -403,30 +600,40 @@ func (m *MySQLDB) UpdateBillItem(id int64, itemInput *models.BillItemInput) erro
 		}
 	}()
 
-	// Get the bill ID
-	var billID int64
-	err = tx.QueryRow("SELECT bill_id FROM bill_items WHERE id = ?", id).Scan(&billID)
+	// Get the bill ID and check that the user may edit the bill
+	billID, err := itemBillID(ctx, tx, noBind, userID, id)
 	if err != nil {
 		return err
 	}
+	if err = checkItemCategory(ctx, tx, noBind, userID, billID, itemInput); err != nil {
+		return err
+	}
 
 	// Update item
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bill_items
-	SET name = ?, description = ?, amount = ?, quantity = ?
+	SET category_id = ?, name = ?, description = ?, amount_cents = ?, quantity = ?
 	WHERE id = ?
-	`, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity, id)
+	`, itemInput.CategoryID, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity, id)
 	if err != nil {
+		return translateError(err)
+	}
+
+	// Replace the tags of the item
+	if err = setItemTags(ctx, tx, noBind, billID, id, itemInput.Tags); err != nil {
 		return err
 	}
 
-	// Update bill total
-	_, err = tx.Exec(`
+	// Update bill total and whether it is paid
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bills
-	SET total = (SELECT SUM(amount * quantity) FROM bill_items WHERE bill_id = ?)
+	SET total_cents = (SELECT COALESCE(SUM(amount_cents * quantity), 0) FROM bill_items WHERE bill_id = ?)
 	WHERE id = ?
 	`, billID, billID)
 	if err != nil {
+		return translateError(err)
+	}
+	if err = syncBillPaid(ctx, tx, noBind, billID); err != nil {
 		return err
 	}
 
@@ -435,9 +642,9 @@ func (m *MySQLDB) UpdateBillItem(id int64, itemInput *models.BillItemInput) erro
 }
 
 // DeleteBillItem deletes a bill item
-func (m *MySQLDB) DeleteBillItem(id int64) error {
+func (m *MySQLDB) DeleteBillItem(ctx context.Context, userID, id int64) error {
 	// Start a transaction
-	tx, err := m.db.Begin()
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -447,26 +654,28 @@ func (m *MySQLDB) DeleteBillItem(id int64) error {
 		}
 	}()
 
-	// Get the bill ID
-	var billID int64
-	err = tx.QueryRow("SELECT bill_id FROM bill_items WHERE id = ?", id).Scan(&billID)
+	// Get the bill ID and check that the user may edit the bill
+	billID, err := itemBillID(ctx, tx, noBind, userID, id)
 	if err != nil {
 		return err
 	}
 
 	// Delete item
-	_, err = tx.Exec("DELETE FROM bill_items WHERE id = ?", id)
+	_, err = tx.ExecContext(ctx, "DELETE FROM bill_items WHERE id = ?", id)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
-	// Update bill total
-	_, err = tx.Exec(`
+	// Update bill total and whether it is paid
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bills
-	SET total = (SELECT SUM(amount * quantity) FROM bill_items WHERE bill_id = ?)
+	SET total_cents = (SELECT COALESCE(SUM(amount_cents * quantity), 0) FROM bill_items WHERE bill_id = ?)
 	WHERE id = ?
 	`, billID, billID)
 	if err != nil {
+		return translateError(err)
+	}
+	if err = syncBillPaid(ctx, tx, noBind, billID); err != nil {
 		return err
 	}
 
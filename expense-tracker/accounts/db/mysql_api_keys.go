@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetAPIKeys returns all API keys, including revoked ones
+func (m *MySQLDB) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return sqlAPIKeys{m.db, noBind}.getAPIKeys(ctx)
+}
+
+// GetAPIKey returns a single API key
+func (m *MySQLDB) GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	return sqlAPIKeys{m.db, noBind}.findAPIKey(ctx, "id = ?", id)
+}
+
+// FindAPIKey returns the API key with the hash, including revoked keys
+func (m *MySQLDB) FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	return sqlAPIKeys{m.db, noBind}.findAPIKey(ctx, "key_hash = ?", hash)
+}
+
+// CreateAPIKey stores a new API key
+func (m *MySQLDB) CreateAPIKey(ctx context.Context, key *models.APIKey) (int64, error) {
+	return sqlAPIKeys{m.db, noBind}.createAPIKey(ctx, key)
+}
+
+// RevokeAPIKey marks an API key as revoked
+func (m *MySQLDB) RevokeAPIKey(ctx context.Context, id int64) error {
+	return sqlAPIKeys{m.db, noBind}.revokeAPIKey(ctx, id)
+}
+
+// TouchAPIKey records that an API key was used now
+func (m *MySQLDB) TouchAPIKey(ctx context.Context, id int64) error {
+	return sqlAPIKeys{m.db, noBind}.touchAPIKey(ctx, id)
+}
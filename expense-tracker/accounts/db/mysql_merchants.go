@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetMerchants returns the merchants of the user
+func (m *MySQLDB) GetMerchants(ctx context.Context, userID int64) ([]models.Merchant, error) {
+	return sqlMerchants{m.db, noBind, false}.getMerchants(ctx, userID)
+}
+
+// GetMerchant returns a single merchant
+func (m *MySQLDB) GetMerchant(ctx context.Context, userID, id int64) (*models.Merchant, error) {
+	return sqlMerchants{m.db, noBind, false}.getMerchant(ctx, userID, id)
+}
+
+// CreateMerchant creates a new merchant owned by the user
+func (m *MySQLDB) CreateMerchant(ctx context.Context, userID int64, merchant *models.MerchantInput) (int64, error) {
+	return sqlMerchants{m.db, noBind, false}.createMerchant(ctx, userID, merchant)
+}
+
+// UpdateMerchant updates a merchant of the user
+func (m *MySQLDB) UpdateMerchant(ctx context.Context, userID, id int64, merchant *models.MerchantInput) error {
+	return sqlMerchants{m.db, noBind, false}.updateMerchant(ctx, userID, id, merchant)
+}
+
+// DeleteMerchant deletes a merchant of the user, keeping its bills
+func (m *MySQLDB) DeleteMerchant(ctx context.Context, userID, id int64) error {
+	return sqlMerchants{m.db, noBind, false}.deleteMerchant(ctx, userID, id)
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetBillPayments returns the payments of a bill
+func (m *MySQLDB) GetBillPayments(ctx context.Context, userID, billID int64) ([]models.Payment, error) {
+	return sqlPayments{m.db, noBind, false}.getBillPayments(ctx, userID, billID)
+}
+
+// GetBillPayment returns a single payment of a bill
+func (m *MySQLDB) GetBillPayment(ctx context.Context, userID, billID, id int64) (*models.Payment, error) {
+	return sqlPayments{m.db, noBind, false}.getBillPayment(ctx, userID, billID, id)
+}
+
+// CreateBillPayment records a payment of a bill
+func (m *MySQLDB) CreateBillPayment(ctx context.Context, userID, billID int64, payment *models.PaymentInput) (int64, error) {
+	return sqlPayments{m.db, noBind, false}.createBillPayment(ctx, userID, billID, payment)
+}
+
+// UpdateBillPayment updates a payment of a bill
+func (m *MySQLDB) UpdateBillPayment(ctx context.Context, userID, billID, id int64, payment *models.PaymentInput) error {
+	return sqlPayments{m.db, noBind, false}.updateBillPayment(ctx, userID, billID, id, payment)
+}
+
+// DeleteBillPayment deletes a payment of a bill
+func (m *MySQLDB) DeleteBillPayment(ctx context.Context, userID, billID, id int64) error {
+	return sqlPayments{m.db, noBind, false}.deleteBillPayment(ctx, userID, billID, id)
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetTags returns the tags of the user
+func (m *MySQLDB) GetTags(ctx context.Context, userID int64) ([]models.Tag, error) {
+	return sqlTags{m.db, noBind, false}.getTags(ctx, userID)
+}
+
+// GetTag returns a single tag
+func (m *MySQLDB) GetTag(ctx context.Context, userID, id int64) (*models.Tag, error) {
+	return sqlTags{m.db, noBind, false}.getTag(ctx, userID, id)
+}
+
+// CreateTag creates a new tag owned by the user
+func (m *MySQLDB) CreateTag(ctx context.Context, userID int64, tag *models.TagInput) (int64, error) {
+	return sqlTags{m.db, noBind, false}.createTag(ctx, userID, tag)
+}
+
+// UpdateTag renames a tag of the user
+func (m *MySQLDB) UpdateTag(ctx context.Context, userID, id int64, tag *models.TagInput) error {
+	return sqlTags{m.db, noBind, false}.updateTag(ctx, userID, id, tag)
+}
+
+// DeleteTag deletes a tag of the user, removing it from its bills and items
+func (m *MySQLDB) DeleteTag(ctx context.Context, userID, id int64) error {
+	return sqlTags{m.db, noBind, false}.deleteTag(ctx, userID, id)
+}
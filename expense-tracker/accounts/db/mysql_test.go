@@ -0,0 +1,40 @@
+package db_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jo/choreo-tutorial/accounts/config"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/db/dbtest"
+)
+
+// TestMySQLConformance runs against the MySQL server configured with
+// TEST_MYSQL_HOST, TEST_MYSQL_PORT, TEST_MYSQL_USER, TEST_MYSQL_PASSWORD and
+// TEST_MYSQL_NAME. The database is wiped by every test.
+func TestMySQLConformance(t *testing.T) {
+	host := os.Getenv("TEST_MYSQL_HOST")
+	if host == "" {
+		t.Skip("TEST_MYSQL_HOST not set")
+	}
+
+	cfg := &config.Config{
+		DBType:     "mysql",
+		DBHost:     host,
+		DBPort:     getTestEnv("TEST_MYSQL_PORT", "3306"),
+		DBUser:     getTestEnv("TEST_MYSQL_USER", "root"),
+		DBPassword: os.Getenv("TEST_MYSQL_PASSWORD"),
+		DBName:     getTestEnv("TEST_MYSQL_NAME", "accounts_test"),
+	}
+
+	dbtest.Run(t, func(t *testing.T) db.Database {
+		database, err := db.NewMySQLDB(cfg)
+		if err != nil {
+			t.Fatalf("opening MySQL database: %v", err)
+		}
+		t.Cleanup(func() { database.Close() })
+
+		resetDB(t, database)
+		return database
+	})
+}
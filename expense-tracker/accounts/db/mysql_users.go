@@ -0,0 +1,27 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// EnsureUser returns the user with the subject of the input, creating it on first use
+func (m *MySQLDB) EnsureUser(ctx context.Context, input *models.UserInput) (*models.User, error) {
+	return sqlUsers{m.db, noBind}.ensureUser(ctx, input)
+}
+
+// GetUser returns a single user
+func (m *MySQLDB) GetUser(ctx context.Context, id int64) (*models.User, error) {
+	return sqlUsers{m.db, noBind}.findUser(ctx, "id = ?", id)
+}
+
+// FindUserBySubject returns the user with the subject without creating it
+func (m *MySQLDB) FindUserBySubject(ctx context.Context, subject string) (*models.User, error) {
+	return sqlUsers{m.db, noBind}.findUser(ctx, "subject = ?", subject)
+}
+
+// FindUserByEmail returns the only user with the verified email
+func (m *MySQLDB) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return sqlUsers{m.db, noBind}.findUserByVerifiedEmail(ctx, email)
+}
@@ -0,0 +1,328 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// sqlPayments implements the payment methods of bills on a connection pool,
+// like sqlRecurring. Every role on a bill reads its payments; payers also
+// record, change and delete them.
+//
+// A bill with payments is paid once they cover its total: syncBillPaid
+// sets the paid flag whenever the payments or the total of a bill change.
+// Bills without payments keep the paid flag their users set.
+type sqlPayments struct {
+	db          *sql.DB
+	bind        func(string) string
+	returningID bool
+}
+
+const paymentColumns = `
+	SELECT id, bill_id, amount_cents, paid_on, method, reference, created_at, updated_at
+	FROM bill_payments
+	WHERE `
+
+// billAmountPaid is the sum of the payments of the bill b in minor units
+const billAmountPaid = `(SELECT COALESCE(SUM(p.amount_cents), 0) FROM bill_payments p WHERE p.bill_id = b.id)`
+
+// getBillPayments returns the payments of a bill the user has a role on
+func (s sqlPayments) getBillPayments(ctx context.Context, userID, billID int64) ([]models.Payment, error) {
+	if _, err := billRole(ctx, s.db, s.bind, userID, billID); err != nil {
+		return nil, err
+	}
+	return billPayments(ctx, s.db, s.bind, billID)
+}
+
+// getBillPayment returns a payment of a bill the user has a role on
+func (s sqlPayments) getBillPayment(ctx context.Context, userID, billID, id int64) (*models.Payment, error) {
+	if _, err := billRole(ctx, s.db, s.bind, userID, billID); err != nil {
+		return nil, err
+	}
+	return findPayment(ctx, s.db, s.bind, billID, id)
+}
+
+// createBillPayment records a payment of a bill the user is a payer of
+func (s sqlPayments) createBillPayment(ctx context.Context, userID, billID int64, input *models.PaymentInput) (id int64, err error) {
+	if _, err := parsePaymentDate(input.Date); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = requireBillRole(ctx, tx, s.bind, userID, billID, models.RolePayer); err != nil {
+		return 0, err
+	}
+	if id, err = s.insert(ctx, tx, billID, input); err != nil {
+		return 0, err
+	}
+	if err = syncBillPaid(ctx, tx, s.bind, billID); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// updateBillPayment updates a payment of a bill the user is a payer of
+func (s sqlPayments) updateBillPayment(ctx context.Context, userID, billID, id int64, input *models.PaymentInput) (err error) {
+	if _, err := parsePaymentDate(input.Date); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = requireBillRole(ctx, tx, s.bind, userID, billID, models.RolePayer); err != nil {
+		return err
+	}
+	if _, err = findPayment(ctx, tx, s.bind, billID, id); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind(`
+	UPDATE bill_payments
+	SET amount_cents = ?, paid_on = ?, method = ?, reference = ?
+	WHERE id = ?
+	`), input.Amount, input.Date, input.Method, nullString(input.Reference), id)
+	if err = translateError(err); err != nil {
+		return err
+	}
+	if err = syncBillPaid(ctx, tx, s.bind, billID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteBillPayment deletes a payment of a bill the user is a payer of. A
+// bill whose last payment is deleted is unpaid.
+func (s sqlPayments) deleteBillPayment(ctx context.Context, userID, billID, id int64) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = requireBillRole(ctx, tx, s.bind, userID, billID, models.RolePayer); err != nil {
+		return err
+	}
+	if _, err = findPayment(ctx, tx, s.bind, billID, id); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind("DELETE FROM bill_payments WHERE id = ?"), id)
+	if err = translateError(err); err != nil {
+		return err
+	}
+
+	_, amountPaid, err := billBalance(ctx, tx, s.bind, billID)
+	if err != nil {
+		return err
+	}
+	if amountPaid == 0 {
+		_, err = tx.ExecContext(ctx, s.bind("UPDATE bills SET paid = ? WHERE id = ?"), false, billID)
+	} else {
+		err = syncBillPaid(ctx, tx, s.bind, billID)
+	}
+	if err = translateError(err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setBillPaid marks a bill the user is a payer of as paid or unpaid. Bills
+// without payments only change their paid flag. Marking a bill with
+// payments as paid records a payment of its balance, if any, dated today;
+// a bill with payments cannot be marked as unpaid.
+func (s sqlPayments) setBillPaid(ctx context.Context, userID, id int64, paid bool) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = requireBillRole(ctx, tx, s.bind, userID, id, models.RolePayer); err != nil {
+		return err
+	}
+	total, amountPaid, err := billBalance(ctx, tx, s.bind, id)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case amountPaid == 0:
+		_, err = tx.ExecContext(ctx, s.bind("UPDATE bills SET paid = ? WHERE id = ?"), paid, id)
+		err = translateError(err)
+	case !paid:
+		err = ErrBillHasPayments
+	case amountPaid < total:
+		err = s.payBalance(ctx, tx, id, total-amountPaid)
+		if err == nil {
+			err = syncBillPaid(ctx, tx, s.bind, id)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insert stores a payment of a bill and returns its ID
+func (s sqlPayments) insert(ctx context.Context, q querier, billID int64, input *models.PaymentInput) (int64, error) {
+	query := `
+	INSERT INTO bill_payments (bill_id, amount_cents, paid_on, method, reference)
+	VALUES (?, ?, ?, ?, ?)`
+	args := []interface{}{billID, input.Amount, input.Date, input.Method, nullString(input.Reference)}
+	if s.returningID {
+		var id int64
+		err := q.QueryRowContext(ctx, s.bind(query+" RETURNING id"), args...).Scan(&id)
+		return id, translateError(err)
+	}
+	result, err := q.ExecContext(ctx, s.bind(query), args...)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
+
+// billPayments returns the payments of a bill by date
+func billPayments(ctx context.Context, q querier, bind func(string) string, billID int64) ([]models.Payment, error) {
+	rows, err := q.QueryContext(ctx, bind(paymentColumns+`bill_id = ?
+	ORDER BY paid_on, id
+	`), billID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []models.Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *payment)
+	}
+	return payments, rows.Err()
+}
+
+// findPayment returns a payment of the bill, or ErrNotFound
+func findPayment(ctx context.Context, q querier, bind func(string) string, billID, id int64) (*models.Payment, error) {
+	payment, err := scanPayment(q.QueryRowContext(ctx, bind(paymentColumns+"bill_id = ? AND id = ?"), billID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return payment, err
+}
+
+// billBalance returns the total of a bill and the sum of its payments
+func billBalance(ctx context.Context, q querier, bind func(string) string, billID int64) (total, amountPaid models.Money, err error) {
+	err = q.QueryRowContext(ctx, bind(`
+	SELECT b.total_cents, `+billAmountPaid+`
+	FROM bills b
+	WHERE b.id = ?
+	`), billID).Scan(&total, &amountPaid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, ErrNotFound
+	}
+	return total, amountPaid, err
+}
+
+// settleBill handles the paid flag of an update of a bill with payments
+// like setBillPaid: paid records a payment of the balance, if any, dated
+// today. The payments then decide the flag through syncBillPaid, so an
+// update as unpaid does not undo them. Bills without payments keep the flag
+// of the update.
+func (s sqlPayments) settleBill(ctx context.Context, q querier, billID int64, paid bool) error {
+	if !paid {
+		return nil
+	}
+	total, amountPaid, err := billBalance(ctx, q, s.bind, billID)
+	if err != nil || amountPaid == 0 || amountPaid >= total {
+		return err
+	}
+	return s.payBalance(ctx, q, billID, total-amountPaid)
+}
+
+// payBalance records a payment of the balance of a bill, dated today
+func (s sqlPayments) payBalance(ctx context.Context, q querier, billID int64, balance models.Money) error {
+	_, err := s.insert(ctx, q, billID, &models.PaymentInput{
+		Amount: balance,
+		Date:   time.Now().UTC().Format("2006-01-02"),
+		Method: models.PaymentMethodOther,
+	})
+	return err
+}
+
+// syncBillPaid marks a bill with payments as paid if they cover its total,
+// and as unpaid otherwise. Bills without payments keep their paid flag.
+func syncBillPaid(ctx context.Context, q querier, bind func(string) string, billID int64) error {
+	total, amountPaid, err := billBalance(ctx, q, bind, billID)
+	if err != nil || amountPaid == 0 {
+		return err
+	}
+	_, err = q.ExecContext(ctx, bind("UPDATE bills SET paid = ? WHERE id = ?"), amountPaid >= total, billID)
+	return translateError(err)
+}
+
+// paymentStatusCondition returns a condition on the bills table "b" that
+// holds for the bills with the payment status, like models.PaymentStatus,
+// with its arguments
+func paymentStatusCondition(status string) (string, []interface{}) {
+	switch status {
+	case models.PaymentStatusUnpaid:
+		return "b.paid = ? AND " + billAmountPaid + " = 0", []interface{}{false}
+	case models.PaymentStatusPartial:
+		return "b.paid = ? AND " + billAmountPaid + " > 0", []interface{}{false}
+	case models.PaymentStatusPaid:
+		return "b.paid = ? AND " + billAmountPaid + " <= b.total_cents", []interface{}{true}
+	default:
+		return billAmountPaid + " > b.total_cents", nil
+	}
+}
+
+// parsePaymentDate parses the date of a payment in ISO format (YYYY-MM-DD)
+func parsePaymentDate(date string) (time.Time, error) {
+	parsed, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return time.Time{}, NewValidationError("date", "must be a date in YYYY-MM-DD format")
+	}
+	return parsed, nil
+}
+
+// scanPayment scans a row of paymentColumns
+func scanPayment(row interface{ Scan(...interface{}) error }) (*models.Payment, error) {
+	var payment models.Payment
+	var reference sql.NullString
+	err := row.Scan(&payment.ID, &payment.BillID, &payment.Amount, &payment.Date, &payment.Method, &reference,
+		&payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	payment.Date = payment.Date.UTC()
+	payment.Reference = reference.String
+	return &payment, nil
+}
@@ -0,0 +1,675 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/config"
+	"github.com/jo/choreo-tutorial/accounts/migrations"
+	"github.com/jo/choreo-tutorial/accounts/models"
+	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// PostgresDB implements the Database interface for PostgreSQL
+type PostgresDB struct {
+	db       *sql.DB
+	migrator *migrations.Migrator
+}
+
+// NewPostgresDB creates a new PostgreSQL database connection
+func NewPostgresDB(cfg *config.Config) (*PostgresDB, error) {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.DBUser, cfg.DBPassword),
+		Host:     net.JoinHostPort(cfg.DBHost, cfg.DBPort),
+		Path:     "/" + cfg.DBName,
+		RawQuery: url.Values{"sslmode": {cfg.DBSSLMode}}.Encode(),
+	}
+
+	db, err := openDB("postgres", dsn.String(), semconv.DBSystemPostgreSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test connection
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := migrations.New(db, "postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostgresDB{db: db, migrator: migrator}, nil
+}
+
+// Migrate applies all pending schema migrations
+func (p *PostgresDB) Migrate(ctx context.Context) error {
+	_, err := p.migrator.Up(ctx)
+	return err
+}
+
+// Migrator returns the schema migrator of the database
+func (p *PostgresDB) Migrator() *migrations.Migrator {
+	return p.migrator
+}
+
+// DB returns the underlying connection pool
+func (p *PostgresDB) DB() *sql.DB {
+	return p.db
+}
+
+// Ping checks that the database is reachable
+func (p *PostgresDB) Ping(ctx context.Context) error {
+	return p.db.PingContext(ctx)
+}
+
+// Close closes the database connection
+func (p *PostgresDB) Close() error {
+	return p.db.Close()
+}
+
+// GetBills returns the bills matching the query with summary information,
+// together with the total number of matching bills
+func (p *PostgresDB) GetBills(ctx context.Context, userID int64, query *models.BillQuery) ([]models.BillSummary, int, error) {
+	where, args := buildBillFilter(userID, query)
+
+	// Count all matching bills
+	var total int
+	err := p.db.QueryRowContext(ctx, rebind("SELECT COUNT(*) FROM bills b "+where), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	listQuery := `
+	SELECT b.id, b.ledger_id, b.category_id, b.merchant_id, b.title, b.description, b.total_cents, b.currency, b.due_date, b.paid, ` + billAmountPaid + `, b.created_at, b.updated_at, COUNT(i.id) as item_count
+	FROM bills b
+	LEFT JOIN bill_items i ON b.id = i.bill_id
+	` + where + `
+	GROUP BY b.id
+	` + buildBillOrder(query)
+	if query.Limit > 0 {
+		listQuery += " LIMIT ? OFFSET ?"
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := p.db.QueryContext(ctx, rebind(listQuery), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var bills []models.BillSummary
+	for rows.Next() {
+		var bill models.BillSummary
+		var ledgerID, categoryID, merchantID sql.NullInt64
+		var dueDate sql.NullTime
+
+		err := rows.Scan(
+			&bill.ID,
+			&ledgerID,
+			&categoryID,
+			&merchantID,
+			&bill.Title,
+			&bill.Description,
+			&bill.Total,
+			&bill.Currency,
+			&dueDate,
+			&bill.Paid,
+			&bill.AmountPaid,
+			&bill.CreatedAt,
+			&bill.UpdatedAt,
+			&bill.ItemCount,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		bill.LedgerID = nullInt64(ledgerID)
+		bill.CategoryID = nullInt64(categoryID)
+		bill.MerchantID = nullInt64(merchantID)
+
+		if dueDate.Valid {
+			bill.DueDate = dueDate.Time
+		}
+
+		bill.SetPaymentStatus()
+		bills = append(bills, bill)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return bills, total, nil
+}
+
+// GetBill returns a single bill with all its items
+func (p *PostgresDB) GetBill(ctx context.Context, userID, id int64) (*models.Bill, error) {
+	role, err := billRole(ctx, p.db, rebind, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the bill
+	bill := models.Bill{Role: role}
+	var ledgerID, categoryID, merchantID sql.NullInt64
+	var dueDate sql.NullTime
+
+	err = p.db.QueryRowContext(ctx, `
+	SELECT id, owner_id, ledger_id, category_id, merchant_id, title, description, total_cents, currency, due_date, paid, `+billAmountPaid+`, created_at, updated_at
+	FROM bills b
+	WHERE id = $1
+	`, id).Scan(
+		&bill.ID,
+		&bill.OwnerID,
+		&ledgerID,
+		&categoryID,
+		&merchantID,
+		&bill.Title,
+		&bill.Description,
+		&bill.Total,
+		&bill.Currency,
+		&dueDate,
+		&bill.Paid,
+		&bill.AmountPaid,
+		&bill.CreatedAt,
+		&bill.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	bill.LedgerID = nullInt64(ledgerID)
+	bill.CategoryID = nullInt64(categoryID)
+	bill.MerchantID = nullInt64(merchantID)
+
+	if dueDate.Valid {
+		bill.DueDate = dueDate.Time
+	}
+
+	// Get the bill items
+	items, err := p.GetBillItems(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	bill.Items = items
+
+	// Get the tags of the bill
+	bill.Tags, err = billTags(ctx, p.db, rebind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the payments of the bill
+	bill.Payments, err = billPayments(ctx, p.db, rebind, id)
+	if err != nil {
+		return nil, err
+	}
+	bill.SetPaymentStatus()
+
+	return &bill, nil
+}
+
+// CreateBill creates a new bill and its items
+func (p *PostgresDB) CreateBill(ctx context.Context, userID int64, billInput *models.BillInput) (int64, error) {
+	// Parse due date
+	var dueDate *time.Time
+	if billInput.DueDate != "" {
+		parsedDate, err := time.Parse("2006-01-02", billInput.DueDate)
+		if err != nil {
+			return 0, NewValidationError("due_date", "must be a date in YYYY-MM-DD format")
+		}
+		dueDate = &parsedDate
+	}
+
+	// Start a transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Check the ledger to file the bill in, the categories and the merchant
+	if err = checkBillLedger(ctx, tx, rebind, userID, billInput); err != nil {
+		return 0, err
+	}
+	if err = checkBillCategories(ctx, tx, rebind, userID, 0, billInput); err != nil {
+		return 0, err
+	}
+	merchantID, categoryID, err := billMerchant(ctx, tx, rebind, userID, 0, billInput)
+	if err != nil {
+		return 0, err
+	}
+
+	// Calculate total from items
+	total := billInput.CalculateTotal()
+
+	// Insert bill
+	var billID int64
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO bills (owner_id, ledger_id, category_id, merchant_id, title, description, total_cents, currency, due_date, paid)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id
+	`, userID, billInput.LedgerID, categoryID, merchantID, billInput.Title, billInput.Description, total, billInput.Currency, dueDate, billInput.Paid).Scan(&billID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	// Set the tags and insert the bill items
+	if err = setBillTags(ctx, tx, rebind, billID, billInput.Tags); err != nil {
+		return 0, err
+	}
+	for _, item := range billInput.Items {
+		var itemID int64
+		err = tx.QueryRowContext(ctx, `
+		INSERT INTO bill_items (bill_id, category_id, name, description, amount_cents, quantity)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+		`, billID, item.CategoryID, item.Name, item.Description, item.Amount, item.Quantity).Scan(&itemID)
+		if err != nil {
+			return 0, translateError(err)
+		}
+		if err = setItemTags(ctx, tx, rebind, billID, itemID, item.Tags); err != nil {
+			return 0, err
+		}
+	}
+
+	// Commit the transaction
+	err = tx.Commit()
+	return billID, err
+}
+
+// UpdateBill updates an existing bill and its items
+func (p *PostgresDB) UpdateBill(ctx context.Context, userID, id int64, billInput *models.BillInput) error {
+	// Parse due date
+	var dueDate *time.Time
+	if billInput.DueDate != "" {
+		parsedDate, err := time.Parse("2006-01-02", billInput.DueDate)
+		if err != nil {
+			return NewValidationError("due_date", "must be a date in YYYY-MM-DD format")
+		}
+		dueDate = &parsedDate
+	}
+
+	// Start a transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Check the role of the user, the ledger to file the bill in, the categories
+	// and the merchant
+	if err = checkBillUpdate(ctx, tx, rebind, userID, id, billInput); err != nil {
+		return err
+	}
+	if err = checkBillCategories(ctx, tx, rebind, userID, id, billInput); err != nil {
+		return err
+	}
+	merchantID, categoryID, err := billMerchant(ctx, tx, rebind, userID, id, billInput)
+	if err != nil {
+		return err
+	}
+
+	// Calculate total from items
+	total := billInput.CalculateTotal()
+
+	// Update bill
+	_, err = tx.ExecContext(ctx, `
+	UPDATE bills
+	SET ledger_id = $1, category_id = $2, merchant_id = $3, title = $4, description = $5, total_cents = $6, currency = $7, due_date = $8, paid = $9
+	WHERE id = $10
+	`, billInput.LedgerID, categoryID, merchantID, billInput.Title, billInput.Description, total, billInput.Currency, dueDate, billInput.Paid, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	// Delete existing items
+	_, err = tx.ExecContext(ctx, "DELETE FROM bill_items WHERE bill_id = $1", id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	// Set the tags and insert the new items
+	if err = setBillTags(ctx, tx, rebind, id, billInput.Tags); err != nil {
+		return err
+	}
+	for _, item := range billInput.Items {
+		var itemID int64
+		err = tx.QueryRowContext(ctx, `
+		INSERT INTO bill_items (bill_id, category_id, name, description, amount_cents, quantity)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+		`, id, item.CategoryID, item.Name, item.Description, item.Amount, item.Quantity).Scan(&itemID)
+		if err != nil {
+			return translateError(err)
+		}
+		if err = setItemTags(ctx, tx, rebind, id, itemID, item.Tags); err != nil {
+			return err
+		}
+	}
+
+	// Bills with payments are paid once they cover the total; updating
+	// them as paid pays the balance
+	if err = (sqlPayments{p.db, rebind, true}).settleBill(ctx, tx, id, billInput.Paid); err != nil {
+		return err
+	}
+	if err = syncBillPaid(ctx, tx, rebind, id); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// SetBillPaid marks a bill as paid or unpaid
+func (p *PostgresDB) SetBillPaid(ctx context.Context, userID, id int64, paid bool) error {
+	return sqlPayments{p.db, rebind, true}.setBillPaid(ctx, userID, id, paid)
+}
+
+// DeleteBill deletes a bill and its items
+func (p *PostgresDB) DeleteBill(ctx context.Context, userID, id int64) error {
+	if _, err := requireBillRole(ctx, p.db, rebind, userID, id, models.RoleOwner); err != nil {
+		return err
+	}
+
+	_, err := p.db.ExecContext(ctx, "DELETE FROM bills WHERE id = $1", id)
+	return translateError(err)
+}
+
+// GetBillTotals returns the totals of the bills matching the query grouped by currency
+func (p *PostgresDB) GetBillTotals(ctx context.Context, userID int64, query *models.BillQuery) ([]models.CurrencyTotal, error) {
+	where, args := buildBillFilter(userID, query)
+
+	rows, err := p.db.QueryContext(ctx, rebind(`
+	SELECT b.currency,
+		COALESCE(SUM(b.total_cents), 0),
+		COALESCE(SUM(CASE WHEN b.paid THEN b.total_cents ELSE `+billAmountPaid+` END), 0),
+		COUNT(*)
+	FROM bills b
+	`+where+`
+	GROUP BY b.currency
+	ORDER BY b.currency
+	`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []models.CurrencyTotal
+	for rows.Next() {
+		var total models.CurrencyTotal
+		err := rows.Scan(&total.Currency, &total.Total, &total.PaidTotal, &total.BillCount)
+		if err != nil {
+			return nil, err
+		}
+		total.UnpaidTotal = total.Total - total.PaidTotal
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
+}
+
+// GetBillItems returns all items for a bill
+func (p *PostgresDB) GetBillItems(ctx context.Context, userID, billID int64) ([]models.BillItem, error) {
+	access, args := billAccessCondition(userID)
+	rows, err := p.db.QueryContext(ctx, rebind(`
+	SELECT i.id, i.bill_id, i.category_id, i.name, i.description, i.amount_cents, i.quantity, i.created_at, i.updated_at
+	FROM bill_items i
+	JOIN bills b ON b.id = i.bill_id
+	WHERE i.bill_id = ? AND `+access+`
+	`), append([]interface{}{billID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.BillItem
+	for rows.Next() {
+		var item models.BillItem
+		var categoryID sql.NullInt64
+		err := rows.Scan(
+			&item.ID,
+			&item.BillID,
+			&categoryID,
+			&item.Name,
+			&item.Description,
+			&item.Amount,
+			&item.Quantity,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.CategoryID = nullInt64(categoryID)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Get the tags of the items
+	tags, err := billItemTags(ctx, p.db, rebind, billID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Tags = tagNames(tags[items[i].ID])
+	}
+
+	return items, nil
+}
+
+// GetBillItem returns a single bill item
+func (p *PostgresDB) GetBillItem(ctx context.Context, userID, id int64) (*models.BillItem, error) {
+	var item models.BillItem
+	var categoryID sql.NullInt64
+	err := p.db.QueryRowContext(ctx, `
+	SELECT id, bill_id, category_id, name, description, amount_cents, quantity, created_at, updated_at
+	FROM bill_items
+	WHERE id = $1
+	`, id).Scan(
+		&item.ID,
+		&item.BillID,
+		&categoryID,
+		&item.Name,
+		&item.Description,
+		&item.Amount,
+		&item.Quantity,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	item.CategoryID = nullInt64(categoryID)
+
+	// Items of bills the user has no role on do not exist for them
+	if _, err := billRole(ctx, p.db, rebind, userID, item.BillID); err != nil {
+		return nil, err
+	}
+
+	// Get the tags of the item
+	tags, err := billItemTags(ctx, p.db, rebind, item.BillID)
+	if err != nil {
+		return nil, err
+	}
+	item.Tags = tagNames(tags[item.ID])
+	return &item, nil
+}
+
+// CreateBillItem creates a new bill item
+func (p *PostgresDB) CreateBillItem(ctx context.Context, userID, billID int64, itemInput *models.BillItemInput) (int64, error) {
+	// Start a transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Check if the bill exists and the user may edit it
+	if _, err = requireBillRole(ctx, tx, rebind, userID, billID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	if err = checkItemCategory(ctx, tx, rebind, userID, billID, itemInput); err != nil {
+		return 0, err
+	}
+
+	// Insert item
+	var itemID int64
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO bill_items (bill_id, category_id, name, description, amount_cents, quantity)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+	`, billID, itemInput.CategoryID, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity).Scan(&itemID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	// Tag the item
+	if err = setItemTags(ctx, tx, rebind, billID, itemID, itemInput.Tags); err != nil {
+		return 0, err
+	}
+
+	// Update bill total and whether it is paid
+	_, err = tx.ExecContext(ctx, `
+	UPDATE bills
+	SET total_cents = (SELECT COALESCE(SUM(amount_cents * quantity), 0) FROM bill_items WHERE bill_id = $1)
+	WHERE id = $2
+	`, billID, billID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	if err = syncBillPaid(ctx, tx, rebind, billID); err != nil {
+		return 0, err
+	}
+
+	// Commit the transaction
+	err = tx.Commit()
+	return itemID, err
+}
+
+// UpdateBillItem updates an existing bill item
+func (p *PostgresDB) UpdateBillItem(ctx context.Context, userID, id int64, itemInput *models.BillItemInput) error {
+	// Start a transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Get the bill ID and check that the user may edit the bill
+	billID, err := itemBillID(ctx, tx, rebind, userID, id)
+	if err != nil {
+		return err
+	}
+	if err = checkItemCategory(ctx, tx, rebind, userID, billID, itemInput); err != nil {
+		return err
+	}
+
+	// Update item
+	_, err = tx.ExecContext(ctx, `
+	UPDATE bill_items
+	SET category_id = $1, name = $2, description = $3, amount_cents = $4, quantity = $5
+	WHERE id = $6
+	`, itemInput.CategoryID, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	// Replace the tags of the item
+	if err = setItemTags(ctx, tx, rebind, billID, id, itemInput.Tags); err != nil {
+		return err
+	}
+
+	// Update bill total and whether it is paid
+	_, err = tx.ExecContext(ctx, `
+	UPDATE bills
+	SET total_cents = (SELECT COALESCE(SUM(amount_cents * quantity), 0) FROM bill_items WHERE bill_id = $1)
+	WHERE id = $2
+	`, billID, billID)
+	if err != nil {
+		return translateError(err)
+	}
+	if err = syncBillPaid(ctx, tx, rebind, billID); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// DeleteBillItem deletes a bill item
+func (p *PostgresDB) DeleteBillItem(ctx context.Context, userID, id int64) error {
+	// Start a transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Get the bill ID and check that the user may edit the bill
+	billID, err := itemBillID(ctx, tx, rebind, userID, id)
+	if err != nil {
+		return err
+	}
+
+	// Delete item
+	_, err = tx.ExecContext(ctx, "DELETE FROM bill_items WHERE id = $1", id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	// Update bill total and whether it is paid
+	_, err = tx.ExecContext(ctx, `
+	UPDATE bills
+	SET total_cents = (SELECT COALESCE(SUM(amount_cents * quantity), 0) FROM bill_items WHERE bill_id = $1)
+	WHERE id = $2
+	`, billID, billID)
+	if err != nil {
+		return translateError(err)
+	}
+	if err = syncBillPaid(ctx, tx, rebind, billID); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
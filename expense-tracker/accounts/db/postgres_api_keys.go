@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetAPIKeys returns all API keys, including revoked ones
+func (p *PostgresDB) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return sqlAPIKeys{p.db, rebind}.getAPIKeys(ctx)
+}
+
+// GetAPIKey returns a single API key
+func (p *PostgresDB) GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	return sqlAPIKeys{p.db, rebind}.findAPIKey(ctx, "id = ?", id)
+}
+
+// FindAPIKey returns the API key with the hash, including revoked keys
+func (p *PostgresDB) FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	return sqlAPIKeys{p.db, rebind}.findAPIKey(ctx, "key_hash = ?", hash)
+}
+
+// CreateAPIKey stores a new API key
+func (p *PostgresDB) CreateAPIKey(ctx context.Context, key *models.APIKey) (int64, error) {
+	return sqlAPIKeys{p.db, rebind}.createAPIKey(ctx, key)
+}
+
+// RevokeAPIKey marks an API key as revoked
+func (p *PostgresDB) RevokeAPIKey(ctx context.Context, id int64) error {
+	return sqlAPIKeys{p.db, rebind}.revokeAPIKey(ctx, id)
+}
+
+// TouchAPIKey records that an API key was used now
+func (p *PostgresDB) TouchAPIKey(ctx context.Context, id int64) error {
+	return sqlAPIKeys{p.db, rebind}.touchAPIKey(ctx, id)
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetCategories returns the default categories and those of the user
+func (p *PostgresDB) GetCategories(ctx context.Context, userID int64) ([]models.Category, error) {
+	return sqlCategories{p.db, rebind, true}.getCategories(ctx, userID)
+}
+
+// GetCategory returns a single category
+func (p *PostgresDB) GetCategory(ctx context.Context, userID, id int64) (*models.Category, error) {
+	return sqlCategories{p.db, rebind, true}.getCategory(ctx, userID, id)
+}
+
+// CreateCategory creates a new category owned by the user
+func (p *PostgresDB) CreateCategory(ctx context.Context, userID int64, category *models.CategoryInput) (int64, error) {
+	return sqlCategories{p.db, rebind, true}.createCategory(ctx, userID, category)
+}
+
+// UpdateCategory renames and moves a category of the user
+func (p *PostgresDB) UpdateCategory(ctx context.Context, userID, id int64, category *models.CategoryInput) error {
+	return sqlCategories{p.db, rebind, true}.updateCategory(ctx, userID, id, category)
+}
+
+// DeleteCategory deletes a category of the user and its subcategories
+func (p *PostgresDB) DeleteCategory(ctx context.Context, userID, id int64) error {
+	return sqlCategories{p.db, rebind, true}.deleteCategory(ctx, userID, id)
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetFXRates returns the exchange rates, optionally filtered by base and quote currency
+func (p *PostgresDB) GetFXRates(ctx context.Context, base, quote string) ([]models.FXRate, error) {
+	rows, err := p.db.QueryContext(ctx, `
+	SELECT id, base_currency, quote_currency, rate, effective_date, created_at, updated_at
+	FROM fx_rates
+	WHERE ($1 = '' OR base_currency = $2) AND ($3 = '' OR quote_currency = $4)
+	ORDER BY base_currency, quote_currency, effective_date DESC
+	`, base, base, quote, quote)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.FXRate
+	for rows.Next() {
+		rate, err := scanPostgresFXRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *rate)
+	}
+
+	return rates, rows.Err()
+}
+
+// GetFXRate returns a single exchange rate
+func (p *PostgresDB) GetFXRate(ctx context.Context, id int64) (*models.FXRate, error) {
+	row := p.db.QueryRowContext(ctx, `
+	SELECT id, base_currency, quote_currency, rate, effective_date, created_at, updated_at
+	FROM fx_rates
+	WHERE id = $1
+	`, id)
+
+	rate, err := scanPostgresFXRate(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return rate, nil
+}
+
+// FindFXRate returns the most recent rate for a currency pair that is effective on the given date
+func (p *PostgresDB) FindFXRate(ctx context.Context, base, quote string, on time.Time) (*models.FXRate, error) {
+	row := p.db.QueryRowContext(ctx, `
+	SELECT id, base_currency, quote_currency, rate, effective_date, created_at, updated_at
+	FROM fx_rates
+	WHERE base_currency = $1 AND quote_currency = $2 AND effective_date <= $3
+	ORDER BY effective_date DESC
+	LIMIT 1
+	`, base, quote, on.Format("2006-01-02"))
+
+	rate, err := scanPostgresFXRate(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return rate, nil
+}
+
+// CreateFXRate creates a new exchange rate
+func (p *PostgresDB) CreateFXRate(ctx context.Context, rateInput *models.FXRateInput) (int64, error) {
+	// Start a transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Only one rate per currency pair and date
+	var exists int
+	err = tx.QueryRowContext(ctx, `
+	SELECT COUNT(*) FROM fx_rates
+	WHERE base_currency = $1 AND quote_currency = $2 AND effective_date = $3
+	`, rateInput.BaseCurrency, rateInput.QuoteCurrency, rateInput.EffectiveDate).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists > 0 {
+		err = ErrConflict
+		return 0, err
+	}
+
+	// Insert rate
+	var rateID int64
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO fx_rates (base_currency, quote_currency, rate, effective_date)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id
+	`, rateInput.BaseCurrency, rateInput.QuoteCurrency, rateInput.Rate, rateInput.EffectiveDate).Scan(&rateID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	// Commit the transaction
+	err = tx.Commit()
+	return rateID, err
+}
+
+// UpdateFXRate updates an existing exchange rate
+func (p *PostgresDB) UpdateFXRate(ctx context.Context, id int64, rateInput *models.FXRateInput) error {
+	// Start a transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Check if the rate exists
+	var exists int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM fx_rates WHERE id = $1", id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		err = ErrNotFound
+		return err
+	}
+
+	// Only one rate per currency pair and date
+	err = tx.QueryRowContext(ctx, `
+	SELECT COUNT(*) FROM fx_rates
+	WHERE base_currency = $1 AND quote_currency = $2 AND effective_date = $3 AND id <> $4
+	`, rateInput.BaseCurrency, rateInput.QuoteCurrency, rateInput.EffectiveDate, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		err = ErrConflict
+		return err
+	}
+
+	// Update rate
+	_, err = tx.ExecContext(ctx, `
+	UPDATE fx_rates
+	SET base_currency = $1, quote_currency = $2, rate = $3, effective_date = $4
+	WHERE id = $5
+	`, rateInput.BaseCurrency, rateInput.QuoteCurrency, rateInput.Rate, rateInput.EffectiveDate, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	// Commit the transaction
+	return tx.Commit()
+}
+
+// DeleteFXRate deletes an exchange rate
+func (p *PostgresDB) DeleteFXRate(ctx context.Context, id int64) error {
+	result, err := p.db.ExecContext(ctx, "DELETE FROM fx_rates WHERE id = $1", id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanPostgresFXRate scans an exchange rate row
+func scanPostgresFXRate(row interface{ Scan(...interface{}) error }) (*models.FXRate, error) {
+	var rate models.FXRate
+	err := row.Scan(
+		&rate.ID,
+		&rate.BaseCurrency,
+		&rate.QuoteCurrency,
+		&rate.Rate,
+		&rate.EffectiveDate,
+		&rate.CreatedAt,
+		&rate.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetMerchants returns the merchants of the user
+func (p *PostgresDB) GetMerchants(ctx context.Context, userID int64) ([]models.Merchant, error) {
+	return sqlMerchants{p.db, rebind, true}.getMerchants(ctx, userID)
+}
+
+// GetMerchant returns a single merchant
+func (p *PostgresDB) GetMerchant(ctx context.Context, userID, id int64) (*models.Merchant, error) {
+	return sqlMerchants{p.db, rebind, true}.getMerchant(ctx, userID, id)
+}
+
+// CreateMerchant creates a new merchant owned by the user
+func (p *PostgresDB) CreateMerchant(ctx context.Context, userID int64, merchant *models.MerchantInput) (int64, error) {
+	return sqlMerchants{p.db, rebind, true}.createMerchant(ctx, userID, merchant)
+}
+
+// UpdateMerchant updates a merchant of the user
+func (p *PostgresDB) UpdateMerchant(ctx context.Context, userID, id int64, merchant *models.MerchantInput) error {
+	return sqlMerchants{p.db, rebind, true}.updateMerchant(ctx, userID, id, merchant)
+}
+
+// DeleteMerchant deletes a merchant of the user, keeping its bills
+func (p *PostgresDB) DeleteMerchant(ctx context.Context, userID, id int64) error {
+	return sqlMerchants{p.db, rebind, true}.deleteMerchant(ctx, userID, id)
+}
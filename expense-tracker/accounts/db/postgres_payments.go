@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetBillPayments returns the payments of a bill
+func (p *PostgresDB) GetBillPayments(ctx context.Context, userID, billID int64) ([]models.Payment, error) {
+	return sqlPayments{p.db, rebind, true}.getBillPayments(ctx, userID, billID)
+}
+
+// GetBillPayment returns a single payment of a bill
+func (p *PostgresDB) GetBillPayment(ctx context.Context, userID, billID, id int64) (*models.Payment, error) {
+	return sqlPayments{p.db, rebind, true}.getBillPayment(ctx, userID, billID, id)
+}
+
+// CreateBillPayment records a payment of a bill
+func (p *PostgresDB) CreateBillPayment(ctx context.Context, userID, billID int64, payment *models.PaymentInput) (int64, error) {
+	return sqlPayments{p.db, rebind, true}.createBillPayment(ctx, userID, billID, payment)
+}
+
+// UpdateBillPayment updates a payment of a bill
+func (p *PostgresDB) UpdateBillPayment(ctx context.Context, userID, billID, id int64, payment *models.PaymentInput) error {
+	return sqlPayments{p.db, rebind, true}.updateBillPayment(ctx, userID, billID, id, payment)
+}
+
+// DeleteBillPayment deletes a payment of a bill
+func (p *PostgresDB) DeleteBillPayment(ctx context.Context, userID, billID, id int64) error {
+	return sqlPayments{p.db, rebind, true}.deleteBillPayment(ctx, userID, billID, id)
+}
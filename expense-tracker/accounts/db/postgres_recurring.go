@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetRecurringBills returns the recurring bills of the user
+func (p *PostgresDB) GetRecurringBills(ctx context.Context, userID int64) ([]models.RecurringBill, error) {
+	return sqlRecurring{p.db, rebind, true}.getRecurringBills(ctx, userID)
+}
+
+// GetRecurringBill returns a single recurring bill
+func (p *PostgresDB) GetRecurringBill(ctx context.Context, userID, id int64) (*models.RecurringBill, error) {
+	return sqlRecurring{p.db, rebind, true}.getRecurringBill(ctx, userID, id)
+}
+
+// CreateRecurringBill creates a new recurring bill owned by the user
+func (p *PostgresDB) CreateRecurringBill(ctx context.Context, userID int64, recurring *models.RecurringBillInput) (int64, error) {
+	return sqlRecurring{p.db, rebind, true}.createRecurringBill(ctx, userID, recurring)
+}
+
+// UpdateRecurringBill updates a recurring bill of the user
+func (p *PostgresDB) UpdateRecurringBill(ctx context.Context, userID, id int64, recurring *models.RecurringBillInput) error {
+	return sqlRecurring{p.db, rebind, true}.updateRecurringBill(ctx, userID, id, recurring)
+}
+
+// DeleteRecurringBill deletes a recurring bill of the user, keeping its bills
+func (p *PostgresDB) DeleteRecurringBill(ctx context.Context, userID, id int64) error {
+	return sqlRecurring{p.db, rebind, true}.deleteRecurringBill(ctx, userID, id)
+}
+
+// SetRecurringBillPaused pauses or resumes a recurring bill of the user
+func (p *PostgresDB) SetRecurringBillPaused(ctx context.Context, userID, id int64, paused bool) error {
+	return sqlRecurring{p.db, rebind, true}.setRecurringBillPaused(ctx, userID, id, paused)
+}
+
+// SkipRecurringBill skips an upcoming occurrence of a recurring bill of the user
+func (p *PostgresDB) SkipRecurringBill(ctx context.Context, userID, id int64, date time.Time) error {
+	return sqlRecurring{p.db, rebind, true}.skipRecurringBill(ctx, userID, id, date)
+}
+
+// EndRecurringBill ends a recurring bill of the user on the date
+func (p *PostgresDB) EndRecurringBill(ctx context.Context, userID, id int64, date time.Time) error {
+	return sqlRecurring{p.db, rebind, true}.endRecurringBill(ctx, userID, id, date)
+}
+
+// GenerateRecurringBills generates the bills of all recurring bills through the date
+func (p *PostgresDB) GenerateRecurringBills(ctx context.Context, today, through time.Time) (int, error) {
+	return sqlRecurring{p.db, rebind, true}.generateRecurringBills(ctx, today, through)
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetLedgers returns the ledgers the user has a role on
+func (p *PostgresDB) GetLedgers(ctx context.Context, userID int64) ([]models.Ledger, error) {
+	return sqlSharing{p.db, rebind, true}.getLedgers(ctx, userID)
+}
+
+// GetLedger returns a single ledger
+func (p *PostgresDB) GetLedger(ctx context.Context, userID, id int64) (*models.Ledger, error) {
+	return sqlSharing{p.db, rebind, true}.getLedger(ctx, userID, id)
+}
+
+// CreateLedger creates a new ledger owned by the user
+func (p *PostgresDB) CreateLedger(ctx context.Context, userID int64, ledger *models.LedgerInput) (int64, error) {
+	return sqlSharing{p.db, rebind, true}.createLedger(ctx, userID, ledger)
+}
+
+// UpdateLedger renames a ledger
+func (p *PostgresDB) UpdateLedger(ctx context.Context, userID, id int64, ledger *models.LedgerInput) error {
+	return sqlSharing{p.db, rebind, true}.updateLedger(ctx, userID, id, ledger)
+}
+
+// DeleteLedger deletes a ledger, keeping its bills
+func (p *PostgresDB) DeleteLedger(ctx context.Context, userID, id int64) error {
+	return sqlSharing{p.db, rebind, true}.deleteLedger(ctx, userID, id)
+}
+
+// GetShares returns the shares of a bill or ledger
+func (p *PostgresDB) GetShares(ctx context.Context, userID int64, target models.ShareTarget) ([]models.Share, error) {
+	return sqlSharing{p.db, rebind, true}.getShares(ctx, userID, target)
+}
+
+// CreateShare shares a bill or ledger with a member in a role
+func (p *PostgresDB) CreateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error {
+	return sqlSharing{p.db, rebind, true}.createShare(ctx, userID, target, memberID, role)
+}
+
+// UpdateShare changes the role of a member of a bill or ledger
+func (p *PostgresDB) UpdateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error {
+	return sqlSharing{p.db, rebind, true}.updateShare(ctx, userID, target, memberID, role)
+}
+
+// DeleteShare revokes the share of a member of a bill or ledger
+func (p *PostgresDB) DeleteShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64) error {
+	return sqlSharing{p.db, rebind, true}.deleteShare(ctx, userID, target, memberID)
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetTags returns the tags of the user
+func (p *PostgresDB) GetTags(ctx context.Context, userID int64) ([]models.Tag, error) {
+	return sqlTags{p.db, rebind, true}.getTags(ctx, userID)
+}
+
+// GetTag returns a single tag
+func (p *PostgresDB) GetTag(ctx context.Context, userID, id int64) (*models.Tag, error) {
+	return sqlTags{p.db, rebind, true}.getTag(ctx, userID, id)
+}
+
+// CreateTag creates a new tag owned by the user
+func (p *PostgresDB) CreateTag(ctx context.Context, userID int64, tag *models.TagInput) (int64, error) {
+	return sqlTags{p.db, rebind, true}.createTag(ctx, userID, tag)
+}
+
+// UpdateTag renames a tag of the user
+func (p *PostgresDB) UpdateTag(ctx context.Context, userID, id int64, tag *models.TagInput) error {
+	return sqlTags{p.db, rebind, true}.updateTag(ctx, userID, id, tag)
+}
+
+// DeleteTag deletes a tag of the user, removing it from its bills and items
+func (p *PostgresDB) DeleteTag(ctx context.Context, userID, id int64) error {
+	return sqlTags{p.db, rebind, true}.deleteTag(ctx, userID, id)
+}
@@ -0,0 +1,41 @@
+package db_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jo/choreo-tutorial/accounts/config"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/db/dbtest"
+)
+
+// TestPostgresConformance runs against the PostgreSQL server configured with
+// TEST_POSTGRES_HOST, TEST_POSTGRES_PORT, TEST_POSTGRES_USER, TEST_POSTGRES_PASSWORD,
+// TEST_POSTGRES_NAME and TEST_POSTGRES_SSLMODE. The database is wiped by every test.
+func TestPostgresConformance(t *testing.T) {
+	host := os.Getenv("TEST_POSTGRES_HOST")
+	if host == "" {
+		t.Skip("TEST_POSTGRES_HOST not set")
+	}
+
+	cfg := &config.Config{
+		DBType:     "postgres",
+		DBHost:     host,
+		DBPort:     getTestEnv("TEST_POSTGRES_PORT", "5432"),
+		DBUser:     getTestEnv("TEST_POSTGRES_USER", "postgres"),
+		DBPassword: os.Getenv("TEST_POSTGRES_PASSWORD"),
+		DBName:     getTestEnv("TEST_POSTGRES_NAME", "accounts_test"),
+		DBSSLMode:  getTestEnv("TEST_POSTGRES_SSLMODE", "disable"),
+	}
+
+	dbtest.Run(t, func(t *testing.T) db.Database {
+		database, err := db.NewPostgresDB(cfg)
+		if err != nil {
+			t.Fatalf("opening PostgreSQL database: %v", err)
+		}
+		t.Cleanup(func() { database.Close() })
+
+		resetDB(t, database)
+		return database
+	})
+}
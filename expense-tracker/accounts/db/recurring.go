@@ -0,0 +1,461 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// sqlRecurring implements the recurring bill methods on a connection pool,
+// like sqlSharing. Users manage their own recurring bills; generating bills
+// covers the recurring bills of all users.
+type sqlRecurring struct {
+	db          *sql.DB
+	bind        func(string) string
+	returningID bool
+}
+
+const recurringBillColumns = `
+	SELECT r.id, r.owner_id, r.ledger_id, r.category_id, r.merchant_id, r.title, r.description,
+		r.amount_cents, r.currency, r.rule, r.start_date, r.end_date, r.paused, r.generated_through,
+		r.created_at, r.updated_at,
+		(SELECT COUNT(o.bill_id) FROM recurring_bill_occurrences o WHERE o.recurring_bill_id = r.id)
+	FROM recurring_bills r
+	WHERE `
+
+// getRecurringBills returns the recurring bills of the user by title
+func (s sqlRecurring) getRecurringBills(ctx context.Context, userID int64) ([]models.RecurringBill, error) {
+	return queryRecurringBills(ctx, s.db, s.bind, "r.owner_id = ?", userID)
+}
+
+// getRecurringBill returns a single recurring bill of the user
+func (s sqlRecurring) getRecurringBill(ctx context.Context, userID, id int64) (*models.RecurringBill, error) {
+	return findRecurringBill(ctx, s.db, s.bind, userID, id)
+}
+
+// createRecurringBill creates a recurring bill of the user
+func (s sqlRecurring) createRecurringBill(ctx context.Context, userID int64, input *models.RecurringBillInput) (int64, error) {
+	if _, _, err := parseRecurringBillDates(input); err != nil {
+		return 0, err
+	}
+	if err := checkRecurringBill(ctx, s.db, s.bind, userID, input); err != nil {
+		return 0, err
+	}
+	return s.insert(ctx, s.db, `
+	INSERT INTO recurring_bills (owner_id, ledger_id, category_id, merchant_id, title, description, amount_cents, currency, rule, start_date, end_date)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, input.LedgerID, input.CategoryID, input.MerchantID, input.Title, input.Description, input.Amount,
+		input.Currency, input.Rule, input.StartDate, nullString(input.EndDate))
+}
+
+// updateRecurringBill updates a recurring bill of the user. Occurrences that
+// were generated or skipped stay so.
+func (s sqlRecurring) updateRecurringBill(ctx context.Context, userID, id int64, input *models.RecurringBillInput) error {
+	if _, _, err := parseRecurringBillDates(input); err != nil {
+		return err
+	}
+	if _, err := findRecurringBill(ctx, s.db, s.bind, userID, id); err != nil {
+		return err
+	}
+	if err := checkRecurringBill(ctx, s.db, s.bind, userID, input); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.bind(`
+	UPDATE recurring_bills
+	SET ledger_id = ?, category_id = ?, merchant_id = ?, title = ?, description = ?, amount_cents = ?, currency = ?, rule = ?, start_date = ?, end_date = ?
+	WHERE id = ?
+	`), input.LedgerID, input.CategoryID, input.MerchantID, input.Title, input.Description, input.Amount,
+		input.Currency, input.Rule, input.StartDate, nullString(input.EndDate), id)
+	return translateError(err)
+}
+
+// deleteRecurringBill deletes a recurring bill of the user, keeping the
+// bills it generated
+func (s sqlRecurring) deleteRecurringBill(ctx context.Context, userID, id int64) error {
+	if _, err := findRecurringBill(ctx, s.db, s.bind, userID, id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.bind("DELETE FROM recurring_bills WHERE id = ?"), id)
+	return translateError(err)
+}
+
+// setRecurringBillPaused pauses or resumes a recurring bill of the user
+func (s sqlRecurring) setRecurringBillPaused(ctx context.Context, userID, id int64, paused bool) error {
+	if _, err := findRecurringBill(ctx, s.db, s.bind, userID, id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.bind("UPDATE recurring_bills SET paused = ? WHERE id = ?"), paused, id)
+	return translateError(err)
+}
+
+// skipRecurringBill skips an upcoming occurrence of a recurring bill of the
+// user, so that no bill is generated for it
+func (s sqlRecurring) skipRecurringBill(ctx context.Context, userID, id int64, date time.Time) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	recurring, err := findRecurringBill(ctx, tx, s.bind, userID, id)
+	if err != nil {
+		return err
+	}
+	if err = checkOccurrence(recurring, date); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind(`
+	INSERT INTO recurring_bill_occurrences (recurring_bill_id, occurrence_date, skipped) VALUES (?, ?, ?)
+	`), id, date.Format("2006-01-02"), true)
+	if err = translateError(err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// endRecurringBill ends a recurring bill of the user on the date, keeping
+// the bills it generated
+func (s sqlRecurring) endRecurringBill(ctx context.Context, userID, id int64, date time.Time) error {
+	recurring, err := findRecurringBill(ctx, s.db, s.bind, userID, id)
+	if err != nil {
+		return err
+	}
+	if date.Before(recurring.StartDate) {
+		return NewValidationError("end_date", "must not be before the start date")
+	}
+	_, err = s.db.ExecContext(ctx, s.bind("UPDATE recurring_bills SET end_date = ? WHERE id = ?"), date.Format("2006-01-02"), id)
+	return translateError(err)
+}
+
+// generateRecurringBills generates the bills of the pending occurrences of
+// all recurring bills through the date and returns how many. Each recurring
+// bill is generated in a transaction of its own; one that another generator
+// got to first fails with ErrConflict and is left to it. A recurring bill
+// that fails does not stop the others; the errors are returned together.
+func (s sqlRecurring) generateRecurringBills(ctx context.Context, today, through time.Time) (int, error) {
+	rows, err := s.db.QueryContext(ctx, s.bind(`
+	SELECT id FROM recurring_bills
+	WHERE end_date IS NULL OR generated_through IS NULL OR end_date > generated_through
+	ORDER BY id
+	`))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	generated := 0
+	var errs []error
+	for _, id := range ids {
+		count, err := s.generateRecurringBill(ctx, id, today, through)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return generated, err
+			}
+			errs = append(errs, fmt.Errorf("recurring bill %d: %w", id, err))
+			continue
+		}
+		generated += count
+	}
+	return generated, errors.Join(errs...)
+}
+
+// generateRecurringBill generates the bills of a recurring bill that are due
+// like recurringBillDue
+func (s sqlRecurring) generateRecurringBill(ctx context.Context, id int64, today, through time.Time) (generated int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Read the recurring bill again, in case it changed since it was listed
+	recurring, err := queryRecurringBills(ctx, tx, s.bind, "r.id = ?", id)
+	if err != nil {
+		return 0, err
+	}
+	if len(recurring) == 0 {
+		return 0, tx.Commit()
+	}
+	pending, dueThrough, ok := recurringBillDue(&recurring[0], today, through)
+	if !ok {
+		return 0, tx.Commit()
+	}
+
+	if len(pending) > 0 {
+		// Bills are only filed in the ledger while the owner may edit it
+		ledgerID := recurring[0].LedgerID
+		var verr *ValidationError
+		err = checkBillLedger(ctx, tx, s.bind, recurring[0].OwnerID, &models.BillInput{LedgerID: ledgerID})
+		if errors.As(err, &verr) {
+			ledgerID = nil
+		} else if err != nil {
+			return 0, err
+		}
+
+		for _, date := range pending {
+			billInput := recurring[0].BillInput(date)
+			var billID int64
+			billID, err = s.insert(ctx, tx, `
+			INSERT INTO bills (owner_id, ledger_id, category_id, merchant_id, title, description, total_cents, currency, due_date)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, recurring[0].OwnerID, ledgerID, billInput.CategoryID, billInput.MerchantID, billInput.Title, billInput.Description,
+				billInput.CalculateTotal(), billInput.Currency, billInput.DueDate)
+			if err != nil {
+				return 0, err
+			}
+			for _, item := range billInput.Items {
+				_, err = tx.ExecContext(ctx, s.bind(`
+				INSERT INTO bill_items (bill_id, name, description, amount_cents, quantity)
+				VALUES (?, ?, ?, ?, ?)
+				`), billID, item.Name, item.Description, item.Amount, item.Quantity)
+				if err = translateError(err); err != nil {
+					return 0, err
+				}
+			}
+			_, err = tx.ExecContext(ctx, s.bind(`
+			INSERT INTO recurring_bill_occurrences (recurring_bill_id, occurrence_date, bill_id) VALUES (?, ?, ?)
+			`), id, billInput.DueDate, billID)
+			if err = translateError(err); err != nil {
+				return 0, err
+			}
+		}
+		generated = len(pending)
+	}
+
+	_, err = tx.ExecContext(ctx, s.bind("UPDATE recurring_bills SET generated_through = ? WHERE id = ?"),
+		dueThrough.Format("2006-01-02"), id)
+	if err = translateError(err); err != nil {
+		return 0, err
+	}
+	return generated, tx.Commit()
+}
+
+// insert runs an INSERT statement and returns the ID of the inserted row
+func (s sqlRecurring) insert(ctx context.Context, q querier, query string, args ...interface{}) (int64, error) {
+	if s.returningID {
+		var id int64
+		err := q.QueryRowContext(ctx, s.bind(query+" RETURNING id"), args...).Scan(&id)
+		return id, translateError(err)
+	}
+	result, err := q.ExecContext(ctx, s.bind(query), args...)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
+
+// findRecurringBill returns a recurring bill of the user, or ErrNotFound
+func findRecurringBill(ctx context.Context, q querier, bind func(string) string, userID, id int64) (*models.RecurringBill, error) {
+	recurring, err := queryRecurringBills(ctx, q, bind, "r.owner_id = ? AND r.id = ?", userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(recurring) == 0 {
+		return nil, ErrNotFound
+	}
+	return &recurring[0], nil
+}
+
+// queryRecurringBills returns the recurring bills matching the condition on
+// recurring_bills r by title, with their upcoming skipped dates and next
+// dates
+func queryRecurringBills(ctx context.Context, q querier, bind func(string) string, where string, args ...interface{}) ([]models.RecurringBill, error) {
+	rows, err := q.QueryContext(ctx, bind(recurringBillColumns+where+`
+	ORDER BY r.title, r.id
+	`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recurring []models.RecurringBill
+	for rows.Next() {
+		bill, err := scanRecurringBill(rows)
+		if err != nil {
+			return nil, err
+		}
+		recurring = append(recurring, *bill)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	skipped, err := loadDates(ctx, q, bind(`
+	SELECT o.recurring_bill_id, o.occurrence_date
+	FROM recurring_bill_occurrences o
+	JOIN recurring_bills r ON r.id = o.recurring_bill_id
+	WHERE o.skipped = ? AND (r.generated_through IS NULL OR o.occurrence_date > r.generated_through) AND `+where+`
+	ORDER BY o.occurrence_date
+	`), append([]interface{}{true}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range recurring {
+		recurring[i].SkippedDates = skipped[recurring[i].ID]
+		if recurring[i].SkippedDates == nil {
+			recurring[i].SkippedDates = []time.Time{}
+		}
+		recurring[i].SetNextDate()
+	}
+	return recurring, nil
+}
+
+// loadDates returns the dates of a query of (id, date) rows by ID
+func loadDates(ctx context.Context, q querier, query string, args ...interface{}) (map[int64][]time.Time, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dates := make(map[int64][]time.Time)
+	for rows.Next() {
+		var id int64
+		var date time.Time
+		if err := rows.Scan(&id, &date); err != nil {
+			return nil, err
+		}
+		dates[id] = append(dates[id], date.UTC())
+	}
+	return dates, rows.Err()
+}
+
+// checkRecurringBill checks the ledger, category and merchant of a recurring
+// bill of the user like those of a bill: the user must be an editor of the
+// ledger, and the category and the merchant must be ones of the user
+func checkRecurringBill(ctx context.Context, q querier, bind func(string) string, userID int64, input *models.RecurringBillInput) error {
+	if err := checkBillLedger(ctx, q, bind, userID, &models.BillInput{LedgerID: input.LedgerID}); err != nil {
+		return err
+	}
+	verr := &ValidationError{}
+	if err := checkCategory(ctx, q, bind, userID, 0, input.CategoryID, "category_id", verr); err != nil {
+		return err
+	}
+	if input.MerchantID != nil {
+		err := findMerchant(ctx, q, bind, userID, *input.MerchantID)
+		if errors.Is(err, ErrNotFound) {
+			verr.Add("merchant_id", "must be a merchant of the user")
+		} else if err != nil {
+			return err
+		}
+	}
+	return verr.Err()
+}
+
+// parseRecurringBillDates parses the start date and the optional end date of
+// a recurring bill
+func parseRecurringBillDates(input *models.RecurringBillInput) (startDate time.Time, endDate *time.Time, err error) {
+	startDate, err = time.Parse("2006-01-02", input.StartDate)
+	if err != nil {
+		return time.Time{}, nil, NewValidationError("start_date", "must be a date in YYYY-MM-DD format")
+	}
+	if input.EndDate != "" {
+		parsed, err := time.Parse("2006-01-02", input.EndDate)
+		if err != nil {
+			return time.Time{}, nil, NewValidationError("end_date", "must be a date in YYYY-MM-DD format")
+		}
+		endDate = &parsed
+	}
+	return startDate, endDate, nil
+}
+
+// checkOccurrence checks that the date is an upcoming occurrence of the
+// recurring bill that is neither generated nor skipped
+func checkOccurrence(recurring *models.RecurringBill, date time.Time) error {
+	if !recurring.IsPending(date) {
+		return NewValidationError("date", "must be an upcoming occurrence of the recurring bill")
+	}
+	return nil
+}
+
+// recurringBillDue returns the occurrences of a recurring bill to generate
+// through the date and the date that its GeneratedThrough advances to. While
+// it is paused, the occurrences before today are passed over instead, and so
+// are they the first time it generates bills, so that a recurring bill that
+// started long ago does not generate all of its past bills. ok is false
+// unless an occurrence, pending or skipped, came due, so that recurring
+// bills only change with their occurrences.
+func recurringBillDue(recurring *models.RecurringBill, today, through time.Time) (pending []time.Time, dueThrough time.Time, ok bool) {
+	if recurring.Paused {
+		through = today.AddDate(0, 0, -1)
+	}
+	pending = recurring.Pending(through)
+	ok = len(pending) > 0 || (len(recurring.SkippedDates) > 0 && !recurring.SkippedDates[0].After(through))
+	if recurring.Paused {
+		pending = nil
+	}
+	if recurring.GeneratedThrough == nil {
+		for len(pending) > 0 && pending[0].Before(today) {
+			pending = pending[1:]
+		}
+	}
+	return pending, through, ok
+}
+
+// nullString returns nil for an empty string, so that it is stored as NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// scanRecurringBill scans a row of recurringBillColumns
+func scanRecurringBill(row interface{ Scan(...interface{}) error }) (*models.RecurringBill, error) {
+	var recurring models.RecurringBill
+	var ledgerID, categoryID, merchantID sql.NullInt64
+	var description sql.NullString
+	var endDate, generatedThrough sql.NullTime
+	err := row.Scan(&recurring.ID, &recurring.OwnerID, &ledgerID, &categoryID, &merchantID, &recurring.Title, &description,
+		&recurring.Amount, &recurring.Currency, &recurring.Rule, &recurring.StartDate, &endDate, &recurring.Paused, &generatedThrough,
+		&recurring.CreatedAt, &recurring.UpdatedAt, &recurring.BillCount)
+	if err != nil {
+		return nil, err
+	}
+	recurring.LedgerID = nullInt64(ledgerID)
+	recurring.CategoryID = nullInt64(categoryID)
+	recurring.MerchantID = nullInt64(merchantID)
+	recurring.Description = description.String
+	recurring.StartDate = recurring.StartDate.UTC()
+	recurring.EndDate = nullDate(endDate)
+	recurring.GeneratedThrough = nullDate(generatedThrough)
+	return &recurring, nil
+}
+
+// nullDate returns the date of a nullable DATE column in UTC, or nil
+func nullDate(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	date := v.Time.UTC()
+	return &date
+}
@@ -0,0 +1,289 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// sqlSharing implements the ledger and share methods on a connection pool.
+// The statements use ? placeholders and are passed through bind, e.g.
+// rebind for PostgreSQL. The driver of PostgreSQL cannot report the ID of
+// an inserted row, so returningID makes inserts ask for it with RETURNING.
+type sqlSharing struct {
+	db          *sql.DB
+	bind        func(string) string
+	returningID bool
+}
+
+const ledgerColumns = `
+	SELECT l.id, l.owner_id, l.name, l.created_at, l.updated_at, ls.role,
+		(SELECT COUNT(*) FROM bills b WHERE b.ledger_id = l.id)
+	FROM ledgers l
+	LEFT JOIN ledger_shares ls ON ls.ledger_id = l.id AND ls.user_id = ?
+	WHERE (l.owner_id = ? OR ls.user_id IS NOT NULL)`
+
+// getLedgers returns the ledgers the user owns or is a member of, by name
+func (s sqlSharing) getLedgers(ctx context.Context, userID int64) ([]models.Ledger, error) {
+	rows, err := s.db.QueryContext(ctx, s.bind(ledgerColumns+`
+	ORDER BY l.name, l.id
+	`), userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ledgers []models.Ledger
+	for rows.Next() {
+		ledger, err := scanLedger(rows, userID)
+		if err != nil {
+			return nil, err
+		}
+		ledgers = append(ledgers, *ledger)
+	}
+	return ledgers, rows.Err()
+}
+
+// getLedger returns a single ledger the user has a role on
+func (s sqlSharing) getLedger(ctx context.Context, userID, id int64) (*models.Ledger, error) {
+	ledger, err := scanLedger(s.db.QueryRowContext(ctx, s.bind(ledgerColumns+`
+	AND l.id = ?
+	`), userID, userID, id), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return ledger, err
+}
+
+// createLedger creates a ledger owned by the user
+func (s sqlSharing) createLedger(ctx context.Context, userID int64, input *models.LedgerInput) (int64, error) {
+	query := "INSERT INTO ledgers (owner_id, name) VALUES (?, ?)"
+	if s.returningID {
+		var id int64
+		err := s.db.QueryRowContext(ctx, s.bind(query+" RETURNING id"), userID, input.Name).Scan(&id)
+		return id, translateError(err)
+	}
+	result, err := s.db.ExecContext(ctx, s.bind(query), userID, input.Name)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
+
+// updateLedger renames a ledger; this requires the owner role
+func (s sqlSharing) updateLedger(ctx context.Context, userID, id int64, input *models.LedgerInput) error {
+	if _, err := requireLedgerRole(ctx, s.db, s.bind, userID, id, models.RoleOwner); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.bind("UPDATE ledgers SET name = ? WHERE id = ?"), input.Name, id)
+	return translateError(err)
+}
+
+// deleteLedger deletes a ledger and its shares; its bills are kept outside
+// of ledgers. This requires the owner role.
+func (s sqlSharing) deleteLedger(ctx context.Context, userID, id int64) error {
+	if _, err := requireLedgerRole(ctx, s.db, s.bind, userID, id, models.RoleOwner); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.bind("DELETE FROM ledgers WHERE id = ?"), id)
+	return translateError(err)
+}
+
+// getShares returns the shares of a bill or ledger the user has a role on
+func (s sqlSharing) getShares(ctx context.Context, userID int64, target models.ShareTarget) ([]models.Share, error) {
+	table, column, err := shareTable(target)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := targetRole(ctx, s.db, s.bind, userID, target, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.bind(`
+	SELECT s.user_id, u.subject, u.email, u.name, s.role, s.created_at, s.updated_at
+	FROM `+table+` s
+	JOIN users u ON u.id = s.user_id
+	WHERE s.`+column+` = ?
+	ORDER BY u.subject
+	`), target.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []models.Share
+	for rows.Next() {
+		var share models.Share
+		err := rows.Scan(&share.UserID, &share.Subject, &share.Email, &share.Name, &share.Role, &share.CreatedAt, &share.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// createShare shares a bill or ledger with a member; this requires the
+// owner role. The owner of the bill or ledger itself cannot be a member.
+func (s sqlSharing) createShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) (err error) {
+	table, column, err := shareTable(target)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = targetRole(ctx, tx, s.bind, userID, target, models.RoleOwner); err != nil {
+		return err
+	}
+	// The bills and ledgers tables are named after the share types
+	var ownerID int64
+	err = tx.QueryRowContext(ctx, s.bind("SELECT owner_id FROM "+target.Type+"s WHERE id = ?"), target.ID).Scan(&ownerID)
+	if err != nil {
+		return err
+	}
+	if ownerID == memberID {
+		err = NewValidationError("user", fmt.Sprintf("must not be the owner of the %s", target.Type))
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, s.bind(`
+	INSERT INTO `+table+` (`+column+`, user_id, role) VALUES (?, ?, ?)
+	`), target.ID, memberID, string(role))
+	if err = translateError(err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateShare changes the role of a member; this requires the owner role
+func (s sqlSharing) updateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) (err error) {
+	table, column, err := shareTable(target)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = targetRole(ctx, tx, s.bind, userID, target, models.RoleOwner); err != nil {
+		return err
+	}
+	// Check if the share exists; RowsAffected is not usable for this in MySQL
+	if err = s.findShare(ctx, tx, table, column, target.ID, memberID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind(`
+	UPDATE `+table+` SET role = ? WHERE `+column+` = ? AND user_id = ?
+	`), string(role), target.ID, memberID)
+	if err = translateError(err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteShare revokes the share of a member. Owners revoke any share;
+// members may revoke their own to leave the bill or ledger.
+func (s sqlSharing) deleteShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64) (err error) {
+	table, column, err := shareTable(target)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	required := models.RoleOwner
+	if memberID == userID {
+		required = models.RoleViewer
+	}
+	if _, err = targetRole(ctx, tx, s.bind, userID, target, required); err != nil {
+		return err
+	}
+	if err = s.findShare(ctx, tx, table, column, target.ID, memberID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind(`
+	DELETE FROM `+table+` WHERE `+column+` = ? AND user_id = ?
+	`), target.ID, memberID)
+	if err = translateError(err); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// findShare returns ErrNotFound if the member has no share of the target
+func (s sqlSharing) findShare(ctx context.Context, q querier, table, column string, targetID, memberID int64) error {
+	var exists int
+	err := q.QueryRowContext(ctx, s.bind(`
+	SELECT COUNT(*) FROM `+table+` WHERE `+column+` = ? AND user_id = ?
+	`), targetID, memberID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// targetRole returns the role of the user on the bill or ledger of a share
+// if it includes the required one
+func targetRole(ctx context.Context, q querier, bind func(string) string, userID int64, target models.ShareTarget, required models.Role) (models.Role, error) {
+	if target.Type == models.ShareTypeLedger {
+		return requireLedgerRole(ctx, q, bind, userID, target.ID, required)
+	}
+	return requireBillRole(ctx, q, bind, userID, target.ID, required)
+}
+
+// shareTable returns the table of the shares of the target and its column
+// referring to the bill or ledger
+func shareTable(target models.ShareTarget) (table, column string, err error) {
+	switch target.Type {
+	case models.ShareTypeBill:
+		return "bill_shares", "bill_id", nil
+	case models.ShareTypeLedger:
+		return "ledger_shares", "ledger_id", nil
+	}
+	return "", "", fmt.Errorf("unknown share type %q", target.Type)
+}
+
+// scanLedger scans a row of ledgerColumns for the requesting user
+func scanLedger(row interface{ Scan(...interface{}) error }, userID int64) (*models.Ledger, error) {
+	var ledger models.Ledger
+	var share sql.NullString
+	err := row.Scan(&ledger.ID, &ledger.OwnerID, &ledger.Name, &ledger.CreatedAt, &ledger.UpdatedAt, &share, &ledger.BillCount)
+	if err != nil {
+		return nil, err
+	}
+	ledger.Role = models.Role(share.String)
+	if ledger.OwnerID == userID {
+		ledger.Role = models.RoleOwner
+	}
+	return &ledger, nil
+}
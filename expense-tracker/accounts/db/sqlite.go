@@ -1,24 +1,39 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jo/choreo-tutorial/accounts/config"
+	"github.com/jo/choreo-tutorial/accounts/migrations"
 	"github.com/jo/choreo-tutorial/accounts/models"
 	_ "github.com/mattn/go-sqlite3"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 // SQLiteDB implements the Database interface for SQLite
 type SQLiteDB struct {
-	db *sql.DB
+	db       *sql.DB
+	migrator *migrations.Migrator
 }
 
 // NewSQLiteDB creates a new SQLite database connection
 func NewSQLiteDB(cfg *config.Config) (*SQLiteDB, error) {
-	db, err := sql.Open("sqlite3", cfg.DBPath)
+	// Wait for locks held by other connections, e.g. a concurrent migration,
+	// and enforce foreign keys on every connection of the pool. Transactions
+	// take the write lock when they begin; a deferred transaction that reads
+	// first cannot wait for the lock when it later writes and fails instead.
+	dsn := cfg.DBPath
+	if strings.Contains(dsn, "?") {
+		dsn += "&_busy_timeout=5000&_foreign_keys=on&_txlock=immediate"
+	} else {
+		dsn += "?_busy_timeout=5000&_foreign_keys=on&_txlock=immediate"
+	}
+
+	db, err := openDB("sqlite3", dsn, semconv.DBSystemSqlite)
 	if err != nil {
 		return nil, err
 	}
@@ -29,75 +44,33 @@ func NewSQLiteDB(cfg *config.Config) (*SQLiteDB, error) {
 		return nil, err
 	}
 
-	// Enable foreign keys
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
+	migrator, err := migrations.New(db, "sqlite")
 	if err != nil {
 		return nil, err
 	}
 
-	return &SQLiteDB{db: db}, nil
+	return &SQLiteDB{db: db, migrator: migrator}, nil
 }
 
-// CreateTables creates the necessary tables if they don't exist
-func (s *SQLiteDB) CreateTables() error {
-	// Create bills table
-	_, err := s.db.Exec(`
-	CREATE TABLE IF NOT EXISTS bills (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL,
-		description TEXT,
-		total REAL NOT NULL DEFAULT 0,
-		due_date DATE,
-		paid INTEGER NOT NULL DEFAULT 0,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-	)
-	`)
-	if err != nil {
-		return err
-	}
+// Migrate applies all pending schema migrations
+func (s *SQLiteDB) Migrate(ctx context.Context) error {
+	_, err := s.migrator.Up(ctx)
+	return err
+}
 
-	// Create bill_items table
-	_, err = s.db.Exec(`
-	CREATE TABLE IF NOT EXISTS bill_items (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		bill_id INTEGER NOT NULL,
-		name TEXT NOT NULL,
-		description TEXT,
-		amount REAL NOT NULL,
-		quantity INTEGER NOT NULL DEFAULT 1,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (bill_id) REFERENCES bills(id) ON DELETE CASCADE
-	)
-	`)
-	if err != nil {
-		return err
-	}
+// Migrator returns the schema migrator of the database
+func (s *SQLiteDB) Migrator() *migrations.Migrator {
+	return s.migrator
+}
 
-	// Create update trigger for bills
-	_, err = s.db.Exec(`
-	CREATE TRIGGER IF NOT EXISTS bills_update_trigger
-	AFTER UPDATE ON bills
-	FOR EACH ROW
-	BEGIN
-		UPDATE bills SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
-	END;
-	`)
-	if err != nil {
-		return err
-	}
+// DB returns the underlying connection pool
+func (s *SQLiteDB) DB() *sql.DB {
+	return s.db
+}
 
-	// Create update trigger for bill_items
-	_, err = s.db.Exec(`
-	CREATE TRIGGER IF NOT EXISTS bill_items_update_trigger
-	AFTER UPDATE ON bill_items
-	FOR EACH ROW
-	BEGIN
-		UPDATE bill_items SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
-	END;
-	`)
-	return err
+// Ping checks that the database is reachable
+func (s *SQLiteDB) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
 }
 
 // Close closes the database connection
@@ -105,74 +78,113 @@ func (s *SQLiteDB) Close() error {
 	return s.db.Close()
 }
 
-// GetBills returns all bills with summary information
-func (s *SQLiteDB) GetBills() ([]models.BillSummary, error) {
-	rows, err := s.db.Query(`
-	SELECT b.id, b.title, b.description, b.total, b.due_date, b.paid, b.created_at, b.updated_at, COUNT(i.id) as item_count
+// GetBills returns the bills matching the query with summary information,
+// together with the total number of matching bills
+func (s *SQLiteDB) GetBills(ctx context.Context, userID int64, query *models.BillQuery) ([]models.BillSummary, int, error) {
+	where, args := buildBillFilter(userID, query)
+
+	// Count all matching bills
+	var total int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM bills b "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	listQuery := `
+	SELECT b.id, b.ledger_id, b.category_id, b.merchant_id, b.title, b.description, b.total_cents, b.currency, b.due_date, b.paid, ` + billAmountPaid + `, b.created_at, b.updated_at, COUNT(i.id) as item_count
 	FROM bills b
 	LEFT JOIN bill_items i ON b.id = i.bill_id
+	` + where + `
 	GROUP BY b.id
-	ORDER BY b.due_date ASC
-	`)
+	` + buildBillOrder(query)
+	if query.Limit > 0 {
+		listQuery += " LIMIT ? OFFSET ?"
+		args = append(args, query.Limit, query.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, listQuery, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var bills []models.BillSummary
 	for rows.Next() {
 		var bill models.BillSummary
-		var dueDate sql.NullString
+		var ledgerID, categoryID, merchantID sql.NullInt64
+		var dueDate sql.NullTime
 		var paid int
 
 		err := rows.Scan(
 			&bill.ID,
+			&ledgerID,
+			&categoryID,
+			&merchantID,
 			&bill.Title,
 			&bill.Description,
 			&bill.Total,
+			&bill.Currency,
 			&dueDate,
 			&paid,
+			&bill.AmountPaid,
 			&bill.CreatedAt,
 			&bill.UpdatedAt,
 			&bill.ItemCount,
 		)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
+		bill.LedgerID = nullInt64(ledgerID)
+		bill.CategoryID = nullInt64(categoryID)
+		bill.MerchantID = nullInt64(merchantID)
 		bill.Paid = paid == 1
 
-		if dueDate.Valid && dueDate.String != "" {
-			parsedDate, err := time.Parse("2006-01-02", dueDate.String)
-			if err == nil {
-				bill.DueDate = parsedDate
-			}
+		if dueDate.Valid {
+			bill.DueDate = dueDate.Time
 		}
 
+		bill.SetPaymentStatus()
 		bills = append(bills, bill)
 	}
 
-	return bills, nil
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return bills, total, nil
 }
 
 // GetBill returns a single bill with all its items
-func (s *SQLiteDB) GetBill(id int64) (*models.Bill, error) {
+func (s *SQLiteDB) GetBill(ctx context.Context, userID, id int64) (*models.Bill, error) {
+	role, err := billRole(ctx, s.db, noBind, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the bill
-	var bill models.Bill
-	var dueDate sql.NullString
+	bill := models.Bill{Role: role}
+	var ledgerID, categoryID, merchantID sql.NullInt64
+	var dueDate sql.NullTime
 	var paid int
 
-	err := s.db.QueryRow(`
-	SELECT id, title, description, total, due_date, paid, created_at, updated_at
-	FROM bills
+	err = s.db.QueryRowContext(ctx, `
+	SELECT id, owner_id, ledger_id, category_id, merchant_id, title, description, total_cents, currency, due_date, paid, `+billAmountPaid+`, created_at, updated_at
+	FROM bills b
 	WHERE id = ?
 	`, id).Scan(
 		&bill.ID,
+		&bill.OwnerID,
+		&ledgerID,
+		&categoryID,
+		&merchantID,
 		&bill.Title,
 		&bill.Description,
 		&bill.Total,
+		&bill.Currency,
 		&dueDate,
 		&paid,
+		&bill.AmountPaid,
 		&bill.CreatedAt,
 		&bill.UpdatedAt,
 	)
@@ -183,29 +195,53 @@ func (s *SQLiteDB) GetBill(id int64) (*models.Bill, error) {
 		return nil, err
 	}
 
+	bill.LedgerID = nullInt64(ledgerID)
+	bill.CategoryID = nullInt64(categoryID)
+	bill.MerchantID = nullInt64(merchantID)
 	bill.Paid = paid == 1
 
-	if dueDate.Valid && dueDate.String != "" {
-		parsedDate, err := time.Parse("2006-01-02", dueDate.String)
-		if err == nil {
-			bill.DueDate = parsedDate
-		}
+	if dueDate.Valid {
+		bill.DueDate = dueDate.Time
 	}
 
 	// Get the bill items
-	items, err := s.GetBillItems(id)
+	items, err := s.GetBillItems(ctx, userID, id)
 	if err != nil {
 		return nil, err
 	}
 	bill.Items = items
 
+	// Get the tags of the bill
+	bill.Tags, err = billTags(ctx, s.db, noBind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the payments of the bill
+	bill.Payments, err = billPayments(ctx, s.db, noBind, id)
+	if err != nil {
+		return nil, err
+	}
+	bill.SetPaymentStatus()
+
 	return &bill, nil
 }
 
 // CreateBill creates a new bill and its items
-func (s *SQLiteDB) CreateBill(billInput *models.BillInput) (int64, error) {
+func (s *SQLiteDB) CreateBill(ctx context.Context, userID int64, billInput *models.BillInput) (int64, error) {
+	// Parse due date
+	var dueDate *string
+	if billInput.DueDate != "" {
+		// Validate date format
+		_, err := time.Parse("2006-01-02", billInput.DueDate)
+		if err != nil {
+			return 0, NewValidationError("due_date", "must be a date in YYYY-MM-DD format")
+		}
+		dueDate = &billInput.DueDate
+	}
+
 	// Start a transaction
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -215,22 +251,20 @@ func (s *SQLiteDB) CreateBill(billInput *models.BillInput) (int64, error) {
 		}
 	}()
 
-	// Parse due date
-	var dueDate *string
-	if billInput.DueDate != "" {
-		// Validate date format
-		_, err := time.Parse("2006-01-02", billInput.DueDate)
-		if err != nil {
-			return 0, fmt.Errorf("invalid due date format: %v", err)
-		}
-		dueDate = &billInput.DueDate
+	// Check the ledger to file the bill in, the categories and the merchant
+	if err = checkBillLedger(ctx, tx, noBind, userID, billInput); err != nil {
+		return 0, err
+	}
+	if err = checkBillCategories(ctx, tx, noBind, userID, 0, billInput); err != nil {
+		return 0, err
+	}
+	merchantID, categoryID, err := billMerchant(ctx, tx, noBind, userID, 0, billInput)
+	if err != nil {
+		return 0, err
 	}
 
 	// Calculate total from items
-	var total float64
-	for _, item := range billInput.Items {
-		total += item.Amount * float64(item.Quantity)
-	}
+	total := billInput.CalculateTotal()
 
 	// SQLite uses integers for boolean (0=false, 1=true)
 	paidInt := 0
@@ -239,12 +273,12 @@ func (s *SQLiteDB) CreateBill(billInput *models.BillInput) (int64, error) {
 	}
 
 	// Insert bill
-	result, err := tx.Exec(`
-	INSERT INTO bills (title, description, total, due_date, paid)
-	VALUES (?, ?, ?, ?, ?)
-	`, billInput.Title, billInput.Description, total, dueDate, paidInt)
+	result, err := tx.ExecContext(ctx, `
+	INSERT INTO bills (owner_id, ledger_id, category_id, merchant_id, title, description, total_cents, currency, due_date, paid)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, billInput.LedgerID, categoryID, merchantID, billInput.Title, billInput.Description, total, billInput.Currency, dueDate, paidInt)
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 
 	// Get the bill ID
@@ -253,13 +287,24 @@ func (s *SQLiteDB) CreateBill(billInput *models.BillInput) (int64, error) {
 		return 0, err
 	}
 
-	// Insert bill items
+	// Set the tags and insert the bill items
+	if err = setBillTags(ctx, tx, noBind, billID, billInput.Tags); err != nil {
+		return 0, err
+	}
 	for _, item := range billInput.Items {
-		_, err = tx.Exec(`
-		INSERT INTO bill_items (bill_id, name, description, amount, quantity)
-		VALUES (?, ?, ?, ?, ?)
-		`, billID, item.Name, item.Description, item.Amount, item.Quantity)
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, `
+		INSERT INTO bill_items (bill_id, category_id, name, description, amount_cents, quantity)
+		VALUES (?, ?, ?, ?, ?, ?)
+		`, billID, item.CategoryID, item.Name, item.Description, item.Amount, item.Quantity)
 		if err != nil {
+			return 0, translateError(err)
+		}
+		var itemID int64
+		if itemID, err = result.LastInsertId(); err != nil {
+			return 0, err
+		}
+		if err = setItemTags(ctx, tx, noBind, billID, itemID, item.Tags); err != nil {
 			return 0, err
 		}
 	}
@@ -270,9 +315,20 @@ func (s *SQLiteDB) CreateBill(billInput *models.BillInput) (int64, error) {
 }
 
 // UpdateBill updates an existing bill and its items
-func (s *SQLiteDB) UpdateBill(id int64, billInput *models.BillInput) error {
+func (s *SQLiteDB) UpdateBill(ctx context.Context, userID, id int64, billInput *models.BillInput) error {
+	// Parse due date
+	var dueDate *string
+	if billInput.DueDate != "" {
+		// Validate date format
+		_, err := time.Parse("2006-01-02", billInput.DueDate)
+		if err != nil {
+			return NewValidationError("due_date", "must be a date in YYYY-MM-DD format")
+		}
+		dueDate = &billInput.DueDate
+	}
+
 	// Start a transaction
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -282,22 +338,21 @@ func (s *SQLiteDB) UpdateBill(id int64, billInput *models.BillInput) error {
 		}
 	}()
 
-	// Parse due date
-	var dueDate *string
-	if billInput.DueDate != "" {
-		// Validate date format
-		_, err := time.Parse("2006-01-02", billInput.DueDate)
-		if err != nil {
-			return fmt.Errorf("invalid due date format: %v", err)
-		}
-		dueDate = &billInput.DueDate
+	// Check the role of the user, the ledger to file the bill in, the categories
+	// and the merchant
+	if err = checkBillUpdate(ctx, tx, noBind, userID, id, billInput); err != nil {
+		return err
+	}
+	if err = checkBillCategories(ctx, tx, noBind, userID, id, billInput); err != nil {
+		return err
+	}
+	merchantID, categoryID, err := billMerchant(ctx, tx, noBind, userID, id, billInput)
+	if err != nil {
+		return err
 	}
 
 	// Calculate total from items
-	var total float64
-	for _, item := range billInput.Items {
-		total += item.Amount * float64(item.Quantity)
-	}
+	total := billInput.CalculateTotal()
 
 	// SQLite uses integers for boolean (0=false, 1=true)
 	paidInt := 0
@@ -306,49 +361,113 @@ func (s *SQLiteDB) UpdateBill(id int64, billInput *models.BillInput) error {
 	}
 
 	// Update bill
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bills
-	SET title = ?, description = ?, total = ?, due_date = ?, paid = ?
+	SET ledger_id = ?, category_id = ?, merchant_id = ?, title = ?, description = ?, total_cents = ?, currency = ?, due_date = ?, paid = ?
 	WHERE id = ?
-	`, billInput.Title, billInput.Description, total, dueDate, paidInt, id)
+	`, billInput.LedgerID, categoryID, merchantID, billInput.Title, billInput.Description, total, billInput.Currency, dueDate, paidInt, id)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	// Delete existing items
-	_, err = tx.Exec("DELETE FROM bill_items WHERE bill_id = ?", id)
+	_, err = tx.ExecContext(ctx, "DELETE FROM bill_items WHERE bill_id = ?", id)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
-	// Insert new items
+	// Set the tags and insert the new items
+	if err = setBillTags(ctx, tx, noBind, id, billInput.Tags); err != nil {
+		return err
+	}
 	for _, item := range billInput.Items {
-		_, err = tx.Exec(`
-		INSERT INTO bill_items (bill_id, name, description, amount, quantity)
-		VALUES (?, ?, ?, ?, ?)
-		`, id, item.Name, item.Description, item.Amount, item.Quantity)
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, `
+		INSERT INTO bill_items (bill_id, category_id, name, description, amount_cents, quantity)
+		VALUES (?, ?, ?, ?, ?, ?)
+		`, id, item.CategoryID, item.Name, item.Description, item.Amount, item.Quantity)
 		if err != nil {
+			return translateError(err)
+		}
+		var itemID int64
+		if itemID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		if err = setItemTags(ctx, tx, noBind, id, itemID, item.Tags); err != nil {
 			return err
 		}
 	}
 
+	// Bills with payments are paid once they cover the total; updating
+	// them as paid pays the balance
+	if err = (sqlPayments{s.db, noBind, false}).settleBill(ctx, tx, id, billInput.Paid); err != nil {
+		return err
+	}
+	if err = syncBillPaid(ctx, tx, noBind, id); err != nil {
+		return err
+	}
+
 	// Commit the transaction
 	return tx.Commit()
 }
 
+// SetBillPaid marks a bill as paid or unpaid
+func (s *SQLiteDB) SetBillPaid(ctx context.Context, userID, id int64, paid bool) error {
+	return sqlPayments{s.db, noBind, false}.setBillPaid(ctx, userID, id, paid)
+}
+
 // DeleteBill deletes a bill and its items
-func (s *SQLiteDB) DeleteBill(id int64) error {
-	_, err := s.db.Exec("DELETE FROM bills WHERE id = ?", id)
-	return err
+func (s *SQLiteDB) DeleteBill(ctx context.Context, userID, id int64) error {
+	if _, err := requireBillRole(ctx, s.db, noBind, userID, id, models.RoleOwner); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM bills WHERE id = ?", id)
+	return translateError(err)
+}
+
+// GetBillTotals returns the totals of the bills matching the query grouped by currency
+func (s *SQLiteDB) GetBillTotals(ctx context.Context, userID int64, query *models.BillQuery) ([]models.CurrencyTotal, error) {
+	where, args := buildBillFilter(userID, query)
+
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT b.currency,
+		COALESCE(SUM(b.total_cents), 0),
+		COALESCE(SUM(CASE WHEN b.paid THEN b.total_cents ELSE `+billAmountPaid+` END), 0),
+		COUNT(*)
+	FROM bills b
+	`+where+`
+	GROUP BY b.currency
+	ORDER BY b.currency
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []models.CurrencyTotal
+	for rows.Next() {
+		var total models.CurrencyTotal
+		err := rows.Scan(&total.Currency, &total.Total, &total.PaidTotal, &total.BillCount)
+		if err != nil {
+			return nil, err
+		}
+		total.UnpaidTotal = total.Total - total.PaidTotal
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
 }
 
 // GetBillItems returns all items for a bill
-func (s *SQLiteDB) GetBillItems(billID int64) ([]models.BillItem, error) {
-	rows, err := s.db.Query(`
-	SELECT id, bill_id, name, description, amount, quantity, created_at, updated_at
-	FROM bill_items
-	WHERE bill_id = ?
-	`, billID)
+func (s *SQLiteDB) GetBillItems(ctx context.Context, userID, billID int64) ([]models.BillItem, error) {
+	access, args := billAccessCondition(userID)
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT i.id, i.bill_id, i.category_id, i.name, i.description, i.amount_cents, i.quantity, i.created_at, i.updated_at
+	FROM bill_items i
+	JOIN bills b ON b.id = i.bill_id
+	WHERE i.bill_id = ? AND `+access+`
+	`, append([]interface{}{billID}, args...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -357,9 +476,11 @@ func (s *SQLiteDB) GetBillItems(billID int64) ([]models.BillItem, error) {
 	var items []models.BillItem
 	for rows.Next() {
 		var item models.BillItem
+		var categoryID sql.NullInt64
 		err := rows.Scan(
 			&item.ID,
 			&item.BillID,
+			&categoryID,
 			&item.Name,
 			&item.Description,
 			&item.Amount,
@@ -370,22 +491,38 @@ func (s *SQLiteDB) GetBillItems(billID int64) ([]models.BillItem, error) {
 		if err != nil {
 			return nil, err
 		}
+		item.CategoryID = nullInt64(categoryID)
 		items = append(items, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Get the tags of the items
+	tags, err := billItemTags(ctx, s.db, noBind, billID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Tags = tagNames(tags[items[i].ID])
+	}
 
 	return items, nil
 }
 
 // GetBillItem returns a single bill item
-func (s *SQLiteDB) GetBillItem(id int64) (*models.BillItem, error) {
+func (s *SQLiteDB) GetBillItem(ctx context.Context, userID, id int64) (*models.BillItem, error) {
 	var item models.BillItem
-	err := s.db.QueryRow(`
-	SELECT id, bill_id, name, description, amount, quantity, created_at, updated_at
+	var categoryID sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+	SELECT id, bill_id, category_id, name, description, amount_cents, quantity, created_at, updated_at
 	FROM bill_items
 	WHERE id = ?
 	`, id).Scan(
 		&item.ID,
 		&item.BillID,
+		&categoryID,
 		&item.Name,
 		&item.Description,
 		&item.Amount,
@@ -399,13 +536,27 @@ func (s *SQLiteDB) GetBillItem(id int64) (*models.BillItem, error) {
 		}
 		return nil, err
 	}
+
+	item.CategoryID = nullInt64(categoryID)
+
+	// Items of bills the user has no role on do not exist for them
+	if _, err := billRole(ctx, s.db, noBind, userID, item.BillID); err != nil {
+		return nil, err
+	}
+
+	// Get the tags of the item
+	tags, err := billItemTags(ctx, s.db, noBind, item.BillID)
+	if err != nil {
+		return nil, err
+	}
+	item.Tags = tagNames(tags[item.ID])
 	return &item, nil
 }
 
 // CreateBillItem creates a new bill item
-func (s *SQLiteDB) CreateBillItem(billID int64, itemInput *models.BillItemInput) (int64, error) {
+func (s *SQLiteDB) CreateBillItem(ctx context.Context, userID, billID int64, itemInput *models.BillItemInput) (int64, error) {
 	// Start a transaction
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -415,13 +566,21 @@ func (s *SQLiteDB) CreateBillItem(billID int64, itemInput *models.BillItemInput)
 		}
 	}()
 
+	// Check if the bill exists and the user may edit it
+	if _, err = requireBillRole(ctx, tx, noBind, userID, billID, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	if err = checkItemCategory(ctx, tx, noBind, userID, billID, itemInput); err != nil {
+		return 0, err
+	}
+
 	// Insert item
-	result, err := tx.Exec(`
-	INSERT INTO bill_items (bill_id, name, description, amount, quantity)
-	VALUES (?, ?, ?, ?, ?)
-	`, billID, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity)
+	result, err := tx.ExecContext(ctx, `
+	INSERT INTO bill_items (bill_id, category_id, name, description, amount_cents, quantity)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`, billID, itemInput.CategoryID, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity)
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 
 	// Get the item ID
@@ -430,13 +589,21 @@ func (s *SQLiteDB) CreateBillItem(billID int64, itemInput *models.BillItemInput)
 		return 0, err
 	}
 
-	// Update bill total
-	_, err = tx.Exec(`
+	// Tag the item
+	if err = setItemTags(ctx, tx, noBind, billID, itemID, itemInput.Tags); err != nil {
+		return 0, err
+	}
+
+	// Update bill total and whether it is paid
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bills
-	SET total = (SELECT SUM(amount * quantity) FROM bill_items WHERE bill_id = ?)
+	SET total_cents = (SELECT COALESCE(SUM(amount_cents * quantity), 0) FROM bill_items WHERE bill_id = ?)
 	WHERE id = ?
 	`, billID, billID)
 	if err != nil {
+		return 0, translateError(err)
+	}
+	if err = syncBillPaid(ctx, tx, noBind, billID); err != nil {
 		return 0, err
 	}
 
@@ -446,9 +613,9 @@ func (s *SQLiteDB) CreateBillItem(billID int64, itemInput *models.BillItemInput)
 }
 
 // UpdateBillItem updates an existing bill item
-func (s *SQLiteDB) UpdateBillItem(id int64, itemInput *models.BillItemInput) error {
+func (s *SQLiteDB) UpdateBillItem(ctx context.Context, userID, id int64, itemInput *models.BillItemInput) error {
 	// Start a transaction
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -458,30 +625,40 @@ func (s *SQLiteDB) UpdateBillItem(id int64, itemInput *models.BillItemInput) err
 		}
 	}()
 
-	// Get the bill ID
-	var billID int64
-	err = tx.QueryRow("SELECT bill_id FROM bill_items WHERE id = ?", id).Scan(&billID)
+	// Get the bill ID and check that the user may edit the bill
+	billID, err := itemBillID(ctx, tx, noBind, userID, id)
 	if err != nil {
 		return err
 	}
+	if err = checkItemCategory(ctx, tx, noBind, userID, billID, itemInput); err != nil {
+		return err
+	}
 
 	// Update item
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bill_items
-	SET name = ?, description = ?, amount = ?, quantity = ?
+	SET category_id = ?, name = ?, description = ?, amount_cents = ?, quantity = ?
 	WHERE id = ?
-	`, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity, id)
+	`, itemInput.CategoryID, itemInput.Name, itemInput.Description, itemInput.Amount, itemInput.Quantity, id)
 	if err != nil {
+		return translateError(err)
+	}
+
+	// Replace the tags of the item
+	if err = setItemTags(ctx, tx, noBind, billID, id, itemInput.Tags); err != nil {
 		return err
 	}
 
-	// Update bill total
-	_, err = tx.Exec(`
+	// Update bill total and whether it is paid
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bills
-	SET total = (SELECT SUM(amount * quantity) FROM bill_items WHERE bill_id = ?)
+	SET total_cents = (SELECT COALESCE(SUM(amount_cents * quantity), 0) FROM bill_items WHERE bill_id = ?)
 	WHERE id = ?
 	`, billID, billID)
 	if err != nil {
+		return translateError(err)
+	}
+	if err = syncBillPaid(ctx, tx, noBind, billID); err != nil {
 		return err
 	}
 
@@ -490,9 +667,9 @@ func (s *SQLiteDB) UpdateBillItem(id int64, itemInput *models.BillItemInput) err
 }
 
 // DeleteBillItem deletes a bill item
-func (s *SQLiteDB) DeleteBillItem(id int64) error {
+func (s *SQLiteDB) DeleteBillItem(ctx context.Context, userID, id int64) error {
 	// Start a transaction
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -502,26 +679,28 @@ func (s *SQLiteDB) DeleteBillItem(id int64) error {
 		}
 	}()
 
-	// Get the bill ID
-	var billID int64
-	err = tx.QueryRow("SELECT bill_id FROM bill_items WHERE id = ?", id).Scan(&billID)
+	// Get the bill ID and check that the user may edit the bill
+	billID, err := itemBillID(ctx, tx, noBind, userID, id)
 	if err != nil {
 		return err
 	}
 
 	// Delete item
-	_, err = tx.Exec("DELETE FROM bill_items WHERE id = ?", id)
+	_, err = tx.ExecContext(ctx, "DELETE FROM bill_items WHERE id = ?", id)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
-	// Update bill total
-	_, err = tx.Exec(`
+	// Update bill total and whether it is paid
+	_, err = tx.ExecContext(ctx, `
 	UPDATE bills
-	SET total = (SELECT SUM(amount * quantity) FROM bill_items WHERE bill_id = ?)
+	SET total_cents = (SELECT COALESCE(SUM(amount_cents * quantity), 0) FROM bill_items WHERE bill_id = ?)
 	WHERE id = ?
 	`, billID, billID)
 	if err != nil {
+		return translateError(err)
+	}
+	if err = syncBillPaid(ctx, tx, noBind, billID); err != nil {
 		return err
 	}
 
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetAPIKeys returns all API keys, including revoked ones
+func (s *SQLiteDB) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return sqlAPIKeys{s.db, noBind}.getAPIKeys(ctx)
+}
+
+// GetAPIKey returns a single API key
+func (s *SQLiteDB) GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	return sqlAPIKeys{s.db, noBind}.findAPIKey(ctx, "id = ?", id)
+}
+
+// FindAPIKey returns the API key with the hash, including revoked keys
+func (s *SQLiteDB) FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	return sqlAPIKeys{s.db, noBind}.findAPIKey(ctx, "key_hash = ?", hash)
+}
+
+// CreateAPIKey stores a new API key
+func (s *SQLiteDB) CreateAPIKey(ctx context.Context, key *models.APIKey) (int64, error) {
+	return sqlAPIKeys{s.db, noBind}.createAPIKey(ctx, key)
+}
+
+// RevokeAPIKey marks an API key as revoked
+func (s *SQLiteDB) RevokeAPIKey(ctx context.Context, id int64) error {
+	return sqlAPIKeys{s.db, noBind}.revokeAPIKey(ctx, id)
+}
+
+// TouchAPIKey records that an API key was used now
+func (s *SQLiteDB) TouchAPIKey(ctx context.Context, id int64) error {
+	return sqlAPIKeys{s.db, noBind}.touchAPIKey(ctx, id)
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetCategories returns the default categories and those of the user
+func (s *SQLiteDB) GetCategories(ctx context.Context, userID int64) ([]models.Category, error) {
+	return sqlCategories{s.db, noBind, false}.getCategories(ctx, userID)
+}
+
+// GetCategory returns a single category
+func (s *SQLiteDB) GetCategory(ctx context.Context, userID, id int64) (*models.Category, error) {
+	return sqlCategories{s.db, noBind, false}.getCategory(ctx, userID, id)
+}
+
+// CreateCategory creates a new category owned by the user
+func (s *SQLiteDB) CreateCategory(ctx context.Context, userID int64, category *models.CategoryInput) (int64, error) {
+	return sqlCategories{s.db, noBind, false}.createCategory(ctx, userID, category)
+}
+
+// UpdateCategory renames and moves a category of the user
+func (s *SQLiteDB) UpdateCategory(ctx context.Context, userID, id int64, category *models.CategoryInput) error {
+	return sqlCategories{s.db, noBind, false}.updateCategory(ctx, userID, id, category)
+}
+
+// DeleteCategory deletes a category of the user and its subcategories
+func (s *SQLiteDB) DeleteCategory(ctx context.Context, userID, id int64) error {
+	return sqlCategories{s.db, noBind, false}.deleteCategory(ctx, userID, id)
+}
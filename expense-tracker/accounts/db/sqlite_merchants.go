@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetMerchants returns the merchants of the user
+func (s *SQLiteDB) GetMerchants(ctx context.Context, userID int64) ([]models.Merchant, error) {
+	return sqlMerchants{s.db, noBind, false}.getMerchants(ctx, userID)
+}
+
+// GetMerchant returns a single merchant
+func (s *SQLiteDB) GetMerchant(ctx context.Context, userID, id int64) (*models.Merchant, error) {
+	return sqlMerchants{s.db, noBind, false}.getMerchant(ctx, userID, id)
+}
+
+// CreateMerchant creates a new merchant owned by the user
+func (s *SQLiteDB) CreateMerchant(ctx context.Context, userID int64, merchant *models.MerchantInput) (int64, error) {
+	return sqlMerchants{s.db, noBind, false}.createMerchant(ctx, userID, merchant)
+}
+
+// UpdateMerchant updates a merchant of the user
+func (s *SQLiteDB) UpdateMerchant(ctx context.Context, userID, id int64, merchant *models.MerchantInput) error {
+	return sqlMerchants{s.db, noBind, false}.updateMerchant(ctx, userID, id, merchant)
+}
+
+// DeleteMerchant deletes a merchant of the user, keeping its bills
+func (s *SQLiteDB) DeleteMerchant(ctx context.Context, userID, id int64) error {
+	return sqlMerchants{s.db, noBind, false}.deleteMerchant(ctx, userID, id)
+}
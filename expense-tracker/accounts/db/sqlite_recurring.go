@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetRecurringBills returns the recurring bills of the user
+func (s *SQLiteDB) GetRecurringBills(ctx context.Context, userID int64) ([]models.RecurringBill, error) {
+	return sqlRecurring{s.db, noBind, false}.getRecurringBills(ctx, userID)
+}
+
+// GetRecurringBill returns a single recurring bill
+func (s *SQLiteDB) GetRecurringBill(ctx context.Context, userID, id int64) (*models.RecurringBill, error) {
+	return sqlRecurring{s.db, noBind, false}.getRecurringBill(ctx, userID, id)
+}
+
+// CreateRecurringBill creates a new recurring bill owned by the user
+func (s *SQLiteDB) CreateRecurringBill(ctx context.Context, userID int64, recurring *models.RecurringBillInput) (int64, error) {
+	return sqlRecurring{s.db, noBind, false}.createRecurringBill(ctx, userID, recurring)
+}
+
+// UpdateRecurringBill updates a recurring bill of the user
+func (s *SQLiteDB) UpdateRecurringBill(ctx context.Context, userID, id int64, recurring *models.RecurringBillInput) error {
+	return sqlRecurring{s.db, noBind, false}.updateRecurringBill(ctx, userID, id, recurring)
+}
+
+// DeleteRecurringBill deletes a recurring bill of the user, keeping its bills
+func (s *SQLiteDB) DeleteRecurringBill(ctx context.Context, userID, id int64) error {
+	return sqlRecurring{s.db, noBind, false}.deleteRecurringBill(ctx, userID, id)
+}
+
+// SetRecurringBillPaused pauses or resumes a recurring bill of the user
+func (s *SQLiteDB) SetRecurringBillPaused(ctx context.Context, userID, id int64, paused bool) error {
+	return sqlRecurring{s.db, noBind, false}.setRecurringBillPaused(ctx, userID, id, paused)
+}
+
+// SkipRecurringBill skips an upcoming occurrence of a recurring bill of the user
+func (s *SQLiteDB) SkipRecurringBill(ctx context.Context, userID, id int64, date time.Time) error {
+	return sqlRecurring{s.db, noBind, false}.skipRecurringBill(ctx, userID, id, date)
+}
+
+// EndRecurringBill ends a recurring bill of the user on the date
+func (s *SQLiteDB) EndRecurringBill(ctx context.Context, userID, id int64, date time.Time) error {
+	return sqlRecurring{s.db, noBind, false}.endRecurringBill(ctx, userID, id, date)
+}
+
+// GenerateRecurringBills generates the bills of all recurring bills through the date
+func (s *SQLiteDB) GenerateRecurringBills(ctx context.Context, today, through time.Time) (int, error) {
+	return sqlRecurring{s.db, noBind, false}.generateRecurringBills(ctx, today, through)
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetLedgers returns the ledgers the user has a role on
+func (s *SQLiteDB) GetLedgers(ctx context.Context, userID int64) ([]models.Ledger, error) {
+	return sqlSharing{s.db, noBind, false}.getLedgers(ctx, userID)
+}
+
+// GetLedger returns a single ledger
+func (s *SQLiteDB) GetLedger(ctx context.Context, userID, id int64) (*models.Ledger, error) {
+	return sqlSharing{s.db, noBind, false}.getLedger(ctx, userID, id)
+}
+
+// CreateLedger creates a new ledger owned by the user
+func (s *SQLiteDB) CreateLedger(ctx context.Context, userID int64, ledger *models.LedgerInput) (int64, error) {
+	return sqlSharing{s.db, noBind, false}.createLedger(ctx, userID, ledger)
+}
+
+// UpdateLedger renames a ledger
+func (s *SQLiteDB) UpdateLedger(ctx context.Context, userID, id int64, ledger *models.LedgerInput) error {
+	return sqlSharing{s.db, noBind, false}.updateLedger(ctx, userID, id, ledger)
+}
+
+// DeleteLedger deletes a ledger, keeping its bills
+func (s *SQLiteDB) DeleteLedger(ctx context.Context, userID, id int64) error {
+	return sqlSharing{s.db, noBind, false}.deleteLedger(ctx, userID, id)
+}
+
+// GetShares returns the shares of a bill or ledger
+func (s *SQLiteDB) GetShares(ctx context.Context, userID int64, target models.ShareTarget) ([]models.Share, error) {
+	return sqlSharing{s.db, noBind, false}.getShares(ctx, userID, target)
+}
+
+// CreateShare shares a bill or ledger with a member in a role
+func (s *SQLiteDB) CreateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error {
+	return sqlSharing{s.db, noBind, false}.createShare(ctx, userID, target, memberID, role)
+}
+
+// UpdateShare changes the role of a member of a bill or ledger
+func (s *SQLiteDB) UpdateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error {
+	return sqlSharing{s.db, noBind, false}.updateShare(ctx, userID, target, memberID, role)
+}
+
+// DeleteShare revokes the share of a member of a bill or ledger
+func (s *SQLiteDB) DeleteShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64) error {
+	return sqlSharing{s.db, noBind, false}.deleteShare(ctx, userID, target, memberID)
+}
@@ -0,0 +1,112 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jo/choreo-tutorial/accounts/config"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/db/dbtest"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+func TestSQLiteConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Database {
+		database, err := db.NewSQLiteDB(&config.Config{
+			DBType: "sqlite",
+			DBPath: filepath.Join(t.TempDir(), "accounts.db"),
+		})
+		if err != nil {
+			t.Fatalf("opening SQLite database: %v", err)
+		}
+		t.Cleanup(func() { database.Close() })
+
+		if err := database.Migrate(context.Background()); err != nil {
+			t.Fatalf("applying migrations: %v", err)
+		}
+		return database
+	})
+}
+
+// TestSQLiteInputChecksMigration checks that rebuilding the tables to add
+// CHECK constraints keeps the data and the cascading foreign key
+func TestSQLiteInputChecksMigration(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.NewSQLiteDB(&config.Config{
+		DBType: "sqlite",
+		DBPath: filepath.Join(t.TempDir(), "accounts.db"),
+	})
+	if err != nil {
+		t.Fatalf("opening SQLite database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	owner, err := database.EnsureUser(ctx, &models.UserInput{Subject: "alice"})
+	if err != nil {
+		t.Fatalf("EnsureUser: %v", err)
+	}
+	id, err := database.CreateBill(ctx, owner.ID, &models.BillInput{
+		Title:    "Groceries",
+		Currency: "EUR",
+		DueDate:  "2024-03-15",
+		Items:    []models.BillItemInput{{Name: "Milk", Amount: 199, Quantity: 2}},
+	})
+	if err != nil {
+		t.Fatalf("CreateBill: %v", err)
+	}
+	before, err := database.GetBill(ctx, owner.ID, id)
+	if err != nil {
+		t.Fatalf("GetBill: %v", err)
+	}
+
+	// Revert the migrations from the input checks, the fourth, on and reapply them
+	if _, err := database.Migrator().Down(ctx, len(database.Migrator().Migrations())-3); err != nil {
+		t.Fatalf("reverting migrations: %v", err)
+	}
+	if _, err := database.DB().ExecContext(ctx, `
+	INSERT INTO bill_items (bill_id, name, amount_cents, quantity) VALUES (?, 'Refund', -100, 1)
+	`, id); err != nil {
+		t.Errorf("inserting a negative amount without constraints: %v", err)
+	}
+	applied, err := database.Migrator().Up(ctx)
+	if err == nil {
+		t.Fatal("applying the migration over a negative amount succeeded, want an error")
+	}
+	// The whole run is rolled back, so nothing is reported as applied
+	pending, perr := database.Migrator().Pending(ctx)
+	if len(applied) != 0 || perr != nil || pending != len(database.Migrator().Migrations())-3 {
+		t.Errorf("failed run applied %d migrations with %d (%v) pending, want none applied", len(applied), pending, perr)
+	}
+	if _, err := database.DB().ExecContext(ctx, `DELETE FROM bill_items WHERE amount_cents < 0`); err != nil {
+		t.Fatalf("deleting the negative amount: %v", err)
+	}
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	// Bills that existed before users were introduced belong to the legacy user
+	legacy, err := database.EnsureUser(ctx, &models.UserInput{Subject: "legacy"})
+	if err != nil {
+		t.Fatalf("EnsureUser(legacy): %v", err)
+	}
+	after, err := database.GetBill(ctx, legacy.ID, id)
+	if err != nil {
+		t.Fatalf("GetBill after migration: %v", err)
+	}
+	if after.Title != before.Title || after.Total != before.Total || after.Currency != before.Currency ||
+		!after.DueDate.Equal(before.DueDate) || len(after.Items) != 1 || after.Items[0].ID != before.Items[0].ID {
+		t.Errorf("bill after migration = %+v, want %+v", after, before)
+	}
+
+	if err := database.DeleteBill(ctx, legacy.ID, id); err != nil {
+		t.Fatalf("DeleteBill: %v", err)
+	}
+	if _, err := database.GetBillItem(ctx, legacy.ID, before.Items[0].ID); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetBillItem after deleting its bill: err = %v, want ErrNotFound", err)
+	}
+}
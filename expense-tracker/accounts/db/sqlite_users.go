@@ -0,0 +1,27 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// EnsureUser returns the user with the subject of the input, creating it on first use
+func (s *SQLiteDB) EnsureUser(ctx context.Context, input *models.UserInput) (*models.User, error) {
+	return sqlUsers{s.db, noBind}.ensureUser(ctx, input)
+}
+
+// GetUser returns a single user
+func (s *SQLiteDB) GetUser(ctx context.Context, id int64) (*models.User, error) {
+	return sqlUsers{s.db, noBind}.findUser(ctx, "id = ?", id)
+}
+
+// FindUserBySubject returns the user with the subject without creating it
+func (s *SQLiteDB) FindUserBySubject(ctx context.Context, subject string) (*models.User, error) {
+	return sqlUsers{s.db, noBind}.findUser(ctx, "subject = ?", subject)
+}
+
+// FindUserByEmail returns the only user with the verified email
+func (s *SQLiteDB) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return sqlUsers{s.db, noBind}.findUserByVerifiedEmail(ctx, email)
+}
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// sqlTags implements the tag methods on a connection pool, like sqlSharing.
+// Users manage their own tags; tags on shared bills belong to the owner of
+// the bill.
+type sqlTags struct {
+	db          *sql.DB
+	bind        func(string) string
+	returningID bool
+}
+
+const tagColumns = `
+	SELECT t.id, t.owner_id, t.name, t.created_at, t.updated_at,
+		(SELECT COUNT(*) FROM bills b
+		WHERE EXISTS (SELECT 1 FROM bill_tags bt WHERE bt.bill_id = b.id AND bt.tag_id = t.id)
+			OR EXISTS (SELECT 1 FROM bill_items bi JOIN bill_item_tags it ON it.item_id = bi.id WHERE bi.bill_id = b.id AND it.tag_id = t.id))
+	FROM tags t
+	WHERE t.owner_id = ?`
+
+// getTags returns the tags of the user by name
+func (s sqlTags) getTags(ctx context.Context, userID int64) ([]models.Tag, error) {
+	rows, err := s.db.QueryContext(ctx, s.bind(tagColumns+`
+	ORDER BY t.name
+	`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, rows.Err()
+}
+
+// getTag returns a single tag of the user
+func (s sqlTags) getTag(ctx context.Context, userID, id int64) (*models.Tag, error) {
+	tag, err := scanTag(s.db.QueryRowContext(ctx, s.bind(tagColumns+`
+	AND t.id = ?
+	`), userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return tag, err
+}
+
+// createTag creates a tag of the user; names are unique per user
+func (s sqlTags) createTag(ctx context.Context, userID int64, input *models.TagInput) (int64, error) {
+	query := "INSERT INTO tags (owner_id, name) VALUES (?, ?)"
+	if s.returningID {
+		var id int64
+		err := s.db.QueryRowContext(ctx, s.bind(query+" RETURNING id"), userID, input.Name).Scan(&id)
+		return id, translateError(err)
+	}
+	result, err := s.db.ExecContext(ctx, s.bind(query), userID, input.Name)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
+
+// updateTag renames a tag of the user, on all of its bills and items
+func (s sqlTags) updateTag(ctx context.Context, userID, id int64, input *models.TagInput) error {
+	// Check if the tag exists; RowsAffected is not usable for this in MySQL
+	if err := s.findTag(ctx, userID, id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.bind("UPDATE tags SET name = ? WHERE id = ?"), input.Name, id)
+	return translateError(err)
+}
+
+// deleteTag deletes a tag of the user and removes it from its bills and items
+func (s sqlTags) deleteTag(ctx context.Context, userID, id int64) error {
+	if err := s.findTag(ctx, userID, id); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.bind("DELETE FROM tags WHERE id = ?"), id)
+	return translateError(err)
+}
+
+// findTag returns ErrNotFound unless the user owns the tag
+func (s sqlTags) findTag(ctx context.Context, userID, id int64) error {
+	var exists int
+	err := s.db.QueryRowContext(ctx, s.bind(`
+	SELECT COUNT(*) FROM tags WHERE id = ? AND owner_id = ?
+	`), id, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// setBillTags replaces the tags of the bill with the named tags of its owner,
+// creating missing ones
+func setBillTags(ctx context.Context, q querier, bind func(string) string, billID int64, names []string) error {
+	if _, err := q.ExecContext(ctx, bind("DELETE FROM bill_tags WHERE bill_id = ?"), billID); err != nil {
+		return translateError(err)
+	}
+	for _, name := range names {
+		tagID, err := ensureTag(ctx, q, bind, billID, name)
+		if err != nil {
+			return err
+		}
+		_, err = q.ExecContext(ctx, bind("INSERT INTO bill_tags (bill_id, tag_id) VALUES (?, ?)"), billID, tagID)
+		if err != nil {
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+// setItemTags replaces the tags of an item of the bill like setBillTags
+func setItemTags(ctx context.Context, q querier, bind func(string) string, billID, itemID int64, names []string) error {
+	if _, err := q.ExecContext(ctx, bind("DELETE FROM bill_item_tags WHERE item_id = ?"), itemID); err != nil {
+		return translateError(err)
+	}
+	for _, name := range names {
+		tagID, err := ensureTag(ctx, q, bind, billID, name)
+		if err != nil {
+			return err
+		}
+		_, err = q.ExecContext(ctx, bind("INSERT INTO bill_item_tags (item_id, tag_id) VALUES (?, ?)"), itemID, tagID)
+		if err != nil {
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+// ensureTag returns the ID of the tag with the name of the owner of the
+// bill, creating it if needed. Selecting it again after the insert works
+// for all drivers, unlike LastInsertId.
+func ensureTag(ctx context.Context, q querier, bind func(string) string, billID int64, name string) (int64, error) {
+	query := bind(`
+	SELECT t.id FROM tags t JOIN bills b ON b.owner_id = t.owner_id
+	WHERE b.id = ? AND t.name = ?
+	`)
+	var id int64
+	err := q.QueryRowContext(ctx, query, billID, name).Scan(&id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return id, err
+	}
+
+	_, err = q.ExecContext(ctx, bind("INSERT INTO tags (owner_id, name) SELECT owner_id, ? FROM bills WHERE id = ?"), name, billID)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	err = q.QueryRowContext(ctx, query, billID, name).Scan(&id)
+	return id, err
+}
+
+// billTags returns the tag names of the bill by name
+func billTags(ctx context.Context, q querier, bind func(string) string, billID int64) ([]string, error) {
+	tags, err := loadNames(ctx, q, bind(`
+	SELECT bt.bill_id, t.name
+	FROM bill_tags bt
+	JOIN tags t ON t.id = bt.tag_id
+	WHERE bt.bill_id = ?
+	ORDER BY t.name
+	`), billID)
+	if err != nil {
+		return nil, err
+	}
+	return tagNames(tags[billID]), nil
+}
+
+// billItemTags returns the tag names of the items of the bill by item ID
+func billItemTags(ctx context.Context, q querier, bind func(string) string, billID int64) (map[int64][]string, error) {
+	return loadNames(ctx, q, bind(`
+	SELECT it.item_id, t.name
+	FROM bill_item_tags it
+	JOIN tags t ON t.id = it.tag_id
+	JOIN bill_items bi ON bi.id = it.item_id
+	WHERE bi.bill_id = ?
+	ORDER BY t.name
+	`), billID)
+}
+
+// loadNames returns the names of a query selecting IDs and names by ID, such
+// as tags or aliases
+func loadNames(ctx context.Context, q querier, query string, args ...interface{}) (map[int64][]string, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], name)
+	}
+	return tags, rows.Err()
+}
+
+// tagNames returns the names, or an empty list instead of nil so that
+// untagged bills and items have "tags": [] in JSON
+func tagNames(names []string) []string {
+	if names == nil {
+		return []string{}
+	}
+	return names
+}
+
+// tagCondition returns a condition on the bills table "b" that holds for
+// the bills with any or, with matchAll, all of the tags on them or on one of
+// their items, with its arguments. The names must be distinct.
+func tagCondition(names []string, matchAll bool) (string, []interface{}) {
+	args := make([]interface{}, 0, len(names)+1)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	required := 1
+	if matchAll {
+		required = len(names)
+	}
+	args = append(args, required)
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	return `(SELECT COUNT(DISTINCT t.name) FROM tags t
+		WHERE t.name IN (` + placeholders + `)
+			AND (EXISTS (SELECT 1 FROM bill_tags bt WHERE bt.bill_id = b.id AND bt.tag_id = t.id)
+				OR EXISTS (SELECT 1 FROM bill_items bi JOIN bill_item_tags it ON it.item_id = bi.id WHERE bi.bill_id = b.id AND it.tag_id = t.id))) >= ?`,
+		args
+}
+
+// scanTag scans a row of tagColumns
+func scanTag(row interface{ Scan(...interface{}) error }) (*models.Tag, error) {
+	var tag models.Tag
+	err := row.Scan(&tag.ID, &tag.OwnerID, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt, &tag.BillCount)
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
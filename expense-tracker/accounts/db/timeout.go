@@ -0,0 +1,562 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// timeoutDB wraps a Database and bounds every operation by a query timeout
+type timeoutDB struct {
+	db      Database
+	timeout time.Duration
+}
+
+// WithQueryTimeout returns a Database that runs every operation of database
+// with a deadline of at most timeout; a timeout of zero or less sets no
+// deadline of its own. Migrate is not bounded, since migrations may take
+// much longer than a query.
+//
+// When an operation fails after its context is done, the error wraps
+// context.DeadlineExceeded or context.Canceled, whatever the driver reported,
+// so callers can detect timeouts and cancellations with errors.Is.
+func WithQueryTimeout(database Database, timeout time.Duration) Database {
+	return &timeoutDB{db: database, timeout: timeout}
+}
+
+// withTimeout derives the context for a single operation
+func (t *timeoutDB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, t.timeout)
+}
+
+// contextError makes an error caused by a done context match the context error
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctx.Err(), err)
+}
+
+// GetBills returns the bills matching the query with summary information
+func (t *timeoutDB) GetBills(ctx context.Context, userID int64, query *models.BillQuery) ([]models.BillSummary, int, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	bills, total, err := t.db.GetBills(ctx, userID, query)
+	return bills, total, contextError(ctx, err)
+}
+
+// GetBill returns a single bill with all its items
+func (t *timeoutDB) GetBill(ctx context.Context, userID, id int64) (*models.Bill, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	bill, err := t.db.GetBill(ctx, userID, id)
+	return bill, contextError(ctx, err)
+}
+
+// CreateBill creates a new bill and its items
+func (t *timeoutDB) CreateBill(ctx context.Context, userID int64, bill *models.BillInput) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateBill(ctx, userID, bill)
+	return id, contextError(ctx, err)
+}
+
+// UpdateBill updates an existing bill and its items
+func (t *timeoutDB) UpdateBill(ctx context.Context, userID, id int64, bill *models.BillInput) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateBill(ctx, userID, id, bill))
+}
+
+// SetBillPaid marks a bill as paid or unpaid
+func (t *timeoutDB) SetBillPaid(ctx context.Context, userID, id int64, paid bool) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.SetBillPaid(ctx, userID, id, paid))
+}
+
+// DeleteBill deletes a bill and its items
+func (t *timeoutDB) DeleteBill(ctx context.Context, userID, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteBill(ctx, userID, id))
+}
+
+// GetBillTotals returns the totals of the bills matching the query grouped by currency
+func (t *timeoutDB) GetBillTotals(ctx context.Context, userID int64, query *models.BillQuery) ([]models.CurrencyTotal, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	totals, err := t.db.GetBillTotals(ctx, userID, query)
+	return totals, contextError(ctx, err)
+}
+
+// GetBillItems returns all items for a bill
+func (t *timeoutDB) GetBillItems(ctx context.Context, userID, billID int64) ([]models.BillItem, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	items, err := t.db.GetBillItems(ctx, userID, billID)
+	return items, contextError(ctx, err)
+}
+
+// GetBillItem returns a single bill item
+func (t *timeoutDB) GetBillItem(ctx context.Context, userID, id int64) (*models.BillItem, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	item, err := t.db.GetBillItem(ctx, userID, id)
+	return item, contextError(ctx, err)
+}
+
+// CreateBillItem creates a new bill item
+func (t *timeoutDB) CreateBillItem(ctx context.Context, userID, billID int64, item *models.BillItemInput) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateBillItem(ctx, userID, billID, item)
+	return id, contextError(ctx, err)
+}
+
+// UpdateBillItem updates an existing bill item
+func (t *timeoutDB) UpdateBillItem(ctx context.Context, userID, id int64, item *models.BillItemInput) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateBillItem(ctx, userID, id, item))
+}
+
+// DeleteBillItem deletes a bill item
+func (t *timeoutDB) DeleteBillItem(ctx context.Context, userID, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteBillItem(ctx, userID, id))
+}
+
+// GetBillPayments returns the payments of a bill
+func (t *timeoutDB) GetBillPayments(ctx context.Context, userID, billID int64) ([]models.Payment, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	payments, err := t.db.GetBillPayments(ctx, userID, billID)
+	return payments, contextError(ctx, err)
+}
+
+// GetBillPayment returns a single payment of a bill
+func (t *timeoutDB) GetBillPayment(ctx context.Context, userID, billID, id int64) (*models.Payment, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	payment, err := t.db.GetBillPayment(ctx, userID, billID, id)
+	return payment, contextError(ctx, err)
+}
+
+// CreateBillPayment records a payment of a bill
+func (t *timeoutDB) CreateBillPayment(ctx context.Context, userID, billID int64, payment *models.PaymentInput) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateBillPayment(ctx, userID, billID, payment)
+	return id, contextError(ctx, err)
+}
+
+// UpdateBillPayment updates a payment of a bill
+func (t *timeoutDB) UpdateBillPayment(ctx context.Context, userID, billID, id int64, payment *models.PaymentInput) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateBillPayment(ctx, userID, billID, id, payment))
+}
+
+// DeleteBillPayment deletes a payment of a bill
+func (t *timeoutDB) DeleteBillPayment(ctx context.Context, userID, billID, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteBillPayment(ctx, userID, billID, id))
+}
+
+// GetLedgers returns the ledgers the user has a role on
+func (t *timeoutDB) GetLedgers(ctx context.Context, userID int64) ([]models.Ledger, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	ledgers, err := t.db.GetLedgers(ctx, userID)
+	return ledgers, contextError(ctx, err)
+}
+
+// GetLedger returns a single ledger
+func (t *timeoutDB) GetLedger(ctx context.Context, userID, id int64) (*models.Ledger, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	ledger, err := t.db.GetLedger(ctx, userID, id)
+	return ledger, contextError(ctx, err)
+}
+
+// CreateLedger creates a new ledger owned by the user
+func (t *timeoutDB) CreateLedger(ctx context.Context, userID int64, ledger *models.LedgerInput) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateLedger(ctx, userID, ledger)
+	return id, contextError(ctx, err)
+}
+
+// UpdateLedger renames a ledger
+func (t *timeoutDB) UpdateLedger(ctx context.Context, userID, id int64, ledger *models.LedgerInput) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateLedger(ctx, userID, id, ledger))
+}
+
+// DeleteLedger deletes a ledger, keeping its bills
+func (t *timeoutDB) DeleteLedger(ctx context.Context, userID, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteLedger(ctx, userID, id))
+}
+
+// GetShares returns the shares of a bill or ledger
+func (t *timeoutDB) GetShares(ctx context.Context, userID int64, target models.ShareTarget) ([]models.Share, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	shares, err := t.db.GetShares(ctx, userID, target)
+	return shares, contextError(ctx, err)
+}
+
+// CreateShare shares a bill or ledger with a member in a role
+func (t *timeoutDB) CreateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.CreateShare(ctx, userID, target, memberID, role))
+}
+
+// UpdateShare changes the role of a member of a bill or ledger
+func (t *timeoutDB) UpdateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateShare(ctx, userID, target, memberID, role))
+}
+
+// DeleteShare revokes the share of a member of a bill or ledger
+func (t *timeoutDB) DeleteShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteShare(ctx, userID, target, memberID))
+}
+
+// EnsureUser returns the user with the subject of the input, creating it on first use
+func (t *timeoutDB) EnsureUser(ctx context.Context, user *models.UserInput) (*models.User, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	stored, err := t.db.EnsureUser(ctx, user)
+	return stored, contextError(ctx, err)
+}
+
+// GetUser returns a single user
+func (t *timeoutDB) GetUser(ctx context.Context, id int64) (*models.User, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	user, err := t.db.GetUser(ctx, id)
+	return user, contextError(ctx, err)
+}
+
+// FindUserBySubject returns the user with the subject without creating it
+func (t *timeoutDB) FindUserBySubject(ctx context.Context, subject string) (*models.User, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	user, err := t.db.FindUserBySubject(ctx, subject)
+	return user, contextError(ctx, err)
+}
+
+// FindUserByEmail returns the only user with the verified email
+func (t *timeoutDB) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	user, err := t.db.FindUserByEmail(ctx, email)
+	return user, contextError(ctx, err)
+}
+
+// GetAPIKeys returns all API keys, including revoked ones
+func (t *timeoutDB) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	keys, err := t.db.GetAPIKeys(ctx)
+	return keys, contextError(ctx, err)
+}
+
+// GetAPIKey returns a single API key
+func (t *timeoutDB) GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	key, err := t.db.GetAPIKey(ctx, id)
+	return key, contextError(ctx, err)
+}
+
+// FindAPIKey returns the API key with the hash, including revoked keys
+func (t *timeoutDB) FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	key, err := t.db.FindAPIKey(ctx, hash)
+	return key, contextError(ctx, err)
+}
+
+// CreateAPIKey stores a new API key
+func (t *timeoutDB) CreateAPIKey(ctx context.Context, key *models.APIKey) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateAPIKey(ctx, key)
+	return id, contextError(ctx, err)
+}
+
+// RevokeAPIKey marks an API key as revoked
+func (t *timeoutDB) RevokeAPIKey(ctx context.Context, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.RevokeAPIKey(ctx, id))
+}
+
+// TouchAPIKey records that an API key was used now
+func (t *timeoutDB) TouchAPIKey(ctx context.Context, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.TouchAPIKey(ctx, id))
+}
+
+// GetCategories returns the default categories and those of the user
+func (t *timeoutDB) GetCategories(ctx context.Context, userID int64) ([]models.Category, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	categories, err := t.db.GetCategories(ctx, userID)
+	return categories, contextError(ctx, err)
+}
+
+// GetCategory returns a single category
+func (t *timeoutDB) GetCategory(ctx context.Context, userID, id int64) (*models.Category, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	category, err := t.db.GetCategory(ctx, userID, id)
+	return category, contextError(ctx, err)
+}
+
+// CreateCategory creates a new category owned by the user
+func (t *timeoutDB) CreateCategory(ctx context.Context, userID int64, category *models.CategoryInput) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateCategory(ctx, userID, category)
+	return id, contextError(ctx, err)
+}
+
+// UpdateCategory renames and moves a category of the user
+func (t *timeoutDB) UpdateCategory(ctx context.Context, userID, id int64, category *models.CategoryInput) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateCategory(ctx, userID, id, category))
+}
+
+// DeleteCategory deletes a category of the user and its subcategories
+func (t *timeoutDB) DeleteCategory(ctx context.Context, userID, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteCategory(ctx, userID, id))
+}
+
+// GetTags returns the tags of the user
+func (t *timeoutDB) GetTags(ctx context.Context, userID int64) ([]models.Tag, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	tags, err := t.db.GetTags(ctx, userID)
+	return tags, contextError(ctx, err)
+}
+
+// GetTag returns a single tag
+func (t *timeoutDB) GetTag(ctx context.Context, userID, id int64) (*models.Tag, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	tag, err := t.db.GetTag(ctx, userID, id)
+	return tag, contextError(ctx, err)
+}
+
+// CreateTag creates a new tag owned by the user
+func (t *timeoutDB) CreateTag(ctx context.Context, userID int64, tag *models.TagInput) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateTag(ctx, userID, tag)
+	return id, contextError(ctx, err)
+}
+
+// UpdateTag renames a tag of the user
+func (t *timeoutDB) UpdateTag(ctx context.Context, userID, id int64, tag *models.TagInput) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateTag(ctx, userID, id, tag))
+}
+
+// DeleteTag deletes a tag of the user, removing it from its bills and items
+func (t *timeoutDB) DeleteTag(ctx context.Context, userID, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteTag(ctx, userID, id))
+}
+
+// GetMerchants returns the merchants of the user
+func (t *timeoutDB) GetMerchants(ctx context.Context, userID int64) ([]models.Merchant, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	merchants, err := t.db.GetMerchants(ctx, userID)
+	return merchants, contextError(ctx, err)
+}
+
+// GetMerchant returns a single merchant
+func (t *timeoutDB) GetMerchant(ctx context.Context, userID, id int64) (*models.Merchant, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	merchant, err := t.db.GetMerchant(ctx, userID, id)
+	return merchant, contextError(ctx, err)
+}
+
+// CreateMerchant creates a new merchant owned by the user
+func (t *timeoutDB) CreateMerchant(ctx context.Context, userID int64, merchant *models.MerchantInput) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateMerchant(ctx, userID, merchant)
+	return id, contextError(ctx, err)
+}
+
+// UpdateMerchant updates a merchant of the user
+func (t *timeoutDB) UpdateMerchant(ctx context.Context, userID, id int64, merchant *models.MerchantInput) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateMerchant(ctx, userID, id, merchant))
+}
+
+// DeleteMerchant deletes a merchant of the user, keeping its bills
+func (t *timeoutDB) DeleteMerchant(ctx context.Context, userID, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteMerchant(ctx, userID, id))
+}
+
+// GetRecurringBills returns the recurring bills of the user
+func (t *timeoutDB) GetRecurringBills(ctx context.Context, userID int64) ([]models.RecurringBill, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	recurring, err := t.db.GetRecurringBills(ctx, userID)
+	return recurring, contextError(ctx, err)
+}
+
+// GetRecurringBill returns a single recurring bill
+func (t *timeoutDB) GetRecurringBill(ctx context.Context, userID, id int64) (*models.RecurringBill, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	recurring, err := t.db.GetRecurringBill(ctx, userID, id)
+	return recurring, contextError(ctx, err)
+}
+
+// CreateRecurringBill creates a new recurring bill owned by the user
+func (t *timeoutDB) CreateRecurringBill(ctx context.Context, userID int64, recurring *models.RecurringBillInput) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateRecurringBill(ctx, userID, recurring)
+	return id, contextError(ctx, err)
+}
+
+// UpdateRecurringBill updates a recurring bill of the user
+func (t *timeoutDB) UpdateRecurringBill(ctx context.Context, userID, id int64, recurring *models.RecurringBillInput) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateRecurringBill(ctx, userID, id, recurring))
+}
+
+// DeleteRecurringBill deletes a recurring bill of the user, keeping its bills
+func (t *timeoutDB) DeleteRecurringBill(ctx context.Context, userID, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteRecurringBill(ctx, userID, id))
+}
+
+// SetRecurringBillPaused pauses or resumes a recurring bill of the user
+func (t *timeoutDB) SetRecurringBillPaused(ctx context.Context, userID, id int64, paused bool) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.SetRecurringBillPaused(ctx, userID, id, paused))
+}
+
+// SkipRecurringBill skips an upcoming occurrence of a recurring bill of the user
+func (t *timeoutDB) SkipRecurringBill(ctx context.Context, userID, id int64, date time.Time) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.SkipRecurringBill(ctx, userID, id, date))
+}
+
+// EndRecurringBill ends a recurring bill of the user on the date
+func (t *timeoutDB) EndRecurringBill(ctx context.Context, userID, id int64, date time.Time) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.EndRecurringBill(ctx, userID, id, date))
+}
+
+// GenerateRecurringBills generates the bills of all recurring bills through the date
+func (t *timeoutDB) GenerateRecurringBills(ctx context.Context, today, through time.Time) (int, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	generated, err := t.db.GenerateRecurringBills(ctx, today, through)
+	return generated, contextError(ctx, err)
+}
+
+// GetFXRates returns the exchange rates, optionally filtered by base and quote currency
+func (t *timeoutDB) GetFXRates(ctx context.Context, base, quote string) ([]models.FXRate, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	rates, err := t.db.GetFXRates(ctx, base, quote)
+	return rates, contextError(ctx, err)
+}
+
+// GetFXRate returns a single exchange rate
+func (t *timeoutDB) GetFXRate(ctx context.Context, id int64) (*models.FXRate, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	rate, err := t.db.GetFXRate(ctx, id)
+	return rate, contextError(ctx, err)
+}
+
+// FindFXRate returns the most recent rate for a currency pair that is effective on the given date
+func (t *timeoutDB) FindFXRate(ctx context.Context, base, quote string, on time.Time) (*models.FXRate, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	rate, err := t.db.FindFXRate(ctx, base, quote, on)
+	return rate, contextError(ctx, err)
+}
+
+// CreateFXRate creates a new exchange rate
+func (t *timeoutDB) CreateFXRate(ctx context.Context, rate *models.FXRateInput) (int64, error) {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	id, err := t.db.CreateFXRate(ctx, rate)
+	return id, contextError(ctx, err)
+}
+
+// UpdateFXRate updates an existing exchange rate
+func (t *timeoutDB) UpdateFXRate(ctx context.Context, id int64, rate *models.FXRateInput) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.UpdateFXRate(ctx, id, rate))
+}
+
+// DeleteFXRate deletes an exchange rate
+func (t *timeoutDB) DeleteFXRate(ctx context.Context, id int64) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.DeleteFXRate(ctx, id))
+}
+
+// Ping checks that the wrapped database is reachable
+func (t *timeoutDB) Ping(ctx context.Context) error {
+	ctx, cancel := t.withTimeout(ctx)
+	defer cancel()
+	return contextError(ctx, t.db.Ping(ctx))
+}
+
+// Migrate applies all pending schema migrations without a query timeout
+func (t *timeoutDB) Migrate(ctx context.Context) error {
+	return t.db.Migrate(ctx)
+}
+
+// Close closes the wrapped database
+func (t *timeoutDB) Close() error {
+	return t.db.Close()
+}
@@ -0,0 +1,67 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/db/dbtest"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// errInterrupted stands in for the error a driver reports when a query is interrupted
+var errInterrupted = errors.New("interrupted")
+
+// slowDB is an in-memory database whose GetBills blocks until its context is done
+type slowDB struct {
+	*db.MemoryDB
+}
+
+func (s slowDB) GetBills(ctx context.Context, userID int64, query *models.BillQuery) ([]models.BillSummary, int, error) {
+	<-ctx.Done()
+	return nil, 0, errInterrupted
+}
+
+func TestQueryTimeoutConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Database {
+		return db.WithQueryTimeout(db.NewMemoryDB(), time.Second)
+	})
+}
+
+func TestQueryTimeout(t *testing.T) {
+	database := db.WithQueryTimeout(slowDB{db.NewMemoryDB()}, 10*time.Millisecond)
+
+	_, _, err := database.GetBills(context.Background(), 1, &models.BillQuery{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if !errors.Is(err, errInterrupted) {
+		t.Errorf("err = %v, want it to wrap the driver error", err)
+	}
+}
+
+func TestQueryTimeoutCancel(t *testing.T) {
+	database := db.WithQueryTimeout(slowDB{db.NewMemoryDB()}, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, _, err := database.GetBills(ctx, 1, &models.BillQuery{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestQueryTimeoutKeepsErrors(t *testing.T) {
+	database := db.WithQueryTimeout(db.NewMemoryDB(), time.Second)
+
+	_, err := database.GetBill(context.Background(), 1, 1)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want no context error", err)
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// sqlUsers implements the user methods on a connection pool. The statements
+// use ? placeholders and are passed through bind, e.g. rebind for PostgreSQL.
+type sqlUsers struct {
+	db   *sql.DB
+	bind func(string) string
+}
+
+// ensureUser returns the user with the subject, creating it on first use.
+// A changed email or name is stored; empty values keep the stored ones. A
+// given email is stored with whether it is verified.
+func (u sqlUsers) ensureUser(ctx context.Context, input *models.UserInput) (*models.User, error) {
+	user, err := u.findUser(ctx, "subject = ?", input.Subject)
+	if errors.Is(err, ErrNotFound) {
+		// Concurrent first requests of a user race to insert; the loser sees a conflict
+		_, err = u.db.ExecContext(ctx, u.bind(`
+		INSERT INTO users (subject, email, email_verified, name) VALUES (?, ?, ?, ?)
+		`), input.Subject, input.Email, input.EmailVerified, input.Name)
+		if err = translateError(err); err != nil && !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		return u.findUser(ctx, "subject = ?", input.Subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if (input.Email == "" || (input.Email == user.Email && input.EmailVerified == user.EmailVerified)) &&
+		(input.Name == "" || input.Name == user.Name) {
+		return user, nil
+	}
+	emailVerified := user.EmailVerified
+	if input.Email != "" {
+		emailVerified = input.EmailVerified
+	}
+	_, err = u.db.ExecContext(ctx, u.bind(`
+	UPDATE users
+	SET email = CASE WHEN ? = '' THEN email ELSE ? END, email_verified = ?, name = CASE WHEN ? = '' THEN name ELSE ? END
+	WHERE id = ?
+	`), input.Email, input.Email, emailVerified, input.Name, input.Name, user.ID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return u.findUser(ctx, "id = ?", user.ID)
+}
+
+// findUserByVerifiedEmail returns the only user with the verified email. An
+// email that no user or several users verified matches no user.
+func (u sqlUsers) findUserByVerifiedEmail(ctx context.Context, email string) (*models.User, error) {
+	rows, err := u.db.QueryContext(ctx, u.bind(`
+	SELECT id FROM users WHERE email = ? AND email_verified = ? ORDER BY id LIMIT 2
+	`), email, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) != 1 {
+		return nil, ErrNotFound
+	}
+	return u.findUser(ctx, "id = ?", ids[0])
+}
+
+// findUser returns the user matching a condition on the users table
+func (u sqlUsers) findUser(ctx context.Context, condition string, args ...interface{}) (*models.User, error) {
+	var user models.User
+	err := u.db.QueryRowContext(ctx, u.bind(`
+	SELECT id, subject, email, email_verified, name, created_at, updated_at
+	FROM users
+	WHERE `+condition), args...).Scan(
+		&user.ID,
+		&user.Subject,
+		&user.Email,
+		&user.EmailVerified,
+		&user.Name,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// noBind leaves ? placeholders unchanged, for SQLite and MySQL
+func noBind(query string) string {
+	return query
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/auth"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// maxAPIKeyNameLength is the length of the name column of api_keys
+const maxAPIKeyNameLength = 255
+
+// APIKeyHandler handles the admin requests that manage API keys
+type APIKeyHandler struct {
+	db db.Database
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(database db.Database) *APIKeyHandler {
+	return &APIKeyHandler{db: database}
+}
+
+// GetAPIKeys returns all API keys
+// @Summary Get API keys
+// @Description Returns all API keys, including revoked ones. The keys themselves are never returned, only their prefixes.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /admin/api-keys [get]
+func (h *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.db.GetAPIKeys(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	responseJSON(w, keys)
+}
+
+// CreateAPIKey creates a new API key
+// @Summary Create an API key
+// @Description Creates an API key with the given scopes that acts as the user with the subject, or as the calling admin. The key is only returned in this response.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param key body models.APIKeyInput true "Key information"
+// @Success 201 {object} models.CreatedAPIKey
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /admin/api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var keyInput models.APIKeyInput
+	err := json.NewDecoder(r.Body).Decode(&keyInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateAPIKeyInput(&keyInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// The key acts as the given user, or as the admin creating it
+	keyUserID := userID(r)
+	if keyInput.Subject != "" {
+		user, err := h.db.EnsureUser(r.Context(), &models.UserInput{Subject: keyInput.Subject})
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		keyUserID = user.ID
+	}
+
+	plain, err := auth.GenerateAPIKey()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	key := models.APIKey{
+		UserID: keyUserID,
+		Name:   keyInput.Name,
+		Prefix: auth.APIKeyPrefix(plain),
+		Scopes: keyInput.Scopes,
+		Hash:   auth.HashAPIKey(plain),
+	}
+	id, err := h.db.CreateAPIKey(r.Context(), &key)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	stored, err := h.db.GetAPIKey(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	responseJSONStatus(w, http.StatusCreated, models.CreatedAPIKey{APIKey: *stored, Key: plain})
+}
+
+// RevokeAPIKey revokes an API key
+// @Summary Revoke an API key
+// @Description Revokes an API key; requests with it are rejected from then on. Revoked keys stay listed.
+// @Tags admin
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /admin/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, r, invalidRequest(errors.New("invalid API key ID")))
+		return
+	}
+
+	err = h.db.RevokeAPIKey(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("API key"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "API key revoked successfully"})
+}
+
+// validateAPIKeyInput validates and normalizes an API key input
+func validateAPIKeyInput(keyInput *models.APIKeyInput) error {
+	verr := &db.ValidationError{}
+
+	keyInput.Name = strings.TrimSpace(keyInput.Name)
+	if keyInput.Name == "" {
+		verr.Add("name", "is required")
+	} else if utf8.RuneCountInString(keyInput.Name) > maxAPIKeyNameLength {
+		verr.Add("name", "must be at most 255 characters")
+	}
+
+	keyInput.Scopes = auth.NormalizeScopes(keyInput.Scopes)
+	if len(keyInput.Scopes) == 0 {
+		verr.Add("scopes", "must contain at least one scope")
+	}
+	for _, scope := range keyInput.Scopes {
+		if !auth.ValidScope(scope) {
+			verr.Add("scopes", "unknown scope "+strconv.Quote(scope)+"; valid scopes are "+strings.Join(auth.Scopes, ", "))
+		}
+	}
+
+	keyInput.Subject = strings.TrimSpace(keyInput.Subject)
+	if utf8.RuneCountInString(keyInput.Subject) > 255 {
+		verr.Add("subject", "must be at most 255 characters")
+	}
+
+	return verr.Err()
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/auth"
+	"github.com/jo/choreo-tutorial/accounts/db"
+)
+
+// apiKeyTouchInterval is how stale the last-used time of an API key may
+// get, so that busy keys do not cause a write on every request
+const apiKeyTouchInterval = time.Minute
+
+// Authenticate returns a middleware that stores the principal of every
+// request in the request context. Requests with an API key act as the user
+// of the key, limited to its scopes. Other requests are identified with
+// authenticator, which creates the user on first use; users have all scopes
+// but admin, which only the users with the admin subjects have. The claims
+// of a bearer token are kept in the principal. Requests without a valid
+// identity are answered with 401 Unauthorized.
+func Authenticate(authenticator auth.Authenticator, database db.Database, admins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := r.Header.Get(auth.APIKeyHeader); key != "" {
+				principal, err := authenticateAPIKey(r.Context(), database, key)
+				if err != nil {
+					writeError(w, r, err)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), principal)))
+				return
+			}
+
+			identity, err := authenticator.Authenticate(r)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			user, err := database.EnsureUser(r.Context(), &identity.User)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			principal := &auth.Principal{UserID: user.ID, Subject: user.Subject, Claims: identity.Claims, Scopes: auth.UserScopes}
+			if slices.Contains(admins, user.Subject) {
+				principal.Scopes = auth.Scopes
+			}
+			next.ServeHTTP(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// authenticateAPIKey resolves an API key to the principal of its user and
+// records that the key was used
+func authenticateAPIKey(ctx context.Context, database db.Database, plain string) (*auth.Principal, error) {
+	key, err := database.FindAPIKey(ctx, auth.HashAPIKey(plain))
+	if errors.Is(err, db.ErrNotFound) || (err == nil && key.RevokedAt != nil) {
+		return nil, withDetail(auth.ErrUnauthenticated, "the API key is invalid or revoked")
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := database.GetUser(ctx, key.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) >= apiKeyTouchInterval {
+		// A failure to record the use does not fail the request
+		if err := database.TouchAPIKey(ctx, key.ID); err != nil {
+			slog.WarnContext(ctx, "failed to record API key use", "api_key_id", key.ID, "error", err)
+		}
+	}
+
+	return &auth.Principal{UserID: user.ID, Subject: user.Subject, Scopes: key.Scopes, APIKeyID: key.ID}, nil
+}
+
+// RequireScope wraps a handler so that it only serves principals with the
+// scope; others are answered with 403 Forbidden
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := auth.PrincipalFromContext(r.Context())
+		if principal == nil {
+			writeError(w, r, auth.ErrUnauthenticated)
+			return
+		}
+		if !principal.HasScope(scope) {
+			writeError(w, r, withDetail(errForbidden, "the request requires the scope "+scope))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// userID returns the ID of the authenticated user, whose roles decide which
+// bills and ledgers a request may work on. Without a principal it returns
+// 0, which matches no user, so queries fail closed.
+func userID(r *http.Request) int64 {
+	if principal := auth.PrincipalFromContext(r.Context()); principal != nil {
+		return principal.UserID
+	}
+	return 0
+}
+
+// UserHandler handles requests about the authenticated user
+type UserHandler struct {
+	db db.Database
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(database db.Database) *UserHandler {
+	return &UserHandler{db: database}
+}
+
+// GetCurrentUser returns the authenticated user
+// @Summary Get the current user
+// @Description Returns the user the request is authenticated as
+// @Tags users
+// @Produce json
+// @Success 200 {object} models.User
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /me [get]
+func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.db.GetUser(r.Context(), userID(r))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, auth.ErrUnauthenticated)
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, user)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jo/choreo-tutorial/accounts/db"
@@ -13,30 +14,187 @@ import (
 
 // BillHandler handles bill-related requests
 type BillHandler struct {
-	db db.Database
+	db     db.Database
+	limits models.ValidationLimits
 }
 
-// NewBillHandler creates a new bill handler
-func NewBillHandler(database db.Database) *BillHandler {
-	return &BillHandler{db: database}
+// NewBillHandler creates a new bill handler that accepts bills and items within the given limits
+func NewBillHandler(database db.Database, limits models.ValidationLimits) *BillHandler {
+	return &BillHandler{db: database, limits: limits}
 }
 
-// GetBills returns all bills
-// @Summary Get all bills
-// @Description Returns a list of all bills with summary information
+// GetBills returns a page of bills
+// @Summary Get bills
+// @Description Returns a page of bills with summary information, optionally filtered and sorted
 // @Tags bills
 // @Produce json
-// @Success 200 {array} models.BillSummary
-// @Failure 500 {object} map[string]string
+// @Param paid query bool false "Only paid (true) or unpaid (false) bills"
+// @Param payment_status query string false "Only bills with the payment status (unpaid, partial, paid, overpaid)"
+// @Param due_from query string false "Earliest due date (YYYY-MM-DD), inclusive"
+// @Param due_to query string false "Latest due date (YYYY-MM-DD), inclusive"
+// @Param min_total query number false "Minimum total, inclusive"
+// @Param max_total query number false "Maximum total, inclusive"
+// @Param title query string false "Case-insensitive title substring"
+// @Param currency query string false "Only bills in this currency (ISO 4217)"
+// @Param ledger_id query int false "Only bills filed in this ledger"
+// @Param category_id query int false "Only bills in this category or its subcategories, or with an item in them"
+// @Param merchant_id query int false "Only bills of this merchant"
+// @Param tags query string false "Comma-separated tag names; only bills with these tags on them or on one of their items"
+// @Param tag_match query string false "Whether bills need any (default) or all of the tags (any, all)"
+// @Param convert_to query string false "Reporting currency to convert totals into (ISO 4217)"
+// @Param as_of query string false "Date of the exchange rates used for conversion (YYYY-MM-DD), defaults to today"
+// @Param sort query string false "Sort field (due_date, total, title, created_at, updated_at)"
+// @Param order query string false "Sort order (asc, desc)"
+// @Param limit query int false "Page size"
+// @Param offset query int false "Number of bills to skip"
+// @Success 200 {object} models.BillPage
+// @Failure 400 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
 // @Router /bills [get]
 func (h *BillHandler) GetBills(w http.ResponseWriter, r *http.Request) {
-	bills, err := h.db.GetBills()
+	query, err := parseBillQuery(r)
 	if err != nil {
-		writeError(w, err, http.StatusInternalServerError)
+		writeError(w, r, invalidRequest(err))
 		return
 	}
 
-	responseJSON(w, bills)
+	convertTo, asOf, err := parseConversion(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	bills, total, err := h.db.GetBills(r.Context(), userID(r), query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if bills == nil {
+		bills = []models.BillSummary{}
+	}
+
+	// Convert totals into the reporting currency
+	if convertTo != "" {
+		converter := newCurrencyConverter(h.db, convertTo, asOf)
+		for i := range bills {
+			converted, rate, err := converter.convert(r.Context(), bills[i].Total, bills[i].Currency)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			bills[i].Converted = &models.ConvertedAmount{
+				Currency: convertTo,
+				Total:    converted,
+				Rate:     rate,
+			}
+		}
+	}
+
+	page := models.BillPage{
+		Bills:  bills,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}
+	if next := query.Offset + len(bills); next < total {
+		page.NextOffset = &next
+	}
+
+	responseJSON(w, page)
+}
+
+// GetBillTotals returns bill totals grouped by currency
+// @Summary Get bill totals
+// @Description Returns the totals of the bills matching the filters grouped by currency, optionally combined in a reporting currency
+// @Tags bills
+// @Produce json
+// @Param paid query bool false "Only paid (true) or unpaid (false) bills"
+// @Param payment_status query string false "Only bills with the payment status (unpaid, partial, paid, overpaid)"
+// @Param due_from query string false "Earliest due date (YYYY-MM-DD), inclusive"
+// @Param due_to query string false "Latest due date (YYYY-MM-DD), inclusive"
+// @Param title query string false "Case-insensitive title substring"
+// @Param currency query string false "Only bills in this currency (ISO 4217)"
+// @Param ledger_id query int false "Only bills filed in this ledger"
+// @Param category_id query int false "Only bills in this category or its subcategories, or with an item in them"
+// @Param merchant_id query int false "Only bills of this merchant"
+// @Param tags query string false "Comma-separated tag names; only bills with these tags on them or on one of their items"
+// @Param tag_match query string false "Whether bills need any (default) or all of the tags (any, all)"
+// @Param convert_to query string false "Reporting currency to convert totals into (ISO 4217)"
+// @Param as_of query string false "Date of the exchange rates used for conversion (YYYY-MM-DD), defaults to today"
+// @Success 200 {object} models.BillTotals
+// @Failure 400 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/totals [get]
+func (h *BillHandler) GetBillTotals(w http.ResponseWriter, r *http.Request) {
+	query, err := parseBillQuery(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	convertTo, asOf, err := parseConversion(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	currencies, err := h.db.GetBillTotals(r.Context(), userID(r), query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if currencies == nil {
+		currencies = []models.CurrencyTotal{}
+	}
+
+	totals := models.BillTotals{Currencies: currencies}
+
+	// Combine all currencies in the reporting currency
+	if convertTo != "" {
+		converter := newCurrencyConverter(h.db, convertTo, asOf)
+		var total, paidTotal, unpaidTotal models.Money
+		for i := range currencies {
+			c := &currencies[i]
+			convertedTotal, rate, err := converter.convert(r.Context(), c.Total, c.Currency)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			convertedPaid, _, err := converter.convert(r.Context(), c.PaidTotal, c.Currency)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			c.Converted = &models.ConvertedTotals{
+				Total:       convertedTotal,
+				PaidTotal:   convertedPaid,
+				UnpaidTotal: convertedTotal - convertedPaid,
+				Rate:        rate,
+			}
+			total += c.Converted.Total
+			paidTotal += c.Converted.PaidTotal
+			unpaidTotal += c.Converted.UnpaidTotal
+		}
+
+		totals.ReportingCurrency = convertTo
+		totals.Total = &total
+		totals.PaidTotal = &paidTotal
+		totals.UnpaidTotal = &unpaidTotal
+		totals.RatesAsOf = asOf.Format("2006-01-02")
+	}
+
+	responseJSON(w, totals)
 }
 
 // GetBill returns a single bill
@@ -46,23 +204,27 @@ func (h *BillHandler) GetBills(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param id path int true "Bill ID"
 // @Success 200 {object} models.Bill
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
 // @Router /bills/{id} [get]
 func (h *BillHandler) GetBill(w http.ResponseWriter, r *http.Request) {
 	id, err := getBillID(r)
 	if err != nil {
-		writeError(w, err, http.StatusBadRequest)
+		writeError(w, r, invalidRequest(err))
 		return
 	}
 
-	bill, err := h.db.GetBill(id)
+	bill, err := h.db.GetBill(r.Context(), userID(r), id)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound) {
-			writeError(w, errors.New("bill not found"), http.StatusNotFound)
+			writeError(w, r, notFound("bill"))
 			return
 		}
-		writeError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -77,88 +239,147 @@ func (h *BillHandler) GetBill(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param bill body models.BillInput true "Bill information"
 // @Success 201 {object} map[string]int64
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
 // @Router /bills [post]
 func (h *BillHandler) CreateBill(w http.ResponseWriter, r *http.Request) {
 	var billInput models.BillInput
 	err := json.NewDecoder(r.Body).Decode(&billInput)
 	if err != nil {
-		writeError(w, err, http.StatusBadRequest)
+		writeError(w, r, invalidRequest(err))
 		return
 	}
 
 	// Validate input
-	if billInput.Title == "" {
-		writeError(w, errors.New("title is required"), http.StatusBadRequest)
+	if err := h.validateBillInput(&billInput); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Create bill
-	id, err := h.db.CreateBill(&billInput)
+	id, err := h.db.CreateBill(r.Context(), userID(r), &billInput)
 	if err != nil {
-		writeError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	responseJSON(w, map[string]int64{"id": id})
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"id": id})
 }
 
 // UpdateBill updates an existing bill
 // @Summary Update a bill
-// @Description Updates an existing bill with the provided information
+// @Description Updates an existing bill with the provided information. The payments of a bill with payments decide whether it is paid; paid true records a payment of the balance like PUT /bills/{id}/paid.
 // @Tags bills
 // @Accept json
 // @Produce json
 // @Param id path int true "Bill ID"
 // @Param bill body models.BillInput true "Bill information"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
 // @Router /bills/{id} [put]
 func (h *BillHandler) UpdateBill(w http.ResponseWriter, r *http.Request) {
 	id, err := getBillID(r)
 	if err != nil {
-		writeError(w, err, http.StatusBadRequest)
+		writeError(w, r, invalidRequest(err))
 		return
 	}
 
 	var billInput models.BillInput
 	err = json.NewDecoder(r.Body).Decode(&billInput)
 	if err != nil {
-		writeError(w, err, http.StatusBadRequest)
+		writeError(w, r, invalidRequest(err))
 		return
 	}
 
 	// Validate input
-	if billInput.Title == "" {
-		writeError(w, errors.New("title is required"), http.StatusBadRequest)
+	if err := h.validateBillInput(&billInput); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// Check if bill exists
-	_, err = h.db.GetBill(id)
+	_, err = h.db.GetBill(r.Context(), userID(r), id)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound) {
-			writeError(w, errors.New("bill not found"), http.StatusNotFound)
+			writeError(w, r, notFound("bill"))
 			return
 		}
-		writeError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	// Update bill
-	err = h.db.UpdateBill(id, &billInput)
+	err = h.db.UpdateBill(r.Context(), userID(r), id, &billInput)
 	if err != nil {
-		writeError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	responseJSON(w, map[string]string{"message": "Bill updated successfully"})
 }
 
+// SetBillPaid marks a bill as paid or unpaid
+// @Summary Mark a bill as paid or unpaid
+// @Description Sets whether a bill is paid. Unlike updating the bill, this only requires the payer role on shared bills. Marking a bill with payments as paid records a payment of its balance; a bill with payments cannot be marked as unpaid.
+// @Tags bills
+// @Accept json
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Param paid body models.PaidInput true "Paid status"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/paid [put]
+func (h *BillHandler) SetBillPaid(w http.ResponseWriter, r *http.Request) {
+	id, err := getBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var paidInput models.PaidInput
+	err = json.NewDecoder(r.Body).Decode(&paidInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	err = h.db.SetBillPaid(r.Context(), userID(r), id, paidInput.Paid)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			writeError(w, r, notFound("bill"))
+		case errors.Is(err, db.ErrBillHasPayments):
+			writeError(w, r, withDetail(err, "the bill has payments; delete them to mark it as unpaid"))
+		default:
+			writeError(w, r, err)
+		}
+		return
+	}
+
+	if paidInput.Paid {
+		responseJSON(w, map[string]string{"message": "Bill marked as paid"})
+		return
+	}
+	responseJSON(w, map[string]string{"message": "Bill marked as unpaid"})
+}
+
 // DeleteBill deletes a bill
 // @Summary Delete a bill
 // @Description Deletes a bill and all its items
@@ -166,32 +387,36 @@ func (h *BillHandler) UpdateBill(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param id path int true "Bill ID"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
 // @Router /bills/{id} [delete]
 func (h *BillHandler) DeleteBill(w http.ResponseWriter, r *http.Request) {
 	id, err := getBillID(r)
 	if err != nil {
-		writeError(w, err, http.StatusBadRequest)
+		writeError(w, r, invalidRequest(err))
 		return
 	}
 
 	// Check if bill exists
-	_, err = h.db.GetBill(id)
+	_, err = h.db.GetBill(r.Context(), userID(r), id)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound) {
-			writeError(w, errors.New("bill not found"), http.StatusNotFound)
+			writeError(w, r, notFound("bill"))
 			return
 		}
-		writeError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	// Delete bill
-	err = h.db.DeleteBill(id)
+	err = h.db.DeleteBill(r.Context(), userID(r), id)
 	if err != nil {
-		writeError(w, err, http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -210,14 +435,26 @@ func getBillID(r *http.Request) (int64, error) {
 	return id, nil
 }
 
-// writeError writes an error response
-func writeError(w http.ResponseWriter, err error, status int) {
-	w.WriteHeader(status)
-	responseJSON(w, map[string]string{"error": err.Error()})
+// validateBillInput checks a bill against the limits and normalizes its
+// currency and tags
+func (h *BillHandler) validateBillInput(billInput *models.BillInput) error {
+	if errs := billInput.Validate(h.limits, time.Now().UTC()); len(errs) > 0 {
+		return &db.ValidationError{Fields: errs}
+	}
+	billInput.NormalizeTags()
+	var err error
+	billInput.Currency, err = models.NormalizeCurrency(billInput.Currency)
+	return err
 }
 
 // responseJSON writes a JSON response
 func responseJSON(w http.ResponseWriter, data interface{}) {
+	responseJSONStatus(w, http.StatusOK, data)
+}
+
+// responseJSONStatus writes a JSON response with the given status
+func responseJSONStatus(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
-}
\ No newline at end of file
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// GetBillItems returns all items of a bill
+// @Summary Get all items of a bill
+// @Description Returns the items that belong to a bill
+// @Tags bill-items
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Success 200 {array} models.BillItem
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/items [get]
+func (h *BillHandler) GetBillItems(w http.ResponseWriter, r *http.Request) {
+	billID, err := getBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	if _, ok := h.findBill(w, r, billID); !ok {
+		return
+	}
+
+	items, err := h.db.GetBillItems(r.Context(), userID(r), billID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if items == nil {
+		items = []models.BillItem{}
+	}
+
+	responseJSON(w, items)
+}
+
+// GetBillItem returns a single item of a bill
+// @Summary Get a single bill item
+// @Description Returns a single item that belongs to a bill
+// @Tags bill-items
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Param itemId path int true "Item ID"
+// @Success 200 {object} models.BillItem
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/items/{itemId} [get]
+func (h *BillHandler) GetBillItem(w http.ResponseWriter, r *http.Request) {
+	billID, itemID, err := getBillItemIDs(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	item, ok := h.findBillItem(w, r, billID, itemID)
+	if !ok {
+		return
+	}
+
+	responseJSON(w, item)
+}
+
+// CreateBillItem adds an item to a bill
+// @Summary Add an item to a bill
+// @Description Creates a new item on a bill and recalculates the bill total
+// @Tags bill-items
+// @Accept json
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Param item body models.BillItemInput true "Item information"
+// @Success 201 {object} map[string]int64
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/items [post]
+func (h *BillHandler) CreateBillItem(w http.ResponseWriter, r *http.Request) {
+	billID, err := getBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var itemInput models.BillItemInput
+	err = json.NewDecoder(r.Body).Decode(&itemInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if errs := itemInput.Validate(h.limits); len(errs) > 0 {
+		writeError(w, r, &db.ValidationError{Fields: errs})
+		return
+	}
+	itemInput.NormalizeTags()
+
+	bill, ok := h.findBill(w, r, billID)
+	if !ok {
+		return
+	}
+	if err := validateItemCurrency(&itemInput, bill.Currency); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(bill.Items) >= h.limits.MaxItems {
+		writeError(w, r, db.NewValidationError("items", fmt.Sprintf("bill must not contain more than %d items", h.limits.MaxItems)))
+		return
+	}
+
+	// Create item
+	id, err := h.db.CreateBillItem(r.Context(), userID(r), billID, &itemInput)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"id": id})
+}
+
+// UpdateBillItem updates a single item of a bill
+// @Summary Update a bill item
+// @Description Updates an item on a bill and recalculates the bill total
+// @Tags bill-items
+// @Accept json
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Param itemId path int true "Item ID"
+// @Param item body models.BillItemInput true "Item information"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/items/{itemId} [put]
+func (h *BillHandler) UpdateBillItem(w http.ResponseWriter, r *http.Request) {
+	billID, itemID, err := getBillItemIDs(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var itemInput models.BillItemInput
+	err = json.NewDecoder(r.Body).Decode(&itemInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if errs := itemInput.Validate(h.limits); len(errs) > 0 {
+		writeError(w, r, &db.ValidationError{Fields: errs})
+		return
+	}
+	itemInput.NormalizeTags()
+
+	// Check if item exists on this bill
+	if _, ok := h.findBillItem(w, r, billID, itemID); !ok {
+		return
+	}
+	bill, ok := h.findBill(w, r, billID)
+	if !ok {
+		return
+	}
+	if err := validateItemCurrency(&itemInput, bill.Currency); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Update item
+	err = h.db.UpdateBillItem(r.Context(), userID(r), itemID, &itemInput)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("bill item"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Bill item updated successfully"})
+}
+
+// DeleteBillItem removes a single item from a bill
+// @Summary Delete a bill item
+// @Description Deletes an item from a bill and recalculates the bill total
+// @Tags bill-items
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Param itemId path int true "Item ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/items/{itemId} [delete]
+func (h *BillHandler) DeleteBillItem(w http.ResponseWriter, r *http.Request) {
+	billID, itemID, err := getBillItemIDs(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Check if item exists on this bill
+	if _, ok := h.findBillItem(w, r, billID, itemID); !ok {
+		return
+	}
+
+	// Delete item
+	err = h.db.DeleteBillItem(r.Context(), userID(r), itemID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("bill item"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Bill item deleted successfully"})
+}
+
+// validateItemCurrency checks that the amount of an item fits the currency
+// of its bill, e.g. that it is a whole amount of JPY
+func validateItemCurrency(itemInput *models.BillItemInput, currency string) error {
+	if !itemInput.Amount.InCurrency(currency) {
+		return db.NewValidationError("amount", models.CurrencyAmountMessage(currency))
+	}
+	return nil
+}
+
+// findBill loads a bill and writes a 404 response if it does not exist
+func (h *BillHandler) findBill(w http.ResponseWriter, r *http.Request, billID int64) (*models.Bill, bool) {
+	bill, err := h.db.GetBill(r.Context(), userID(r), billID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("bill"))
+			return nil, false
+		}
+		writeError(w, r, err)
+		return nil, false
+	}
+	return bill, true
+}
+
+// findBillItem loads an item and checks that it belongs to the given bill.
+// Items of other bills are reported as not found.
+func (h *BillHandler) findBillItem(w http.ResponseWriter, r *http.Request, billID, itemID int64) (*models.BillItem, bool) {
+	item, err := h.db.GetBillItem(r.Context(), userID(r), itemID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("bill item"))
+			return nil, false
+		}
+		writeError(w, r, err)
+		return nil, false
+	}
+
+	if item.BillID != billID {
+		writeError(w, r, notFound("bill item"))
+		return nil, false
+	}
+
+	return item, true
+}
+
+// getBillItemIDs extracts the bill ID and item ID from the URL
+func getBillItemIDs(r *http.Request) (int64, int64, error) {
+	billID, err := getBillID(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	itemID, err := strconv.ParseInt(mux.Vars(r)["itemId"], 10, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid item ID")
+	}
+	return billID, itemID, nil
+}
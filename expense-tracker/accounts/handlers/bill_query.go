@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// parseBillQuery builds a bill query from the URL query parameters
+func parseBillQuery(r *http.Request) (*models.BillQuery, error) {
+	params := r.URL.Query()
+	query := &models.BillQuery{
+		Title:     params.Get("title"),
+		TagMatch:  models.TagMatchAny,
+		SortBy:    models.BillSortDueDate,
+		SortOrder: models.SortAsc,
+		Limit:     models.DefaultBillPageSize,
+	}
+
+	if v := params.Get("currency"); v != "" {
+		currency, err := models.NormalizeCurrency(v)
+		if err != nil {
+			return nil, err
+		}
+		query.Currency = currency
+	}
+
+	if v := params.Get("paid"); v != "" {
+		paid, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("invalid paid filter: must be true or false")
+		}
+		query.Paid = &paid
+	}
+	if v := params.Get("payment_status"); v != "" {
+		if !models.ValidPaymentStatus(v) {
+			return nil, errors.New("invalid payment_status: must be unpaid, partial, paid or overpaid")
+		}
+		query.PaymentStatus = v
+	}
+
+	if v := params.Get("ledger_id"); v != "" {
+		ledgerID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || ledgerID < 1 {
+			return nil, errors.New("invalid ledger_id: must be a positive integer")
+		}
+		query.LedgerID = &ledgerID
+	}
+	if v := params.Get("category_id"); v != "" {
+		categoryID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || categoryID < 1 {
+			return nil, errors.New("invalid category_id: must be a positive integer")
+		}
+		query.CategoryID = &categoryID
+	}
+	if v := params.Get("merchant_id"); v != "" {
+		merchantID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || merchantID < 1 {
+			return nil, errors.New("invalid merchant_id: must be a positive integer")
+		}
+		query.MerchantID = &merchantID
+	}
+
+	if v := params.Get("tags"); v != "" {
+		query.Tags = models.NormalizeTags(strings.Split(v, ","))
+		if len(query.Tags) > models.MaxTags {
+			return nil, fmt.Errorf("invalid tags: must not contain more than %d tags", models.MaxTags)
+		}
+		for _, tag := range query.Tags {
+			if message := models.ValidateTag(tag); message != "" {
+				return nil, fmt.Errorf("invalid tags: tag %q %s", tag, message)
+			}
+		}
+	}
+	if v := params.Get("tag_match"); v != "" {
+		if v != models.TagMatchAny && v != models.TagMatchAll {
+			return nil, errors.New("invalid tag_match: must be any or all")
+		}
+		query.TagMatch = v
+	}
+
+	var err error
+	if query.DueFrom, err = parseDateParam(params.Get("due_from"), "due_from"); err != nil {
+		return nil, err
+	}
+	if query.DueTo, err = parseDateParam(params.Get("due_to"), "due_to"); err != nil {
+		return nil, err
+	}
+	if query.MinTotal, err = parseAmountParam(params.Get("min_total"), "min_total"); err != nil {
+		return nil, err
+	}
+	if query.MaxTotal, err = parseAmountParam(params.Get("max_total"), "max_total"); err != nil {
+		return nil, err
+	}
+
+	if v := params.Get("sort"); v != "" {
+		switch v {
+		case models.BillSortDueDate, models.BillSortTotal, models.BillSortTitle,
+			models.BillSortCreatedAt, models.BillSortUpdatedAt:
+			query.SortBy = v
+		default:
+			return nil, fmt.Errorf("invalid sort field: %s", v)
+		}
+	}
+
+	if v := params.Get("order"); v != "" {
+		if v != models.SortAsc && v != models.SortDesc {
+			return nil, fmt.Errorf("invalid sort order: %s", v)
+		}
+		query.SortOrder = v
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > models.MaxBillPageSize {
+			return nil, fmt.Errorf("invalid limit: must be between 1 and %d", models.MaxBillPageSize)
+		}
+		query.Limit = limit
+	}
+
+	if v := params.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return nil, errors.New("invalid offset: must be a non-negative integer")
+		}
+		query.Offset = offset
+	}
+
+	return query, nil
+}
+
+// parseConversion reads the optional reporting currency and the date whose
+// exchange rates are used for conversion. The date defaults to today.
+func parseConversion(r *http.Request) (string, time.Time, error) {
+	params := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	to, err := parseCurrencyParam(params.Get("convert_to"))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	asOf, err := parseDateParam(params.Get("as_of"), "as_of")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if asOf == nil {
+		return to, today, nil
+	}
+	return to, *asOf, nil
+}
+
+// parseDateParam parses an optional YYYY-MM-DD query parameter
+func parseDateParam(value, name string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: must be a date in YYYY-MM-DD format", name)
+	}
+	return &date, nil
+}
+
+// parseAmountParam parses an optional decimal query parameter
+func parseAmountParam(value, name string) (*models.Money, error) {
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := models.ParseMoney(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: must be a number", name)
+	}
+	return &amount, nil
+}
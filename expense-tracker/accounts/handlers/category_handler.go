@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// CategoryHandler handles category-related requests
+type CategoryHandler struct {
+	db db.Database
+}
+
+// NewCategoryHandler creates a new category handler
+func NewCategoryHandler(database db.Database) *CategoryHandler {
+	return &CategoryHandler{db: database}
+}
+
+// GetCategories returns the categories of the user
+// @Summary Get categories
+// @Description Returns the default categories and those of the user; subcategories refer to their parent
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.Category
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /categories [get]
+func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.db.GetCategories(r.Context(), userID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if categories == nil {
+		categories = []models.Category{}
+	}
+
+	responseJSON(w, categories)
+}
+
+// GetCategory returns a single category
+// @Summary Get a single category
+// @Description Returns a default category or one of the user
+// @Tags categories
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /categories/{id} [get]
+func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := getCategoryID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	category, err := h.db.GetCategory(r.Context(), userID(r), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("category"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, category)
+}
+
+// CreateCategory creates a new category
+// @Summary Create a new category
+// @Description Creates a new category owned by the user, optionally under a default category or one of the user
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param category body models.CategoryInput true "Category information"
+// @Success 201 {object} map[string]int64
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /categories [post]
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var categoryInput models.CategoryInput
+	err := json.NewDecoder(r.Body).Decode(&categoryInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateCategoryInput(&categoryInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	id, err := h.db.CreateCategory(r.Context(), userID(r), &categoryInput)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"id": id})
+}
+
+// UpdateCategory renames and moves a category
+// @Summary Update a category
+// @Description Renames a category of the user or moves it under another parent; default categories cannot be changed
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Param category body models.CategoryInput true "Category information"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /categories/{id} [put]
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := getCategoryID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var categoryInput models.CategoryInput
+	err = json.NewDecoder(r.Body).Decode(&categoryInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateCategoryInput(&categoryInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.db.UpdateCategory(r.Context(), userID(r), id, &categoryInput)
+	if err != nil {
+		writeCategoryError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Category updated successfully"})
+}
+
+// DeleteCategory deletes a category
+// @Summary Delete a category
+// @Description Deletes a category of the user and its subcategories; default categories cannot be deleted. Their bills and items are kept uncategorized.
+// @Tags categories
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /categories/{id} [delete]
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := getCategoryID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	err = h.db.DeleteCategory(r.Context(), userID(r), id)
+	if err != nil {
+		writeCategoryError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Category deleted successfully"})
+}
+
+// writeCategoryError writes an error of changing a category
+func writeCategoryError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, r, notFound("category"))
+	case errors.Is(err, db.ErrDefaultCategory):
+		writeError(w, r, withDetail(err, "default categories cannot be changed"))
+	default:
+		writeError(w, r, err)
+	}
+}
+
+// getCategoryID extracts the category ID from the URL
+func getCategoryID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid category ID")
+	}
+	return id, nil
+}
+
+// validateCategoryInput validates and normalizes a category input
+func validateCategoryInput(categoryInput *models.CategoryInput) error {
+	verr := &db.ValidationError{}
+
+	categoryInput.Name = strings.TrimSpace(categoryInput.Name)
+	if categoryInput.Name == "" {
+		verr.Add("name", "is required")
+	} else if utf8.RuneCountInString(categoryInput.Name) > models.MaxNameLength {
+		verr.Add("name", "must be at most 255 characters")
+	}
+	if categoryInput.ParentID != nil && *categoryInput.ParentID < 1 {
+		verr.Add("parent_id", "must be a positive ID")
+	}
+
+	return verr.Err()
+}
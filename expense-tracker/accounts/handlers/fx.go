@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// errNoFXRate is returned when no exchange rate is known for a currency pair
+var errNoFXRate = errors.New("no exchange rate available")
+
+// currencyConverter converts amounts into a reporting currency using the
+// stored exchange rates effective on a given date. Rates are looked up once
+// per source currency.
+type currencyConverter struct {
+	db    db.Database
+	to    string
+	on    time.Time
+	rates map[string]models.Rate
+}
+
+// newCurrencyConverter creates a converter into the given reporting currency
+func newCurrencyConverter(database db.Database, to string, on time.Time) *currencyConverter {
+	return &currencyConverter{
+		db:    database,
+		to:    to,
+		on:    on,
+		rates: map[string]models.Rate{to: "1"},
+	}
+}
+
+// rate returns the rate from a currency into the reporting currency. A rate
+// stored for the opposite direction is inverted when no direct rate exists.
+func (c *currencyConverter) rate(ctx context.Context, from string) (models.Rate, error) {
+	if rate, ok := c.rates[from]; ok {
+		return rate, nil
+	}
+
+	fxRate, err := c.db.FindFXRate(ctx, from, c.to, c.on)
+	if err == nil {
+		c.rates[from] = fxRate.Rate
+		return fxRate.Rate, nil
+	}
+	if !errors.Is(err, db.ErrNotFound) {
+		return "", err
+	}
+
+	fxRate, err = c.db.FindFXRate(ctx, c.to, from, c.on)
+	if err == nil {
+		rate := fxRate.Rate.Inverse()
+		c.rates[from] = rate
+		return rate, nil
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		return "", fmt.Errorf("%w from %s to %s on %s", errNoFXRate, from, c.to, c.on.Format("2006-01-02"))
+	}
+	return "", err
+}
+
+// convert converts an amount from a currency into the reporting currency
+func (c *currencyConverter) convert(ctx context.Context, amount models.Money, from string) (models.Money, models.Rate, error) {
+	rate, err := c.rate(ctx, from)
+	if err != nil {
+		return 0, "", err
+	}
+
+	converted, err := amount.Convert(rate, c.to)
+	if err != nil {
+		return 0, "", err
+	}
+	return converted, rate, nil
+}
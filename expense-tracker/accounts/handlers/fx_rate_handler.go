@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// FXRateHandler handles exchange rate requests
+type FXRateHandler struct {
+	db db.Database
+}
+
+// NewFXRateHandler creates a new exchange rate handler
+func NewFXRateHandler(database db.Database) *FXRateHandler {
+	return &FXRateHandler{db: database}
+}
+
+// GetFXRates returns all exchange rates
+// @Summary Get exchange rates
+// @Description Returns the exchange rates, newest first per currency pair
+// @Tags fx-rates
+// @Produce json
+// @Param base query string false "Base currency (ISO 4217)"
+// @Param quote query string false "Quote currency (ISO 4217)"
+// @Success 200 {array} models.FXRate
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /fx-rates [get]
+func (h *FXRateHandler) GetFXRates(w http.ResponseWriter, r *http.Request) {
+	base, err := parseCurrencyParam(r.URL.Query().Get("base"))
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+	quote, err := parseCurrencyParam(r.URL.Query().Get("quote"))
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	rates, err := h.db.GetFXRates(r.Context(), base, quote)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if rates == nil {
+		rates = []models.FXRate{}
+	}
+
+	responseJSON(w, rates)
+}
+
+// GetFXRate returns a single exchange rate
+// @Summary Get an exchange rate
+// @Description Returns a single exchange rate
+// @Tags fx-rates
+// @Produce json
+// @Param id path int true "Rate ID"
+// @Success 200 {object} models.FXRate
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /fx-rates/{id} [get]
+func (h *FXRateHandler) GetFXRate(w http.ResponseWriter, r *http.Request) {
+	id, err := getFXRateID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	rate, err := h.db.GetFXRate(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("exchange rate"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, rate)
+}
+
+// CreateFXRate creates a new exchange rate
+// @Summary Create an exchange rate
+// @Description Creates an exchange rate for a currency pair, effective from the given date. Requires the fx-rates:write scope, which only admins have.
+// @Tags fx-rates
+// @Accept json
+// @Produce json
+// @Param rate body models.FXRateInput true "Rate information"
+// @Success 201 {object} map[string]int64
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /fx-rates [post]
+func (h *FXRateHandler) CreateFXRate(w http.ResponseWriter, r *http.Request) {
+	var rateInput models.FXRateInput
+	err := json.NewDecoder(r.Body).Decode(&rateInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateFXRateInput(&rateInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Create rate
+	id, err := h.db.CreateFXRate(r.Context(), &rateInput)
+	if err != nil {
+		if errors.Is(err, db.ErrConflict) {
+			writeError(w, r, withDetail(err, "a rate for this currency pair and date already exists"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"id": id})
+}
+
+// UpdateFXRate updates an existing exchange rate
+// @Summary Update an exchange rate
+// @Description Updates an existing exchange rate. Requires the fx-rates:write scope, which only admins have.
+// @Tags fx-rates
+// @Accept json
+// @Produce json
+// @Param id path int true "Rate ID"
+// @Param rate body models.FXRateInput true "Rate information"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /fx-rates/{id} [put]
+func (h *FXRateHandler) UpdateFXRate(w http.ResponseWriter, r *http.Request) {
+	id, err := getFXRateID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var rateInput models.FXRateInput
+	err = json.NewDecoder(r.Body).Decode(&rateInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateFXRateInput(&rateInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Update rate
+	err = h.db.UpdateFXRate(r.Context(), id, &rateInput)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			writeError(w, r, notFound("exchange rate"))
+		case errors.Is(err, db.ErrConflict):
+			writeError(w, r, withDetail(err, "a rate for this currency pair and date already exists"))
+		default:
+			writeError(w, r, err)
+		}
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Exchange rate updated successfully"})
+}
+
+// DeleteFXRate deletes an exchange rate
+// @Summary Delete an exchange rate
+// @Description Deletes an exchange rate. Requires the fx-rates:write scope, which only admins have.
+// @Tags fx-rates
+// @Produce json
+// @Param id path int true "Rate ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /fx-rates/{id} [delete]
+func (h *FXRateHandler) DeleteFXRate(w http.ResponseWriter, r *http.Request) {
+	id, err := getFXRateID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	err = h.db.DeleteFXRate(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("exchange rate"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Exchange rate deleted successfully"})
+}
+
+// validateFXRateInput validates and normalizes an exchange rate input
+func validateFXRateInput(rateInput *models.FXRateInput) error {
+	verr := &db.ValidationError{}
+
+	var err error
+	if rateInput.BaseCurrency == "" {
+		verr.Add("base_currency", "is required")
+	} else if rateInput.BaseCurrency, err = models.NormalizeCurrency(rateInput.BaseCurrency); err != nil {
+		verr.Add("base_currency", models.CurrencyErrorReason(err))
+	}
+	if rateInput.QuoteCurrency == "" {
+		verr.Add("quote_currency", "is required")
+	} else if rateInput.QuoteCurrency, err = models.NormalizeCurrency(rateInput.QuoteCurrency); err != nil {
+		verr.Add("quote_currency", models.CurrencyErrorReason(err))
+	}
+	if rateInput.BaseCurrency != "" && rateInput.BaseCurrency == rateInput.QuoteCurrency {
+		verr.Add("quote_currency", "must differ from base_currency")
+	}
+
+	if rateInput.Rate == "" {
+		verr.Add("rate", "is required")
+	}
+
+	if _, err := time.Parse("2006-01-02", rateInput.EffectiveDate); err != nil {
+		verr.Add("effective_date", "is required in YYYY-MM-DD format")
+	}
+
+	return verr.Err()
+}
+
+// parseCurrencyParam parses an optional currency query parameter
+func parseCurrencyParam(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	return models.NormalizeCurrency(value)
+}
+
+// getFXRateID extracts the exchange rate ID from the URL
+func getFXRateID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid rate ID")
+	}
+	return id, nil
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/migrations"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// readinessTimeout bounds all checks of a single readiness probe
+const readinessTimeout = 2 * time.Second
+
+// HealthHandler handles liveness and readiness probes
+type HealthHandler struct {
+	db           db.Database
+	migrator     *migrations.Migrator
+	shuttingDown atomic.Bool
+}
+
+// NewHealthHandler creates a new health handler. The migrator is used to
+// check for pending migrations and may be nil for databases without schema
+// migrations.
+func NewHealthHandler(database db.Database, migrator *migrations.Migrator) *HealthHandler {
+	return &HealthHandler{db: database, migrator: migrator}
+}
+
+// SetShuttingDown marks the server as shutting down, which makes it not ready
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Liveness reports that the process is running
+// @Summary Liveness probe
+// @Description Reports that the process is running; does not check any dependencies
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.HealthStatus
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, models.HealthStatus{Status: models.HealthOK})
+}
+
+// Readiness reports whether the server can handle requests
+// @Summary Readiness probe
+// @Description Checks that the database is reachable, that all schema migrations are applied and that the server is not shutting down
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.HealthStatus
+// @Failure 503 {object} models.HealthStatus
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	status := models.HealthStatus{
+		Status: models.HealthOK,
+		Checks: map[string]models.HealthCheck{
+			"database":   h.checkDatabase(ctx),
+			"migrations": h.checkMigrations(ctx),
+			"shutdown":   h.checkShutdown(),
+		},
+	}
+	for _, check := range status.Checks {
+		if check.Status == models.HealthFail {
+			status.Status = models.HealthFail
+		}
+	}
+
+	writeHealth(w, status)
+}
+
+// checkDatabase pings the database. The probe is unauthenticated, so the
+// error, which may name the database host, is only logged.
+func (h *HealthHandler) checkDatabase(ctx context.Context) models.HealthCheck {
+	if err := h.db.Ping(ctx); err != nil {
+		slog.ErrorContext(ctx, "readiness check failed", "check", "database", "error", err)
+		return models.HealthCheck{Status: models.HealthFail, Detail: "database is unreachable"}
+	}
+	return models.HealthCheck{Status: models.HealthOK}
+}
+
+// checkMigrations checks that no schema migrations are pending
+func (h *HealthHandler) checkMigrations(ctx context.Context) models.HealthCheck {
+	if h.migrator == nil {
+		return models.HealthCheck{Status: models.HealthSkipped, Detail: "database has no schema migrations"}
+	}
+
+	pending, err := h.migrator.Pending(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "readiness check failed", "check", "migrations", "error", err)
+		return models.HealthCheck{Status: models.HealthFail, Detail: "migration status is unavailable"}
+	}
+	if pending > 0 {
+		return models.HealthCheck{Status: models.HealthFail, Detail: fmt.Sprintf("%d pending migrations", pending)}
+	}
+	return models.HealthCheck{Status: models.HealthOK}
+}
+
+// checkShutdown fails once the server has started shutting down
+func (h *HealthHandler) checkShutdown() models.HealthCheck {
+	if h.shuttingDown.Load() {
+		return models.HealthCheck{Status: models.HealthFail, Detail: "shutdown in progress"}
+	}
+	return models.HealthCheck{Status: models.HealthOK}
+}
+
+// writeHealth writes a health response with 503 if the status is not ok
+func writeHealth(w http.ResponseWriter, status models.HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if status.Status != models.HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
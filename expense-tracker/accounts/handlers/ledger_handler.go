@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// LedgerHandler handles ledger-related requests
+type LedgerHandler struct {
+	db db.Database
+}
+
+// NewLedgerHandler creates a new ledger handler
+func NewLedgerHandler(database db.Database) *LedgerHandler {
+	return &LedgerHandler{db: database}
+}
+
+// GetLedgers returns the ledgers of the user
+// @Summary Get ledgers
+// @Description Returns the ledgers the user owns or is a member of, with the role of the user
+// @Tags ledgers
+// @Produce json
+// @Success 200 {array} models.Ledger
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /ledgers [get]
+func (h *LedgerHandler) GetLedgers(w http.ResponseWriter, r *http.Request) {
+	ledgers, err := h.db.GetLedgers(r.Context(), userID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if ledgers == nil {
+		ledgers = []models.Ledger{}
+	}
+
+	responseJSON(w, ledgers)
+}
+
+// GetLedger returns a single ledger
+// @Summary Get a single ledger
+// @Description Returns a single ledger with the role of the user
+// @Tags ledgers
+// @Produce json
+// @Param id path int true "Ledger ID"
+// @Success 200 {object} models.Ledger
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /ledgers/{id} [get]
+func (h *LedgerHandler) GetLedger(w http.ResponseWriter, r *http.Request) {
+	id, err := getLedgerID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	ledger, err := h.db.GetLedger(r.Context(), userID(r), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("ledger"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, ledger)
+}
+
+// CreateLedger creates a new ledger
+// @Summary Create a new ledger
+// @Description Creates a new ledger owned by the user
+// @Tags ledgers
+// @Accept json
+// @Produce json
+// @Param ledger body models.LedgerInput true "Ledger information"
+// @Success 201 {object} map[string]int64
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /ledgers [post]
+func (h *LedgerHandler) CreateLedger(w http.ResponseWriter, r *http.Request) {
+	var ledgerInput models.LedgerInput
+	err := json.NewDecoder(r.Body).Decode(&ledgerInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateLedgerInput(&ledgerInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	id, err := h.db.CreateLedger(r.Context(), userID(r), &ledgerInput)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"id": id})
+}
+
+// UpdateLedger renames a ledger
+// @Summary Update a ledger
+// @Description Renames a ledger; this requires the owner role
+// @Tags ledgers
+// @Accept json
+// @Produce json
+// @Param id path int true "Ledger ID"
+// @Param ledger body models.LedgerInput true "Ledger information"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /ledgers/{id} [put]
+func (h *LedgerHandler) UpdateLedger(w http.ResponseWriter, r *http.Request) {
+	id, err := getLedgerID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var ledgerInput models.LedgerInput
+	err = json.NewDecoder(r.Body).Decode(&ledgerInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateLedgerInput(&ledgerInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.db.UpdateLedger(r.Context(), userID(r), id, &ledgerInput)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("ledger"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Ledger updated successfully"})
+}
+
+// DeleteLedger deletes a ledger
+// @Summary Delete a ledger
+// @Description Deletes a ledger and its shares; this requires the owner role. The bills of the ledger are kept outside of ledgers.
+// @Tags ledgers
+// @Produce json
+// @Param id path int true "Ledger ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /ledgers/{id} [delete]
+func (h *LedgerHandler) DeleteLedger(w http.ResponseWriter, r *http.Request) {
+	id, err := getLedgerID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	err = h.db.DeleteLedger(r.Context(), userID(r), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("ledger"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Ledger deleted successfully"})
+}
+
+// getLedgerID extracts the ledger ID from the URL
+func getLedgerID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid ledger ID")
+	}
+	return id, nil
+}
+
+// validateLedgerInput validates and normalizes a ledger input
+func validateLedgerInput(ledgerInput *models.LedgerInput) error {
+	verr := &db.ValidationError{}
+
+	ledgerInput.Name = strings.TrimSpace(ledgerInput.Name)
+	if ledgerInput.Name == "" {
+		verr.Add("name", "is required")
+	} else if utf8.RuneCountInString(ledgerInput.Name) > models.MaxNameLength {
+		verr.Add("name", "must be at most 255 characters")
+	}
+
+	return verr.Err()
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// MerchantHandler handles merchant-related requests
+type MerchantHandler struct {
+	db db.Database
+}
+
+// NewMerchantHandler creates a new merchant handler
+func NewMerchantHandler(database db.Database) *MerchantHandler {
+	return &MerchantHandler{db: database}
+}
+
+// GetMerchants returns the merchants of the user
+// @Summary Get merchants
+// @Description Returns the merchants of the user by name with their aliases, default category and number of bills
+// @Tags merchants
+// @Produce json
+// @Success 200 {array} models.Merchant
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /merchants [get]
+func (h *MerchantHandler) GetMerchants(w http.ResponseWriter, r *http.Request) {
+	merchants, err := h.db.GetMerchants(r.Context(), userID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if merchants == nil {
+		merchants = []models.Merchant{}
+	}
+
+	responseJSON(w, merchants)
+}
+
+// GetMerchant returns a single merchant
+// @Summary Get a single merchant
+// @Description Returns a merchant of the user
+// @Tags merchants
+// @Produce json
+// @Param id path int true "Merchant ID"
+// @Success 200 {object} models.Merchant
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /merchants/{id} [get]
+func (h *MerchantHandler) GetMerchant(w http.ResponseWriter, r *http.Request) {
+	id, err := getMerchantID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	merchant, err := h.db.GetMerchant(r.Context(), userID(r), id)
+	if err != nil {
+		writeMerchantError(w, r, err)
+		return
+	}
+
+	responseJSON(w, merchant)
+}
+
+// CreateMerchant creates a new merchant
+// @Summary Create a new merchant
+// @Description Creates a new merchant owned by the user with aliases that bills with a matching merchant name are assigned to
+// @Tags merchants
+// @Accept json
+// @Produce json
+// @Param merchant body models.MerchantInput true "Merchant information"
+// @Success 201 {object} map[string]int64
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /merchants [post]
+func (h *MerchantHandler) CreateMerchant(w http.ResponseWriter, r *http.Request) {
+	var merchantInput models.MerchantInput
+	err := json.NewDecoder(r.Body).Decode(&merchantInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateMerchantInput(&merchantInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	id, err := h.db.CreateMerchant(r.Context(), userID(r), &merchantInput)
+	if err != nil {
+		writeMerchantError(w, r, err)
+		return
+	}
+
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"id": id})
+}
+
+// UpdateMerchant updates a merchant
+// @Summary Update a merchant
+// @Description Updates a merchant of the user, replacing its aliases; bills keep their merchant and category
+// @Tags merchants
+// @Accept json
+// @Produce json
+// @Param id path int true "Merchant ID"
+// @Param merchant body models.MerchantInput true "Merchant information"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /merchants/{id} [put]
+func (h *MerchantHandler) UpdateMerchant(w http.ResponseWriter, r *http.Request) {
+	id, err := getMerchantID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var merchantInput models.MerchantInput
+	err = json.NewDecoder(r.Body).Decode(&merchantInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateMerchantInput(&merchantInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.db.UpdateMerchant(r.Context(), userID(r), id, &merchantInput)
+	if err != nil {
+		writeMerchantError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Merchant updated successfully"})
+}
+
+// DeleteMerchant deletes a merchant
+// @Summary Delete a merchant
+// @Description Deletes a merchant of the user; its bills are kept without a merchant
+// @Tags merchants
+// @Produce json
+// @Param id path int true "Merchant ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /merchants/{id} [delete]
+func (h *MerchantHandler) DeleteMerchant(w http.ResponseWriter, r *http.Request) {
+	id, err := getMerchantID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	err = h.db.DeleteMerchant(r.Context(), userID(r), id)
+	if err != nil {
+		writeMerchantError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Merchant deleted successfully"})
+}
+
+// writeMerchantError writes an error of reading or changing a merchant
+func writeMerchantError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, r, notFound("merchant"))
+	case errors.Is(err, db.ErrConflict):
+		writeError(w, r, withDetail(err, "a merchant with this name or alias already exists"))
+	default:
+		writeError(w, r, err)
+	}
+}
+
+// getMerchantID extracts the merchant ID from the URL
+func getMerchantID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid merchant ID")
+	}
+	return id, nil
+}
+
+// validateMerchantInput validates and normalizes a merchant input
+func validateMerchantInput(merchantInput *models.MerchantInput) error {
+	verr := &db.ValidationError{}
+
+	merchantInput.Name = strings.TrimSpace(merchantInput.Name)
+	if merchantInput.Name == "" {
+		verr.Add("name", "is required")
+	} else if utf8.RuneCountInString(merchantInput.Name) > models.MaxNameLength {
+		verr.Add("name", "must be at most 255 characters")
+	}
+	for _, alias := range merchantInput.Aliases {
+		if utf8.RuneCountInString(alias) > models.MaxNameLength {
+			verr.Add("aliases", "must each be at most 255 characters")
+			break
+		}
+	}
+	merchantInput.Aliases = models.NormalizeAliases(merchantInput.Aliases)
+	if len(merchantInput.Aliases) > models.MaxAliases {
+		verr.Add("aliases", fmt.Sprintf("must not contain more than %d aliases", models.MaxAliases))
+	}
+	if merchantInput.CategoryID != nil && *merchantInput.CategoryID < 1 {
+		verr.Add("category_id", "must be a positive ID")
+	}
+
+	return verr.Err()
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// PaymentHandler handles requests for the payments of bills
+type PaymentHandler struct {
+	db     db.Database
+	limits models.ValidationLimits
+}
+
+// NewPaymentHandler creates a new payment handler that accepts payments
+// within the limits of bills
+func NewPaymentHandler(database db.Database, limits models.ValidationLimits) *PaymentHandler {
+	return &PaymentHandler{db: database, limits: limits}
+}
+
+// GetBillPayments returns the payments of a bill
+// @Summary Get the payments of a bill
+// @Description Returns the payments of a bill by date
+// @Tags bill-payments
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Success 200 {array} models.Payment
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/payments [get]
+func (h *PaymentHandler) GetBillPayments(w http.ResponseWriter, r *http.Request) {
+	billID, err := getBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	payments, err := h.db.GetBillPayments(r.Context(), userID(r), billID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("bill"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, payments)
+}
+
+// GetBillPayment returns a single payment of a bill
+// @Summary Get a single payment
+// @Description Returns a single payment of a bill
+// @Tags bill-payments
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Param paymentId path int true "Payment ID"
+// @Success 200 {object} models.Payment
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/payments/{paymentId} [get]
+func (h *PaymentHandler) GetBillPayment(w http.ResponseWriter, r *http.Request) {
+	billID, paymentID, err := getPaymentIDs(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	payment, err := h.db.GetBillPayment(r.Context(), userID(r), billID, paymentID)
+	if err != nil {
+		h.writePaymentError(w, r, billID, err)
+		return
+	}
+
+	responseJSON(w, payment)
+}
+
+// CreateBillPayment records a payment of a bill
+// @Summary Record a payment of a bill
+// @Description Records a payment of a bill in its currency, dated today unless given. The bill is paid once its payments cover the total. Requires the payer role on shared bills.
+// @Tags bill-payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Param payment body models.PaymentInput true "Payment information"
+// @Success 201 {object} map[string]int64
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/payments [post]
+func (h *PaymentHandler) CreateBillPayment(w http.ResponseWriter, r *http.Request) {
+	billID, err := getBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var paymentInput models.PaymentInput
+	err = json.NewDecoder(r.Body).Decode(&paymentInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := h.validatePaymentInput(&paymentInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !h.checkPaymentCurrency(w, r, billID, &paymentInput) {
+		return
+	}
+
+	id, err := h.db.CreateBillPayment(r.Context(), userID(r), billID, &paymentInput)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("bill"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"id": id})
+}
+
+// UpdateBillPayment updates a payment of a bill
+// @Summary Update a payment
+// @Description Updates a payment of a bill and whether the bill is paid. Requires the payer role on shared bills.
+// @Tags bill-payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Param paymentId path int true "Payment ID"
+// @Param payment body models.PaymentInput true "Payment information"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/payments/{paymentId} [put]
+func (h *PaymentHandler) UpdateBillPayment(w http.ResponseWriter, r *http.Request) {
+	billID, paymentID, err := getPaymentIDs(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var paymentInput models.PaymentInput
+	err = json.NewDecoder(r.Body).Decode(&paymentInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := h.validatePaymentInput(&paymentInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !h.checkPaymentCurrency(w, r, billID, &paymentInput) {
+		return
+	}
+
+	err = h.db.UpdateBillPayment(r.Context(), userID(r), billID, paymentID, &paymentInput)
+	if err != nil {
+		h.writePaymentError(w, r, billID, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Payment updated successfully"})
+}
+
+// DeleteBillPayment deletes a payment of a bill
+// @Summary Delete a payment
+// @Description Deletes a payment of a bill. A bill whose last payment is deleted is unpaid. Requires the payer role on shared bills.
+// @Tags bill-payments
+// @Produce json
+// @Param id path int true "Bill ID"
+// @Param paymentId path int true "Payment ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/payments/{paymentId} [delete]
+func (h *PaymentHandler) DeleteBillPayment(w http.ResponseWriter, r *http.Request) {
+	billID, paymentID, err := getPaymentIDs(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	err = h.db.DeleteBillPayment(r.Context(), userID(r), billID, paymentID)
+	if err != nil {
+		h.writePaymentError(w, r, billID, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Payment deleted successfully"})
+}
+
+// writePaymentError writes an error of reading or changing a payment. The
+// database reports a missing bill and a missing payment alike, so the bill
+// is looked up again to tell them apart.
+func (h *PaymentHandler) writePaymentError(w http.ResponseWriter, r *http.Request, billID int64, err error) {
+	if !errors.Is(err, db.ErrNotFound) {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := h.db.GetBillPayments(r.Context(), userID(r), billID); errors.Is(err, db.ErrNotFound) {
+		writeError(w, r, notFound("bill"))
+		return
+	}
+	writeError(w, r, notFound("payment"))
+}
+
+// checkPaymentCurrency checks that the amount of a payment fits the currency
+// of the bill, e.g. that it is a whole amount of JPY. It writes a 404
+// response if the bill does not exist and a 400 response if the amount does
+// not fit.
+func (h *PaymentHandler) checkPaymentCurrency(w http.ResponseWriter, r *http.Request, billID int64, paymentInput *models.PaymentInput) bool {
+	bill, err := h.db.GetBill(r.Context(), userID(r), billID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("bill"))
+			return false
+		}
+		writeError(w, r, err)
+		return false
+	}
+	if !paymentInput.Amount.InCurrency(bill.Currency) {
+		writeError(w, r, db.NewValidationError("amount", models.CurrencyAmountMessage(bill.Currency)))
+		return false
+	}
+	return true
+}
+
+// validatePaymentInput applies the defaults of a payment input and validates it
+func (h *PaymentHandler) validatePaymentInput(paymentInput *models.PaymentInput) error {
+	today := time.Now().UTC()
+	paymentInput.Normalize(today)
+	if errs := paymentInput.Validate(h.limits, today); len(errs) > 0 {
+		return &db.ValidationError{Fields: errs}
+	}
+	return nil
+}
+
+// getPaymentIDs extracts the bill ID and payment ID from the URL
+func getPaymentIDs(r *http.Request) (int64, int64, error) {
+	billID, err := getBillID(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	paymentID, err := strconv.ParseInt(mux.Vars(r)["paymentId"], 10, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid payment ID")
+	}
+	return billID, paymentID, nil
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/jo/choreo-tutorial/accounts/auth"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/logging"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// Error codes of problem responses. They are part of the API and must not change.
+const (
+	CodeInvalidRequest      = "invalid_request"
+	CodeUnauthenticated     = "unauthenticated"
+	CodeForbidden           = "forbidden"
+	CodeValidationFailed    = "validation_failed"
+	CodeNotFound            = "not_found"
+	CodeConflict            = "conflict"
+	CodeConstraintViolation = "constraint_violation"
+	CodeFXRateUnavailable   = "fx_rate_unavailable"
+	CodeRouteNotFound       = "route_not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeCancelled           = "request_cancelled"
+	CodeTimeout             = "timeout"
+	CodeInternal            = "internal_error"
+)
+
+// problemTypePrefix makes a problem type URI of an error code
+const problemTypePrefix = "urn:accounts:problem:"
+
+// errInvalidRequest marks requests that cannot be parsed, e.g. malformed
+// JSON, path parameters or query parameters
+var errInvalidRequest = errors.New("invalid request")
+
+// errForbidden marks requests of a principal that lacks the required scope
+var errForbidden = errors.New("forbidden")
+
+// forbidden reports whether err denies a request for a missing scope or an
+// insufficient role on a bill or ledger
+func forbidden(err error) bool {
+	return errors.Is(err, errForbidden) || errors.Is(err, db.ErrForbidden)
+}
+
+// detailError attaches a message for clients to an error of the taxonomy
+type detailError struct {
+	err    error
+	detail string
+}
+
+func (e *detailError) Error() string {
+	return e.detail
+}
+
+func (e *detailError) Unwrap() error {
+	return e.err
+}
+
+// withDetail returns err with a message that is shown to clients
+func withDetail(err error, detail string) error {
+	return &detailError{err: err, detail: detail}
+}
+
+// invalidRequest reports a request that cannot be parsed; the message of err is shown to clients
+func invalidRequest(err error) error {
+	return withDetail(errInvalidRequest, err.Error())
+}
+
+// notFound reports a missing resource, e.g. notFound("bill")
+func notFound(resource string) error {
+	return withDetail(db.ErrNotFound, resource+" not found")
+}
+
+// writeError writes err as a problem response. The status and code follow
+// from the kind of error; errors that are not part of the taxonomy are logged
+// and reported as internal errors without their details, which may reveal
+// the schema or the database server.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	problem := models.Problem{Instance: r.URL.Path, RequestID: logging.RequestIDFromContext(ctx)}
+
+	var validationErr *db.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		problem.Status, problem.Code = http.StatusBadRequest, CodeValidationFailed
+		problem.Detail = "the request contains invalid fields"
+		problem.Errors = validationErr.Fields
+	case errors.Is(err, errInvalidRequest):
+		problem.Status, problem.Code = http.StatusBadRequest, CodeInvalidRequest
+	case errors.Is(err, auth.ErrUnauthenticated):
+		problem.Status, problem.Code = http.StatusUnauthorized, CodeUnauthenticated
+		problem.Detail = "the request does not identify a user"
+		var tokenErr *auth.TokenError
+		if errors.As(err, &tokenErr) {
+			problem.Detail = tokenErr.Description
+			w.Header().Set("WWW-Authenticate", tokenErr.Challenge())
+		}
+	case forbidden(err):
+		problem.Status, problem.Code = http.StatusForbidden, CodeForbidden
+		problem.Detail = "the request is not permitted"
+		var roleErr *db.RoleError
+		if errors.As(err, &roleErr) {
+			problem.Detail = roleErr.Error()
+		}
+	case errors.Is(err, db.ErrNotFound):
+		problem.Status, problem.Code = http.StatusNotFound, CodeNotFound
+		problem.Detail = "record not found"
+	case errors.Is(err, db.ErrConflict):
+		problem.Status, problem.Code = http.StatusConflict, CodeConflict
+		problem.Detail = "record already exists"
+	case errors.Is(err, db.ErrConstraint):
+		slog.InfoContext(ctx, "constraint violation", "error", err)
+		problem.Status, problem.Code = http.StatusConflict, CodeConstraintViolation
+		problem.Detail = "the request violates a data integrity rule"
+	case errors.Is(err, errNoFXRate):
+		problem.Status, problem.Code = http.StatusUnprocessableEntity, CodeFXRateUnavailable
+		problem.Detail = err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.WarnContext(ctx, "database operation timed out", "error", err)
+		problem.Status, problem.Code = http.StatusGatewayTimeout, CodeTimeout
+		problem.Detail = "database operation timed out"
+	case errors.Is(err, context.Canceled):
+		slog.InfoContext(ctx, "request cancelled", "error", err)
+		problem.Status, problem.Code = http.StatusServiceUnavailable, CodeCancelled
+		problem.Detail = "request cancelled"
+	default:
+		slog.ErrorContext(ctx, "database operation failed", "error", err)
+		problem.Status, problem.Code = http.StatusInternalServerError, CodeInternal
+		problem.Detail = "internal server error"
+	}
+
+	// Errors with a message for clients replace the generic one
+	var detailed *detailError
+	if errors.As(err, &detailed) {
+		problem.Detail = detailed.detail
+	}
+
+	writeProblem(w, problem)
+}
+
+// writeProblem completes and writes a problem response
+func writeProblem(w http.ResponseWriter, problem models.Problem) {
+	problem.Type = problemTypePrefix + problem.Code
+	problem.Title = http.StatusText(problem.Status)
+
+	w.Header().Set("Content-Type", models.ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// NotFound responds to requests that match no route
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, models.Problem{
+		Status:    http.StatusNotFound,
+		Code:      CodeRouteNotFound,
+		Detail:    "no route matches " + r.URL.Path,
+		Instance:  r.URL.Path,
+		RequestID: logging.RequestIDFromContext(r.Context()),
+	})
+}
+
+// MethodNotAllowed responds to requests whose route does not support the method
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, models.Problem{
+		Status:    http.StatusMethodNotAllowed,
+		Code:      CodeMethodNotAllowed,
+		Detail:    r.Method + " is not supported by " + r.URL.Path,
+		Instance:  r.URL.Path,
+		RequestID: logging.RequestIDFromContext(r.Context()),
+	})
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// RecurringBillHandler handles recurring bill-related requests
+type RecurringBillHandler struct {
+	db     db.Database
+	limits models.ValidationLimits
+}
+
+// NewRecurringBillHandler creates a new recurring bill handler that accepts
+// recurring bills within the limits of bills
+func NewRecurringBillHandler(database db.Database, limits models.ValidationLimits) *RecurringBillHandler {
+	return &RecurringBillHandler{db: database, limits: limits}
+}
+
+// GetRecurringBills returns the recurring bills of the user
+// @Summary Get recurring bills
+// @Description Returns the recurring bills of the user by title with their next date, upcoming skipped dates and number of generated bills
+// @Tags recurring-bills
+// @Produce json
+// @Success 200 {array} models.RecurringBill
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /recurring-bills [get]
+func (h *RecurringBillHandler) GetRecurringBills(w http.ResponseWriter, r *http.Request) {
+	recurring, err := h.db.GetRecurringBills(r.Context(), userID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if recurring == nil {
+		recurring = []models.RecurringBill{}
+	}
+
+	responseJSON(w, recurring)
+}
+
+// GetRecurringBill returns a single recurring bill
+// @Summary Get a single recurring bill
+// @Description Returns a recurring bill of the user
+// @Tags recurring-bills
+// @Produce json
+// @Param id path int true "Recurring bill ID"
+// @Success 200 {object} models.RecurringBill
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /recurring-bills/{id} [get]
+func (h *RecurringBillHandler) GetRecurringBill(w http.ResponseWriter, r *http.Request) {
+	id, err := getRecurringBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	recurring, err := h.db.GetRecurringBill(r.Context(), userID(r), id)
+	if err != nil {
+		writeRecurringBillError(w, r, err)
+		return
+	}
+
+	responseJSON(w, recurring)
+}
+
+// CreateRecurringBill creates a new recurring bill
+// @Summary Create a new recurring bill
+// @Description Creates a new recurring bill owned by the user. Bills with a single item of the amount are generated in the background ahead of each occurrence of the recurrence rule.
+// @Tags recurring-bills
+// @Accept json
+// @Produce json
+// @Param recurring body models.RecurringBillInput true "Recurring bill information"
+// @Success 201 {object} map[string]int64
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /recurring-bills [post]
+func (h *RecurringBillHandler) CreateRecurringBill(w http.ResponseWriter, r *http.Request) {
+	var recurringInput models.RecurringBillInput
+	err := json.NewDecoder(r.Body).Decode(&recurringInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := h.validateRecurringBillInput(&recurringInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	id, err := h.db.CreateRecurringBill(r.Context(), userID(r), &recurringInput)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"id": id})
+}
+
+// UpdateRecurringBill updates a recurring bill
+// @Summary Update a recurring bill
+// @Description Updates a recurring bill of the user. Bills already generated are kept, and occurrences that were generated or skipped are not generated again.
+// @Tags recurring-bills
+// @Accept json
+// @Produce json
+// @Param id path int true "Recurring bill ID"
+// @Param recurring body models.RecurringBillInput true "Recurring bill information"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /recurring-bills/{id} [put]
+func (h *RecurringBillHandler) UpdateRecurringBill(w http.ResponseWriter, r *http.Request) {
+	id, err := getRecurringBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var recurringInput models.RecurringBillInput
+	err = json.NewDecoder(r.Body).Decode(&recurringInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := h.validateRecurringBillInput(&recurringInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.db.UpdateRecurringBill(r.Context(), userID(r), id, &recurringInput)
+	if err != nil {
+		writeRecurringBillError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Recurring bill updated successfully"})
+}
+
+// DeleteRecurringBill deletes a recurring bill
+// @Summary Delete a recurring bill
+// @Description Deletes a recurring bill of the user; the bills it generated are kept
+// @Tags recurring-bills
+// @Produce json
+// @Param id path int true "Recurring bill ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /recurring-bills/{id} [delete]
+func (h *RecurringBillHandler) DeleteRecurringBill(w http.ResponseWriter, r *http.Request) {
+	id, err := getRecurringBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	err = h.db.DeleteRecurringBill(r.Context(), userID(r), id)
+	if err != nil {
+		writeRecurringBillError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Recurring bill deleted successfully"})
+}
+
+// SetRecurringBillPaused pauses or resumes a recurring bill
+// @Summary Pause or resume a recurring bill
+// @Description Sets whether a recurring bill is paused. No bills are generated while it is paused; occurrences that come due meanwhile are passed over.
+// @Tags recurring-bills
+// @Accept json
+// @Produce json
+// @Param id path int true "Recurring bill ID"
+// @Param paused body models.PausedInput true "Paused status"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /recurring-bills/{id}/paused [put]
+func (h *RecurringBillHandler) SetRecurringBillPaused(w http.ResponseWriter, r *http.Request) {
+	id, err := getRecurringBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var pausedInput models.PausedInput
+	err = json.NewDecoder(r.Body).Decode(&pausedInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	err = h.db.SetRecurringBillPaused(r.Context(), userID(r), id, pausedInput.Paused)
+	if err != nil {
+		writeRecurringBillError(w, r, err)
+		return
+	}
+
+	if pausedInput.Paused {
+		responseJSON(w, map[string]string{"message": "Recurring bill paused"})
+		return
+	}
+	responseJSON(w, map[string]string{"message": "Recurring bill resumed"})
+}
+
+// SkipRecurringBill skips an occurrence of a recurring bill
+// @Summary Skip an occurrence of a recurring bill
+// @Description Skips an upcoming occurrence of a recurring bill, so that no bill is generated for it
+// @Tags recurring-bills
+// @Accept json
+// @Produce json
+// @Param id path int true "Recurring bill ID"
+// @Param occurrence body models.OccurrenceInput true "Occurrence to skip"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /recurring-bills/{id}/skips [post]
+func (h *RecurringBillHandler) SkipRecurringBill(w http.ResponseWriter, r *http.Request) {
+	id, err := getRecurringBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var occurrenceInput models.OccurrenceInput
+	err = json.NewDecoder(r.Body).Decode(&occurrenceInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+	date, err := h.parseDateField("date", occurrenceInput.Date)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.db.SkipRecurringBill(r.Context(), userID(r), id, date)
+	if err != nil {
+		writeRecurringBillError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Occurrence skipped"})
+}
+
+// EndRecurringBill ends a recurring bill
+// @Summary End a recurring bill
+// @Description Ends a recurring bill on the end date, today unless given. No bills are generated for later occurrences; bills already generated are kept.
+// @Tags recurring-bills
+// @Accept json
+// @Produce json
+// @Param id path int true "Recurring bill ID"
+// @Param end body models.EndInput false "End date"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /recurring-bills/{id}/end [post]
+func (h *RecurringBillHandler) EndRecurringBill(w http.ResponseWriter, r *http.Request) {
+	id, err := getRecurringBillID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// The body is optional
+	var endInput models.EndInput
+	err = json.NewDecoder(r.Body).Decode(&endInput)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	if endInput.EndDate != "" {
+		if date, err = h.parseDateField("end_date", endInput.EndDate); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	err = h.db.EndRecurringBill(r.Context(), userID(r), id, date)
+	if err != nil {
+		writeRecurringBillError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Recurring bill ended"})
+}
+
+// writeRecurringBillError writes an error of reading or changing a
+// recurring bill
+func writeRecurringBillError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, r, notFound("recurring bill"))
+		return
+	}
+	writeError(w, r, err)
+}
+
+// getRecurringBillID extracts the recurring bill ID from the URL
+func getRecurringBillID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid recurring bill ID")
+	}
+	return id, nil
+}
+
+// validateRecurringBillInput validates a recurring bill input and normalizes
+// its currency and rule
+func (h *RecurringBillHandler) validateRecurringBillInput(recurringInput *models.RecurringBillInput) error {
+	if errs := recurringInput.Validate(h.limits, time.Now().UTC()); len(errs) > 0 {
+		return &db.ValidationError{Fields: errs}
+	}
+	rule, err := models.ParseRule(recurringInput.Rule)
+	if err != nil {
+		return err
+	}
+	recurringInput.Rule = rule.String()
+	recurringInput.Currency, err = models.NormalizeCurrency(recurringInput.Currency)
+	return err
+}
+
+// parseDateField parses the date of a required input field. Like due dates,
+// the date must be within limits.DueDateYears of today, which also bounds
+// the occurrences checked for it.
+func (h *RecurringBillHandler) parseDateField(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, db.NewValidationError(field, "is required")
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, db.NewValidationError(field, "must be a date in YYYY-MM-DD format")
+	}
+	today := time.Now().UTC()
+	if date.Before(today.AddDate(-h.limits.DueDateYears, 0, 0)) || date.After(today.AddDate(h.limits.DueDateYears, 0, 0)) {
+		return time.Time{}, db.NewValidationError(field, fmt.Sprintf("must be within %d years of today", h.limits.DueDateYears))
+	}
+	return date, nil
+}
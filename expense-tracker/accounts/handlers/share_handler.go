@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// ShareHandler handles the shares of bills or of ledgers
+type ShareHandler struct {
+	db        db.Database
+	shareType string
+}
+
+// NewShareHandler creates a new share handler for the shares of the type,
+// models.ShareTypeBill or models.ShareTypeLedger. The routes of the handler
+// have the ID of the bill or ledger in the id variable.
+func NewShareHandler(database db.Database, shareType string) *ShareHandler {
+	return &ShareHandler{db: database, shareType: shareType}
+}
+
+// GetShares returns the members of a bill or ledger
+// @Summary Get the shares of a bill or ledger
+// @Description Returns the members a bill or ledger is shared with and their roles. Only owners see the emails of other members.
+// @Tags shares
+// @Produce json
+// @Param id path int true "Bill or ledger ID"
+// @Success 200 {array} models.Share
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/shares [get]
+// @Router /ledgers/{id}/shares [get]
+func (h *ShareHandler) GetShares(w http.ResponseWriter, r *http.Request) {
+	target, err := h.getTarget(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	role, err := h.targetRole(r, target)
+	if err != nil {
+		writeTargetError(w, r, target, err)
+		return
+	}
+
+	shares, err := h.db.GetShares(r.Context(), userID(r), target)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound(h.shareType))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+	if shares == nil {
+		shares = []models.Share{}
+	}
+
+	// Owners invite members and need their emails; the other members only
+	// see their own
+	if !role.Allows(models.RoleOwner) {
+		for i := range shares {
+			if shares[i].UserID != userID(r) {
+				shares[i].Email = ""
+			}
+		}
+	}
+
+	responseJSON(w, shares)
+}
+
+// CreateShare shares a bill or ledger with a user
+// @Summary Share a bill or ledger
+// @Description Shares a bill or ledger with the user with the subject or email in a role; this requires the owner role. Users must have signed in before; they are invited by subject or by an email that only they verified.
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Param id path int true "Bill or ledger ID"
+// @Param share body models.ShareInput true "Member and role"
+// @Success 201 {object} map[string]int64
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/shares [post]
+// @Router /ledgers/{id}/shares [post]
+func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	target, err := h.getTarget(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var shareInput models.ShareInput
+	err = json.NewDecoder(r.Body).Decode(&shareInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateShareInput(&shareInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Check the bill or ledger and the owner role before resolving the
+	// member, so that inviting users cannot be used to probe for other IDs
+	// or for which users have signed in
+	if !h.findTarget(w, r, target, models.RoleOwner) {
+		return
+	}
+
+	member, err := h.findMember(r, &shareInput)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.db.CreateShare(r.Context(), userID(r), target, member.ID, shareInput.Role)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound(h.shareType))
+			return
+		}
+		if errors.Is(err, db.ErrConflict) {
+			writeError(w, r, withDetail(err, "the user is already a member of this "+h.shareType))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"user_id": member.ID})
+}
+
+// UpdateShare changes the role of a member of a bill or ledger
+// @Summary Change the role of a member
+// @Description Changes the role of a member of a bill or ledger; this requires the owner role
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Param id path int true "Bill or ledger ID"
+// @Param userId path int true "User ID of the member"
+// @Param role body models.RoleInput true "Role"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/shares/{userId} [put]
+// @Router /ledgers/{id}/shares/{userId} [put]
+func (h *ShareHandler) UpdateShare(w http.ResponseWriter, r *http.Request) {
+	target, memberID, err := h.getShareIDs(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var roleInput models.RoleInput
+	err = json.NewDecoder(r.Body).Decode(&roleInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if !roleInput.Role.Valid() {
+		writeError(w, r, db.NewValidationError("role", "must be one of viewer, payer, editor or owner"))
+		return
+	}
+
+	if !h.findTarget(w, r, target, models.RoleViewer) {
+		return
+	}
+
+	err = h.db.UpdateShare(r.Context(), userID(r), target, memberID, roleInput.Role)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("share"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Share updated successfully"})
+}
+
+// DeleteShare revokes the share of a member of a bill or ledger
+// @Summary Revoke a share
+// @Description Revokes the share of a member of a bill or ledger; this requires the owner role, except for members leaving on their own
+// @Tags shares
+// @Produce json
+// @Param id path int true "Bill or ledger ID"
+// @Param userId path int true "User ID of the member"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /bills/{id}/shares/{userId} [delete]
+// @Router /ledgers/{id}/shares/{userId} [delete]
+func (h *ShareHandler) DeleteShare(w http.ResponseWriter, r *http.Request) {
+	target, memberID, err := h.getShareIDs(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	if !h.findTarget(w, r, target, models.RoleViewer) {
+		return
+	}
+
+	err = h.db.DeleteShare(r.Context(), userID(r), target, memberID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, r, notFound("share"))
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Share revoked successfully"})
+}
+
+// findTarget checks that the bill or ledger exists for the user and writes a
+// 404 response if it does not, so that later ErrNotFound refer to the share.
+// It writes a 403 response if the role of the user is below required.
+func (h *ShareHandler) findTarget(w http.ResponseWriter, r *http.Request, target models.ShareTarget, required models.Role) bool {
+	role, err := h.targetRole(r, target)
+	if err != nil {
+		return writeTargetError(w, r, target, err)
+	}
+	if !role.Allows(required) {
+		writeError(w, r, &db.RoleError{Type: target.Type, Role: role, Required: required})
+		return false
+	}
+	return true
+}
+
+// targetRole returns the role of the user on the bill or ledger
+func (h *ShareHandler) targetRole(r *http.Request, target models.ShareTarget) (models.Role, error) {
+	if target.Type == models.ShareTypeLedger {
+		ledger, err := h.db.GetLedger(r.Context(), userID(r), target.ID)
+		if err != nil {
+			return "", err
+		}
+		return ledger.Role, nil
+	}
+	bill, err := h.db.GetBill(r.Context(), userID(r), target.ID)
+	if err != nil {
+		return "", err
+	}
+	return bill.Role, nil
+}
+
+// writeTargetError writes the error of looking up a bill or ledger for
+// findTarget and returns false
+func writeTargetError(w http.ResponseWriter, r *http.Request, target models.ShareTarget, err error) bool {
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, r, notFound(target.Type))
+		return false
+	}
+	writeError(w, r, err)
+	return false
+}
+
+// findMember returns the user to share with, who must have signed in. An
+// email only identifies a user who verified it and is the only one who did,
+// so that nobody is invited by an email they merely claim.
+func (h *ShareHandler) findMember(r *http.Request, shareInput *models.ShareInput) (*models.User, error) {
+	if shareInput.Subject != "" {
+		member, err := h.db.FindUserBySubject(r.Context(), shareInput.Subject)
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, db.NewValidationError("subject", "must be the subject of a user who has signed in")
+		}
+		return member, err
+	}
+
+	member, err := h.db.FindUserByEmail(r.Context(), shareInput.Email)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, db.NewValidationError("email", "must be the verified email of a user who has signed in")
+	}
+	return member, err
+}
+
+// getTarget returns the bill or ledger from the URL
+func (h *ShareHandler) getTarget(r *http.Request) (models.ShareTarget, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return models.ShareTarget{}, errors.New("invalid " + h.shareType + " ID")
+	}
+	return models.ShareTarget{Type: h.shareType, ID: id}, nil
+}
+
+// getShareIDs returns the bill or ledger and the user ID of the member from
+// the URL
+func (h *ShareHandler) getShareIDs(r *http.Request) (models.ShareTarget, int64, error) {
+	target, err := h.getTarget(r)
+	if err != nil {
+		return target, 0, err
+	}
+
+	memberID, err := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+	if err != nil {
+		return target, 0, errors.New("invalid user ID")
+	}
+	return target, memberID, nil
+}
+
+// validateShareInput validates and normalizes a share input
+func validateShareInput(shareInput *models.ShareInput) error {
+	verr := &db.ValidationError{}
+
+	shareInput.Subject = strings.TrimSpace(shareInput.Subject)
+	shareInput.Email = strings.TrimSpace(shareInput.Email)
+	switch {
+	case shareInput.Subject == "" && shareInput.Email == "":
+		verr.Add("subject", "either subject or email is required")
+	case shareInput.Subject != "" && shareInput.Email != "":
+		verr.Add("subject", "must not be given together with email")
+	}
+	if !shareInput.Role.Valid() {
+		verr.Add("role", "must be one of viewer, payer, editor or owner")
+	}
+
+	return verr.Err()
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// TagHandler handles tag-related requests
+type TagHandler struct {
+	db db.Database
+}
+
+// NewTagHandler creates a new tag handler
+func NewTagHandler(database db.Database) *TagHandler {
+	return &TagHandler{db: database}
+}
+
+// GetTags returns the tags of the user
+// @Summary Get tags
+// @Description Returns the tags of the user by name, with the number of bills they are on
+// @Tags tags
+// @Produce json
+// @Success 200 {array} models.Tag
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /tags [get]
+func (h *TagHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.db.GetTags(r.Context(), userID(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if tags == nil {
+		tags = []models.Tag{}
+	}
+
+	responseJSON(w, tags)
+}
+
+// GetTag returns a single tag
+// @Summary Get a single tag
+// @Description Returns a tag of the user
+// @Tags tags
+// @Produce json
+// @Param id path int true "Tag ID"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /tags/{id} [get]
+func (h *TagHandler) GetTag(w http.ResponseWriter, r *http.Request) {
+	id, err := getTagID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	tag, err := h.db.GetTag(r.Context(), userID(r), id)
+	if err != nil {
+		writeTagError(w, r, err)
+		return
+	}
+
+	responseJSON(w, tag)
+}
+
+// CreateTag creates a new tag
+// @Summary Create a new tag
+// @Description Creates a new tag owned by the user. Tags are also created when first used on a bill or item.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param tag body models.TagInput true "Tag information"
+// @Success 201 {object} map[string]int64
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /tags [post]
+func (h *TagHandler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	var tagInput models.TagInput
+	err := json.NewDecoder(r.Body).Decode(&tagInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateTagInput(&tagInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	id, err := h.db.CreateTag(r.Context(), userID(r), &tagInput)
+	if err != nil {
+		writeTagError(w, r, err)
+		return
+	}
+
+	responseJSONStatus(w, http.StatusCreated, map[string]int64{"id": id})
+}
+
+// UpdateTag renames a tag
+// @Summary Rename a tag
+// @Description Renames a tag of the user on all of its bills and items
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Tag ID"
+// @Param tag body models.TagInput true "Tag information"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /tags/{id} [put]
+func (h *TagHandler) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	id, err := getTagID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	var tagInput models.TagInput
+	err = json.NewDecoder(r.Body).Decode(&tagInput)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	// Validate input
+	if err := validateTagInput(&tagInput); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	err = h.db.UpdateTag(r.Context(), userID(r), id, &tagInput)
+	if err != nil {
+		writeTagError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Tag updated successfully"})
+}
+
+// DeleteTag deletes a tag
+// @Summary Delete a tag
+// @Description Deletes a tag of the user and removes it from its bills and items
+// @Tags tags
+// @Produce json
+// @Param id path int true "Tag ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Failure 503 {object} models.Problem
+// @Failure 504 {object} models.Problem
+// @Router /tags/{id} [delete]
+func (h *TagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	id, err := getTagID(r)
+	if err != nil {
+		writeError(w, r, invalidRequest(err))
+		return
+	}
+
+	err = h.db.DeleteTag(r.Context(), userID(r), id)
+	if err != nil {
+		writeTagError(w, r, err)
+		return
+	}
+
+	responseJSON(w, map[string]string{"message": "Tag deleted successfully"})
+}
+
+// writeTagError writes an error of reading or changing a tag
+func writeTagError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, r, notFound("tag"))
+	case errors.Is(err, db.ErrConflict):
+		writeError(w, r, withDetail(err, "a tag with this name already exists"))
+	default:
+		writeError(w, r, err)
+	}
+}
+
+// getTagID extracts the tag ID from the URL
+func getTagID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid tag ID")
+	}
+	return id, nil
+}
+
+// validateTagInput validates and normalizes a tag input
+func validateTagInput(tagInput *models.TagInput) error {
+	if message := models.ValidateTag(tagInput.Name); message != "" {
+		return db.NewValidationError("name", message)
+	}
+	tagInput.Name = models.NormalizeTag(tagInput.Name)
+	return nil
+}
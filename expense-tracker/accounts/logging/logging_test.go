@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/config"
+)
+
+// capture installs a JSON logger at the given level and returns its output
+func capture(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var buf bytes.Buffer
+	Setup(&config.Config{LogLevel: level, LogFormat: "json"}, &buf)
+	return &buf
+}
+
+// records decodes the JSON log records in buf
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"accepted", "abc-123.DEF:4_5", true},
+		{"missing", "", false},
+		{"invalid", "two words", false},
+		{"too long", string(bytes.Repeat([]byte("a"), 129)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get(RequestIDHeader); got != seen {
+				t.Errorf("response header %q differs from the context %q", got, seen)
+			}
+			if tt.keep && seen != tt.header {
+				t.Errorf("request ID = %q, want %q", seen, tt.header)
+			}
+			if !tt.keep && (seen == tt.header || len(seen) != 32) {
+				t.Errorf("request ID = %q, want a generated one", seen)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	buf := capture(t, slog.LevelInfo)
+
+	r := mux.NewRouter()
+	r.Use(AccessLog)
+	r.HandleFunc("/bills/{id}", func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "handling")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+	r.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	handler := RequestID(r)
+
+	req := httptest.NewRequest("GET", "/bills/7", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/broken", nil))
+
+	logged := records(t, buf)
+	if len(logged) != 3 {
+		t.Fatalf("%d records, want the handler's and two access logs (probes at debug level): %v", len(logged), logged)
+	}
+
+	// Records of a request carry its ID
+	if logged[0]["msg"] != "handling" || logged[0]["request_id"] != "req-1" {
+		t.Errorf("handler record = %v, want request_id req-1", logged[0])
+	}
+
+	access := logged[1]
+	want := map[string]interface{}{
+		"level":      "INFO",
+		"msg":        "request",
+		"method":     "GET",
+		"path":       "/bills/7",
+		"route":      "/bills/{id}",
+		"status":     float64(http.StatusTeapot),
+		"bytes":      float64(15),
+		"request_id": "req-1",
+	}
+	for key, value := range want {
+		if access[key] != value {
+			t.Errorf("access log %s = %v, want %v", key, access[key], value)
+		}
+	}
+
+	if logged[2]["level"] != "ERROR" || logged[2]["status"] != float64(500) {
+		t.Errorf("access log of a server error = %v, want level ERROR", logged[2])
+	}
+}
@@ -1,19 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/jo/choreo-tutorial/accounts/auth"
 	"github.com/jo/choreo-tutorial/accounts/config"
 	"github.com/jo/choreo-tutorial/accounts/db"
 	"github.com/jo/choreo-tutorial/accounts/handlers"
+	"github.com/jo/choreo-tutorial/accounts/logging"
+	"github.com/jo/choreo-tutorial/accounts/metrics"
+	"github.com/jo/choreo-tutorial/accounts/migrations"
+	"github.com/jo/choreo-tutorial/accounts/tracing"
 
-	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
-	"github.com/swaggo/http-swagger"
-	_ "github.com/swaggo/swag/example/basic/docs" // for swagger
 )
 
 // @title Accounts API
@@ -32,51 +39,166 @@ import (
 // @BasePath /api/v1
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("Exiting", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the server, or the migrate or token subcommand, and returns once
+// it has stopped and the database is closed
+func run() error {
 	// Load .env file if it exists
 	godotenv.Load()
 
 	// Initialize configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Initialize logging
+	logging.Setup(cfg, os.Stdout)
+
+	// Run the token subcommand, which needs no database
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		return runToken(cfg, os.Args[2:])
+	}
+
+	// Initialize tracing; pending spans are flushed after the database is closed
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
 
 	// Initialize database
 	database, err := db.InitDB(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer func() {
+		slog.Info("Closing database")
+		if err := database.Close(); err != nil {
+			slog.Error("Failed to close database", "error", err)
+		}
+	}()
+
+	// Run the migrate subcommand instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(database, os.Args[2:]); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		return nil
+	}
+
+	// Apply pending schema migrations
+	if cfg.AutoMigrate {
+		if err := database.Migrate(context.Background()); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	// Keep the migrator for readiness checks before the database is wrapped
+	var migrator *migrations.Migrator
+	if migratable, ok := database.(db.Migratable); ok {
+		migrator = migratable.Migrator()
 	}
-	defer database.Close()
 
-	// Create tables if they don't exist
-	if err := database.CreateTables(); err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+	// Expose connection pool statistics
+	m := metrics.New()
+	if pooled, ok := database.(db.Pooled); ok {
+		m.RegisterDBStats(pooled.DB(), cfg.DBType)
 	}
 
+	// Bound every database operation by the query timeout and record its duration and span
+	database = tracing.InstrumentDB(m.InstrumentDB(db.WithQueryTimeout(database, cfg.DBQueryTimeout)), cfg.DBType)
+	m.RegisterBills(database)
+
 	// Initialize router
-	r := mux.NewRouter()
-	
-	// API routes
-	api := r.PathPrefix("/").Subrouter()
-	
-	// Bill handlers
-	billHandler := handlers.NewBillHandler(database)
-	api.HandleFunc("/bills", billHandler.GetBills).Methods("GET")
-	api.HandleFunc("/bills", billHandler.CreateBill).Methods("POST")
-	api.HandleFunc("/bills/{id}", billHandler.GetBill).Methods("GET")
-	api.HandleFunc("/bills/{id}", billHandler.UpdateBill).Methods("PUT")
-	api.HandleFunc("/bills/{id}", billHandler.DeleteBill).Methods("DELETE")
-	
-	// Swagger
-	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	health := handlers.NewHealthHandler(database, migrator)
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to set up authentication: %w", err)
+	}
+	r := newRouter(database, authenticator, cfg.AuthAdminSubjects, cfg.Validation, health, m)
+
+	// Stop on SIGINT or SIGTERM, after failing readiness for the shutdown delay
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx := delayShutdown(signalCtx, cfg.ShutdownDelay, health.SetShuttingDown)
+
+	// Generate bills from recurring bills until shutdown; the database is
+	// closed once the generator has stopped
+	if cfg.RecurringBillsInterval > 0 {
+		generatorCtx, stopGenerator := context.WithCancel(signalCtx)
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			generateRecurringBills(generatorCtx, database, cfg.RecurringBillsInterval, cfg.RecurringBillsLeadDays)
+		}()
+		defer func() {
+			stopGenerator()
+			<-stopped
+		}()
+	}
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	server := newServer(cfg, r)
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", server.Addr, err)
+	}
+
+	slog.Info("Server starting", "addr", server.Addr)
+	if err := serve(ctx, server, listener, cfg.ShutdownTimeout); err != nil {
+		return fmt.Errorf("server stopped: %w", err)
+	}
+	return nil
+}
+
+// newAuthenticator returns the authenticator of the configured AUTH_MODE
+func newAuthenticator(cfg *config.Config) (auth.Authenticator, error) {
+	if cfg.AuthMode == "header" {
+		slog.Warn("Trusting identity headers; the service must only be reachable through the gateway", "subject_header", cfg.AuthSubjectHeader)
+		return &auth.HeaderAuthenticator{
+			SubjectHeader:       cfg.AuthSubjectHeader,
+			EmailHeader:         cfg.AuthEmailHeader,
+			EmailVerifiedHeader: cfg.AuthEmailVerifiedHeader,
+			NameHeader:          cfg.AuthNameHeader,
+		}, nil
 	}
 
-	serverAddr := fmt.Sprintf(":%s", port)
-	log.Printf("Server starting on %s", serverAddr)
-	log.Fatal(http.ListenAndServe(serverAddr, r))
+	jwtConfig := auth.JWTConfig{
+		HS256Secret: []byte(cfg.AuthHS256Secret),
+		Issuer:      cfg.AuthIssuer,
+		Audience:    cfg.AuthAudience,
+		Leeway:      cfg.AuthClockSkew,
+	}
+	switch {
+	case cfg.AuthJWKSURL != "":
+		jwtConfig.Keys = auth.NewRemoteKeySet(cfg.AuthJWKSURL, cfg.AuthJWKSRefresh)
+	case cfg.AuthJWKSFile != "":
+		keys, err := auth.LoadJWKSFile(cfg.AuthJWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading AUTH_JWKS_FILE: %w", err)
+		}
+		jwtConfig.Keys = keys
+	}
+	if jwtConfig.Keys == nil && cfg.AuthHS256Secret == "" {
+		return nil, errors.New("AUTH_MODE=jwt requires AUTH_JWKS_URL, AUTH_JWKS_FILE or AUTH_HS256_SECRET")
+	}
+	if cfg.AuthIssuer == "" || cfg.AuthAudience == "" {
+		return nil, errors.New("AUTH_MODE=jwt requires AUTH_ISSUER and AUTH_AUDIENCE")
+	}
+	if cfg.AuthHS256Secret != "" {
+		slog.Warn("Accepting HS256 tokens; AUTH_HS256_SECRET is meant for local development only")
+	}
+	return auth.NewJWTAuthenticator(jwtConfig)
 }
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// billsScrapeTimeout bounds the queries of a single scrape
+const billsScrapeTimeout = 5 * time.Second
+
+var (
+	unpaidBillsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "unpaid_bills"),
+		"Number of unpaid bills by currency.",
+		[]string{"currency"}, nil,
+	)
+	unpaidAmountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "unpaid_bills_amount"),
+		"Total amount of unpaid bills by currency, in major units.",
+		[]string{"currency"}, nil,
+	)
+	overdueBillsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "overdue_bills"),
+		"Number of unpaid bills due before today (UTC) by currency.",
+		[]string{"currency"}, nil,
+	)
+	overdueAmountDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "overdue_bills_amount"),
+		"Total amount of unpaid bills due before today (UTC) by currency, in major units.",
+		[]string{"currency"}, nil,
+	)
+)
+
+// billsCollector queries the unpaid and overdue bills on every scrape
+type billsCollector struct {
+	db db.Database
+}
+
+func newBillsCollector(database db.Database) *billsCollector {
+	return &billsCollector{db: database}
+}
+
+// Describe implements prometheus.Collector
+func (c *billsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- unpaidBillsDesc
+	ch <- unpaidAmountDesc
+	ch <- overdueBillsDesc
+	ch <- overdueAmountDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *billsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), billsScrapeTimeout)
+	defer cancel()
+
+	unpaid := false
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yesterday := today.AddDate(0, 0, -1)
+
+	c.collectTotals(ctx, ch, &models.BillQuery{Paid: &unpaid}, unpaidBillsDesc, unpaidAmountDesc)
+	c.collectTotals(ctx, ch, &models.BillQuery{Paid: &unpaid, DueTo: &yesterday}, overdueBillsDesc, overdueAmountDesc)
+}
+
+// collectTotals reports the count and amount of the bills matching query per currency
+func (c *billsCollector) collectTotals(ctx context.Context, ch chan<- prometheus.Metric, query *models.BillQuery, countDesc, amountDesc *prometheus.Desc) {
+	totals, err := c.db.GetBillTotals(ctx, db.AllOwners, query)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to collect bill metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(countDesc, err)
+		return
+	}
+
+	for _, total := range totals {
+		ch <- prometheus.MustNewConstMetric(countDesc, prometheus.GaugeValue, float64(total.BillCount), total.Currency)
+		ch <- prometheus.MustNewConstMetric(amountDesc, prometheus.GaugeValue, float64(total.UnpaidTotal)/100, total.Currency)
+	}
+}
@@ -0,0 +1,465 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// instrumentedDB wraps a Database and records the duration of every operation
+type instrumentedDB struct {
+	db      db.Database
+	metrics *Metrics
+}
+
+// InstrumentDB returns a Database that records the duration and result of
+// every operation of database, labelled by method name
+func (m *Metrics) InstrumentDB(database db.Database) db.Database {
+	return &instrumentedDB{db: database, metrics: m}
+}
+
+// observe records an operation that started at start; it is deferred with a
+// pointer to the named error result, so it sees the error that is returned
+func (i *instrumentedDB) observe(operation string, start time.Time, err *error) {
+	i.metrics.dbDuration.WithLabelValues(operation, result(*err)).Observe(time.Since(start).Seconds())
+}
+
+// result classifies the outcome of a database operation
+func result(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, db.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, db.ErrConflict):
+		return "conflict"
+	case errors.Is(err, db.ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, db.ErrValidation):
+		return "invalid"
+	case errors.Is(err, db.ErrConstraint):
+		return "constraint"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// GetBills returns the bills matching the query with summary information
+func (i *instrumentedDB) GetBills(ctx context.Context, userID int64, query *models.BillQuery) (bills []models.BillSummary, total int, err error) {
+	defer i.observe("GetBills", time.Now(), &err)
+	return i.db.GetBills(ctx, userID, query)
+}
+
+// GetBill returns a single bill with all its items
+func (i *instrumentedDB) GetBill(ctx context.Context, userID, id int64) (bill *models.Bill, err error) {
+	defer i.observe("GetBill", time.Now(), &err)
+	return i.db.GetBill(ctx, userID, id)
+}
+
+// CreateBill creates a new bill and its items
+func (i *instrumentedDB) CreateBill(ctx context.Context, userID int64, bill *models.BillInput) (id int64, err error) {
+	defer i.observe("CreateBill", time.Now(), &err)
+	return i.db.CreateBill(ctx, userID, bill)
+}
+
+// UpdateBill updates an existing bill and its items
+func (i *instrumentedDB) UpdateBill(ctx context.Context, userID, id int64, bill *models.BillInput) (err error) {
+	defer i.observe("UpdateBill", time.Now(), &err)
+	return i.db.UpdateBill(ctx, userID, id, bill)
+}
+
+// SetBillPaid marks a bill as paid or unpaid
+func (i *instrumentedDB) SetBillPaid(ctx context.Context, userID, id int64, paid bool) (err error) {
+	defer i.observe("SetBillPaid", time.Now(), &err)
+	return i.db.SetBillPaid(ctx, userID, id, paid)
+}
+
+// DeleteBill deletes a bill and its items
+func (i *instrumentedDB) DeleteBill(ctx context.Context, userID, id int64) (err error) {
+	defer i.observe("DeleteBill", time.Now(), &err)
+	return i.db.DeleteBill(ctx, userID, id)
+}
+
+// GetBillTotals returns the totals of the bills matching the query grouped by currency
+func (i *instrumentedDB) GetBillTotals(ctx context.Context, userID int64, query *models.BillQuery) (totals []models.CurrencyTotal, err error) {
+	defer i.observe("GetBillTotals", time.Now(), &err)
+	return i.db.GetBillTotals(ctx, userID, query)
+}
+
+// GetBillItems returns all items for a bill
+func (i *instrumentedDB) GetBillItems(ctx context.Context, userID, billID int64) (items []models.BillItem, err error) {
+	defer i.observe("GetBillItems", time.Now(), &err)
+	return i.db.GetBillItems(ctx, userID, billID)
+}
+
+// GetBillItem returns a single bill item
+func (i *instrumentedDB) GetBillItem(ctx context.Context, userID, id int64) (item *models.BillItem, err error) {
+	defer i.observe("GetBillItem", time.Now(), &err)
+	return i.db.GetBillItem(ctx, userID, id)
+}
+
+// CreateBillItem creates a new bill item
+func (i *instrumentedDB) CreateBillItem(ctx context.Context, userID, billID int64, item *models.BillItemInput) (id int64, err error) {
+	defer i.observe("CreateBillItem", time.Now(), &err)
+	return i.db.CreateBillItem(ctx, userID, billID, item)
+}
+
+// UpdateBillItem updates an existing bill item
+func (i *instrumentedDB) UpdateBillItem(ctx context.Context, userID, id int64, item *models.BillItemInput) (err error) {
+	defer i.observe("UpdateBillItem", time.Now(), &err)
+	return i.db.UpdateBillItem(ctx, userID, id, item)
+}
+
+// DeleteBillItem deletes a bill item
+func (i *instrumentedDB) DeleteBillItem(ctx context.Context, userID, id int64) (err error) {
+	defer i.observe("DeleteBillItem", time.Now(), &err)
+	return i.db.DeleteBillItem(ctx, userID, id)
+}
+
+// GetBillPayments returns the payments of a bill
+func (i *instrumentedDB) GetBillPayments(ctx context.Context, userID, billID int64) (payments []models.Payment, err error) {
+	defer i.observe("GetBillPayments", time.Now(), &err)
+	return i.db.GetBillPayments(ctx, userID, billID)
+}
+
+// GetBillPayment returns a single payment of a bill
+func (i *instrumentedDB) GetBillPayment(ctx context.Context, userID, billID, id int64) (payment *models.Payment, err error) {
+	defer i.observe("GetBillPayment", time.Now(), &err)
+	return i.db.GetBillPayment(ctx, userID, billID, id)
+}
+
+// CreateBillPayment records a payment of a bill
+func (i *instrumentedDB) CreateBillPayment(ctx context.Context, userID, billID int64, payment *models.PaymentInput) (id int64, err error) {
+	defer i.observe("CreateBillPayment", time.Now(), &err)
+	return i.db.CreateBillPayment(ctx, userID, billID, payment)
+}
+
+// UpdateBillPayment updates a payment of a bill
+func (i *instrumentedDB) UpdateBillPayment(ctx context.Context, userID, billID, id int64, payment *models.PaymentInput) (err error) {
+	defer i.observe("UpdateBillPayment", time.Now(), &err)
+	return i.db.UpdateBillPayment(ctx, userID, billID, id, payment)
+}
+
+// DeleteBillPayment deletes a payment of a bill
+func (i *instrumentedDB) DeleteBillPayment(ctx context.Context, userID, billID, id int64) (err error) {
+	defer i.observe("DeleteBillPayment", time.Now(), &err)
+	return i.db.DeleteBillPayment(ctx, userID, billID, id)
+}
+
+// EnsureUser returns the user with the subject of the input, creating it on first use
+func (i *instrumentedDB) EnsureUser(ctx context.Context, user *models.UserInput) (stored *models.User, err error) {
+	defer i.observe("EnsureUser", time.Now(), &err)
+	return i.db.EnsureUser(ctx, user)
+}
+
+// GetUser returns a single user
+func (i *instrumentedDB) GetUser(ctx context.Context, id int64) (user *models.User, err error) {
+	defer i.observe("GetUser", time.Now(), &err)
+	return i.db.GetUser(ctx, id)
+}
+
+// FindUserBySubject returns the user with the subject without creating it
+func (i *instrumentedDB) FindUserBySubject(ctx context.Context, subject string) (user *models.User, err error) {
+	defer i.observe("FindUserBySubject", time.Now(), &err)
+	return i.db.FindUserBySubject(ctx, subject)
+}
+
+// FindUserByEmail returns the only user with the verified email
+func (i *instrumentedDB) FindUserByEmail(ctx context.Context, email string) (user *models.User, err error) {
+	defer i.observe("FindUserByEmail", time.Now(), &err)
+	return i.db.FindUserByEmail(ctx, email)
+}
+
+// GetLedgers returns the ledgers the user has a role on
+func (i *instrumentedDB) GetLedgers(ctx context.Context, userID int64) (ledgers []models.Ledger, err error) {
+	defer i.observe("GetLedgers", time.Now(), &err)
+	return i.db.GetLedgers(ctx, userID)
+}
+
+// GetLedger returns a single ledger
+func (i *instrumentedDB) GetLedger(ctx context.Context, userID, id int64) (ledger *models.Ledger, err error) {
+	defer i.observe("GetLedger", time.Now(), &err)
+	return i.db.GetLedger(ctx, userID, id)
+}
+
+// CreateLedger creates a new ledger owned by the user
+func (i *instrumentedDB) CreateLedger(ctx context.Context, userID int64, ledger *models.LedgerInput) (id int64, err error) {
+	defer i.observe("CreateLedger", time.Now(), &err)
+	return i.db.CreateLedger(ctx, userID, ledger)
+}
+
+// UpdateLedger renames a ledger
+func (i *instrumentedDB) UpdateLedger(ctx context.Context, userID, id int64, ledger *models.LedgerInput) (err error) {
+	defer i.observe("UpdateLedger", time.Now(), &err)
+	return i.db.UpdateLedger(ctx, userID, id, ledger)
+}
+
+// DeleteLedger deletes a ledger, keeping its bills
+func (i *instrumentedDB) DeleteLedger(ctx context.Context, userID, id int64) (err error) {
+	defer i.observe("DeleteLedger", time.Now(), &err)
+	return i.db.DeleteLedger(ctx, userID, id)
+}
+
+// GetShares returns the shares of a bill or ledger
+func (i *instrumentedDB) GetShares(ctx context.Context, userID int64, target models.ShareTarget) (shares []models.Share, err error) {
+	defer i.observe("GetShares", time.Now(), &err)
+	return i.db.GetShares(ctx, userID, target)
+}
+
+// CreateShare shares a bill or ledger with a member in a role
+func (i *instrumentedDB) CreateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) (err error) {
+	defer i.observe("CreateShare", time.Now(), &err)
+	return i.db.CreateShare(ctx, userID, target, memberID, role)
+}
+
+// UpdateShare changes the role of a member of a bill or ledger
+func (i *instrumentedDB) UpdateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) (err error) {
+	defer i.observe("UpdateShare", time.Now(), &err)
+	return i.db.UpdateShare(ctx, userID, target, memberID, role)
+}
+
+// DeleteShare revokes the share of a member of a bill or ledger
+func (i *instrumentedDB) DeleteShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64) (err error) {
+	defer i.observe("DeleteShare", time.Now(), &err)
+	return i.db.DeleteShare(ctx, userID, target, memberID)
+}
+
+// GetAPIKeys returns all API keys, including revoked ones
+func (i *instrumentedDB) GetAPIKeys(ctx context.Context) (keys []models.APIKey, err error) {
+	defer i.observe("GetAPIKeys", time.Now(), &err)
+	return i.db.GetAPIKeys(ctx)
+}
+
+// GetAPIKey returns a single API key
+func (i *instrumentedDB) GetAPIKey(ctx context.Context, id int64) (key *models.APIKey, err error) {
+	defer i.observe("GetAPIKey", time.Now(), &err)
+	return i.db.GetAPIKey(ctx, id)
+}
+
+// FindAPIKey returns the API key with the hash, including revoked keys
+func (i *instrumentedDB) FindAPIKey(ctx context.Context, hash string) (key *models.APIKey, err error) {
+	defer i.observe("FindAPIKey", time.Now(), &err)
+	return i.db.FindAPIKey(ctx, hash)
+}
+
+// CreateAPIKey stores a new API key
+func (i *instrumentedDB) CreateAPIKey(ctx context.Context, key *models.APIKey) (id int64, err error) {
+	defer i.observe("CreateAPIKey", time.Now(), &err)
+	return i.db.CreateAPIKey(ctx, key)
+}
+
+// RevokeAPIKey marks an API key as revoked
+func (i *instrumentedDB) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	defer i.observe("RevokeAPIKey", time.Now(), &err)
+	return i.db.RevokeAPIKey(ctx, id)
+}
+
+// TouchAPIKey records that an API key was used now
+func (i *instrumentedDB) TouchAPIKey(ctx context.Context, id int64) (err error) {
+	defer i.observe("TouchAPIKey", time.Now(), &err)
+	return i.db.TouchAPIKey(ctx, id)
+}
+
+// GetCategories returns the default categories and those of the user
+func (i *instrumentedDB) GetCategories(ctx context.Context, userID int64) (categories []models.Category, err error) {
+	defer i.observe("GetCategories", time.Now(), &err)
+	return i.db.GetCategories(ctx, userID)
+}
+
+// GetCategory returns a single category
+func (i *instrumentedDB) GetCategory(ctx context.Context, userID, id int64) (category *models.Category, err error) {
+	defer i.observe("GetCategory", time.Now(), &err)
+	return i.db.GetCategory(ctx, userID, id)
+}
+
+// CreateCategory creates a new category owned by the user
+func (i *instrumentedDB) CreateCategory(ctx context.Context, userID int64, category *models.CategoryInput) (id int64, err error) {
+	defer i.observe("CreateCategory", time.Now(), &err)
+	return i.db.CreateCategory(ctx, userID, category)
+}
+
+// UpdateCategory renames and moves a category of the user
+func (i *instrumentedDB) UpdateCategory(ctx context.Context, userID, id int64, category *models.CategoryInput) (err error) {
+	defer i.observe("UpdateCategory", time.Now(), &err)
+	return i.db.UpdateCategory(ctx, userID, id, category)
+}
+
+// DeleteCategory deletes a category of the user and its subcategories
+func (i *instrumentedDB) DeleteCategory(ctx context.Context, userID, id int64) (err error) {
+	defer i.observe("DeleteCategory", time.Now(), &err)
+	return i.db.DeleteCategory(ctx, userID, id)
+}
+
+// GetTags returns the tags of the user
+func (i *instrumentedDB) GetTags(ctx context.Context, userID int64) (tags []models.Tag, err error) {
+	defer i.observe("GetTags", time.Now(), &err)
+	return i.db.GetTags(ctx, userID)
+}
+
+// GetTag returns a single tag
+func (i *instrumentedDB) GetTag(ctx context.Context, userID, id int64) (tag *models.Tag, err error) {
+	defer i.observe("GetTag", time.Now(), &err)
+	return i.db.GetTag(ctx, userID, id)
+}
+
+// CreateTag creates a new tag owned by the user
+func (i *instrumentedDB) CreateTag(ctx context.Context, userID int64, tag *models.TagInput) (id int64, err error) {
+	defer i.observe("CreateTag", time.Now(), &err)
+	return i.db.CreateTag(ctx, userID, tag)
+}
+
+// UpdateTag renames a tag of the user
+func (i *instrumentedDB) UpdateTag(ctx context.Context, userID, id int64, tag *models.TagInput) (err error) {
+	defer i.observe("UpdateTag", time.Now(), &err)
+	return i.db.UpdateTag(ctx, userID, id, tag)
+}
+
+// DeleteTag deletes a tag of the user, removing it from its bills and items
+func (i *instrumentedDB) DeleteTag(ctx context.Context, userID, id int64) (err error) {
+	defer i.observe("DeleteTag", time.Now(), &err)
+	return i.db.DeleteTag(ctx, userID, id)
+}
+
+// GetMerchants returns the merchants of the user
+func (i *instrumentedDB) GetMerchants(ctx context.Context, userID int64) (merchants []models.Merchant, err error) {
+	defer i.observe("GetMerchants", time.Now(), &err)
+	return i.db.GetMerchants(ctx, userID)
+}
+
+// GetMerchant returns a single merchant
+func (i *instrumentedDB) GetMerchant(ctx context.Context, userID, id int64) (merchant *models.Merchant, err error) {
+	defer i.observe("GetMerchant", time.Now(), &err)
+	return i.db.GetMerchant(ctx, userID, id)
+}
+
+// CreateMerchant creates a new merchant owned by the user
+func (i *instrumentedDB) CreateMerchant(ctx context.Context, userID int64, merchant *models.MerchantInput) (id int64, err error) {
+	defer i.observe("CreateMerchant", time.Now(), &err)
+	return i.db.CreateMerchant(ctx, userID, merchant)
+}
+
+// UpdateMerchant updates a merchant of the user
+func (i *instrumentedDB) UpdateMerchant(ctx context.Context, userID, id int64, merchant *models.MerchantInput) (err error) {
+	defer i.observe("UpdateMerchant", time.Now(), &err)
+	return i.db.UpdateMerchant(ctx, userID, id, merchant)
+}
+
+// DeleteMerchant deletes a merchant of the user, keeping its bills
+func (i *instrumentedDB) DeleteMerchant(ctx context.Context, userID, id int64) (err error) {
+	defer i.observe("DeleteMerchant", time.Now(), &err)
+	return i.db.DeleteMerchant(ctx, userID, id)
+}
+
+// GetRecurringBills returns the recurring bills of the user
+func (i *instrumentedDB) GetRecurringBills(ctx context.Context, userID int64) (recurring []models.RecurringBill, err error) {
+	defer i.observe("GetRecurringBills", time.Now(), &err)
+	return i.db.GetRecurringBills(ctx, userID)
+}
+
+// GetRecurringBill returns a single recurring bill
+func (i *instrumentedDB) GetRecurringBill(ctx context.Context, userID, id int64) (recurring *models.RecurringBill, err error) {
+	defer i.observe("GetRecurringBill", time.Now(), &err)
+	return i.db.GetRecurringBill(ctx, userID, id)
+}
+
+// CreateRecurringBill creates a new recurring bill owned by the user
+func (i *instrumentedDB) CreateRecurringBill(ctx context.Context, userID int64, recurring *models.RecurringBillInput) (id int64, err error) {
+	defer i.observe("CreateRecurringBill", time.Now(), &err)
+	return i.db.CreateRecurringBill(ctx, userID, recurring)
+}
+
+// UpdateRecurringBill updates a recurring bill of the user
+func (i *instrumentedDB) UpdateRecurringBill(ctx context.Context, userID, id int64, recurring *models.RecurringBillInput) (err error) {
+	defer i.observe("UpdateRecurringBill", time.Now(), &err)
+	return i.db.UpdateRecurringBill(ctx, userID, id, recurring)
+}
+
+// DeleteRecurringBill deletes a recurring bill of the user, keeping its bills
+func (i *instrumentedDB) DeleteRecurringBill(ctx context.Context, userID, id int64) (err error) {
+	defer i.observe("DeleteRecurringBill", time.Now(), &err)
+	return i.db.DeleteRecurringBill(ctx, userID, id)
+}
+
+// SetRecurringBillPaused pauses or resumes a recurring bill of the user
+func (i *instrumentedDB) SetRecurringBillPaused(ctx context.Context, userID, id int64, paused bool) (err error) {
+	defer i.observe("SetRecurringBillPaused", time.Now(), &err)
+	return i.db.SetRecurringBillPaused(ctx, userID, id, paused)
+}
+
+// SkipRecurringBill skips an upcoming occurrence of a recurring bill of the user
+func (i *instrumentedDB) SkipRecurringBill(ctx context.Context, userID, id int64, date time.Time) (err error) {
+	defer i.observe("SkipRecurringBill", time.Now(), &err)
+	return i.db.SkipRecurringBill(ctx, userID, id, date)
+}
+
+// EndRecurringBill ends a recurring bill of the user on the date
+func (i *instrumentedDB) EndRecurringBill(ctx context.Context, userID, id int64, date time.Time) (err error) {
+	defer i.observe("EndRecurringBill", time.Now(), &err)
+	return i.db.EndRecurringBill(ctx, userID, id, date)
+}
+
+// GenerateRecurringBills generates the bills of all recurring bills through the date
+func (i *instrumentedDB) GenerateRecurringBills(ctx context.Context, today, through time.Time) (generated int, err error) {
+	defer i.observe("GenerateRecurringBills", time.Now(), &err)
+	return i.db.GenerateRecurringBills(ctx, today, through)
+}
+
+// GetFXRates returns the exchange rates, optionally filtered by base and quote currency
+func (i *instrumentedDB) GetFXRates(ctx context.Context, base, quote string) (rates []models.FXRate, err error) {
+	defer i.observe("GetFXRates", time.Now(), &err)
+	return i.db.GetFXRates(ctx, base, quote)
+}
+
+// GetFXRate returns a single exchange rate
+func (i *instrumentedDB) GetFXRate(ctx context.Context, id int64) (rate *models.FXRate, err error) {
+	defer i.observe("GetFXRate", time.Now(), &err)
+	return i.db.GetFXRate(ctx, id)
+}
+
+// FindFXRate returns the most recent rate for a currency pair that is effective on the given date
+func (i *instrumentedDB) FindFXRate(ctx context.Context, base, quote string, on time.Time) (rate *models.FXRate, err error) {
+	defer i.observe("FindFXRate", time.Now(), &err)
+	return i.db.FindFXRate(ctx, base, quote, on)
+}
+
+// CreateFXRate creates a new exchange rate
+func (i *instrumentedDB) CreateFXRate(ctx context.Context, rate *models.FXRateInput) (id int64, err error) {
+	defer i.observe("CreateFXRate", time.Now(), &err)
+	return i.db.CreateFXRate(ctx, rate)
+}
+
+// UpdateFXRate updates an existing exchange rate
+func (i *instrumentedDB) UpdateFXRate(ctx context.Context, id int64, rate *models.FXRateInput) (err error) {
+	defer i.observe("UpdateFXRate", time.Now(), &err)
+	return i.db.UpdateFXRate(ctx, id, rate)
+}
+
+// DeleteFXRate deletes an exchange rate
+func (i *instrumentedDB) DeleteFXRate(ctx context.Context, id int64) (err error) {
+	defer i.observe("DeleteFXRate", time.Now(), &err)
+	return i.db.DeleteFXRate(ctx, id)
+}
+
+// Ping checks that the wrapped database is reachable
+func (i *instrumentedDB) Ping(ctx context.Context) (err error) {
+	defer i.observe("Ping", time.Now(), &err)
+	return i.db.Ping(ctx)
+}
+
+// Migrate applies all pending schema migrations
+func (i *instrumentedDB) Migrate(ctx context.Context) (err error) {
+	defer i.observe("Migrate", time.Now(), &err)
+	return i.db.Migrate(ctx)
+}
+
+// Close closes the wrapped database
+func (i *instrumentedDB) Close() error {
+	return i.db.Close()
+}
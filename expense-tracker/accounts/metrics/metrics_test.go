@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/db/dbtest"
+	"github.com/jo/choreo-tutorial/accounts/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentDBConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.Database {
+		return New().InstrumentDB(db.NewMemoryDB())
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+	r := mux.NewRouter()
+	r.Use(m.Middleware)
+	r.HandleFunc("/bills/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+
+	for _, path := range []string{"/bills/1", "/bills/2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Requests are counted by route template, not by path
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/bills/{id}", "404")); got != 2 {
+		t.Errorf("requests for /bills/{id} = %v, want 2", got)
+	}
+	if got := testutil.CollectAndCount(m.httpDuration); got != 1 {
+		t.Errorf("%d duration series, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.httpInFlight); got != 0 {
+		t.Errorf("in-flight requests = %v, want 0", got)
+	}
+}
+
+func TestInstrumentDB(t *testing.T) {
+	m := New()
+	memory := db.NewMemoryDB()
+	database := m.InstrumentDB(memory)
+	ctx := context.Background()
+
+	owner, err := memory.EnsureUser(ctx, &models.UserInput{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := database.CreateBill(ctx, owner.ID, &models.BillInput{Title: "Rent", Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.GetBill(ctx, owner.ID, id)
+	database.GetBill(ctx, owner.ID, id+1)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	database.GetBill(canceled, owner.ID, id)
+
+	want := map[string]int{"CreateBill/ok": 1, "GetBill/ok": 1, "GetBill/not_found": 1, "GetBill/canceled": 1}
+	got := map[string]int{}
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "accounts_db_operation_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			got[labels["operation"]+"/"+labels["result"]] = int(metric.GetHistogram().GetSampleCount())
+		}
+	}
+
+	for key, count := range want {
+		if got[key] != count {
+			t.Errorf("%s observations = %d, want %d", key, got[key], count)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("observations = %v, want %v", got, want)
+	}
+}
+
+func TestBillsCollector(t *testing.T) {
+	database := db.NewMemoryDB()
+	ctx := context.Background()
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+
+	alice, err := database.EnsureUser(ctx, &models.UserInput{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := database.EnsureUser(ctx, &models.UserInput{Subject: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The metrics cover the bills of all users
+	bills := []struct {
+		owner int64
+		bill  *models.BillInput
+	}{
+		{alice.ID, &models.BillInput{Title: "Overdue", Currency: "USD", DueDate: yesterday, Items: []models.BillItemInput{{Name: "A", Amount: 1050, Quantity: 1}}}},
+		{alice.ID, &models.BillInput{Title: "Upcoming", Currency: "USD", DueDate: tomorrow, Items: []models.BillItemInput{{Name: "B", Amount: 200, Quantity: 1}}}},
+		{bob.ID, &models.BillInput{Title: "Paid", Currency: "USD", DueDate: yesterday, Paid: true, Items: []models.BillItemInput{{Name: "C", Amount: 999, Quantity: 1}}}},
+		{bob.ID, &models.BillInput{Title: "Hotel", Currency: "EUR", Items: []models.BillItemInput{{Name: "D", Amount: 5000, Quantity: 1}}}},
+	}
+	for _, b := range bills {
+		if _, err := database.CreateBill(ctx, b.owner, b.bill); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := New()
+	m.RegisterBills(database)
+
+	expected := `
+# HELP accounts_overdue_bills Number of unpaid bills due before today (UTC) by currency.
+# TYPE accounts_overdue_bills gauge
+accounts_overdue_bills{currency="USD"} 1
+# HELP accounts_overdue_bills_amount Total amount of unpaid bills due before today (UTC) by currency, in major units.
+# TYPE accounts_overdue_bills_amount gauge
+accounts_overdue_bills_amount{currency="USD"} 10.5
+# HELP accounts_unpaid_bills Number of unpaid bills by currency.
+# TYPE accounts_unpaid_bills gauge
+accounts_unpaid_bills{currency="EUR"} 1
+accounts_unpaid_bills{currency="USD"} 2
+# HELP accounts_unpaid_bills_amount Total amount of unpaid bills by currency, in major units.
+# TYPE accounts_unpaid_bills_amount gauge
+accounts_unpaid_bills_amount{currency="EUR"} 50
+accounts_unpaid_bills_amount{currency="USD"} 12.5
+`
+	err = testutil.GatherAndCompare(m.registry, strings.NewReader(expected),
+		"accounts_unpaid_bills", "accounts_unpaid_bills_amount", "accounts_overdue_bills", "accounts_overdue_bills_amount")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,152 @@
+package migrations
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// lockName identifies the migration lock of the accounts schema
+const lockName = "accounts_schema_migrations"
+
+// lockTimeoutSeconds is how long to wait for another process to finish migrating
+const lockTimeoutSeconds = 60
+
+// dialect holds the SQL and locking behaviour specific to a database
+type dialect struct {
+	createTable string
+	// countTable counts the schema_migrations tables of the database, 0 or 1
+	countTable    string
+	insertVersion string
+	deleteVersion string
+
+	// lock acquires the migration lock on the connection; unlock releases it.
+	// unlock receives the error of the migration run, if any.
+	lock   func(ctx context.Context, conn *sql.Conn) error
+	unlock func(ctx context.Context, conn *sql.Conn, runErr error) error
+
+	// lockIsTransaction means the lock is a transaction spanning the whole run,
+	// so migrations are applied directly on the locked connection
+	lockIsTransaction bool
+
+	// splitStatements means the driver can only execute one statement per call
+	splitStatements bool
+}
+
+var dialects = map[string]*dialect{
+	"sqlite": {
+		createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		countTable:    "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'",
+		insertVersion: "INSERT INTO schema_migrations (version, name) VALUES (?, ?)",
+		deleteVersion: "DELETE FROM schema_migrations WHERE version = ?",
+
+		// BEGIN IMMEDIATE takes the database write lock, so a second process
+		// waits (up to the busy timeout) until the first one has committed
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+			return err
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn, runErr error) error {
+			if runErr != nil {
+				_, err := conn.ExecContext(ctx, "ROLLBACK")
+				return err
+			}
+			_, err := conn.ExecContext(ctx, "COMMIT")
+			return err
+		},
+		lockIsTransaction: true,
+	},
+	"mysql": {
+		createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		countTable:    "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'",
+		insertVersion: "INSERT INTO schema_migrations (version, name) VALUES (?, ?)",
+		deleteVersion: "DELETE FROM schema_migrations WHERE version = ?",
+
+		// GET_LOCK is a named lock held by the connection
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			var acquired sql.NullInt64
+			err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeoutSeconds).Scan(&acquired)
+			if err != nil {
+				return err
+			}
+			if !acquired.Valid || acquired.Int64 != 1 {
+				return errors.New("timed out waiting for another migration to finish")
+			}
+			return nil
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn, runErr error) error {
+			_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+			return err
+		},
+		splitStatements: true,
+	},
+	"postgres": {
+		createTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		countTable:    "SELECT COUNT(to_regclass('schema_migrations'))",
+		insertVersion: "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+		deleteVersion: "DELETE FROM schema_migrations WHERE version = $1",
+
+		// Session-level advisory lock, keyed by a hash of the lock name
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			ctx, cancel := context.WithTimeout(ctx, lockTimeoutSeconds*time.Second)
+			defer cancel()
+
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", lockName)
+			if errors.Is(err, context.DeadlineExceeded) {
+				return errors.New("timed out waiting for another migration to finish")
+			}
+			return err
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn, runErr error) error {
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", lockName)
+			return err
+		},
+	},
+}
+
+// splitStatements splits a script into statements at lines ending with a semicolon.
+// Comment-only lines are dropped.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, current.String())
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
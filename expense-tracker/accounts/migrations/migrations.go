@@ -0,0 +1,336 @@
+// Package migrations manages the database schema with numbered up/down
+// migrations per SQL dialect.
+//
+// Migrations live in a directory per dialect and are named
+// NNNN_description.up.sql and NNNN_description.down.sql. Applied versions are
+// recorded in the schema_migrations table. A dialect-specific lock makes sure
+// only one process migrates a database at a time.
+//
+// On SQLite a run is a single transaction, so a failed run applies nothing.
+// On PostgreSQL each migration is its own transaction. MySQL commits DDL
+// implicitly, so a migration that fails there may be partly applied and has
+// to be repaired by hand before it is retried.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sqlite/*.sql mysql/*.sql postgres/*.sql
+var files embed.FS
+
+// migrationFilePattern matches migration file names such as 0001_create_bills.up.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration represents a numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// execer is implemented by both *sql.Conn and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Migrator applies and reverts migrations on a database
+type Migrator struct {
+	db         *sql.DB
+	dialect    *dialect
+	migrations []Migration
+}
+
+// New creates a migrator for a database of the given dialect ("sqlite", "mysql" or "postgres")
+func New(db *sql.DB, dialectName string) (*Migrator, error) {
+	d, ok := dialects[dialectName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported migration dialect: %s", dialectName)
+	}
+
+	migrations, err := load(files, dialectName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: d, migrations: migrations}, nil
+}
+
+// Migrations returns all known migrations in version order
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies all pending migrations in version order and returns the ones
+// applied. On error it returns the ones that stay applied, which are none
+// on SQLite, where the whole run is rolled back.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			err := m.run(ctx, conn, migration.Up, m.dialect.insertVersion, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s up: %v", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return m.kept(applied, err), err
+}
+
+// Down reverts the given number of most recently applied migrations and
+// returns the ones reverted. Like Up, on error it returns only the ones that
+// stay reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, errors.New("steps must be at least 1")
+	}
+
+	var reverted []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+
+			err := m.run(ctx, conn, migration.Down, m.dialect.deleteVersion, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s down: %v", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+
+	return m.kept(reverted, err), err
+}
+
+// kept returns the migrations a run has applied or reverted for good. A
+// failed run on a dialect whose lock is a transaction is rolled back as a
+// whole, so it keeps none of them.
+func (m *Migrator) kept(done []Migration, err error) []Migration {
+	if err != nil && m.dialect.lockIsTransaction {
+		return nil
+	}
+	return done
+}
+
+// Status reports for every known migration whether it has been applied. It
+// only reads the database, so it is cheap enough for readiness probes; a
+// database without the schema_migrations table has all migrations pending.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var tables int
+	if err := m.db.QueryRowContext(ctx, m.dialect.countTable).Scan(&tables); err != nil {
+		return nil, err
+	}
+
+	versions := map[int]time.Time{}
+	if tables > 0 {
+		var err error
+		versions, err = m.appliedVersions(ctx, m.db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the number of migrations that have not been applied yet
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// withLock runs fn on a dedicated connection while holding the migration lock.
+// The tracking table is created first so fn can rely on it.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.dialect.lock(ctx, conn); err != nil {
+		return fmt.Errorf("acquiring migration lock: %v", err)
+	}
+	defer func() {
+		if unlockErr := m.dialect.unlock(context.Background(), conn, err); unlockErr != nil && err == nil {
+			err = fmt.Errorf("releasing migration lock: %v", unlockErr)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// run executes a migration script and records the change in schema_migrations.
+// Dialects whose lock is a transaction run directly on the locked connection;
+// the others run each migration in its own transaction. On MySQL that
+// transaction does not cover DDL, which commits implicitly, so a migration
+// is not atomic there.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script, record string, args ...interface{}) error {
+	if m.dialect.lockIsTransaction {
+		return m.exec(ctx, conn, script, record, args...)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := m.exec(ctx, tx, script, record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// exec executes a migration script followed by the tracking statement
+func (m *Migrator) exec(ctx context.Context, ex execer, script, record string, args ...interface{}) error {
+	statements := []string{script}
+	if m.dialect.splitStatements {
+		statements = splitStatements(script)
+	}
+
+	for _, stmt := range statements {
+		if _, err := ex.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	_, err := ex.ExecContext(ctx, record, args...)
+	return err
+}
+
+// appliedVersions returns the applied migration versions with the time they were applied
+func (m *Migrator) appliedVersions(ctx context.Context, ex execer) (map[int]time.Time, error) {
+	rows, err := ex.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+
+	return versions, rows.Err()
+}
+
+// load reads the migrations of a dialect from the embedded files
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has conflicting names %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
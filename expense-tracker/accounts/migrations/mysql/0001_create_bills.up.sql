@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS bills (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	title VARCHAR(255) NOT NULL,
+	description TEXT,
+	total DECIMAL(10, 2) NOT NULL DEFAULT 0,
+	due_date DATE,
+	paid BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS bill_items (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	bill_id BIGINT NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	description TEXT,
+	amount DECIMAL(10, 2) NOT NULL,
+	quantity INT NOT NULL DEFAULT 1,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	FOREIGN KEY (bill_id) REFERENCES bills(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+ALTER TABLE bills ADD COLUMN total DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER total_cents;
+UPDATE bills SET total = total_cents / 100, updated_at = updated_at;
+ALTER TABLE bills DROP COLUMN total_cents;
+
+ALTER TABLE bill_items ADD COLUMN amount DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER amount_cents;
+UPDATE bill_items SET amount = amount_cents / 100, updated_at = updated_at;
+ALTER TABLE bill_items DROP COLUMN amount_cents;
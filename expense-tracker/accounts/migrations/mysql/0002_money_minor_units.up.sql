@@ -0,0 +1,9 @@
+-- Store amounts as integer minor units (cents) instead of DECIMAL.
+-- updated_at is set to itself so ON UPDATE CURRENT_TIMESTAMP doesn't fire.
+ALTER TABLE bills ADD COLUMN total_cents BIGINT NOT NULL DEFAULT 0 AFTER total;
+UPDATE bills SET total_cents = ROUND(total * 100), updated_at = updated_at;
+ALTER TABLE bills DROP COLUMN total;
+
+ALTER TABLE bill_items ADD COLUMN amount_cents BIGINT NOT NULL DEFAULT 0 AFTER amount;
+UPDATE bill_items SET amount_cents = ROUND(amount * 100), updated_at = updated_at;
+ALTER TABLE bill_items DROP COLUMN amount;
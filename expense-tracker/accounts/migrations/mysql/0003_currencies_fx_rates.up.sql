@@ -0,0 +1,12 @@
+ALTER TABLE bills ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER total_cents;
+
+CREATE TABLE fx_rates (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	base_currency CHAR(3) NOT NULL,
+	quote_currency CHAR(3) NOT NULL,
+	rate DECIMAL(24, 10) NOT NULL,
+	effective_date DATE NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	UNIQUE KEY fx_rates_pair_date (base_currency, quote_currency, effective_date)
+);
@@ -0,0 +1,9 @@
+ALTER TABLE bill_items
+	DROP CHECK bill_items_name_check,
+	DROP CHECK bill_items_amount_check,
+	DROP CHECK bill_items_quantity_check;
+
+ALTER TABLE bills
+	DROP CHECK bills_title_check,
+	DROP CHECK bills_total_check,
+	DROP CHECK bills_currency_check;
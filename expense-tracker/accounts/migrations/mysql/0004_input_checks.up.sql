@@ -0,0 +1,11 @@
+-- Enforce the input validation rules in the schema (MySQL 8.0.16 or later).
+-- Rows that violate the new constraints make the migration fail.
+ALTER TABLE bills
+	ADD CONSTRAINT bills_title_check CHECK (CHAR_LENGTH(TRIM(title)) > 0),
+	ADD CONSTRAINT bills_total_check CHECK (total_cents >= 0),
+	ADD CONSTRAINT bills_currency_check CHECK (CHAR_LENGTH(currency) = 3);
+
+ALTER TABLE bill_items
+	ADD CONSTRAINT bill_items_name_check CHECK (CHAR_LENGTH(TRIM(name)) > 0),
+	ADD CONSTRAINT bill_items_amount_check CHECK (amount_cents >= 0),
+	ADD CONSTRAINT bill_items_quantity_check CHECK (quantity > 0);
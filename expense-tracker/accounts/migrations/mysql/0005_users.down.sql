@@ -0,0 +1,6 @@
+ALTER TABLE bills
+	DROP FOREIGN KEY bills_owner_id_fk,
+	DROP INDEX bills_owner_id_idx,
+	DROP COLUMN owner_id;
+
+DROP TABLE users;
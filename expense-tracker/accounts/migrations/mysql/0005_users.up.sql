@@ -0,0 +1,25 @@
+-- Bills belong to users. Bills that existed before are assigned to a user
+-- with the subject "legacy", which is only created if there are any; rename
+-- its subject to hand them over to a real user.
+CREATE TABLE users (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	subject VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL DEFAULT '',
+	name VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	UNIQUE KEY users_subject (subject),
+	CONSTRAINT users_subject_check CHECK (CHAR_LENGTH(subject) > 0)
+);
+
+INSERT INTO users (subject)
+SELECT 'legacy' FROM DUAL WHERE EXISTS (SELECT 1 FROM bills);
+
+ALTER TABLE bills ADD COLUMN owner_id BIGINT NULL AFTER id;
+
+UPDATE bills SET owner_id = (SELECT id FROM users WHERE subject = 'legacy');
+
+ALTER TABLE bills
+	MODIFY owner_id BIGINT NOT NULL,
+	ADD INDEX bills_owner_id_idx (owner_id),
+	ADD CONSTRAINT bills_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id);
@@ -0,0 +1,18 @@
+-- API keys of automation. Only the SHA-256 hash of a key is stored, with a
+-- prefix to recognize it; scopes are space-separated. Revoked keys are kept
+-- for auditing.
+CREATE TABLE api_keys (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	user_id BIGINT NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	prefix VARCHAR(32) NOT NULL,
+	key_hash CHAR(64) NOT NULL,
+	scopes VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP NULL,
+	revoked_at TIMESTAMP NULL,
+	UNIQUE KEY api_keys_key_hash (key_hash),
+	INDEX api_keys_user_id_idx (user_id),
+	CONSTRAINT api_keys_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id),
+	CONSTRAINT api_keys_name_check CHECK (CHAR_LENGTH(TRIM(name)) > 0)
+);
@@ -0,0 +1,9 @@
+DROP TABLE ledger_shares;
+DROP TABLE bill_shares;
+
+ALTER TABLE bills
+	DROP FOREIGN KEY bills_ledger_id_fk,
+	DROP INDEX bills_ledger_id_idx,
+	DROP COLUMN ledger_id;
+
+DROP TABLE ledgers;
@@ -0,0 +1,44 @@
+-- Sharing of bills with other users, one by one or filed in a ledger. The
+-- owner of a bill or ledger has no share; shares grant the other roles or
+-- make further users owners. Deleting a ledger keeps its bills.
+CREATE TABLE ledgers (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	owner_id BIGINT NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	INDEX ledgers_owner_id_idx (owner_id),
+	CONSTRAINT ledgers_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id),
+	CONSTRAINT ledgers_name_check CHECK (CHAR_LENGTH(TRIM(name)) > 0)
+);
+
+ALTER TABLE bills
+	ADD COLUMN ledger_id BIGINT NULL AFTER owner_id,
+	ADD INDEX bills_ledger_id_idx (ledger_id),
+	ADD CONSTRAINT bills_ledger_id_fk FOREIGN KEY (ledger_id) REFERENCES ledgers (id) ON DELETE SET NULL;
+
+CREATE TABLE bill_shares (
+	bill_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	role VARCHAR(16) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	PRIMARY KEY (bill_id, user_id),
+	INDEX bill_shares_user_id_idx (user_id),
+	CONSTRAINT bill_shares_bill_id_fk FOREIGN KEY (bill_id) REFERENCES bills (id) ON DELETE CASCADE,
+	CONSTRAINT bill_shares_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id),
+	CONSTRAINT bill_shares_role_check CHECK (role IN ('viewer', 'payer', 'editor', 'owner'))
+);
+
+CREATE TABLE ledger_shares (
+	ledger_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	role VARCHAR(16) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	PRIMARY KEY (ledger_id, user_id),
+	INDEX ledger_shares_user_id_idx (user_id),
+	CONSTRAINT ledger_shares_ledger_id_fk FOREIGN KEY (ledger_id) REFERENCES ledgers (id) ON DELETE CASCADE,
+	CONSTRAINT ledger_shares_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id),
+	CONSTRAINT ledger_shares_role_check CHECK (role IN ('viewer', 'payer', 'editor', 'owner'))
+);
@@ -0,0 +1,11 @@
+ALTER TABLE bill_items
+	DROP FOREIGN KEY bill_items_category_id_fk,
+	DROP INDEX bill_items_category_id_idx,
+	DROP COLUMN category_id;
+
+ALTER TABLE bills
+	DROP FOREIGN KEY bills_category_id_fk,
+	DROP INDEX bills_category_id_idx,
+	DROP COLUMN category_id;
+
+DROP TABLE categories;
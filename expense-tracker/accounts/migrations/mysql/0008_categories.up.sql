@@ -0,0 +1,44 @@
+-- Categories of bills and bill items. Default categories have no owner and
+-- are shared by all users; users add their own, also under default ones.
+-- Deleting a category deletes its subcategories and uncategorizes its bills
+-- and items.
+CREATE TABLE categories (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	owner_id BIGINT NULL,
+	parent_id BIGINT NULL,
+	name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	INDEX categories_owner_id_idx (owner_id),
+	INDEX categories_parent_id_idx (parent_id),
+	CONSTRAINT categories_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id),
+	CONSTRAINT categories_parent_id_fk FOREIGN KEY (parent_id) REFERENCES categories (id) ON DELETE CASCADE,
+	CONSTRAINT categories_name_check CHECK (CHAR_LENGTH(TRIM(name)) > 0)
+);
+
+INSERT INTO categories (name) VALUES
+	('Housing'), ('Food'), ('Transport'), ('Health'), ('Insurance'), ('Entertainment'), ('Shopping'), ('Travel');
+
+INSERT INTO categories (parent_id, name)
+SELECT p.id, c.name
+FROM categories p
+JOIN (
+	SELECT 'Housing' AS parent, 'Rent' AS name
+	UNION ALL SELECT 'Housing', 'Utilities'
+	UNION ALL SELECT 'Housing', 'Maintenance'
+	UNION ALL SELECT 'Food', 'Groceries'
+	UNION ALL SELECT 'Food', 'Dining Out'
+	UNION ALL SELECT 'Transport', 'Fuel'
+	UNION ALL SELECT 'Transport', 'Public Transport'
+) AS c ON c.parent = p.name
+WHERE p.owner_id IS NULL AND p.parent_id IS NULL;
+
+ALTER TABLE bills
+	ADD COLUMN category_id BIGINT NULL AFTER ledger_id,
+	ADD INDEX bills_category_id_idx (category_id),
+	ADD CONSTRAINT bills_category_id_fk FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL;
+
+ALTER TABLE bill_items
+	ADD COLUMN category_id BIGINT NULL AFTER bill_id,
+	ADD INDEX bill_items_category_id_idx (category_id),
+	ADD CONSTRAINT bill_items_category_id_fk FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL;
@@ -0,0 +1,31 @@
+-- Free-form tags of bills and bill items. Tags belong to the owner of the
+-- bills they are on and are named in lower case. Deleting a tag removes it
+-- from its bills and items.
+CREATE TABLE tags (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	owner_id BIGINT NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	UNIQUE KEY tags_owner_name (owner_id, name),
+	CONSTRAINT tags_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id),
+	CONSTRAINT tags_name_check CHECK (CHAR_LENGTH(TRIM(name)) > 0)
+);
+
+CREATE TABLE bill_tags (
+	bill_id BIGINT NOT NULL,
+	tag_id BIGINT NOT NULL,
+	PRIMARY KEY (bill_id, tag_id),
+	INDEX bill_tags_tag_id_idx (tag_id),
+	CONSTRAINT bill_tags_bill_id_fk FOREIGN KEY (bill_id) REFERENCES bills (id) ON DELETE CASCADE,
+	CONSTRAINT bill_tags_tag_id_fk FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+CREATE TABLE bill_item_tags (
+	item_id BIGINT NOT NULL,
+	tag_id BIGINT NOT NULL,
+	PRIMARY KEY (item_id, tag_id),
+	INDEX bill_item_tags_tag_id_idx (tag_id),
+	CONSTRAINT bill_item_tags_item_id_fk FOREIGN KEY (item_id) REFERENCES bill_items (id) ON DELETE CASCADE,
+	CONSTRAINT bill_item_tags_tag_id_fk FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+ALTER TABLE bills
+	DROP FOREIGN KEY bills_merchant_id_fk,
+	DROP INDEX bills_merchant_id_idx,
+	DROP COLUMN merchant_id;
+
+DROP TABLE merchant_aliases;
+DROP TABLE merchants;
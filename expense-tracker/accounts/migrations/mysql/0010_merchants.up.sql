@@ -0,0 +1,34 @@
+-- Merchants that bills are paid to. Merchants belong to the owner of the
+-- bills they are on and have a canonical name, normalized aliases to match
+-- the merchant names of receipts and an optional default category of their
+-- bills. Deleting a merchant keeps its bills.
+CREATE TABLE merchants (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	owner_id BIGINT NOT NULL,
+	category_id BIGINT NULL,
+	name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	UNIQUE KEY merchants_owner_name (owner_id, name),
+	INDEX merchants_category_id_idx (category_id),
+	CONSTRAINT merchants_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id),
+	CONSTRAINT merchants_category_id_fk FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL,
+	CONSTRAINT merchants_name_check CHECK (CHAR_LENGTH(TRIM(name)) > 0)
+);
+
+-- Aliases are unique per owner, so that a name resolves to one merchant
+CREATE TABLE merchant_aliases (
+	merchant_id BIGINT NOT NULL,
+	owner_id BIGINT NOT NULL,
+	alias VARCHAR(255) NOT NULL,
+	PRIMARY KEY (merchant_id, alias),
+	UNIQUE KEY merchant_aliases_owner_alias (owner_id, alias),
+	CONSTRAINT merchant_aliases_merchant_id_fk FOREIGN KEY (merchant_id) REFERENCES merchants (id) ON DELETE CASCADE,
+	CONSTRAINT merchant_aliases_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id),
+	CONSTRAINT merchant_aliases_alias_check CHECK (CHAR_LENGTH(TRIM(alias)) > 0)
+);
+
+ALTER TABLE bills
+	ADD COLUMN merchant_id BIGINT NULL AFTER category_id,
+	ADD INDEX bills_merchant_id_idx (merchant_id),
+	ADD CONSTRAINT bills_merchant_id_fk FOREIGN KEY (merchant_id) REFERENCES merchants (id) ON DELETE SET NULL;
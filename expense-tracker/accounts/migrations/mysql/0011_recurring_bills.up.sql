@@ -0,0 +1,45 @@
+-- Recurring bills are templates of bills that are generated on the
+-- occurrences of a schedule, an RFC 5545 recurrence rule starting on the
+-- start date. Recurring bills belong to a user. Deleting one keeps its bills.
+CREATE TABLE recurring_bills (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	owner_id BIGINT NOT NULL,
+	ledger_id BIGINT NULL,
+	category_id BIGINT NULL,
+	merchant_id BIGINT NULL,
+	title VARCHAR(255) NOT NULL,
+	description TEXT,
+	amount_cents BIGINT NOT NULL,
+	currency CHAR(3) NOT NULL,
+	rule VARCHAR(255) NOT NULL,
+	start_date DATE NOT NULL,
+	end_date DATE NULL,
+	paused BOOLEAN NOT NULL DEFAULT FALSE,
+	-- Occurrences up to this date have been generated, skipped or passed
+	-- over while paused
+	generated_through DATE NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	INDEX recurring_bills_owner_id_idx (owner_id),
+	CONSTRAINT recurring_bills_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id),
+	CONSTRAINT recurring_bills_ledger_id_fk FOREIGN KEY (ledger_id) REFERENCES ledgers (id) ON DELETE SET NULL,
+	CONSTRAINT recurring_bills_category_id_fk FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL,
+	CONSTRAINT recurring_bills_merchant_id_fk FOREIGN KEY (merchant_id) REFERENCES merchants (id) ON DELETE SET NULL,
+	CONSTRAINT recurring_bills_title_check CHECK (CHAR_LENGTH(TRIM(title)) > 0),
+	CONSTRAINT recurring_bills_amount_check CHECK (amount_cents >= 0),
+	CONSTRAINT recurring_bills_currency_check CHECK (CHAR_LENGTH(currency) = 3),
+	CONSTRAINT recurring_bills_dates_check CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+-- Occurrences that a bill was generated for or that were skipped. The
+-- primary key keeps generators from generating an occurrence twice.
+CREATE TABLE recurring_bill_occurrences (
+	recurring_bill_id BIGINT NOT NULL,
+	occurrence_date DATE NOT NULL,
+	bill_id BIGINT NULL,
+	skipped BOOLEAN NOT NULL DEFAULT FALSE,
+	PRIMARY KEY (recurring_bill_id, occurrence_date),
+	INDEX recurring_bill_occurrences_bill_id_idx (bill_id),
+	CONSTRAINT recurring_bill_occurrences_recurring_bill_id_fk FOREIGN KEY (recurring_bill_id) REFERENCES recurring_bills (id) ON DELETE CASCADE,
+	CONSTRAINT recurring_bill_occurrences_bill_id_fk FOREIGN KEY (bill_id) REFERENCES bills (id) ON DELETE SET NULL
+);
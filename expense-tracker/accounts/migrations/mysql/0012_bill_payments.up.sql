@@ -0,0 +1,16 @@
+-- Payments of bills, in the currency of the bill. A bill with payments is
+-- paid once they cover its total; bills marked as paid before payments were
+-- recorded keep their paid flag.
+CREATE TABLE bill_payments (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	bill_id BIGINT NOT NULL,
+	amount_cents BIGINT NOT NULL,
+	paid_on DATE NOT NULL,
+	method VARCHAR(32) NOT NULL,
+	reference VARCHAR(255) NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	INDEX bill_payments_bill_id_idx (bill_id),
+	CONSTRAINT bill_payments_bill_id_fk FOREIGN KEY (bill_id) REFERENCES bills (id) ON DELETE CASCADE,
+	CONSTRAINT bill_payments_amount_check CHECK (amount_cents > 0)
+);
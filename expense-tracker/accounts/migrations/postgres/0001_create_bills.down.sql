@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS bill_items;
+DROP TABLE IF EXISTS bills;
+DROP FUNCTION IF EXISTS set_updated_at();
@@ -0,0 +1,41 @@
+-- The PostgreSQL backend stores amounts as integer minor units (cents) from
+-- the start, so unlike the other dialects it has no migration 0002
+CREATE TABLE IF NOT EXISTS bills (
+	id BIGSERIAL PRIMARY KEY,
+	title VARCHAR(255) NOT NULL,
+	description TEXT,
+	total_cents BIGINT NOT NULL DEFAULT 0,
+	due_date DATE,
+	paid BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS bill_items (
+	id BIGSERIAL PRIMARY KEY,
+	bill_id BIGINT NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+	name VARCHAR(255) NOT NULL,
+	description TEXT,
+	amount_cents BIGINT NOT NULL DEFAULT 0,
+	quantity INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS bill_items_bill_id_idx ON bill_items (bill_id);
+
+-- Keeps updated_at current on every update, like ON UPDATE CURRENT_TIMESTAMP in MySQL
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+	NEW.updated_at = CURRENT_TIMESTAMP;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bills_update_trigger
+BEFORE UPDATE ON bills
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TRIGGER bill_items_update_trigger
+BEFORE UPDATE ON bill_items
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,16 @@
+ALTER TABLE bills ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+CREATE TABLE fx_rates (
+	id BIGSERIAL PRIMARY KEY,
+	base_currency CHAR(3) NOT NULL,
+	quote_currency CHAR(3) NOT NULL,
+	rate NUMERIC(24, 10) NOT NULL,
+	effective_date DATE NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT fx_rates_pair_date UNIQUE (base_currency, quote_currency, effective_date)
+);
+
+CREATE TRIGGER fx_rates_update_trigger
+BEFORE UPDATE ON fx_rates
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
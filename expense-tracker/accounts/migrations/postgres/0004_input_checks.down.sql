@@ -0,0 +1,9 @@
+ALTER TABLE bill_items
+	DROP CONSTRAINT bill_items_name_check,
+	DROP CONSTRAINT bill_items_amount_check,
+	DROP CONSTRAINT bill_items_quantity_check;
+
+ALTER TABLE bills
+	DROP CONSTRAINT bills_title_check,
+	DROP CONSTRAINT bills_total_check,
+	DROP CONSTRAINT bills_currency_check;
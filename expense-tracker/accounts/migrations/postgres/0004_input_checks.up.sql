@@ -0,0 +1,11 @@
+-- Enforce the input validation rules in the schema.
+-- Rows that violate the new constraints make the migration fail.
+ALTER TABLE bills
+	ADD CONSTRAINT bills_title_check CHECK (char_length(trim(title)) > 0),
+	ADD CONSTRAINT bills_total_check CHECK (total_cents >= 0),
+	ADD CONSTRAINT bills_currency_check CHECK (char_length(currency) = 3);
+
+ALTER TABLE bill_items
+	ADD CONSTRAINT bill_items_name_check CHECK (char_length(trim(name)) > 0),
+	ADD CONSTRAINT bill_items_amount_check CHECK (amount_cents >= 0),
+	ADD CONSTRAINT bill_items_quantity_check CHECK (quantity > 0);
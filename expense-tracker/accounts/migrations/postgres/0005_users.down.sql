@@ -0,0 +1,5 @@
+DROP INDEX bills_owner_id_idx;
+
+ALTER TABLE bills DROP COLUMN owner_id;
+
+DROP TABLE users;
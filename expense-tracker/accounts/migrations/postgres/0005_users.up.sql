@@ -0,0 +1,30 @@
+-- Bills belong to users. Bills that existed before are assigned to a user
+-- with the subject "legacy", which is only created if there are any; rename
+-- its subject to hand them over to a real user.
+CREATE TABLE users (
+	id BIGSERIAL PRIMARY KEY,
+	subject VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL DEFAULT '',
+	name VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT users_subject_key UNIQUE (subject),
+	CONSTRAINT users_subject_check CHECK (char_length(subject) > 0)
+);
+
+CREATE TRIGGER users_update_trigger
+BEFORE UPDATE ON users
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+INSERT INTO users (subject)
+SELECT 'legacy' WHERE EXISTS (SELECT 1 FROM bills);
+
+ALTER TABLE bills ADD COLUMN owner_id BIGINT;
+
+UPDATE bills SET owner_id = (SELECT id FROM users WHERE subject = 'legacy');
+
+ALTER TABLE bills
+	ALTER COLUMN owner_id SET NOT NULL,
+	ADD CONSTRAINT bills_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id);
+
+CREATE INDEX bills_owner_id_idx ON bills (owner_id);
@@ -0,0 +1,19 @@
+-- API keys of automation. Only the SHA-256 hash of a key is stored, with a
+-- prefix to recognize it; scopes are space-separated. Revoked keys are kept
+-- for auditing.
+CREATE TABLE api_keys (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	prefix VARCHAR(32) NOT NULL,
+	key_hash CHAR(64) NOT NULL,
+	scopes VARCHAR(255) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ,
+	CONSTRAINT api_keys_key_hash_key UNIQUE (key_hash),
+	CONSTRAINT api_keys_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id),
+	CONSTRAINT api_keys_name_check CHECK (char_length(trim(name)) > 0)
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
@@ -0,0 +1,8 @@
+DROP TABLE ledger_shares;
+DROP TABLE bill_shares;
+
+DROP INDEX bills_ledger_id_idx;
+
+ALTER TABLE bills DROP COLUMN ledger_id;
+
+DROP TABLE ledgers;
@@ -0,0 +1,55 @@
+-- Sharing of bills with other users, one by one or filed in a ledger. The
+-- owner of a bill or ledger has no share; shares grant the other roles or
+-- make further users owners. Deleting a ledger keeps its bills.
+CREATE TABLE ledgers (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT NOT NULL REFERENCES users (id),
+	name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT ledgers_name_check CHECK (char_length(trim(name)) > 0)
+);
+
+CREATE INDEX ledgers_owner_id_idx ON ledgers (owner_id);
+
+CREATE TRIGGER ledgers_update_trigger
+BEFORE UPDATE ON ledgers
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+ALTER TABLE bills
+	ADD COLUMN ledger_id BIGINT,
+	ADD CONSTRAINT bills_ledger_id_fk FOREIGN KEY (ledger_id) REFERENCES ledgers (id) ON DELETE SET NULL;
+
+CREATE INDEX bills_ledger_id_idx ON bills (ledger_id);
+
+CREATE TABLE bill_shares (
+	bill_id BIGINT NOT NULL REFERENCES bills (id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL REFERENCES users (id),
+	role VARCHAR(16) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (bill_id, user_id),
+	CONSTRAINT bill_shares_role_check CHECK (role IN ('viewer', 'payer', 'editor', 'owner'))
+);
+
+CREATE INDEX bill_shares_user_id_idx ON bill_shares (user_id);
+
+CREATE TRIGGER bill_shares_update_trigger
+BEFORE UPDATE ON bill_shares
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE ledger_shares (
+	ledger_id BIGINT NOT NULL REFERENCES ledgers (id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL REFERENCES users (id),
+	role VARCHAR(16) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (ledger_id, user_id),
+	CONSTRAINT ledger_shares_role_check CHECK (role IN ('viewer', 'payer', 'editor', 'owner'))
+);
+
+CREATE INDEX ledger_shares_user_id_idx ON ledger_shares (user_id);
+
+CREATE TRIGGER ledger_shares_update_trigger
+BEFORE UPDATE ON ledger_shares
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
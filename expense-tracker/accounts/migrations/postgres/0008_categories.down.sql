@@ -0,0 +1,7 @@
+DROP INDEX bill_items_category_id_idx;
+ALTER TABLE bill_items DROP COLUMN category_id;
+
+DROP INDEX bills_category_id_idx;
+ALTER TABLE bills DROP COLUMN category_id;
+
+DROP TABLE categories;
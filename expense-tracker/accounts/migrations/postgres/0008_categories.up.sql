@@ -0,0 +1,45 @@
+-- Categories of bills and bill items. Default categories have no owner and
+-- are shared by all users; users add their own, also under default ones.
+-- Deleting a category deletes its subcategories and uncategorizes its bills
+-- and items.
+CREATE TABLE categories (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT REFERENCES users (id),
+	parent_id BIGINT REFERENCES categories (id) ON DELETE CASCADE,
+	name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT categories_name_check CHECK (char_length(trim(name)) > 0)
+);
+
+CREATE INDEX categories_owner_id_idx ON categories (owner_id);
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
+
+CREATE TRIGGER categories_update_trigger
+BEFORE UPDATE ON categories
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+INSERT INTO categories (name) VALUES
+	('Housing'), ('Food'), ('Transport'), ('Health'), ('Insurance'), ('Entertainment'), ('Shopping'), ('Travel');
+
+INSERT INTO categories (parent_id, name)
+SELECT p.id, c.name
+FROM categories p
+JOIN (VALUES
+	('Housing', 'Rent'), ('Housing', 'Utilities'), ('Housing', 'Maintenance'),
+	('Food', 'Groceries'), ('Food', 'Dining Out'),
+	('Transport', 'Fuel'), ('Transport', 'Public Transport')
+) AS c (parent, name) ON c.parent = p.name
+WHERE p.owner_id IS NULL AND p.parent_id IS NULL;
+
+ALTER TABLE bills
+	ADD COLUMN category_id BIGINT,
+	ADD CONSTRAINT bills_category_id_fk FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL;
+
+CREATE INDEX bills_category_id_idx ON bills (category_id);
+
+ALTER TABLE bill_items
+	ADD COLUMN category_id BIGINT,
+	ADD CONSTRAINT bill_items_category_id_fk FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL;
+
+CREATE INDEX bill_items_category_id_idx ON bill_items (category_id);
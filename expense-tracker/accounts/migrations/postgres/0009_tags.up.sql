@@ -0,0 +1,32 @@
+-- Free-form tags of bills and bill items. Tags belong to the owner of the
+-- bills they are on and are named in lower case. Deleting a tag removes it
+-- from its bills and items.
+CREATE TABLE tags (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT NOT NULL REFERENCES users (id),
+	name VARCHAR(64) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT tags_owner_name_key UNIQUE (owner_id, name),
+	CONSTRAINT tags_name_check CHECK (char_length(trim(name)) > 0)
+);
+
+CREATE TRIGGER tags_update_trigger
+BEFORE UPDATE ON tags
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE bill_tags (
+	bill_id BIGINT NOT NULL REFERENCES bills (id) ON DELETE CASCADE,
+	tag_id BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	PRIMARY KEY (bill_id, tag_id)
+);
+
+CREATE INDEX bill_tags_tag_id_idx ON bill_tags (tag_id);
+
+CREATE TABLE bill_item_tags (
+	item_id BIGINT NOT NULL REFERENCES bill_items (id) ON DELETE CASCADE,
+	tag_id BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	PRIMARY KEY (item_id, tag_id)
+);
+
+CREATE INDEX bill_item_tags_tag_id_idx ON bill_item_tags (tag_id);
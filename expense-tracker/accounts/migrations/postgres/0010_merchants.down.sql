@@ -0,0 +1,5 @@
+DROP INDEX bills_merchant_id_idx;
+ALTER TABLE bills DROP COLUMN merchant_id;
+
+DROP TABLE merchant_aliases;
+DROP TABLE merchants;
@@ -0,0 +1,36 @@
+-- Merchants that bills are paid to. Merchants belong to the owner of the
+-- bills they are on and have a canonical name, normalized aliases to match
+-- the merchant names of receipts and an optional default category of their
+-- bills. Deleting a merchant keeps its bills.
+CREATE TABLE merchants (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT NOT NULL REFERENCES users (id),
+	category_id BIGINT REFERENCES categories (id) ON DELETE SET NULL,
+	name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT merchants_owner_name_key UNIQUE (owner_id, name),
+	CONSTRAINT merchants_name_check CHECK (char_length(trim(name)) > 0)
+);
+
+CREATE INDEX merchants_category_id_idx ON merchants (category_id);
+
+CREATE TRIGGER merchants_update_trigger
+BEFORE UPDATE ON merchants
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Aliases are unique per owner, so that a name resolves to one merchant
+CREATE TABLE merchant_aliases (
+	merchant_id BIGINT NOT NULL REFERENCES merchants (id) ON DELETE CASCADE,
+	owner_id BIGINT NOT NULL REFERENCES users (id),
+	alias VARCHAR(255) NOT NULL,
+	PRIMARY KEY (merchant_id, alias),
+	CONSTRAINT merchant_aliases_owner_alias_key UNIQUE (owner_id, alias),
+	CONSTRAINT merchant_aliases_alias_check CHECK (char_length(trim(alias)) > 0)
+);
+
+ALTER TABLE bills
+	ADD COLUMN merchant_id BIGINT,
+	ADD CONSTRAINT bills_merchant_id_fk FOREIGN KEY (merchant_id) REFERENCES merchants (id) ON DELETE SET NULL;
+
+CREATE INDEX bills_merchant_id_idx ON bills (merchant_id);
@@ -0,0 +1,45 @@
+-- Recurring bills are templates of bills that are generated on the
+-- occurrences of a schedule, an RFC 5545 recurrence rule starting on the
+-- start date. Recurring bills belong to a user. Deleting one keeps its bills.
+CREATE TABLE recurring_bills (
+	id BIGSERIAL PRIMARY KEY,
+	owner_id BIGINT NOT NULL REFERENCES users (id),
+	ledger_id BIGINT REFERENCES ledgers (id) ON DELETE SET NULL,
+	category_id BIGINT REFERENCES categories (id) ON DELETE SET NULL,
+	merchant_id BIGINT REFERENCES merchants (id) ON DELETE SET NULL,
+	title VARCHAR(255) NOT NULL,
+	description TEXT,
+	amount_cents BIGINT NOT NULL,
+	currency CHAR(3) NOT NULL,
+	rule VARCHAR(255) NOT NULL,
+	start_date DATE NOT NULL,
+	end_date DATE,
+	paused BOOLEAN NOT NULL DEFAULT FALSE,
+	-- Occurrences up to this date have been generated, skipped or passed
+	-- over while paused
+	generated_through DATE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT recurring_bills_title_check CHECK (char_length(trim(title)) > 0),
+	CONSTRAINT recurring_bills_amount_check CHECK (amount_cents >= 0),
+	CONSTRAINT recurring_bills_currency_check CHECK (char_length(currency) = 3),
+	CONSTRAINT recurring_bills_dates_check CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+CREATE INDEX recurring_bills_owner_id_idx ON recurring_bills (owner_id);
+
+CREATE TRIGGER recurring_bills_update_trigger
+BEFORE UPDATE ON recurring_bills
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Occurrences that a bill was generated for or that were skipped. The
+-- primary key keeps generators from generating an occurrence twice.
+CREATE TABLE recurring_bill_occurrences (
+	recurring_bill_id BIGINT NOT NULL REFERENCES recurring_bills (id) ON DELETE CASCADE,
+	occurrence_date DATE NOT NULL,
+	bill_id BIGINT REFERENCES bills (id) ON DELETE SET NULL,
+	skipped BOOLEAN NOT NULL DEFAULT FALSE,
+	PRIMARY KEY (recurring_bill_id, occurrence_date)
+);
+
+CREATE INDEX recurring_bill_occurrences_bill_id_idx ON recurring_bill_occurrences (bill_id);
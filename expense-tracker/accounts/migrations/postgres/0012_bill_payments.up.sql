@@ -0,0 +1,20 @@
+-- Payments of bills, in the currency of the bill. A bill with payments is
+-- paid once they cover its total; bills marked as paid before payments were
+-- recorded keep their paid flag.
+CREATE TABLE bill_payments (
+	id BIGSERIAL PRIMARY KEY,
+	bill_id BIGINT NOT NULL REFERENCES bills (id) ON DELETE CASCADE,
+	amount_cents BIGINT NOT NULL,
+	paid_on DATE NOT NULL,
+	method VARCHAR(32) NOT NULL,
+	reference VARCHAR(255),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT bill_payments_amount_check CHECK (amount_cents > 0)
+);
+
+CREATE INDEX bill_payments_bill_id_idx ON bill_payments (bill_id);
+
+CREATE TRIGGER bill_payments_update_trigger
+BEFORE UPDATE ON bill_payments
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,4 @@
+-- Whether the identity provider verified the email of a user. Only verified
+-- emails are used to find users to share with; emails stored before are
+-- unverified until their users sign in again.
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
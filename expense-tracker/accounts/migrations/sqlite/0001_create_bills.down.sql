@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS bill_items_update_trigger;
+DROP TRIGGER IF EXISTS bills_update_trigger;
+DROP TABLE IF EXISTS bill_items;
+DROP TABLE IF EXISTS bills;
@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS bills (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	description TEXT,
+	total REAL NOT NULL DEFAULT 0,
+	due_date DATE,
+	paid INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS bill_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	bill_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	description TEXT,
+	amount REAL NOT NULL,
+	quantity INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (bill_id) REFERENCES bills(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER IF NOT EXISTS bills_update_trigger
+AFTER UPDATE ON bills
+FOR EACH ROW
+BEGIN
+	UPDATE bills SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS bill_items_update_trigger
+AFTER UPDATE ON bill_items
+FOR EACH ROW
+BEGIN
+	UPDATE bill_items SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
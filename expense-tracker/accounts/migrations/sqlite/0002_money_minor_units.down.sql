@@ -0,0 +1,24 @@
+DROP TRIGGER IF EXISTS bills_update_trigger;
+DROP TRIGGER IF EXISTS bill_items_update_trigger;
+
+ALTER TABLE bills ADD COLUMN total REAL NOT NULL DEFAULT 0;
+UPDATE bills SET total = total_cents / 100.0;
+ALTER TABLE bills DROP COLUMN total_cents;
+
+ALTER TABLE bill_items ADD COLUMN amount REAL NOT NULL DEFAULT 0;
+UPDATE bill_items SET amount = amount_cents / 100.0;
+ALTER TABLE bill_items DROP COLUMN amount_cents;
+
+CREATE TRIGGER bills_update_trigger
+AFTER UPDATE ON bills
+FOR EACH ROW
+BEGIN
+	UPDATE bills SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+CREATE TRIGGER bill_items_update_trigger
+AFTER UPDATE ON bill_items
+FOR EACH ROW
+BEGIN
+	UPDATE bill_items SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
@@ -0,0 +1,26 @@
+-- Store amounts as integer minor units (cents) instead of REAL.
+-- The update triggers are dropped during the conversion so updated_at is kept.
+DROP TRIGGER IF EXISTS bills_update_trigger;
+DROP TRIGGER IF EXISTS bill_items_update_trigger;
+
+ALTER TABLE bills ADD COLUMN total_cents INTEGER NOT NULL DEFAULT 0;
+UPDATE bills SET total_cents = CAST(ROUND(total * 100) AS INTEGER);
+ALTER TABLE bills DROP COLUMN total;
+
+ALTER TABLE bill_items ADD COLUMN amount_cents INTEGER NOT NULL DEFAULT 0;
+UPDATE bill_items SET amount_cents = CAST(ROUND(amount * 100) AS INTEGER);
+ALTER TABLE bill_items DROP COLUMN amount;
+
+CREATE TRIGGER bills_update_trigger
+AFTER UPDATE ON bills
+FOR EACH ROW
+BEGIN
+	UPDATE bills SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+CREATE TRIGGER bill_items_update_trigger
+AFTER UPDATE ON bill_items
+FOR EACH ROW
+BEGIN
+	UPDATE bill_items SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS fx_rates_update_trigger;
+DROP TABLE IF EXISTS fx_rates;
+ALTER TABLE bills DROP COLUMN currency;
@@ -0,0 +1,19 @@
+ALTER TABLE bills ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+CREATE TABLE fx_rates (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	base_currency TEXT NOT NULL,
+	quote_currency TEXT NOT NULL,
+	rate TEXT NOT NULL,
+	effective_date DATE NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (base_currency, quote_currency, effective_date)
+);
+
+CREATE TRIGGER fx_rates_update_trigger
+AFTER UPDATE ON fx_rates
+FOR EACH ROW
+BEGIN
+	UPDATE fx_rates SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
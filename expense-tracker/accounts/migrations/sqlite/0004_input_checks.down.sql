@@ -0,0 +1,48 @@
+CREATE TABLE bills_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	description TEXT,
+	total_cents INTEGER NOT NULL DEFAULT 0,
+	currency TEXT NOT NULL DEFAULT 'USD',
+	due_date DATE,
+	paid INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO bills_old (id, title, description, total_cents, currency, due_date, paid, created_at, updated_at)
+SELECT id, title, description, total_cents, currency, due_date, paid, created_at, updated_at FROM bills;
+
+CREATE TABLE bill_items_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	bill_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	description TEXT,
+	amount_cents INTEGER NOT NULL DEFAULT 0,
+	quantity INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (bill_id) REFERENCES bills_old(id) ON DELETE CASCADE
+);
+
+INSERT INTO bill_items_old (id, bill_id, name, description, amount_cents, quantity, created_at, updated_at)
+SELECT id, bill_id, name, description, amount_cents, quantity, created_at, updated_at FROM bill_items;
+
+DROP TABLE bill_items;
+DROP TABLE bills;
+ALTER TABLE bills_old RENAME TO bills;
+ALTER TABLE bill_items_old RENAME TO bill_items;
+
+CREATE TRIGGER bills_update_trigger
+AFTER UPDATE ON bills
+FOR EACH ROW
+BEGIN
+	UPDATE bills SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+CREATE TRIGGER bill_items_update_trigger
+AFTER UPDATE ON bill_items
+FOR EACH ROW
+BEGIN
+	UPDATE bill_items SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
@@ -0,0 +1,75 @@
+-- Bills belong to users. Bills that existed before are assigned to a user
+-- with the subject "legacy", which is only created if there are any; rename
+-- its subject to hand them over to a real user. SQLite cannot add a NOT NULL
+-- foreign key to an existing table, so bills and bill_items are rebuilt as
+-- in 0004.
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	subject TEXT NOT NULL UNIQUE CHECK (length(subject) > 0),
+	email TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER users_update_trigger
+AFTER UPDATE ON users
+FOR EACH ROW
+BEGIN
+	UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+INSERT INTO users (subject)
+SELECT 'legacy' WHERE EXISTS (SELECT 1 FROM bills);
+
+CREATE TABLE bills_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner_id INTEGER NOT NULL REFERENCES users(id),
+	title TEXT NOT NULL CHECK (length(trim(title)) > 0 AND length(title) <= 255),
+	description TEXT,
+	total_cents INTEGER NOT NULL DEFAULT 0 CHECK (total_cents >= 0),
+	currency TEXT NOT NULL DEFAULT 'USD' CHECK (length(currency) = 3),
+	due_date DATE,
+	paid INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO bills_new (id, owner_id, title, description, total_cents, currency, due_date, paid, created_at, updated_at)
+SELECT id, (SELECT id FROM users WHERE subject = 'legacy'), title, description, total_cents, currency, due_date, paid, created_at, updated_at FROM bills;
+
+CREATE TABLE bill_items_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	bill_id INTEGER NOT NULL,
+	name TEXT NOT NULL CHECK (length(trim(name)) > 0 AND length(name) <= 255),
+	description TEXT,
+	amount_cents INTEGER NOT NULL DEFAULT 0 CHECK (amount_cents >= 0),
+	quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (bill_id) REFERENCES bills_new(id) ON DELETE CASCADE
+);
+
+INSERT INTO bill_items_new (id, bill_id, name, description, amount_cents, quantity, created_at, updated_at)
+SELECT id, bill_id, name, description, amount_cents, quantity, created_at, updated_at FROM bill_items;
+
+DROP TABLE bill_items;
+DROP TABLE bills;
+ALTER TABLE bills_new RENAME TO bills;
+ALTER TABLE bill_items_new RENAME TO bill_items;
+
+CREATE INDEX bills_owner_id_idx ON bills (owner_id);
+
+CREATE TRIGGER bills_update_trigger
+AFTER UPDATE ON bills
+FOR EACH ROW
+BEGIN
+	UPDATE bills SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+CREATE TRIGGER bill_items_update_trigger
+AFTER UPDATE ON bill_items
+FOR EACH ROW
+BEGIN
+	UPDATE bill_items SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
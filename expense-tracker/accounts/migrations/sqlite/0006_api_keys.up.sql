@@ -0,0 +1,16 @@
+-- API keys of automation. Only the SHA-256 hash of a key is stored, with a
+-- prefix to recognize it; scopes are space-separated. Revoked keys are kept
+-- for auditing.
+CREATE TABLE api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	name TEXT NOT NULL CHECK (length(trim(name)) > 0 AND length(name) <= 255),
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
@@ -0,0 +1,59 @@
+-- Sharing of bills with other users, one by one or filed in a ledger. The
+-- owner of a bill or ledger has no share; shares grant the other roles or
+-- make further users owners. Deleting a ledger keeps its bills.
+CREATE TABLE ledgers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner_id INTEGER NOT NULL REFERENCES users(id),
+	name TEXT NOT NULL CHECK (length(trim(name)) > 0 AND length(name) <= 255),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ledgers_owner_id_idx ON ledgers (owner_id);
+
+CREATE TRIGGER ledgers_update_trigger
+AFTER UPDATE ON ledgers
+FOR EACH ROW
+BEGIN
+	UPDATE ledgers SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+ALTER TABLE bills ADD COLUMN ledger_id INTEGER REFERENCES ledgers(id) ON DELETE SET NULL;
+
+CREATE INDEX bills_ledger_id_idx ON bills (ledger_id);
+
+CREATE TABLE bill_shares (
+	bill_id INTEGER NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	role TEXT NOT NULL CHECK (role IN ('viewer', 'payer', 'editor', 'owner')),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (bill_id, user_id)
+);
+
+CREATE INDEX bill_shares_user_id_idx ON bill_shares (user_id);
+
+CREATE TRIGGER bill_shares_update_trigger
+AFTER UPDATE ON bill_shares
+FOR EACH ROW
+BEGIN
+	UPDATE bill_shares SET updated_at = CURRENT_TIMESTAMP WHERE bill_id = OLD.bill_id AND user_id = OLD.user_id;
+END;
+
+CREATE TABLE ledger_shares (
+	ledger_id INTEGER NOT NULL REFERENCES ledgers(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	role TEXT NOT NULL CHECK (role IN ('viewer', 'payer', 'editor', 'owner')),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (ledger_id, user_id)
+);
+
+CREATE INDEX ledger_shares_user_id_idx ON ledger_shares (user_id);
+
+CREATE TRIGGER ledger_shares_update_trigger
+AFTER UPDATE ON ledger_shares
+FOR EACH ROW
+BEGIN
+	UPDATE ledger_shares SET updated_at = CURRENT_TIMESTAMP WHERE ledger_id = OLD.ledger_id AND user_id = OLD.user_id;
+END;
@@ -0,0 +1,43 @@
+-- Categories of bills and bill items. Default categories have no owner and
+-- are shared by all users; users add their own, also under default ones.
+-- Deleting a category deletes its subcategories and uncategorizes its bills
+-- and items.
+CREATE TABLE categories (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner_id INTEGER REFERENCES users(id),
+	parent_id INTEGER REFERENCES categories(id) ON DELETE CASCADE,
+	name TEXT NOT NULL CHECK (length(trim(name)) > 0 AND length(name) <= 255),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX categories_owner_id_idx ON categories (owner_id);
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
+
+CREATE TRIGGER categories_update_trigger
+AFTER UPDATE ON categories
+FOR EACH ROW
+BEGIN
+	UPDATE categories SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+INSERT INTO categories (name) VALUES
+	('Housing'), ('Food'), ('Transport'), ('Health'), ('Insurance'), ('Entertainment'), ('Shopping'), ('Travel');
+
+INSERT INTO categories (parent_id, name)
+SELECT p.id, c.column2
+FROM categories p
+JOIN (VALUES
+	('Housing', 'Rent'), ('Housing', 'Utilities'), ('Housing', 'Maintenance'),
+	('Food', 'Groceries'), ('Food', 'Dining Out'),
+	('Transport', 'Fuel'), ('Transport', 'Public Transport')
+) AS c ON c.column1 = p.name
+WHERE p.owner_id IS NULL AND p.parent_id IS NULL;
+
+ALTER TABLE bills ADD COLUMN category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL;
+
+CREATE INDEX bills_category_id_idx ON bills (category_id);
+
+ALTER TABLE bill_items ADD COLUMN category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL;
+
+CREATE INDEX bill_items_category_id_idx ON bill_items (category_id);
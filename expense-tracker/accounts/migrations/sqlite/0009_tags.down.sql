@@ -0,0 +1,3 @@
+DROP TABLE bill_item_tags;
+DROP TABLE bill_tags;
+DROP TABLE tags;
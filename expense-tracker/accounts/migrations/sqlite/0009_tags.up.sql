@@ -0,0 +1,34 @@
+-- Free-form tags of bills and bill items. Tags belong to the owner of the
+-- bills they are on and are named in lower case. Deleting a tag removes it
+-- from its bills and items.
+CREATE TABLE tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner_id INTEGER NOT NULL REFERENCES users(id),
+	name TEXT NOT NULL CHECK (length(trim(name)) > 0 AND length(name) <= 64),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (owner_id, name)
+);
+
+CREATE TRIGGER tags_update_trigger
+AFTER UPDATE ON tags
+FOR EACH ROW
+BEGIN
+	UPDATE tags SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+CREATE TABLE bill_tags (
+	bill_id INTEGER NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (bill_id, tag_id)
+);
+
+CREATE INDEX bill_tags_tag_id_idx ON bill_tags (tag_id);
+
+CREATE TABLE bill_item_tags (
+	item_id INTEGER NOT NULL REFERENCES bill_items(id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (item_id, tag_id)
+);
+
+CREATE INDEX bill_item_tags_tag_id_idx ON bill_item_tags (tag_id);
@@ -0,0 +1,74 @@
+-- SQLite cannot drop a foreign key column, so bills and bill_items are
+-- rebuilt as in 0009. Dropping them would delete the shares and tags of the
+-- bills and items, so they are kept aside meanwhile.
+CREATE TEMP TABLE bill_shares_backup AS SELECT * FROM bill_shares;
+CREATE TEMP TABLE bill_tags_backup AS SELECT * FROM bill_tags;
+CREATE TEMP TABLE bill_item_tags_backup AS SELECT * FROM bill_item_tags;
+
+CREATE TABLE bills_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner_id INTEGER NOT NULL REFERENCES users(id),
+	title TEXT NOT NULL CHECK (length(trim(title)) > 0 AND length(title) <= 255),
+	description TEXT,
+	total_cents INTEGER NOT NULL DEFAULT 0 CHECK (total_cents >= 0),
+	currency TEXT NOT NULL DEFAULT 'USD' CHECK (length(currency) = 3),
+	due_date DATE,
+	paid INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ledger_id INTEGER REFERENCES ledgers(id) ON DELETE SET NULL,
+	category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL
+);
+
+INSERT INTO bills_old (id, owner_id, title, description, total_cents, currency, due_date, paid, created_at, updated_at, ledger_id, category_id)
+SELECT id, owner_id, title, description, total_cents, currency, due_date, paid, created_at, updated_at, ledger_id, category_id FROM bills;
+
+CREATE TABLE bill_items_old (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	bill_id INTEGER NOT NULL,
+	name TEXT NOT NULL CHECK (length(trim(name)) > 0 AND length(name) <= 255),
+	description TEXT,
+	amount_cents INTEGER NOT NULL DEFAULT 0 CHECK (amount_cents >= 0),
+	quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+	FOREIGN KEY (bill_id) REFERENCES bills_old(id) ON DELETE CASCADE
+);
+
+INSERT INTO bill_items_old (id, bill_id, name, description, amount_cents, quantity, created_at, updated_at, category_id)
+SELECT id, bill_id, name, description, amount_cents, quantity, created_at, updated_at, category_id FROM bill_items;
+
+DROP TABLE bill_items;
+DROP TABLE bills;
+ALTER TABLE bills_old RENAME TO bills;
+ALTER TABLE bill_items_old RENAME TO bill_items;
+
+CREATE INDEX bills_owner_id_idx ON bills (owner_id);
+CREATE INDEX bills_ledger_id_idx ON bills (ledger_id);
+CREATE INDEX bills_category_id_idx ON bills (category_id);
+CREATE INDEX bill_items_category_id_idx ON bill_items (category_id);
+
+CREATE TRIGGER bills_update_trigger
+AFTER UPDATE ON bills
+FOR EACH ROW
+BEGIN
+	UPDATE bills SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+CREATE TRIGGER bill_items_update_trigger
+AFTER UPDATE ON bill_items
+FOR EACH ROW
+BEGIN
+	UPDATE bill_items SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+INSERT INTO bill_shares SELECT * FROM bill_shares_backup;
+INSERT INTO bill_tags SELECT * FROM bill_tags_backup;
+INSERT INTO bill_item_tags SELECT * FROM bill_item_tags_backup;
+DROP TABLE bill_shares_backup;
+DROP TABLE bill_tags_backup;
+DROP TABLE bill_item_tags_backup;
+
+DROP TABLE merchant_aliases;
+DROP TABLE merchants;
@@ -0,0 +1,35 @@
+-- Merchants that bills are paid to. Merchants belong to the owner of the
+-- bills they are on and have a canonical name, normalized aliases to match
+-- the merchant names of receipts and an optional default category of their
+-- bills. Deleting a merchant keeps its bills.
+CREATE TABLE merchants (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner_id INTEGER NOT NULL REFERENCES users(id),
+	category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+	name TEXT NOT NULL CHECK (length(trim(name)) > 0 AND length(name) <= 255),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (owner_id, name)
+);
+
+CREATE INDEX merchants_category_id_idx ON merchants (category_id);
+
+CREATE TRIGGER merchants_update_trigger
+AFTER UPDATE ON merchants
+FOR EACH ROW
+BEGIN
+	UPDATE merchants SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+-- Aliases are unique per owner, so that a name resolves to one merchant
+CREATE TABLE merchant_aliases (
+	merchant_id INTEGER NOT NULL REFERENCES merchants(id) ON DELETE CASCADE,
+	owner_id INTEGER NOT NULL REFERENCES users(id),
+	alias TEXT NOT NULL CHECK (length(trim(alias)) > 0 AND length(alias) <= 255),
+	PRIMARY KEY (merchant_id, alias),
+	UNIQUE (owner_id, alias)
+);
+
+ALTER TABLE bills ADD COLUMN merchant_id INTEGER REFERENCES merchants(id) ON DELETE SET NULL;
+
+CREATE INDEX bills_merchant_id_idx ON bills (merchant_id);
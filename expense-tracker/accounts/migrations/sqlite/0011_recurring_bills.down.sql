@@ -0,0 +1,2 @@
+DROP TABLE recurring_bill_occurrences;
+DROP TABLE recurring_bills;
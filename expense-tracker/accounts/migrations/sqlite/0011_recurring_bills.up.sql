@@ -0,0 +1,44 @@
+-- Recurring bills are templates of bills that are generated on the
+-- occurrences of a schedule, an RFC 5545 recurrence rule starting on the
+-- start date. Recurring bills belong to a user. Deleting one keeps its bills.
+CREATE TABLE recurring_bills (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner_id INTEGER NOT NULL REFERENCES users(id),
+	ledger_id INTEGER REFERENCES ledgers(id) ON DELETE SET NULL,
+	category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+	merchant_id INTEGER REFERENCES merchants(id) ON DELETE SET NULL,
+	title TEXT NOT NULL CHECK (length(trim(title)) > 0 AND length(title) <= 255),
+	description TEXT,
+	amount_cents INTEGER NOT NULL CHECK (amount_cents >= 0),
+	currency TEXT NOT NULL CHECK (length(currency) = 3),
+	rule TEXT NOT NULL CHECK (length(rule) <= 255),
+	start_date DATE NOT NULL,
+	end_date DATE CHECK (end_date IS NULL OR end_date >= start_date),
+	paused INTEGER NOT NULL DEFAULT 0,
+	-- Occurrences up to this date have been generated, skipped or passed
+	-- over while paused
+	generated_through DATE,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX recurring_bills_owner_id_idx ON recurring_bills (owner_id);
+
+CREATE TRIGGER recurring_bills_update_trigger
+AFTER UPDATE ON recurring_bills
+FOR EACH ROW
+BEGIN
+	UPDATE recurring_bills SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
+
+-- Occurrences that a bill was generated for or that were skipped. The
+-- primary key keeps generators from generating an occurrence twice.
+CREATE TABLE recurring_bill_occurrences (
+	recurring_bill_id INTEGER NOT NULL REFERENCES recurring_bills(id) ON DELETE CASCADE,
+	occurrence_date DATE NOT NULL,
+	bill_id INTEGER REFERENCES bills(id) ON DELETE SET NULL,
+	skipped INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (recurring_bill_id, occurrence_date)
+);
+
+CREATE INDEX recurring_bill_occurrences_bill_id_idx ON recurring_bill_occurrences (bill_id);
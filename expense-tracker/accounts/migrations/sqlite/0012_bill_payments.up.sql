@@ -0,0 +1,22 @@
+-- Payments of bills, in the currency of the bill. A bill with payments is
+-- paid once they cover its total; bills marked as paid before payments were
+-- recorded keep their paid flag.
+CREATE TABLE bill_payments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	bill_id INTEGER NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+	amount_cents INTEGER NOT NULL CHECK (amount_cents > 0),
+	paid_on DATE NOT NULL,
+	method TEXT NOT NULL CHECK (length(method) <= 32),
+	reference TEXT CHECK (reference IS NULL OR length(reference) <= 255),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX bill_payments_bill_id_idx ON bill_payments (bill_id);
+
+CREATE TRIGGER bill_payments_update_trigger
+AFTER UPDATE ON bill_payments
+FOR EACH ROW
+BEGIN
+	UPDATE bill_payments SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
+END;
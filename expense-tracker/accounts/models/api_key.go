@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// APIKey is a credential for automation such as scripts and the receipts
+// pipeline. A key acts as its user, limited to its scopes. Only a hash of
+// the key is stored; the key itself is returned once, when it is created.
+type APIKey struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	// Prefix is the start of the key, to recognize it in lists
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Hash is the SHA-256 hash of the key, hex encoded
+	Hash string `json:"-"`
+}
+
+// APIKeyInput represents the JSON input for creating an API key
+type APIKeyInput struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Subject of the user the key acts as, e.g. "service:receipts"; the user
+	// is created if it does not exist. Empty means the creating user.
+	Subject string `json:"subject,omitempty"`
+}
+
+// CreatedAPIKey is the response to creating an API key. It is the only
+// response that contains the key.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
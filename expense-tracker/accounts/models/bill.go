@@ -7,25 +7,39 @@ import (
 
 // Bill represents a financial bill
 type Bill struct {
-	ID          int64       `json:"id"`
-	Title       string      `json:"title"`
-	Description string      `json:"description"`
-	Total       float64     `json:"total"`
-	DueDate     time.Time   `json:"due_date"`
-	Paid        bool        `json:"paid"`
-	Items       []BillItem  `json:"items"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
+	ID            int64      `json:"id"`
+	OwnerID       int64      `json:"owner_id"`
+	LedgerID      *int64     `json:"ledger_id"`
+	CategoryID    *int64     `json:"category_id"`
+	MerchantID    *int64     `json:"merchant_id"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	Total         Money      `json:"total"`
+	Currency      string     `json:"currency"`
+	DueDate       time.Time  `json:"due_date"`
+	Paid          bool       `json:"paid"`
+	AmountPaid    Money      `json:"amount_paid"` // sum of the payments
+	Balance       Money      `json:"balance"`     // outstanding amount, negative if overpaid
+	PaymentStatus string     `json:"payment_status"`
+	Items         []BillItem `json:"items"`
+	Tags          []string   `json:"tags"`
+	Payments      []Payment  `json:"payments"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	// Role of the requesting user
+	Role Role `json:"role"`
 }
 
 // BillItem represents an item within a bill
 type BillItem struct {
 	ID          int64     `json:"id"`
 	BillID      int64     `json:"bill_id"`
+	CategoryID  *int64    `json:"category_id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
-	Amount      float64   `json:"amount"`
+	Amount      Money     `json:"amount"`
 	Quantity    int       `json:"quantity"`
+	Tags        []string  `json:"tags"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -34,17 +48,44 @@ type BillItem struct {
 type BillInput struct {
 	Title       string          `json:"title"`
 	Description string          `json:"description"`
+	Currency    string          `json:"currency"` // ISO 4217 code, defaults to DefaultCurrency
 	DueDate     string          `json:"due_date"` // ISO format (YYYY-MM-DD)
 	Paid        bool            `json:"paid"`
 	Items       []BillItemInput `json:"items"`
+	// LedgerID files the bill in a ledger the user may edit; nil keeps it out of ledgers
+	LedgerID *int64 `json:"ledger_id"`
+	// CategoryID is a default category or one of the user or the owner of the bill
+	CategoryID *int64 `json:"category_id"`
+	// Tags replace the tags of the bill by name; missing tags are created
+	// for the owner of the bill
+	Tags []string `json:"tags"`
+	// MerchantID is a merchant of the owner of the bill
+	MerchantID *int64 `json:"merchant_id"`
+	// Merchant is a merchant name as printed on a receipt. Without a
+	// MerchantID, it is resolved to the merchant of the owner of the bill
+	// whose name or alias matches best, if any.
+	Merchant string `json:"merchant"`
 }
 
 // BillItemInput represents the JSON input for creating/updating a bill item
 type BillItemInput struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Amount      float64 `json:"amount"`
-	Quantity    int     `json:"quantity"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
+	Quantity    int    `json:"quantity"`
+	// CategoryID optionally categorizes the item apart from its bill
+	CategoryID *int64 `json:"category_id"`
+	// Tags replace the tags of the item like those of a bill
+	Tags []string `json:"tags"`
+}
+
+// CalculateTotal returns the sum of amount times quantity over all items
+func (b *BillInput) CalculateTotal() Money {
+	var total Money
+	for _, item := range b.Items {
+		total += item.Amount.Mul(item.Quantity)
+	}
+	return total
 }
 
 // FromJSON converts a JSON string to a BillInput
@@ -59,13 +100,80 @@ func (b *Bill) ToJSON() ([]byte, error) {
 
 // BillSummary represents a summary of a bill with total amount
 type BillSummary struct {
-	ID          int64     `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Total       float64   `json:"total"`
-	DueDate     time.Time `json:"due_date"`
-	Paid        bool      `json:"paid"`
-	ItemCount   int       `json:"item_count"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID            int64     `json:"id"`
+	LedgerID      *int64    `json:"ledger_id"`
+	CategoryID    *int64    `json:"category_id"`
+	MerchantID    *int64    `json:"merchant_id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	Total         Money     `json:"total"`
+	Currency      string    `json:"currency"`
+	DueDate       time.Time `json:"due_date"`
+	Paid          bool      `json:"paid"`
+	AmountPaid    Money     `json:"amount_paid"` // sum of the payments
+	Balance       Money     `json:"balance"`     // outstanding amount, negative if overpaid
+	PaymentStatus string    `json:"payment_status"`
+	ItemCount     int       `json:"item_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	// Set when the bills are listed in a reporting currency
+	Converted *ConvertedAmount `json:"converted,omitempty"`
+}
+
+// Sort fields accepted when listing bills
+const (
+	BillSortDueDate   = "due_date"
+	BillSortTotal     = "total"
+	BillSortTitle     = "title"
+	BillSortCreatedAt = "created_at"
+	BillSortUpdatedAt = "updated_at"
+)
+
+// Sort orders accepted when listing bills
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// Page size limits for listing bills
+const (
+	DefaultBillPageSize = 50
+	MaxBillPageSize     = 500
+)
+
+// BillQuery represents the filters, sort order and page window used when listing bills.
+// Nil pointer fields and empty strings mean the filter is not applied.
+type BillQuery struct {
+	Paid     *bool
+	DueFrom  *time.Time // inclusive
+	DueTo    *time.Time // inclusive
+	MinTotal *Money     // inclusive
+	MaxTotal *Money     // inclusive
+	Title    string     // case-insensitive substring match
+	Currency string     // ISO 4217 code
+	LedgerID *int64
+	// CategoryID matches bills in the category or its subcategories, or
+	// with an item in them
+	CategoryID *int64
+	MerchantID *int64
+	// PaymentStatus matches bills with the payment status derived from
+	// their payments
+	PaymentStatus string
+	// Tags match bills with any or, for TagMatchAll, all of the tags on
+	// them or on their items
+	Tags      []string
+	TagMatch  string
+	SortBy    string
+	SortOrder string
+	Limit     int
+	Offset    int
+}
+
+// BillPage represents a page of bill summaries together with paging information
+type BillPage struct {
+	Bills      []BillSummary `json:"bills"`
+	Total      int           `json:"total"`
+	Limit      int           `json:"limit"`
+	Offset     int           `json:"offset"`
+	NextOffset *int          `json:"next_offset"`
 }
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Category classifies bills and bill items, e.g. as groceries. Categories
+// nest under a parent category. Default categories are shared by all users
+// and have no owner; users add their own categories, also under default
+// ones.
+type Category struct {
+	ID       int64  `json:"id"`
+	ParentID *int64 `json:"parent_id"`
+	// OwnerID is nil for default categories
+	OwnerID   *int64    `json:"owner_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CategoryInput represents the JSON input for creating/updating a category
+type CategoryInput struct {
+	Name string `json:"name"`
+	// ParentID nests the category under a category of the user or a default
+	// one; nil makes it a top-level category
+	ParentID *int64 `json:"parent_id"`
+}
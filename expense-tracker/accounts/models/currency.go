@@ -0,0 +1,268 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCurrency is the currency assigned to bills created without one
+const DefaultCurrency = "USD"
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// CurrencyError reports an invalid or unsupported currency code. Reason is
+// the message of the field error.
+type CurrencyError struct {
+	Code   string
+	Reason string
+}
+
+func (e *CurrencyError) Error() string {
+	return fmt.Sprintf("currency %q %s", e.Code, e.Reason)
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 alphabetic currency code and
+// checks that it is an active currency Money can hold, one with at most two
+// decimal places. An empty code yields DefaultCurrency. Errors are
+// *CurrencyError.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency, nil
+	}
+	if !currencyCodePattern.MatchString(code) {
+		return "", &CurrencyError{Code: code, Reason: "must be a 3-letter ISO 4217 code"}
+	}
+	digits, ok := currencyMinorDigits[code]
+	if !ok {
+		return "", &CurrencyError{Code: code, Reason: "must be an active ISO 4217 code"}
+	}
+	if digits > minorDigits {
+		return "", &CurrencyError{Code: code, Reason: fmt.Sprintf("is not supported: amounts have at most %d decimal places and %s has %d", minorDigits, code, digits)}
+	}
+	return code, nil
+}
+
+// CurrencyErrorReason returns the field error message of an error of
+// NormalizeCurrency
+func CurrencyErrorReason(err error) string {
+	var cerr *CurrencyError
+	if errors.As(err, &cerr) {
+		return cerr.Reason
+	}
+	return err.Error()
+}
+
+// CurrencyDigits returns the number of decimal places of amounts in a
+// supported currency, e.g. 0 for JPY
+func CurrencyDigits(code string) int {
+	if digits, ok := currencyMinorDigits[code]; ok && digits < minorDigits {
+		return digits
+	}
+	return minorDigits
+}
+
+// InCurrency reports whether the amount has no more decimal places than the
+// currency, e.g. whether it is a whole amount of JPY
+func (m Money) InCurrency(code string) bool {
+	return m == m.roundTo(CurrencyDigits(code))
+}
+
+// roundTo rounds the amount to the given number of decimal places, at most
+// minorDigits, with halves away from zero
+func (m Money) roundTo(digits int) Money {
+	step := Money(1)
+	for i := digits; i < minorDigits; i++ {
+		step *= 10
+	}
+	if step == 1 {
+		return m
+	}
+	rounded := (m + step/2) / step * step
+	if m < 0 {
+		rounded = (m - step/2) / step * step
+	}
+	return rounded
+}
+
+// CurrencyAmountMessage is the field error message of an amount that has
+// more decimal places than its currency
+func CurrencyAmountMessage(code string) string {
+	return fmt.Sprintf("must be a whole amount of %s", code)
+}
+
+// Rate is an exchange rate kept as an exact decimal string, e.g. "1.0832".
+// One unit of the base currency buys Rate units of the quote currency.
+type Rate string
+
+// rateDigits is the number of decimal places rates are kept with
+const rateDigits = 10
+
+var errInvalidRate = errors.New("rate must be a positive decimal number with at most 10 decimal places")
+
+// ParseRate parses and normalizes a positive decimal exchange rate. Like
+// amounts, rates are plain decimals; fractions and exponents are rejected.
+func ParseRate(s string) (Rate, error) {
+	r, ok := parseDecimal(strings.TrimSpace(s), rateDigits)
+	if !ok || r.Sign() <= 0 {
+		return "", errInvalidRate
+	}
+	return rateFromRat(r), nil
+}
+
+// rateFromRat formats a rational rate with up to ten decimal places
+func rateFromRat(r *big.Rat) Rate {
+	s := r.FloatString(rateDigits)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return Rate(s)
+}
+
+// Rat returns the rate as a rational number, or zero if it is not a valid
+// rate
+func (r Rate) Rat() *big.Rat {
+	rat, ok := parseDecimal(string(r), rateDigits)
+	if !ok {
+		return new(big.Rat)
+	}
+	return rat
+}
+
+// Inverse returns the rate for the opposite direction
+func (r Rate) Inverse() Rate {
+	rat := r.Rat()
+	if rat.Sign() == 0 {
+		return r
+	}
+	return rateFromRat(new(big.Rat).Inv(rat))
+}
+
+// MarshalJSON encodes the rate as a JSON number
+func (r Rate) MarshalJSON() ([]byte, error) {
+	if r == "" {
+		return []byte("null"), nil
+	}
+	return []byte(r), nil
+}
+
+// UnmarshalJSON decodes a JSON number or numeric string into a Rate
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner. Rates are stored as decimal text or DECIMAL columns.
+func (r *Rate) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', rateDigits, 64)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	default:
+		return fmt.Errorf("cannot scan %T into Rate", src)
+	}
+
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return fmt.Errorf("invalid stored rate %q: %v", s, err)
+	}
+	*r = parsed
+	return nil
+}
+
+// Value implements driver.Valuer
+func (r Rate) Value() (driver.Value, error) {
+	return string(r), nil
+}
+
+// Convert converts the amount at the given rate into the currency, rounding
+// to the nearest cent, or unit of currencies without cents, with halves away
+// from zero
+func (m Money) Convert(rate Rate, to string) (Money, error) {
+	amount := new(big.Rat).SetFrac(big.NewInt(int64(m)), big.NewInt(minorUnits))
+	converted, err := moneyFromRat(amount.Mul(amount, rate.Rat()))
+	if err != nil {
+		return 0, err
+	}
+	return converted.roundTo(CurrencyDigits(to)), nil
+}
+
+// FXRate represents an exchange rate between two currencies from an effective date on
+type FXRate struct {
+	ID            int64     `json:"id"`
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	Rate          Rate      `json:"rate"`
+	EffectiveDate time.Time `json:"effective_date"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// FXRateInput represents the JSON input for creating/updating an exchange rate
+type FXRateInput struct {
+	BaseCurrency  string `json:"base_currency"`
+	QuoteCurrency string `json:"quote_currency"`
+	Rate          Rate   `json:"rate"`
+	EffectiveDate string `json:"effective_date"` // ISO format (YYYY-MM-DD)
+}
+
+// ConvertedAmount represents an amount converted into a reporting currency
+type ConvertedAmount struct {
+	Currency string `json:"currency"`
+	Total    Money  `json:"total"`
+	Rate     Rate   `json:"rate"`
+}
+
+// CurrencyTotal represents the sum of bill totals in a single currency
+type CurrencyTotal struct {
+	Currency    string `json:"currency"`
+	Total       Money  `json:"total"`
+	PaidTotal   Money  `json:"paid_total"`
+	UnpaidTotal Money  `json:"unpaid_total"`
+	BillCount   int    `json:"bill_count"`
+	// Set when the totals are converted into a reporting currency
+	Converted *ConvertedTotals `json:"converted,omitempty"`
+}
+
+// ConvertedTotals represents currency totals converted into a reporting currency
+type ConvertedTotals struct {
+	Total       Money `json:"total"`
+	PaidTotal   Money `json:"paid_total"`
+	UnpaidTotal Money `json:"unpaid_total"`
+	Rate        Rate  `json:"rate"`
+}
+
+// BillTotals represents bill totals grouped by currency, optionally
+// combined in a reporting currency
+type BillTotals struct {
+	Currencies []CurrencyTotal `json:"currencies"`
+	// Set when a reporting currency is requested
+	ReportingCurrency string `json:"reporting_currency,omitempty"`
+	Total             *Money `json:"total,omitempty"`
+	PaidTotal         *Money `json:"paid_total,omitempty"`
+	UnpaidTotal       *Money `json:"unpaid_total,omitempty"`
+	RatesAsOf         string `json:"rates_as_of,omitempty"`
+}
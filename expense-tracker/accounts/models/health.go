@@ -0,0 +1,20 @@
+package models
+
+// Health check results
+const (
+	HealthOK      = "ok"
+	HealthFail    = "fail"
+	HealthSkipped = "skipped"
+)
+
+// HealthStatus is the response of the health endpoints
+type HealthStatus struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the result of a single readiness check
+type HealthCheck struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxAliases limits the aliases per merchant
+const MaxAliases = 20
+
+// Merchant is a payee of bills, such as a shop. Merchants belong to the owner
+// of the bills they are on. Besides its canonical name, a merchant has
+// aliases that match the names printed on receipts, such as "AMZN MKTP" for
+// Amazon.
+type Merchant struct {
+	ID      int64 `json:"id"`
+	OwnerID int64 `json:"owner_id"`
+	// CategoryID is the default category of bills of the merchant
+	CategoryID *int64    `json:"category_id"`
+	Name       string    `json:"name"`
+	Aliases    []string  `json:"aliases"` // normalized and sorted
+	BillCount  int       `json:"bill_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// MerchantInput represents the JSON input for creating/updating a merchant
+type MerchantInput struct {
+	Name string `json:"name"`
+	// Aliases replace the aliases of the merchant; they are normalized with
+	// NormalizeMerchant
+	Aliases []string `json:"aliases"`
+	// CategoryID is a default category or one of the user that bills of the
+	// merchant get unless they have a category
+	CategoryID *int64 `json:"category_id"`
+}
+
+// NormalizeMerchant reduces a merchant name to lower-case words of letters
+// and digits separated by single spaces, so that "AMZN Mktp US*2K4" becomes
+// "amzn mktp us 2k4"
+func NormalizeMerchant(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// NormalizeAliases normalizes aliases with NormalizeMerchant and drops empty
+// ones and duplicates, keeping the order of the first occurrences
+func NormalizeAliases(aliases []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		alias = NormalizeMerchant(alias)
+		if alias != "" && !seen[alias] {
+			seen[alias] = true
+			normalized = append(normalized, alias)
+		}
+	}
+	return normalized
+}
+
+// MatchMerchant returns the merchant whose name or one of whose aliases
+// occurs in the name as whole words, or nil. The longest match wins, so that
+// "amazon prime" takes precedence over "amazon"; ties go to the first
+// merchant.
+func MatchMerchant(name string, merchants []Merchant) *Merchant {
+	text := " " + NormalizeMerchant(name) + " "
+	if text == "  " {
+		return nil
+	}
+
+	var best *Merchant
+	bestLength := 0
+	for i := range merchants {
+		candidates := append([]string{NormalizeMerchant(merchants[i].Name)}, merchants[i].Aliases...)
+		for _, candidate := range candidates {
+			if candidate != "" && len(candidate) > bestLength && strings.Contains(text, " "+candidate+" ") {
+				best, bestLength = &merchants[i], len(candidate)
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Money represents an amount of money in minor units (cents).
+//
+// Amounts are exact to two decimal places. Parsing strings rejects values
+// with more precision. JSON numbers, which clients often compute as binary
+// floats such as 0.30000000000000004, and conversions are rounded to the
+// nearest cent with halves rounded away from zero. All arithmetic is done on
+// integers, so totals never drift.
+type Money int64
+
+// minorUnits is the number of minor units in one major unit, and
+// minorDigits the number of decimal places they take
+const (
+	minorUnits  = 100
+	minorDigits = 2
+)
+
+// maxDecimalLength limits the length of parsed decimal strings, which is far
+// more than any amount or rate needs
+const maxDecimalLength = 32
+
+var errMoneyOutOfRange = errors.New("amount out of range")
+
+// ParseMoney parses a decimal string such as "12.34" into Money. Amounts
+// have an optional sign and at most two decimal places; fractions such as
+// "1/3" and exponents such as "1e3" are rejected.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > maxDecimalLength {
+		return 0, errors.New("invalid amount: too long")
+	}
+	r, ok := parseDecimal(s, minorDigits)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount: %q (expected a decimal number with at most %d decimal places)", s, minorDigits)
+	}
+	return moneyFromRat(r)
+}
+
+// parseDecimal parses a decimal string of an optional sign, digits and an
+// optional decimal point followed by up to maxFraction digits. Unlike
+// big.Rat.SetString it rejects fractions, exponents and strings longer than
+// maxDecimalLength, so that input cannot make it compute huge numbers.
+func parseDecimal(s string, maxFraction int) (*big.Rat, bool) {
+	if len(s) > maxDecimalLength {
+		return nil, false
+	}
+	unsigned := s
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		unsigned = s[1:]
+	}
+	whole, fraction, hasPoint := strings.Cut(unsigned, ".")
+	if !isDigits(whole) || (hasPoint && !isDigits(fraction)) || len(fraction) > maxFraction {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// roundMoney parses a JSON number with any number of decimal places into
+// Money, rounding to the nearest cent with halves away from zero. Like
+// ParseMoney it rejects fractions, exponents and overly long input.
+func roundMoney(s string) (Money, error) {
+	r, ok := parseDecimal(s, maxDecimalLength)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount: %q (expected a decimal number)", s)
+	}
+	return moneyFromRat(r)
+}
+
+// MoneyFromFloat converts a float amount into Money,
+// rounding to the nearest cent with halves away from zero
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * minorUnits))
+}
+
+// moneyFromRat converts a rational amount in major units into Money
+func moneyFromRat(r *big.Rat) (Money, error) {
+	cents := new(big.Rat).Mul(r, big.NewRat(minorUnits, 1))
+
+	// Round half away from zero
+	num := new(big.Int).Abs(cents.Num())
+	den := cents.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if cents.Sign() < 0 {
+		quo.Neg(quo)
+	}
+
+	if !quo.IsInt64() {
+		return 0, errMoneyOutOfRange
+	}
+	return Money(quo.Int64()), nil
+}
+
+// Cents returns the amount in minor units
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// Mul multiplies the amount by a quantity
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Float64 returns the amount in major units as a float, for display only
+func (m Money) Float64() float64 {
+	return float64(m) / minorUnits
+}
+
+// String formats the amount in major units with two decimal places, e.g. "12.34"
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	abs := uint64(cents)
+	if cents < 0 {
+		sign = "-"
+		abs = uint64(-cents)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/minorUnits, abs%minorUnits)
+}
+
+// MarshalJSON encodes the amount as a JSON number with two decimal places
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or numeric string into Money. Numeric
+// strings are parsed like ParseMoney; numbers are rounded to the nearest cent.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	var parsed Money
+	var err error
+	if unquoted, uerr := strconv.Unquote(s); uerr == nil {
+		parsed, err = ParseMoney(unquoted)
+	} else {
+		parsed, err = roundMoney(s)
+	}
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner. Amounts are stored as integer minor units.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v)
+	case []byte:
+		cents, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid stored amount: %q", v)
+		}
+		*m = Money(cents)
+	case string:
+		cents, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid stored amount: %q", v)
+		}
+		*m = Money(cents)
+	case float64:
+		*m = Money(math.Round(v))
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer. Amounts are stored as integer minor units.
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Payment methods
+const (
+	PaymentMethodCash         = "cash"
+	PaymentMethodCard         = "card"
+	PaymentMethodBankTransfer = "bank_transfer"
+	PaymentMethodDirectDebit  = "direct_debit"
+	PaymentMethodCheck        = "check"
+	PaymentMethodOther        = "other"
+)
+
+// paymentMethods are the accepted payment methods
+var paymentMethods = []string{
+	PaymentMethodCash,
+	PaymentMethodCard,
+	PaymentMethodBankTransfer,
+	PaymentMethodDirectDebit,
+	PaymentMethodCheck,
+	PaymentMethodOther,
+}
+
+// MaxReferenceLength is the maximum length of payment references in characters
+const MaxReferenceLength = 255
+
+// Payment statuses of bills, derived from their payments and total
+const (
+	PaymentStatusUnpaid   = "unpaid"
+	PaymentStatusPartial  = "partial"
+	PaymentStatusPaid     = "paid"
+	PaymentStatusOverpaid = "overpaid"
+)
+
+// ValidPaymentStatus reports whether s is a payment status
+func ValidPaymentStatus(s string) bool {
+	switch s {
+	case PaymentStatusUnpaid, PaymentStatusPartial, PaymentStatusPaid, PaymentStatusOverpaid:
+		return true
+	}
+	return false
+}
+
+// PaymentStatus returns the outstanding balance and payment status of a bill
+// with the total, the sum of its payments and its paid flag. A bill marked
+// as paid without payments has no balance; a bill whose payments exceed its
+// total has a negative balance.
+func PaymentStatus(total, amountPaid Money, paid bool) (Money, string) {
+	switch {
+	case amountPaid > total:
+		return total - amountPaid, PaymentStatusOverpaid
+	case paid || (amountPaid > 0 && amountPaid == total):
+		return 0, PaymentStatusPaid
+	case amountPaid > 0:
+		return total - amountPaid, PaymentStatusPartial
+	}
+	return total, PaymentStatusUnpaid
+}
+
+// SetPaymentStatus sets the balance and payment status of the bill from its
+// total, amount paid and paid flag
+func (b *Bill) SetPaymentStatus() {
+	b.Balance, b.PaymentStatus = PaymentStatus(b.Total, b.AmountPaid, b.Paid)
+}
+
+// SetPaymentStatus sets the balance and payment status of the bill like
+// Bill.SetPaymentStatus
+func (b *BillSummary) SetPaymentStatus() {
+	b.Balance, b.PaymentStatus = PaymentStatus(b.Total, b.AmountPaid, b.Paid)
+}
+
+// Payment represents a payment of a bill, in the currency of the bill
+type Payment struct {
+	ID        int64     `json:"id"`
+	BillID    int64     `json:"bill_id"`
+	Amount    Money     `json:"amount"`
+	Date      time.Time `json:"date"`
+	Method    string    `json:"method"`
+	Reference string    `json:"reference"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PaymentInput represents the JSON input for recording or updating a payment
+type PaymentInput struct {
+	Amount    Money  `json:"amount"`
+	Date      string `json:"date"`      // ISO format (YYYY-MM-DD), defaults to today
+	Method    string `json:"method"`    // one of the payment methods, defaults to PaymentMethodOther
+	Reference string `json:"reference"` // such as a transaction ID, optional
+}
+
+// Normalize applies the defaults of the date and method and trims the reference
+func (p *PaymentInput) Normalize(today time.Time) {
+	if p.Date == "" {
+		p.Date = today.Format("2006-01-02")
+	}
+	p.Method = strings.ToLower(strings.TrimSpace(p.Method))
+	if p.Method == "" {
+		p.Method = PaymentMethodOther
+	}
+	p.Reference = strings.TrimSpace(p.Reference)
+}
+
+// Validate checks the payment against the limits and returns all invalid
+// fields. Payment dates are bounded like due dates.
+func (p *PaymentInput) Validate(limits ValidationLimits, today time.Time) []FieldError {
+	var errs []FieldError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if p.Amount <= 0 {
+		add("amount", "must be positive")
+	} else if p.Amount > limits.MaxAmount {
+		add("amount", "must not exceed %s", limits.MaxAmount)
+	}
+
+	if p.Date != "" {
+		date, err := time.Parse("2006-01-02", p.Date)
+		switch {
+		case err != nil:
+			add("date", "must be a date in YYYY-MM-DD format")
+		case date.Before(today.AddDate(-limits.DueDateYears, 0, 0)) || date.After(today.AddDate(limits.DueDateYears, 0, 0)):
+			add("date", "must be within %d years of today", limits.DueDateYears)
+		}
+	}
+
+	if p.Method != "" && !slices.Contains(paymentMethods, p.Method) {
+		add("method", "must be one of %s", strings.Join(paymentMethods, ", "))
+	}
+	validateText(add, "reference", p.Reference, false, MaxReferenceLength)
+
+	return errs
+}
@@ -0,0 +1,353 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies of recurrence rules
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// MaxRuleInterval limits the INTERVAL of recurrence rules
+const MaxRuleInterval = 1000
+
+// Rule is the subset of an RFC 5545 recurrence rule (RRULE) that recurring
+// bills are scheduled with, such as "FREQ=MONTHLY;BYMONTHDAY=1" for rent due
+// on the first of every month or "FREQ=WEEKLY;INTERVAL=2" for every other
+// week. Rules start on the start date of their recurring bill.
+type Rule struct {
+	Freq     string
+	Interval int // at least 1
+	// MonthDay is the BYMONTHDAY of a monthly rule, 1 to 31 or -1 for the
+	// last day of the month; 0 keeps the day of the start date
+	MonthDay int
+	// Count limits the number of occurrences; 0 means no limit
+	Count int
+}
+
+// ParseRule parses a recurrence rule of FREQ, INTERVAL, BYMONTHDAY and COUNT
+// parts, optionally prefixed with "RRULE:". Other parts of RFC 5545, such as
+// BYDAY, are not supported; the end of a series is its end date rather than
+// UNTIL.
+func ParseRule(s string) (Rule, error) {
+	rule := Rule{Interval: 1}
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || seen[name] {
+			return Rule{}, fmt.Errorf("invalid part %q", part)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch value {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = value
+			default:
+				return Rule{}, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err != nil || rule.Interval < 1 || rule.Interval > MaxRuleInterval {
+				return Rule{}, fmt.Errorf("INTERVAL must be between 1 and %d", MaxRuleInterval)
+			}
+		case "BYMONTHDAY":
+			rule.MonthDay, err = strconv.Atoi(value)
+			if err != nil || rule.MonthDay < -1 || rule.MonthDay == 0 || rule.MonthDay > 31 {
+				return Rule{}, errors.New("BYMONTHDAY must be between 1 and 31, or -1")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err != nil || rule.Count < 1 {
+				return Rule{}, errors.New("COUNT must be a positive integer")
+			}
+		default:
+			return Rule{}, fmt.Errorf("unsupported part %s", name)
+		}
+	}
+	if rule.Freq == "" {
+		return Rule{}, errors.New("FREQ is required")
+	}
+	if rule.MonthDay != 0 && rule.Freq != FreqMonthly {
+		return Rule{}, errors.New("BYMONTHDAY requires FREQ=MONTHLY")
+	}
+	return rule, nil
+}
+
+// String returns the rule in canonical form, without defaults
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.MonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.MonthDay))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// each calls fn with the occurrences of the rule starting on start in order
+// until fn returns false or the COUNT is reached. The start date is the
+// first occurrence unless the BYMONTHDAY of a monthly rule is earlier in
+// the month. Unlike in RFC 5545, days beyond the end of a month fall on its
+// last day, so that a bill due on the 31st is due in every month.
+func (r Rule) each(start time.Time, fn func(time.Time) bool) {
+	count := 0
+	for n := 0; r.Count == 0 || count < r.Count; n++ {
+		var occurrence time.Time
+		switch r.Freq {
+		case FreqDaily:
+			occurrence = start.AddDate(0, 0, n*r.Interval)
+		case FreqWeekly:
+			occurrence = start.AddDate(0, 0, 7*n*r.Interval)
+		case FreqMonthly:
+			day := r.MonthDay
+			if day == 0 {
+				day = start.Day()
+			}
+			occurrence = monthDay(start.Year(), start.Month()+time.Month(n*r.Interval), day)
+		default:
+			occurrence = monthDay(start.Year()+n*r.Interval, start.Month(), start.Day())
+		}
+		if occurrence.Before(start) {
+			continue
+		}
+		count++
+		if !fn(occurrence) {
+			return
+		}
+	}
+}
+
+// monthDay returns the day of the month in UTC; days beyond the end of the
+// month and -1 are its last day
+func monthDay(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	if day < 1 || day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// RecurringBill is a template of bills that are generated on the occurrences
+// of its schedule, such as rent or a subscription. Recurring bills belong to
+// a user; the generated bills belong to the same user and have a single item
+// of the amount.
+type RecurringBill struct {
+	ID          int64  `json:"id"`
+	OwnerID     int64  `json:"owner_id"`
+	LedgerID    *int64 `json:"ledger_id"`
+	CategoryID  *int64 `json:"category_id"`
+	MerchantID  *int64 `json:"merchant_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
+	Currency    string `json:"currency"`
+	// Rule is the recurrence rule in canonical form
+	Rule      string     `json:"rule"`
+	StartDate time.Time  `json:"start_date"`
+	EndDate   *time.Time `json:"end_date"`
+	// Paused recurring bills generate no bills; occurrences that come due
+	// while paused are passed over
+	Paused bool `json:"paused"`
+	// GeneratedThrough is the date up to which occurrences have been
+	// generated, skipped or passed over, or nil before the first one
+	GeneratedThrough *time.Time `json:"generated_through"`
+	// SkippedDates are the upcoming occurrences that are skipped, by date
+	SkippedDates []time.Time `json:"skipped_dates"`
+	// NextDate is the next occurrence a bill will be generated for, or nil
+	// when the series has ended
+	NextDate *time.Time `json:"next_date"`
+	// BillCount is the number of generated bills that still exist
+	BillCount int       `json:"bill_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Pending returns the occurrences after GeneratedThrough and on or before
+// the date that are not skipped, up to the end date. A zero date means no
+// limit but the next occurrence.
+func (b *RecurringBill) Pending(through time.Time) []time.Time {
+	rule, err := ParseRule(b.Rule)
+	if err != nil {
+		return nil
+	}
+
+	var pending []time.Time
+	rule.each(b.StartDate, func(occurrence time.Time) bool {
+		if (b.EndDate != nil && occurrence.After(*b.EndDate)) || (!through.IsZero() && occurrence.After(through)) {
+			return false
+		}
+		if (b.GeneratedThrough != nil && !occurrence.After(*b.GeneratedThrough)) || containsDate(b.SkippedDates, occurrence) {
+			return true
+		}
+		pending = append(pending, occurrence)
+		return !through.IsZero()
+	})
+	return pending
+}
+
+// IsPending reports whether the date is a pending occurrence. Unlike
+// Pending it collects nothing and stops at the date.
+func (b *RecurringBill) IsPending(date time.Time) bool {
+	rule, err := ParseRule(b.Rule)
+	if err != nil {
+		return false
+	}
+
+	pending := false
+	rule.each(b.StartDate, func(occurrence time.Time) bool {
+		if occurrence.After(date) || (b.EndDate != nil && occurrence.After(*b.EndDate)) {
+			return false
+		}
+		if occurrence.Equal(date) {
+			pending = (b.GeneratedThrough == nil || occurrence.After(*b.GeneratedThrough)) && !containsDate(b.SkippedDates, occurrence)
+			return false
+		}
+		return true
+	})
+	return pending
+}
+
+// SetNextDate sets NextDate to the first pending occurrence
+func (b *RecurringBill) SetNextDate() {
+	b.NextDate = nil
+	if pending := b.Pending(time.Time{}); len(pending) > 0 {
+		b.NextDate = &pending[0]
+	}
+}
+
+// containsDate reports whether the dates contain the date
+func containsDate(dates []time.Time, date time.Time) bool {
+	for _, other := range dates {
+		if other.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecurringBillInput represents the JSON input for creating/updating a
+// recurring bill
+type RecurringBillInput struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
+	Currency    string `json:"currency"` // ISO 4217 code, defaults to DefaultCurrency
+	// Rule is a recurrence rule as accepted by ParseRule
+	Rule      string `json:"rule"`
+	StartDate string `json:"start_date"` // ISO format (YYYY-MM-DD)
+	EndDate   string `json:"end_date"`   // ISO format (YYYY-MM-DD), optional
+	// LedgerID, CategoryID and MerchantID are set on the generated bills
+	// and checked like those of a bill of the user
+	LedgerID   *int64 `json:"ledger_id"`
+	CategoryID *int64 `json:"category_id"`
+	MerchantID *int64 `json:"merchant_id"`
+}
+
+// PausedInput represents the JSON input for pausing or resuming a recurring
+// bill
+type PausedInput struct {
+	Paused bool `json:"paused"`
+}
+
+// OccurrenceInput represents the JSON input for skipping an occurrence of a
+// recurring bill
+type OccurrenceInput struct {
+	Date string `json:"date"` // ISO format (YYYY-MM-DD)
+}
+
+// EndInput represents the JSON input for ending a recurring bill
+type EndInput struct {
+	// EndDate is the last day of the series; empty means today
+	EndDate string `json:"end_date"`
+}
+
+// Validate checks the recurring bill against the limits of bills and items
+// and returns all invalid fields
+func (b *RecurringBillInput) Validate(limits ValidationLimits, today time.Time) []FieldError {
+	var errs []FieldError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	validateText(add, "title", b.Title, true, limits.MaxTitleLength)
+	validateText(add, "description", b.Description, false, limits.MaxDescriptionLength)
+
+	if b.Amount < 0 {
+		add("amount", "must not be negative")
+	} else if b.Amount > limits.MaxAmount {
+		add("amount", "must not exceed %s", limits.MaxAmount)
+	}
+	if currency, err := NormalizeCurrency(b.Currency); err != nil {
+		add("currency", "%s", CurrencyErrorReason(err))
+	} else if !b.Amount.InCurrency(currency) {
+		add("amount", "%s", CurrencyAmountMessage(currency))
+	}
+
+	if _, err := ParseRule(b.Rule); err != nil {
+		add("rule", "must be a recurrence rule: %s", err)
+	}
+	startDate, err := time.Parse("2006-01-02", b.StartDate)
+	switch {
+	case b.StartDate == "":
+		add("start_date", "is required")
+	case err != nil:
+		add("start_date", "must be a date in YYYY-MM-DD format")
+	case startDate.Before(today.AddDate(-limits.DueDateYears, 0, 0)) || startDate.After(today.AddDate(limits.DueDateYears, 0, 0)):
+		add("start_date", "must be within %d years of today", limits.DueDateYears)
+	}
+	if b.EndDate != "" {
+		endDate, err := time.Parse("2006-01-02", b.EndDate)
+		switch {
+		case err != nil:
+			add("end_date", "must be a date in YYYY-MM-DD format")
+		case endDate.Before(startDate):
+			add("end_date", "must not be before the start date")
+		case endDate.After(today.AddDate(limits.DueDateYears, 0, 0)):
+			add("end_date", "must be within %d years of today", limits.DueDateYears)
+		}
+	}
+
+	if b.LedgerID != nil && *b.LedgerID < 1 {
+		add("ledger_id", "must be a positive ID")
+	}
+	if b.CategoryID != nil && *b.CategoryID < 1 {
+		add("category_id", "must be a positive ID")
+	}
+	if b.MerchantID != nil && *b.MerchantID < 1 {
+		add("merchant_id", "must be a positive ID")
+	}
+
+	return errs
+}
+
+// BillInput returns the input of the bill generated for an occurrence: a
+// bill due on the date with a single item of the amount
+func (b *RecurringBill) BillInput(date time.Time) *BillInput {
+	return &BillInput{
+		Title:       b.Title,
+		Description: b.Description,
+		Currency:    b.Currency,
+		DueDate:     date.Format("2006-01-02"),
+		Items:       []BillItemInput{{Name: b.Title, Amount: b.Amount, Quantity: 1}},
+		LedgerID:    b.LedgerID,
+		CategoryID:  b.CategoryID,
+		MerchantID:  b.MerchantID,
+	}
+}
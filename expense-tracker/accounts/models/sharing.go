@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+// Role is the access a user has to a bill or ledger. Each role includes the
+// rights of the roles before it: viewers read, payers also mark bills as
+// paid, editors also change bills and their items, and owners also delete
+// bills and manage who they are shared with.
+type Role string
+
+// Roles in ascending order of rights
+const (
+	RoleViewer Role = "viewer"
+	RolePayer  Role = "payer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+// roleRanks orders the roles; unknown roles rank 0 and allow nothing
+var roleRanks = map[Role]int{RoleViewer: 1, RolePayer: 2, RoleEditor: 3, RoleOwner: 4}
+
+// Valid reports whether r is one of the roles
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Allows reports whether r includes the rights of required
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// Max returns the role with more rights, ignoring invalid ones
+func (r Role) Max(other Role) Role {
+	if roleRanks[other] > roleRanks[r] {
+		return other
+	}
+	return r
+}
+
+// Ledger is a named collection of bills, e.g. of a household. Sharing a
+// ledger gives access to all bills in it.
+type Ledger struct {
+	ID        int64  `json:"id"`
+	OwnerID   int64  `json:"owner_id"`
+	Name      string `json:"name"`
+	BillCount int    `json:"bill_count"`
+	// Role of the requesting user
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LedgerInput represents the JSON input for creating/updating a ledger
+type LedgerInput struct {
+	Name string `json:"name"`
+}
+
+// Share types
+const (
+	ShareTypeBill   = "bill"
+	ShareTypeLedger = "ledger"
+)
+
+// ShareTarget identifies the bill or ledger a share is about
+type ShareTarget struct {
+	Type string // ShareTypeBill or ShareTypeLedger
+	ID   int64
+}
+
+// Share grants a user other than the owner a role on a bill or ledger
+type Share struct {
+	UserID  int64  `json:"user_id"`
+	Subject string `json:"subject"`
+	// Email is only shown to owners and to the member themselves
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ShareInput represents the JSON input for inviting a user who signed in
+// before. The user is identified by subject, or by an email that only they
+// verified.
+type ShareInput struct {
+	Subject string `json:"subject,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Role    Role   `json:"role"`
+}
+
+// RoleInput represents the JSON input for changing the role of a share
+type RoleInput struct {
+	Role Role `json:"role"`
+}
+
+// PaidInput represents the JSON input for marking a bill as paid or unpaid
+type PaidInput struct {
+	Paid bool `json:"paid"`
+}
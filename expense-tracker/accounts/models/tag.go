@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits of tags; tag names are short labels such as "trip-lisbon"
+const (
+	MaxTagLength = 64 // in characters, the column size
+	MaxTags      = 20 // per bill or item
+)
+
+// Tag matching modes when listing bills by tags
+const (
+	TagMatchAny = "any"
+	TagMatchAll = "all"
+)
+
+// Tag is a free-form label of bills and bill items. Tags belong to the
+// owner of the bills they are on; their names are unique per owner and in
+// lower case.
+type Tag struct {
+	ID        int64     `json:"id"`
+	OwnerID   int64     `json:"owner_id"`
+	Name      string    `json:"name"`
+	BillCount int       `json:"bill_count"` // bills with the tag on them or on one of their items
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TagInput represents the JSON input for creating/renaming a tag
+type TagInput struct {
+	Name string `json:"name"`
+}
+
+// NormalizeTag trims and lower-cases a tag name, so that tags match
+// regardless of case
+func NormalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NormalizeTags normalizes tag names with NormalizeTag and drops duplicates,
+// keeping the order of the first occurrences
+func NormalizeTags(names []string) []string {
+	var tags []string
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = NormalizeTag(name)
+		if !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+// ValidateTag returns why a tag name is invalid, or "" if it is valid.
+// Commas separate tags in queries, so names cannot contain them.
+func ValidateTag(name string) string {
+	name = NormalizeTag(name)
+	switch {
+	case name == "":
+		return "is required"
+	case utf8.RuneCountInString(name) > MaxTagLength:
+		return fmt.Sprintf("must not be longer than %d characters", MaxTagLength)
+	case strings.Contains(name, ","):
+		return "must not contain commas"
+	}
+	return ""
+}
+
+// NormalizeTags normalizes the tags of the bill and its items
+func (b *BillInput) NormalizeTags() {
+	b.Tags = NormalizeTags(b.Tags)
+	for i := range b.Items {
+		b.Items[i].NormalizeTags()
+	}
+}
+
+// NormalizeTags normalizes the tags of the item
+func (i *BillItemInput) NormalizeTags() {
+	i.Tags = NormalizeTags(i.Tags)
+}
+
+// validateTags checks the number of tags and each tag name, reported as
+// field[i]
+func validateTags(add func(field, format string, args ...interface{}), field string, tags []string) {
+	if len(tags) > MaxTags {
+		add(field, "must not contain more than %d tags", MaxTags)
+	}
+	for i, name := range tags {
+		if message := ValidateTag(name); message != "" {
+			add(fmt.Sprintf("%s[%d]", field, i), "%s", message)
+		}
+	}
+}
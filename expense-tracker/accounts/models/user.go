@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// User is a person whose bills are stored by the service. Users are created
+// on their first authenticated request and identified by the subject of
+// their identity provider.
+type User struct {
+	ID            int64     `json:"id"`
+	Subject       string    `json:"subject"`
+	Email         string    `json:"email,omitempty"`
+	EmailVerified bool      `json:"email_verified"`
+	Name          string    `json:"name,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// UserInput identifies a user by subject. Empty email and name keep the
+// stored values; a given email is stored with whether the identity provider
+// verified it.
+type UserInput struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
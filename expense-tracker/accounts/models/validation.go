@@ -0,0 +1,138 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes why the value of an input field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Schema limits that the validation limits cannot exceed, because the
+// database rejects longer values
+const (
+	MaxTitleLength = 255
+	MaxNameLength  = 255
+)
+
+// ValidationLimits bounds the values accepted for bills and bill items
+type ValidationLimits struct {
+	MaxTitleLength       int   // in characters, at most MaxTitleLength
+	MaxNameLength        int   // in characters, at most MaxNameLength
+	MaxDescriptionLength int   // in characters
+	MaxItems             int   // per bill
+	MaxQuantity          int   // per item
+	MaxAmount            Money // per item
+	DueDateYears         int   // how many years before or after today a due date may be
+}
+
+// DefaultValidationLimits returns the limits used unless configured otherwise
+func DefaultValidationLimits() ValidationLimits {
+	return ValidationLimits{
+		MaxTitleLength:       MaxTitleLength,
+		MaxNameLength:        MaxNameLength,
+		MaxDescriptionLength: 2000,
+		MaxItems:             100,
+		MaxQuantity:          10000,
+		MaxAmount:            100000000, // 1,000,000.00
+		DueDateYears:         10,
+	}
+}
+
+// Validate checks the bill and its items against the limits and returns all
+// invalid fields. Items are reported as items[i].field.
+func (b *BillInput) Validate(limits ValidationLimits, today time.Time) []FieldError {
+	var errs []FieldError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	validateText(add, "title", b.Title, true, limits.MaxTitleLength)
+	validateText(add, "description", b.Description, false, limits.MaxDescriptionLength)
+
+	currency, err := NormalizeCurrency(b.Currency)
+	if err != nil {
+		add("currency", "%s", CurrencyErrorReason(err))
+	}
+
+	if b.DueDate != "" {
+		dueDate, err := time.Parse("2006-01-02", b.DueDate)
+		switch {
+		case err != nil:
+			add("due_date", "must be a date in YYYY-MM-DD format")
+		case dueDate.Before(today.AddDate(-limits.DueDateYears, 0, 0)) || dueDate.After(today.AddDate(limits.DueDateYears, 0, 0)):
+			add("due_date", "must be within %d years of today", limits.DueDateYears)
+		}
+	}
+
+	if b.LedgerID != nil && *b.LedgerID < 1 {
+		add("ledger_id", "must be a positive ID")
+	}
+	if b.CategoryID != nil && *b.CategoryID < 1 {
+		add("category_id", "must be a positive ID")
+	}
+	validateTags(add, "tags", b.Tags)
+	if b.MerchantID != nil && *b.MerchantID < 1 {
+		add("merchant_id", "must be a positive ID")
+	}
+	validateText(add, "merchant", b.Merchant, false, MaxNameLength)
+
+	if len(b.Items) > limits.MaxItems {
+		add("items", "must not contain more than %d items", limits.MaxItems)
+	}
+	for i := range b.Items {
+		for _, err := range b.Items[i].Validate(limits) {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("items[%d].%s", i, err.Field), Message: err.Message})
+		}
+		if currency != "" && !b.Items[i].Amount.InCurrency(currency) {
+			add(fmt.Sprintf("items[%d].amount", i), "%s", CurrencyAmountMessage(currency))
+		}
+	}
+
+	return errs
+}
+
+// Validate checks the item against the limits and returns all invalid fields
+func (i *BillItemInput) Validate(limits ValidationLimits) []FieldError {
+	var errs []FieldError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	validateText(add, "name", i.Name, true, limits.MaxNameLength)
+	validateText(add, "description", i.Description, false, limits.MaxDescriptionLength)
+
+	if i.Amount < 0 {
+		add("amount", "must not be negative")
+	} else if i.Amount > limits.MaxAmount {
+		add("amount", "must not exceed %s", limits.MaxAmount)
+	}
+
+	if i.Quantity < 1 {
+		add("quantity", "must be at least 1")
+	} else if i.Quantity > limits.MaxQuantity {
+		add("quantity", "must not exceed %d", limits.MaxQuantity)
+	}
+
+	if i.CategoryID != nil && *i.CategoryID < 1 {
+		add("category_id", "must be a positive ID")
+	}
+	validateTags(add, "tags", i.Tags)
+
+	return errs
+}
+
+// validateText checks that a text field is not blank, if required, and not longer than max characters
+func validateText(add func(field, format string, args ...interface{}), field, value string, required bool, max int) {
+	switch {
+	case required && strings.TrimSpace(value) == "":
+		add(field, "is required")
+	case utf8.RuneCountInString(value) > max:
+		add(field, "must not be longer than %d characters", max)
+	}
+}
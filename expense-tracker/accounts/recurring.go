@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+)
+
+// generateRecurringBills generates the bills of the recurring bills of all
+// users that are due within leadDays, right away and then every interval
+// until ctx is done. Generating is idempotent, so several instances of the
+// service may run it at once; failures are logged and retried on the next
+// run, and the recurring bills that did not fail are generated regardless.
+func generateRecurringBills(ctx context.Context, database db.Database, interval time.Duration, leadDays int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		generated, err := database.GenerateRecurringBills(ctx, today, today.AddDate(0, 0, leadDays))
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Failed to generate recurring bills", "error", err)
+		}
+		if generated > 0 {
+			slog.Info("Generated recurring bills", "bills", generated)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
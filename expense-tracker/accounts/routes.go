@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/jo/choreo-tutorial/accounts/auth"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/handlers"
+	"github.com/jo/choreo-tutorial/accounts/logging"
+	"github.com/jo/choreo-tutorial/accounts/metrics"
+	"github.com/jo/choreo-tutorial/accounts/models"
+	"github.com/jo/choreo-tutorial/accounts/tracing"
+
+	"github.com/gorilla/mux"
+	"github.com/swaggo/http-swagger"
+	_ "github.com/swaggo/swag/example/basic/docs" // for swagger
+)
+
+// newRouter registers the API routes backed by the given database, the health
+// probes and the metrics endpoint. API requests are authenticated with
+// authenticator or an API key. The users with the admin subjects manage API
+// keys and exchange rates and read metrics. Bills and items are accepted
+// within limits.
+func newRouter(database db.Database, authenticator auth.Authenticator, admins []string, limits models.ValidationLimits, health *handlers.HealthHandler, m *metrics.Metrics) http.Handler {
+	r := mux.NewRouter()
+	r.Use(tracing.Middleware(), m.Middleware, logging.AccessLog)
+
+	// Requests that match no route are logged and answered with problems as well
+	r.NotFoundHandler = logging.AccessLog(http.HandlerFunc(handlers.NotFound))
+	r.MethodNotAllowedHandler = logging.AccessLog(http.HandlerFunc(handlers.MethodNotAllowed))
+
+	// Health probes
+	r.HandleFunc("/healthz", health.Liveness).Methods("GET")
+	r.HandleFunc("/readyz", health.Readiness).Methods("GET")
+
+	// API routes require an authenticated user or API key, and each route a
+	// scope of it. They are registered on a subrouter without matchers:
+	// routes of a subrouter at "/" would all match the prefix, which makes
+	// mux drop method mismatches and answer 404 instead of 405
+	api := r.NewRoute().Subrouter()
+	api.Use(handlers.Authenticate(authenticator, database, admins))
+
+	// Metrics include the bills of all users, so they require a scope of their own
+	api.HandleFunc("/metrics", handlers.RequireScope(auth.ScopeMetricsRead, m.Handler().ServeHTTP)).Methods("GET")
+
+	// User handlers
+	userHandler := handlers.NewUserHandler(database)
+	api.HandleFunc("/me", userHandler.GetCurrentUser).Methods("GET")
+
+	// Bill handlers
+	billHandler := handlers.NewBillHandler(database, limits)
+	api.HandleFunc("/bills", handlers.RequireScope(auth.ScopeBillsRead, billHandler.GetBills)).Methods("GET")
+	api.HandleFunc("/bills", handlers.RequireScope(auth.ScopeBillsWrite, billHandler.CreateBill)).Methods("POST")
+	api.HandleFunc("/bills/totals", handlers.RequireScope(auth.ScopeBillsRead, billHandler.GetBillTotals)).Methods("GET")
+	api.HandleFunc("/bills/{id}", handlers.RequireScope(auth.ScopeBillsRead, billHandler.GetBill)).Methods("GET")
+	api.HandleFunc("/bills/{id}", handlers.RequireScope(auth.ScopeBillsWrite, billHandler.UpdateBill)).Methods("PUT")
+	api.HandleFunc("/bills/{id}", handlers.RequireScope(auth.ScopeBillsWrite, billHandler.DeleteBill)).Methods("DELETE")
+
+	// Bill item handlers
+	api.HandleFunc("/bills/{id}/items", handlers.RequireScope(auth.ScopeBillsRead, billHandler.GetBillItems)).Methods("GET")
+	api.HandleFunc("/bills/{id}/items", handlers.RequireScope(auth.ScopeBillsWrite, billHandler.CreateBillItem)).Methods("POST")
+	api.HandleFunc("/bills/{id}/items/{itemId}", handlers.RequireScope(auth.ScopeBillsRead, billHandler.GetBillItem)).Methods("GET")
+	api.HandleFunc("/bills/{id}/items/{itemId}", handlers.RequireScope(auth.ScopeBillsWrite, billHandler.UpdateBillItem)).Methods("PUT")
+	api.HandleFunc("/bills/{id}/items/{itemId}", handlers.RequireScope(auth.ScopeBillsWrite, billHandler.DeleteBillItem)).Methods("DELETE")
+
+	api.HandleFunc("/bills/{id}/paid", handlers.RequireScope(auth.ScopeBillsWrite, billHandler.SetBillPaid)).Methods("PUT")
+
+	// Bill payment handlers
+	paymentHandler := handlers.NewPaymentHandler(database, limits)
+	api.HandleFunc("/bills/{id}/payments", handlers.RequireScope(auth.ScopeBillsRead, paymentHandler.GetBillPayments)).Methods("GET")
+	api.HandleFunc("/bills/{id}/payments", handlers.RequireScope(auth.ScopeBillsWrite, paymentHandler.CreateBillPayment)).Methods("POST")
+	api.HandleFunc("/bills/{id}/payments/{paymentId}", handlers.RequireScope(auth.ScopeBillsRead, paymentHandler.GetBillPayment)).Methods("GET")
+	api.HandleFunc("/bills/{id}/payments/{paymentId}", handlers.RequireScope(auth.ScopeBillsWrite, paymentHandler.UpdateBillPayment)).Methods("PUT")
+	api.HandleFunc("/bills/{id}/payments/{paymentId}", handlers.RequireScope(auth.ScopeBillsWrite, paymentHandler.DeleteBillPayment)).Methods("DELETE")
+
+	// Ledger handlers
+	ledgerHandler := handlers.NewLedgerHandler(database)
+	api.HandleFunc("/ledgers", handlers.RequireScope(auth.ScopeBillsRead, ledgerHandler.GetLedgers)).Methods("GET")
+	api.HandleFunc("/ledgers", handlers.RequireScope(auth.ScopeBillsWrite, ledgerHandler.CreateLedger)).Methods("POST")
+	api.HandleFunc("/ledgers/{id}", handlers.RequireScope(auth.ScopeBillsRead, ledgerHandler.GetLedger)).Methods("GET")
+	api.HandleFunc("/ledgers/{id}", handlers.RequireScope(auth.ScopeBillsWrite, ledgerHandler.UpdateLedger)).Methods("PUT")
+	api.HandleFunc("/ledgers/{id}", handlers.RequireScope(auth.ScopeBillsWrite, ledgerHandler.DeleteLedger)).Methods("DELETE")
+
+	// Share handlers of bills and ledgers
+	for _, shareType := range []string{models.ShareTypeBill, models.ShareTypeLedger} {
+		shareHandler := handlers.NewShareHandler(database, shareType)
+		prefix := "/" + shareType + "s/{id}/shares"
+		api.HandleFunc(prefix, handlers.RequireScope(auth.ScopeBillsRead, shareHandler.GetShares)).Methods("GET")
+		api.HandleFunc(prefix, handlers.RequireScope(auth.ScopeBillsWrite, shareHandler.CreateShare)).Methods("POST")
+		api.HandleFunc(prefix+"/{userId}", handlers.RequireScope(auth.ScopeBillsWrite, shareHandler.UpdateShare)).Methods("PUT")
+		api.HandleFunc(prefix+"/{userId}", handlers.RequireScope(auth.ScopeBillsWrite, shareHandler.DeleteShare)).Methods("DELETE")
+	}
+
+	// Category handlers
+	categoryHandler := handlers.NewCategoryHandler(database)
+	api.HandleFunc("/categories", handlers.RequireScope(auth.ScopeBillsRead, categoryHandler.GetCategories)).Methods("GET")
+	api.HandleFunc("/categories", handlers.RequireScope(auth.ScopeBillsWrite, categoryHandler.CreateCategory)).Methods("POST")
+	api.HandleFunc("/categories/{id}", handlers.RequireScope(auth.ScopeBillsRead, categoryHandler.GetCategory)).Methods("GET")
+	api.HandleFunc("/categories/{id}", handlers.RequireScope(auth.ScopeBillsWrite, categoryHandler.UpdateCategory)).Methods("PUT")
+	api.HandleFunc("/categories/{id}", handlers.RequireScope(auth.ScopeBillsWrite, categoryHandler.DeleteCategory)).Methods("DELETE")
+
+	// Tag handlers
+	tagHandler := handlers.NewTagHandler(database)
+	api.HandleFunc("/tags", handlers.RequireScope(auth.ScopeBillsRead, tagHandler.GetTags)).Methods("GET")
+	api.HandleFunc("/tags", handlers.RequireScope(auth.ScopeBillsWrite, tagHandler.CreateTag)).Methods("POST")
+	api.HandleFunc("/tags/{id}", handlers.RequireScope(auth.ScopeBillsRead, tagHandler.GetTag)).Methods("GET")
+	api.HandleFunc("/tags/{id}", handlers.RequireScope(auth.ScopeBillsWrite, tagHandler.UpdateTag)).Methods("PUT")
+	api.HandleFunc("/tags/{id}", handlers.RequireScope(auth.ScopeBillsWrite, tagHandler.DeleteTag)).Methods("DELETE")
+
+	// Merchant handlers
+	merchantHandler := handlers.NewMerchantHandler(database)
+	api.HandleFunc("/merchants", handlers.RequireScope(auth.ScopeBillsRead, merchantHandler.GetMerchants)).Methods("GET")
+	api.HandleFunc("/merchants", handlers.RequireScope(auth.ScopeBillsWrite, merchantHandler.CreateMerchant)).Methods("POST")
+	api.HandleFunc("/merchants/{id}", handlers.RequireScope(auth.ScopeBillsRead, merchantHandler.GetMerchant)).Methods("GET")
+	api.HandleFunc("/merchants/{id}", handlers.RequireScope(auth.ScopeBillsWrite, merchantHandler.UpdateMerchant)).Methods("PUT")
+	api.HandleFunc("/merchants/{id}", handlers.RequireScope(auth.ScopeBillsWrite, merchantHandler.DeleteMerchant)).Methods("DELETE")
+
+	// Recurring bill handlers
+	recurringBillHandler := handlers.NewRecurringBillHandler(database, limits)
+	api.HandleFunc("/recurring-bills", handlers.RequireScope(auth.ScopeBillsRead, recurringBillHandler.GetRecurringBills)).Methods("GET")
+	api.HandleFunc("/recurring-bills", handlers.RequireScope(auth.ScopeBillsWrite, recurringBillHandler.CreateRecurringBill)).Methods("POST")
+	api.HandleFunc("/recurring-bills/{id}", handlers.RequireScope(auth.ScopeBillsRead, recurringBillHandler.GetRecurringBill)).Methods("GET")
+	api.HandleFunc("/recurring-bills/{id}", handlers.RequireScope(auth.ScopeBillsWrite, recurringBillHandler.UpdateRecurringBill)).Methods("PUT")
+	api.HandleFunc("/recurring-bills/{id}", handlers.RequireScope(auth.ScopeBillsWrite, recurringBillHandler.DeleteRecurringBill)).Methods("DELETE")
+	api.HandleFunc("/recurring-bills/{id}/paused", handlers.RequireScope(auth.ScopeBillsWrite, recurringBillHandler.SetRecurringBillPaused)).Methods("PUT")
+	api.HandleFunc("/recurring-bills/{id}/skips", handlers.RequireScope(auth.ScopeBillsWrite, recurringBillHandler.SkipRecurringBill)).Methods("POST")
+	api.HandleFunc("/recurring-bills/{id}/end", handlers.RequireScope(auth.ScopeBillsWrite, recurringBillHandler.EndRecurringBill)).Methods("POST")
+
+	// Exchange rate handlers
+	fxRateHandler := handlers.NewFXRateHandler(database)
+	api.HandleFunc("/fx-rates", handlers.RequireScope(auth.ScopeFXRatesRead, fxRateHandler.GetFXRates)).Methods("GET")
+	api.HandleFunc("/fx-rates", handlers.RequireScope(auth.ScopeFXRatesWrite, fxRateHandler.CreateFXRate)).Methods("POST")
+	api.HandleFunc("/fx-rates/{id}", handlers.RequireScope(auth.ScopeFXRatesRead, fxRateHandler.GetFXRate)).Methods("GET")
+	api.HandleFunc("/fx-rates/{id}", handlers.RequireScope(auth.ScopeFXRatesWrite, fxRateHandler.UpdateFXRate)).Methods("PUT")
+	api.HandleFunc("/fx-rates/{id}", handlers.RequireScope(auth.ScopeFXRatesWrite, fxRateHandler.DeleteFXRate)).Methods("DELETE")
+
+	// API key handlers
+	apiKeyHandler := handlers.NewAPIKeyHandler(database)
+	api.HandleFunc("/admin/api-keys", handlers.RequireScope(auth.ScopeAdmin, apiKeyHandler.GetAPIKeys)).Methods("GET")
+	api.HandleFunc("/admin/api-keys", handlers.RequireScope(auth.ScopeAdmin, apiKeyHandler.CreateAPIKey)).Methods("POST")
+	api.HandleFunc("/admin/api-keys/{id}", handlers.RequireScope(auth.ScopeAdmin, apiKeyHandler.RevokeAPIKey)).Methods("DELETE")
+
+	// Swagger
+	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+
+	return logging.RequestID(r)
+}
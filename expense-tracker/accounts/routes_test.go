@@ -0,0 +1,1446 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/auth"
+	"github.com/jo/choreo-tutorial/accounts/auth/authtest"
+	"github.com/jo/choreo-tutorial/accounts/config"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/handlers"
+	"github.com/jo/choreo-tutorial/accounts/logging"
+	"github.com/jo/choreo-tutorial/accounts/metrics"
+	"github.com/jo/choreo-tutorial/accounts/models"
+)
+
+// newTestServer starts the API on an empty in-memory database
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestServerWithDB(t, db.NewMemoryDB())
+}
+
+// testAuthenticator identifies users by the X-User-Subject header
+var testAuthenticator = &auth.HeaderAuthenticator{SubjectHeader: "X-User-Subject", EmailHeader: "X-User-Email", EmailVerifiedHeader: "X-User-Email-Verified"}
+
+// testAdmins are the subjects of the users who may manage API keys
+var testAdmins = []string{"admin"}
+
+// newTestServerWithDB starts the API on the given database
+func newTestServerWithDB(t *testing.T, database db.Database) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(newRouter(database, testAuthenticator, testAdmins, models.DefaultValidationLimits(), handlers.NewHealthHandler(database, nil), metrics.New()))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// do sends a request as the user alice with an optional JSON body and
+// decodes the JSON response into out
+func do(t *testing.T, server *httptest.Server, method, path string, body, out interface{}) int {
+	t.Helper()
+	return doAs(t, server, "alice", method, path, body, out)
+}
+
+// doAs sends a request like do as the user with the subject; an empty
+// subject sends an unauthenticated request
+func doAs(t *testing.T, server *httptest.Server, subject, method, path string, body, out interface{}) int {
+	t.Helper()
+	headers := map[string]string{}
+	if subject != "" {
+		headers["X-User-Subject"] = subject
+	}
+	return doWithHeaders(t, server, headers, method, path, body, out)
+}
+
+// doWithKey sends a request like do with the API key
+func doWithKey(t *testing.T, server *httptest.Server, key, method, path string, body, out interface{}) int {
+	t.Helper()
+	return doWithHeaders(t, server, map[string]string{auth.APIKeyHeader: key}, method, path, body, out)
+}
+
+// doWithHeaders sends a request like do with the headers
+func doWithHeaders(t *testing.T, server *httptest.Server, headers map[string]string, method, path string, body, out interface{}) int {
+	t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, server.URL+path, &reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestBillLifecycle(t *testing.T) {
+	server := newTestServer(t)
+
+	// Create
+	var created map[string]int64
+	status := do(t, server, "POST", "/bills", map[string]interface{}{
+		"title":    "Groceries",
+		"currency": "eur",
+		"due_date": "2024-03-15",
+		"items": []map[string]interface{}{
+			{"name": "Milk", "amount": 1.99, "quantity": 2},
+			{"name": "Bread", "amount": "3.49", "quantity": 1},
+		},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /bills = %d, want 201", status)
+	}
+	billPath := fmt.Sprintf("/bills/%d", created["id"])
+
+	var bill models.Bill
+	if status := do(t, server, "GET", billPath, nil, &bill); status != http.StatusOK {
+		t.Fatalf("GET %s = %d, want 200", billPath, status)
+	}
+	if bill.Total != 747 || bill.Currency != "EUR" || len(bill.Items) != 2 {
+		t.Errorf("bill = %s %s with %d items, want 7.47 EUR with 2 items", bill.Total, bill.Currency, len(bill.Items))
+	}
+	if bill.DueDate.Format("2006-01-02") != "2024-03-15" {
+		t.Errorf("due date = %v, want 2024-03-15", bill.DueDate)
+	}
+
+	// Add, update and delete an item; the total follows
+	status = do(t, server, "POST", billPath+"/items", map[string]interface{}{"name": "Eggs", "amount": 2.5, "quantity": 4}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST %s/items = %d, want 201", billPath, status)
+	}
+	itemPath := fmt.Sprintf("%s/items/%d", billPath, created["id"])
+	do(t, server, "GET", billPath, nil, &bill)
+	if bill.Total != 1747 {
+		t.Errorf("total after adding an item = %s, want 17.47", bill.Total)
+	}
+
+	status = do(t, server, "PUT", itemPath, map[string]interface{}{"name": "Eggs", "amount": 2.5, "quantity": 2}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("PUT %s = %d, want 200", itemPath, status)
+	}
+	do(t, server, "GET", billPath, nil, &bill)
+	if bill.Total != 1247 {
+		t.Errorf("total after updating an item = %s, want 12.47", bill.Total)
+	}
+
+	if status := do(t, server, "DELETE", itemPath, nil, nil); status != http.StatusOK {
+		t.Fatalf("DELETE %s = %d, want 200", itemPath, status)
+	}
+	if status := do(t, server, "GET", itemPath, nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET deleted item = %d, want 404", status)
+	}
+
+	// JSON numbers computed as floats are rounded to the nearest cent
+	price := 0.1
+	price += 0.2
+	status = do(t, server, "POST", billPath+"/items", map[string]interface{}{"name": "Stamp", "amount": price, "quantity": 1}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST %s/items with %v = %d, want 201", billPath, price, status)
+	}
+	do(t, server, "GET", billPath, nil, &bill)
+	if bill.Total != 777 {
+		t.Errorf("total after adding an item of %v = %s, want 7.77", price, bill.Total)
+	}
+
+	// Delete the bill
+	if status := do(t, server, "DELETE", billPath, nil, nil); status != http.StatusOK {
+		t.Fatalf("DELETE %s = %d, want 200", billPath, status)
+	}
+	if status := do(t, server, "GET", billPath, nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET deleted bill = %d, want 404", status)
+	}
+}
+
+func TestBillErrors(t *testing.T) {
+	server := newTestServer(t)
+
+	var created map[string]int64
+	do(t, server, "POST", "/bills", map[string]interface{}{"title": "Rent"}, &created)
+	otherItem := fmt.Sprintf("/bills/%d/items/1", created["id"]+1)
+
+	tests := []struct {
+		method string
+		path   string
+		body   interface{}
+		want   int
+		code   string
+	}{
+		{"POST", "/bills", map[string]interface{}{"description": "no title"}, http.StatusBadRequest, handlers.CodeValidationFailed},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "currency": "EURO"}, http.StatusBadRequest, handlers.CodeValidationFailed},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "currency": "XYZ"}, http.StatusBadRequest, handlers.CodeValidationFailed},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "currency": "JPY", "items": []map[string]interface{}{{"name": "A", "amount": 1.5, "quantity": 1}}}, http.StatusBadRequest, handlers.CodeValidationFailed},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "currency": "BHD"}, http.StatusBadRequest, handlers.CodeValidationFailed},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "due_date": "15.03.2024"}, http.StatusBadRequest, handlers.CodeValidationFailed},
+		{"POST", "/bills", "not an object", http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "items": []map[string]interface{}{{"name": "A", "amount": "1/3", "quantity": 1}}}, http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "items": []map[string]interface{}{{"name": "A", "amount": "1e3", "quantity": 1}}}, http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "items": []map[string]interface{}{{"name": "A", "amount": "1e999999999", "quantity": 1}}}, http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "items": []map[string]interface{}{{"name": "A", "amount": "1.005", "quantity": 1}}}, http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"POST", "/bills", map[string]interface{}{"title": "Bad", "items": []map[string]interface{}{{"name": "A", "amount": "1" + strings.Repeat("0", 40), "quantity": 1}}}, http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"GET", "/bills/abc", nil, http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"GET", "/bills/999", nil, http.StatusNotFound, handlers.CodeNotFound},
+		{"PUT", "/bills/999", map[string]interface{}{"title": "Ghost"}, http.StatusNotFound, handlers.CodeNotFound},
+		{"DELETE", "/bills/999", nil, http.StatusNotFound, handlers.CodeNotFound},
+		{"POST", "/bills/999/items", map[string]interface{}{"name": "A", "amount": 1, "quantity": 1}, http.StatusNotFound, handlers.CodeNotFound},
+		{"GET", otherItem, nil, http.StatusNotFound, handlers.CodeNotFound},
+		{"GET", "/bills?limit=0", nil, http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"GET", "/bills?sort=amount", nil, http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"GET", "/bills?min_total=1e9", nil, http.StatusBadRequest, handlers.CodeInvalidRequest},
+		{"GET", "/fx-rates/999", nil, http.StatusNotFound, handlers.CodeNotFound},
+		{"POST", "/fx-rates", map[string]interface{}{"base_currency": "EUR"}, http.StatusForbidden, handlers.CodeForbidden},
+		{"GET", "/nowhere", nil, http.StatusNotFound, handlers.CodeRouteNotFound},
+		{"PATCH", "/bills", nil, http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		var problem models.Problem
+		if status := do(t, server, tt.method, tt.path, tt.body, &problem); status != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, status, tt.want)
+		}
+		if problem.Code != tt.code || problem.Status != tt.want {
+			t.Errorf("%s %s: code %q, status %d, want %q, %d", tt.method, tt.path, problem.Code, problem.Status, tt.code, tt.want)
+		}
+	}
+}
+
+func TestUserIsolation(t *testing.T) {
+	server := newTestServer(t)
+
+	// Requests without a user are rejected
+	var problem models.Problem
+	if status := doAs(t, server, "", "GET", "/bills", nil, &problem); status != http.StatusUnauthorized || problem.Code != handlers.CodeUnauthenticated {
+		t.Errorf("GET /bills without a user = %d %q, want 401 %s", status, problem.Code, handlers.CodeUnauthenticated)
+	}
+
+	var created map[string]int64
+	status := doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{
+		"title": "Rent",
+		"items": []map[string]interface{}{{"name": "March", "amount": 900, "quantity": 1}},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /bills = %d, want 201", status)
+	}
+	billPath := fmt.Sprintf("/bills/%d", created["id"])
+
+	var me models.User
+	if status := doAs(t, server, "alice", "GET", "/me", nil, &me); status != http.StatusOK || me.Subject != "alice" {
+		t.Errorf("GET /me = %d %+v, want alice", status, me)
+	}
+	var bill models.Bill
+	doAs(t, server, "alice", "GET", billPath, nil, &bill)
+	if bill.OwnerID != me.ID {
+		t.Errorf("owner of the bill = %d, want %d", bill.OwnerID, me.ID)
+	}
+
+	// Another user neither sees nor changes the bill
+	var page models.BillPage
+	if doAs(t, server, "bob", "GET", "/bills", nil, &page); page.Total != 0 {
+		t.Errorf("bob sees %d bills, want none", page.Total)
+	}
+	requests := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"GET", billPath, nil},
+		{"PUT", billPath, map[string]interface{}{"title": "Mine"}},
+		{"DELETE", billPath, nil},
+		{"GET", billPath + "/items", nil},
+		{"POST", billPath + "/items", map[string]interface{}{"name": "Extra", "amount": 1, "quantity": 1}},
+		{"GET", fmt.Sprintf("%s/items/%d", billPath, bill.Items[0].ID), nil},
+	}
+	for _, r := range requests {
+		if status := doAs(t, server, "bob", r.method, r.path, r.body, nil); status != http.StatusNotFound {
+			t.Errorf("%s %s as bob = %d, want 404", r.method, r.path, status)
+		}
+	}
+
+	doAs(t, server, "alice", "GET", billPath, nil, &bill)
+	if bill.Title != "Rent" || bill.Total != 90000 || len(bill.Items) != 1 {
+		t.Errorf("bill after bob's requests = %+v, want it unchanged", bill)
+	}
+}
+
+func TestSharing(t *testing.T) {
+	server := newTestServer(t)
+
+	var created map[string]int64
+	status := doAs(t, server, "alice", "POST", "/ledgers", map[string]interface{}{"name": " Household "}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /ledgers = %d, want 201", status)
+	}
+	ledgerID := created["id"]
+	ledgerPath := fmt.Sprintf("/ledgers/%d", ledgerID)
+	status = doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{
+		"title":     "Dinner",
+		"ledger_id": ledgerID,
+		"items":     []map[string]interface{}{{"name": "Pizza", "amount": 12, "quantity": 2}},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /bills = %d, want 201", status)
+	}
+	billPath := fmt.Sprintf("/bills/%d", created["id"])
+	doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{"title": "Gym"}, nil)
+
+	// Bills are filtered by ledger
+	var page models.BillPage
+	if doAs(t, server, "alice", "GET", fmt.Sprintf("/bills?ledger_id=%d", ledgerID), nil, &page); page.Total != 1 {
+		t.Errorf("bills in the ledger = %d, want 1", page.Total)
+	}
+	if status := doAs(t, server, "alice", "GET", "/bills?ledger_id=0", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("GET /bills?ledger_id=0 = %d, want 400", status)
+	}
+
+	// Only users who have signed in are invited; unknown subjects are not created
+	var problem models.Problem
+	status = doAs(t, server, "alice", "POST", billPath+"/shares", map[string]interface{}{"subject": "bob", "role": "viewer"}, &problem)
+	if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+		t.Errorf("POST %s/shares with an unknown subject = %d %q, want 400 %s", billPath, status, problem.Code, handlers.CodeValidationFailed)
+	}
+	if status := doAs(t, server, "alice", "POST", billPath+"/shares", map[string]interface{}{"subject": "bob", "role": "viewer"}, nil); status != http.StatusBadRequest {
+		t.Errorf("POST %s/shares with an unknown subject again = %d, want 400", billPath, status)
+	}
+
+	// Bob is invited by subject as viewer and may read the bill but not change it
+	doAs(t, server, "bob", "GET", "/me", nil, nil)
+	status = doAs(t, server, "alice", "POST", billPath+"/shares", map[string]interface{}{"subject": "bob", "role": "viewer"}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST %s/shares = %d, want 201", billPath, status)
+	}
+	bobID := created["user_id"]
+	sharePath := fmt.Sprintf("%s/shares/%d", billPath, bobID)
+	var bill models.Bill
+	if status := doAs(t, server, "bob", "GET", billPath, nil, &bill); status != http.StatusOK || bill.Role != models.RoleViewer {
+		t.Errorf("GET %s as viewer = %d with role %q, want 200 viewer", billPath, status, bill.Role)
+	}
+	status = doAs(t, server, "bob", "PUT", billPath, map[string]interface{}{"title": "Mine"}, &problem)
+	if status != http.StatusForbidden || problem.Code != handlers.CodeForbidden || !strings.Contains(problem.Detail, "editor is required") {
+		t.Errorf("PUT %s as viewer = %d %+v, want 403 requiring editor", billPath, status, problem)
+	}
+	if status := doAs(t, server, "bob", "PUT", billPath+"/paid", map[string]interface{}{"paid": true}, nil); status != http.StatusForbidden {
+		t.Errorf("PUT %s/paid as viewer = %d, want 403", billPath, status)
+	}
+
+	// Payers mark the bill as paid, editors change its items
+	if status := doAs(t, server, "alice", "PUT", sharePath, map[string]interface{}{"role": "payer"}, nil); status != http.StatusOK {
+		t.Fatalf("PUT %s = %d, want 200", sharePath, status)
+	}
+	if status := doAs(t, server, "bob", "PUT", billPath+"/paid", map[string]interface{}{"paid": true}, nil); status != http.StatusOK {
+		t.Errorf("PUT %s/paid as payer = %d, want 200", billPath, status)
+	}
+	doAs(t, server, "alice", "PUT", sharePath, map[string]interface{}{"role": "editor"}, nil)
+	if status := doAs(t, server, "bob", "POST", billPath+"/items", map[string]interface{}{"name": "Wine", "amount": 20, "quantity": 1}, nil); status != http.StatusCreated {
+		t.Errorf("POST %s/items as editor = %d, want 201", billPath, status)
+	}
+	doAs(t, server, "alice", "GET", billPath, nil, &bill)
+	if !bill.Paid || bill.Total != 4400 {
+		t.Errorf("bill after bob's changes = %+v, want paid with a total of 44.00", bill)
+	}
+
+	// Only the owner manages shares
+	var shares []models.Share
+	if doAs(t, server, "bob", "GET", billPath+"/shares", nil, &shares); len(shares) != 1 || shares[0].Subject != "bob" {
+		t.Errorf("shares = %+v, want bob", shares)
+	}
+	if status := doAs(t, server, "bob", "POST", billPath+"/shares", map[string]interface{}{"subject": "carol", "role": "owner"}, nil); status != http.StatusForbidden {
+		t.Errorf("POST %s/shares as editor = %d, want 403", billPath, status)
+	}
+	status = doAs(t, server, "alice", "POST", billPath+"/shares", map[string]interface{}{"email": "nobody@example.com", "role": "viewer"}, &problem)
+	if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+		t.Errorf("POST %s/shares with an unknown email = %d %q, want 400 %s", billPath, status, problem.Code, handlers.CodeValidationFailed)
+	}
+	if status := doAs(t, server, "alice", "POST", billPath+"/shares", map[string]interface{}{"subject": "bob", "role": "viewer"}, nil); status != http.StatusConflict {
+		t.Errorf("POST %s/shares twice = %d, want 409", billPath, status)
+	}
+
+	// Emails only identify the one user who verified them
+	carol := func(verified string) {
+		doWithHeaders(t, server, map[string]string{"X-User-Subject": "carol", "X-User-Email": "carol@example.com", "X-User-Email-Verified": verified}, "GET", "/me", nil, nil)
+	}
+	carol("false")
+	doWithHeaders(t, server, map[string]string{"X-User-Subject": "mallory", "X-User-Email": "carol@example.com"}, "GET", "/me", nil, nil)
+	if status := doAs(t, server, "alice", "POST", billPath+"/shares", map[string]interface{}{"email": "carol@example.com", "role": "viewer"}, nil); status != http.StatusBadRequest {
+		t.Errorf("POST %s/shares with an unverified email = %d, want 400", billPath, status)
+	}
+	carol("true")
+	status = doAs(t, server, "alice", "POST", billPath+"/shares", map[string]interface{}{"email": "carol@example.com", "role": "viewer"}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST %s/shares with a verified email = %d, want 201", billPath, status)
+	}
+	if status := doAs(t, server, "carol", "GET", billPath, nil, nil); status != http.StatusOK {
+		t.Errorf("GET %s as carol = %d, want 200", billPath, status)
+	}
+
+	// Only owners see the emails of other members
+	emails := func(subject string) map[string]string {
+		var shares []models.Share
+		doAs(t, server, subject, "GET", billPath+"/shares", nil, &shares)
+		result := map[string]string{}
+		for _, share := range shares {
+			result[share.Subject] = share.Email
+		}
+		return result
+	}
+	if got := emails("alice")["carol"]; got != "carol@example.com" {
+		t.Errorf("email of carol for the owner = %q, want carol@example.com", got)
+	}
+	if got := emails("bob")["carol"]; got != "" {
+		t.Errorf("email of carol for an editor = %q, want none", got)
+	}
+	if got := emails("carol")["carol"]; got != "carol@example.com" {
+		t.Errorf("own email of carol = %q, want carol@example.com", got)
+	}
+	doAs(t, server, "alice", "DELETE", fmt.Sprintf("%s/shares/%d", billPath, created["user_id"]), nil, nil)
+
+	// Revoking the share revokes access
+	if status := doAs(t, server, "alice", "DELETE", sharePath, nil, nil); status != http.StatusOK {
+		t.Fatalf("DELETE %s = %d, want 200", sharePath, status)
+	}
+	if status := doAs(t, server, "bob", "GET", billPath, nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET %s after revoking = %d, want 404", billPath, status)
+	}
+	if status := doAs(t, server, "alice", "DELETE", sharePath, nil, nil); status != http.StatusNotFound {
+		t.Errorf("DELETE %s twice = %d, want 404", sharePath, status)
+	}
+
+	// Sharing the ledger shares its bills
+	if status := doAs(t, server, "alice", "POST", ledgerPath+"/shares", map[string]interface{}{"subject": "bob", "role": "viewer"}, nil); status != http.StatusCreated {
+		t.Fatalf("POST %s/shares = %d, want 201", ledgerPath, status)
+	}
+	var ledgers []models.Ledger
+	if doAs(t, server, "bob", "GET", "/ledgers", nil, &ledgers); len(ledgers) != 1 || ledgers[0].Name != "Household" || ledgers[0].BillCount != 1 {
+		t.Errorf("bob's ledgers = %+v, want Household with 1 bill", ledgers)
+	}
+	if doAs(t, server, "bob", "GET", "/bills", nil, &page); page.Total != 1 {
+		t.Errorf("bob sees %d bills, want the one in the ledger", page.Total)
+	}
+	if status := doAs(t, server, "bob", "DELETE", ledgerPath, nil, nil); status != http.StatusForbidden {
+		t.Errorf("DELETE %s as viewer = %d, want 403", ledgerPath, status)
+	}
+	if status := doAs(t, server, "carol", "GET", ledgerPath, nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET %s as stranger = %d, want 404", ledgerPath, status)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	server := newTestServer(t)
+
+	// Users see the default categories and nest their own under them
+	var categories []models.Category
+	if status := doAs(t, server, "alice", "GET", "/categories", nil, &categories); status != http.StatusOK {
+		t.Fatalf("GET /categories = %d, want 200", status)
+	}
+	var food int64
+	for _, category := range categories {
+		if category.Name == "Food" {
+			food = category.ID
+		}
+	}
+	if food == 0 {
+		t.Fatalf("categories = %+v, want the default Food category", categories)
+	}
+	var created map[string]int64
+	status := doAs(t, server, "alice", "POST", "/categories", map[string]interface{}{"name": " Snacks ", "parent_id": food}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /categories = %d, want 201", status)
+	}
+	snacks := created["id"]
+	categoryPath := fmt.Sprintf("/categories/%d", snacks)
+	var category models.Category
+	if doAs(t, server, "alice", "GET", categoryPath, nil, &category); category.Name != "Snacks" || category.ParentID == nil || *category.ParentID != food {
+		t.Errorf("GET %s = %+v, want Snacks under Food", categoryPath, category)
+	}
+	if status := doAs(t, server, "bob", "GET", categoryPath, nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET %s as another user = %d, want 404", categoryPath, status)
+	}
+
+	// Cycles and changes of default categories are rejected
+	var problem models.Problem
+	status = doAs(t, server, "alice", "PUT", categoryPath, map[string]interface{}{"name": "Snacks", "parent_id": snacks}, &problem)
+	if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+		t.Errorf("PUT %s under itself = %d %q, want 400 %s", categoryPath, status, problem.Code, handlers.CodeValidationFailed)
+	}
+	foodPath := fmt.Sprintf("/categories/%d", food)
+	status = doAs(t, server, "alice", "DELETE", foodPath, nil, &problem)
+	if status != http.StatusForbidden || problem.Detail != "default categories cannot be changed" {
+		t.Errorf("DELETE %s = %d %q, want 403 for a default category", foodPath, status, problem.Detail)
+	}
+
+	// Bills and items are categorized and filtered by category
+	status = doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{
+		"title": "Market",
+		"items": []map[string]interface{}{{"name": "Chips", "amount": 2.5, "quantity": 1, "category_id": snacks}},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /bills = %d, want 201", status)
+	}
+	doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{"title": "Gym"}, nil)
+	var page models.BillPage
+	if doAs(t, server, "alice", "GET", fmt.Sprintf("/bills?category_id=%d", food), nil, &page); page.Total != 1 || page.Bills[0].Title != "Market" {
+		t.Errorf("bills in Food = %+v, want Market", page.Bills)
+	}
+	if status := doAs(t, server, "alice", "GET", "/bills?category_id=x", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("GET /bills?category_id=x = %d, want 400", status)
+	}
+	status = doAs(t, server, "bob", "POST", "/bills", map[string]interface{}{"title": "Phone", "category_id": snacks}, &problem)
+	if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+		t.Errorf("POST /bills with a category of another user = %d %q, want 400 %s", status, problem.Code, handlers.CodeValidationFailed)
+	}
+
+	if status := doAs(t, server, "alice", "DELETE", categoryPath, nil, nil); status != http.StatusOK {
+		t.Errorf("DELETE %s = %d, want 200", categoryPath, status)
+	}
+	if doAs(t, server, "alice", "GET", fmt.Sprintf("/bills?category_id=%d", food), nil, &page); page.Total != 0 {
+		t.Errorf("bills in Food after deleting Snacks = %d, want 0", page.Total)
+	}
+}
+
+func TestTags(t *testing.T) {
+	server := newTestServer(t)
+
+	// Tags of bills and items are normalized and created on first use
+	var created map[string]int64
+	status := doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{
+		"title": "Flight",
+		"tags":  []string{" Travel ", "travel", "Work"},
+		"items": []map[string]interface{}{{"name": "Seat", "amount": 200, "quantity": 1, "tags": []string{"Lisbon"}}},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /bills = %d, want 201", status)
+	}
+	var bill models.Bill
+	doAs(t, server, "alice", "GET", fmt.Sprintf("/bills/%d", created["id"]), nil, &bill)
+	if !slices.Equal(bill.Tags, []string{"travel", "work"}) || !slices.Equal(bill.Items[0].Tags, []string{"lisbon"}) {
+		t.Errorf("bill tags = %v and %v, want [travel work] and [lisbon]", bill.Tags, bill.Items[0].Tags)
+	}
+	doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{"title": "Gym", "tags": []string{"health"}}, nil)
+
+	var tags []models.Tag
+	if status := doAs(t, server, "alice", "GET", "/tags", nil, &tags); status != http.StatusOK || len(tags) != 4 {
+		t.Fatalf("GET /tags = %d %+v, want 4 tags", status, tags)
+	}
+	var problem models.Problem
+	status = doAs(t, server, "alice", "POST", "/tags", map[string]string{"name": "WORK"}, &problem)
+	if status != http.StatusConflict || problem.Detail != "a tag with this name already exists" {
+		t.Errorf("POST /tags with a taken name = %d %q, want 409", status, problem.Detail)
+	}
+	status = doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{"title": "Odd", "tags": []string{"a,b"}}, &problem)
+	if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+		t.Errorf("POST /bills with a comma in a tag = %d %q, want 400 %s", status, problem.Code, handlers.CodeValidationFailed)
+	}
+
+	// Bills are filtered by any or all of the tags
+	filters := []struct {
+		query string
+		want  int
+	}{
+		{"/bills?tags=travel,health", 2},
+		{"/bills?tags=Lisbon", 1},
+		{"/bills?tags=travel,health&tag_match=all", 0},
+		{"/bills?tags=travel,lisbon&tag_match=all", 1},
+	}
+	var page models.BillPage
+	for _, filter := range filters {
+		if status := doAs(t, server, "alice", "GET", filter.query, nil, &page); status != http.StatusOK || page.Total != filter.want {
+			t.Errorf("GET %s = %d with %d bills, want %d", filter.query, status, page.Total, filter.want)
+		}
+	}
+	for _, query := range []string{"/bills?tags=a,,b", "/bills?tags=a&tag_match=some"} {
+		if status := doAs(t, server, "alice", "GET", query, nil, nil); status != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", query, status)
+		}
+	}
+
+	// Tags are renamed and deleted on all of their bills
+	var travel int64
+	for _, tag := range tags {
+		if tag.Name == "travel" {
+			travel = tag.ID
+		}
+	}
+	tagPath := fmt.Sprintf("/tags/%d", travel)
+	if status := doAs(t, server, "bob", "GET", tagPath, nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET %s as another user = %d, want 404", tagPath, status)
+	}
+	if status := doAs(t, server, "alice", "PUT", tagPath, map[string]string{"name": "Trip"}, nil); status != http.StatusOK {
+		t.Errorf("PUT %s = %d, want 200", tagPath, status)
+	}
+	var tag models.Tag
+	if doAs(t, server, "alice", "GET", tagPath, nil, &tag); tag.Name != "trip" || tag.BillCount != 1 {
+		t.Errorf("GET %s = %+v, want trip on one bill", tagPath, tag)
+	}
+	if status := doAs(t, server, "alice", "DELETE", tagPath, nil, nil); status != http.StatusOK {
+		t.Errorf("DELETE %s = %d, want 200", tagPath, status)
+	}
+	if doAs(t, server, "alice", "GET", "/bills?tags=trip", nil, &page); page.Total != 0 {
+		t.Errorf("bills tagged trip after delete = %d, want 0", page.Total)
+	}
+}
+
+func TestMerchants(t *testing.T) {
+	server := newTestServer(t)
+
+	var categories []models.Category
+	doAs(t, server, "alice", "GET", "/categories", nil, &categories)
+	var shopping int64
+	for _, category := range categories {
+		if category.Name == "Shopping" {
+			shopping = category.ID
+		}
+	}
+
+	// Aliases are normalized
+	var created map[string]int64
+	status := doAs(t, server, "alice", "POST", "/merchants", map[string]interface{}{
+		"name":        " Amazon ",
+		"aliases":     []string{"AMZN Mktp", "amzn mktp", " "},
+		"category_id": shopping,
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /merchants = %d, want 201", status)
+	}
+	merchantPath := fmt.Sprintf("/merchants/%d", created["id"])
+	var merchant models.Merchant
+	doAs(t, server, "alice", "GET", merchantPath, nil, &merchant)
+	if merchant.Name != "Amazon" || !slices.Equal(merchant.Aliases, []string{"amzn mktp"}) {
+		t.Errorf("GET %s = %+v, want Amazon with the alias amzn mktp", merchantPath, merchant)
+	}
+	var problem models.Problem
+	status = doAs(t, server, "alice", "POST", "/merchants", map[string]interface{}{"name": "Marketplace", "aliases": []string{"AMZN MKTP"}}, &problem)
+	if status != http.StatusConflict || problem.Detail != "a merchant with this name or alias already exists" {
+		t.Errorf("POST /merchants with a taken alias = %d %q, want 409", status, problem.Detail)
+	}
+	status = doAs(t, server, "alice", "POST", "/merchants", map[string]interface{}{"name": " "}, &problem)
+	if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+		t.Errorf("POST /merchants without a name = %d %q, want 400 %s", status, problem.Code, handlers.CodeValidationFailed)
+	}
+
+	// Merchant names of bills resolve to merchants and their default category
+	status = doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{"title": "Books", "merchant": "AMZN MKTP US*2K4"}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /bills = %d, want 201", status)
+	}
+	var bill models.Bill
+	doAs(t, server, "alice", "GET", fmt.Sprintf("/bills/%d", created["id"]), nil, &bill)
+	if bill.MerchantID == nil || *bill.MerchantID != merchant.ID || bill.CategoryID == nil || *bill.CategoryID != shopping {
+		t.Errorf("bill = %+v, want merchant Amazon in Shopping", bill)
+	}
+	doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{"title": "Milk", "merchant": "Corner Shop"}, nil)
+
+	var page models.BillPage
+	query := fmt.Sprintf("/bills?merchant_id=%d", merchant.ID)
+	if status := doAs(t, server, "alice", "GET", query, nil, &page); status != http.StatusOK || page.Total != 1 {
+		t.Errorf("GET %s = %d with %d bills, want 1", query, status, page.Total)
+	}
+	if status := doAs(t, server, "alice", "GET", "/bills?merchant_id=0", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("GET /bills?merchant_id=0 = %d, want 400", status)
+	}
+
+	// Merchants of other users are not found, deleting one keeps its bills
+	if status := doAs(t, server, "bob", "GET", merchantPath, nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET %s as another user = %d, want 404", merchantPath, status)
+	}
+	status = doAs(t, server, "bob", "POST", "/bills", map[string]interface{}{"title": "Books", "merchant_id": merchant.ID}, &problem)
+	if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+		t.Errorf("POST /bills with a merchant of another user = %d %q, want 400 %s", status, problem.Code, handlers.CodeValidationFailed)
+	}
+	if status := doAs(t, server, "alice", "DELETE", merchantPath, nil, nil); status != http.StatusOK {
+		t.Errorf("DELETE %s = %d, want 200", merchantPath, status)
+	}
+	if doAs(t, server, "alice", "GET", "/bills", nil, &page); page.Total != 2 {
+		t.Errorf("bills after deleting their merchant = %d, want 2", page.Total)
+	}
+}
+
+func TestRecurringBills(t *testing.T) {
+	database := db.NewMemoryDB()
+	server := newTestServerWithDB(t, database)
+
+	// Rules are canonicalized
+	var created map[string]int64
+	status := doAs(t, server, "alice", "POST", "/recurring-bills", map[string]interface{}{
+		"title":      "Rent",
+		"amount":     "1200.00",
+		"rule":       "rrule:freq=monthly;interval=1",
+		"start_date": "2025-01-31",
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /recurring-bills = %d, want 201", status)
+	}
+	recurringPath := fmt.Sprintf("/recurring-bills/%d", created["id"])
+	var recurring models.RecurringBill
+	doAs(t, server, "alice", "GET", recurringPath, nil, &recurring)
+	if recurring.Rule != "FREQ=MONTHLY" || recurring.Currency != models.DefaultCurrency || recurring.NextDate == nil || recurring.NextDate.Format("2006-01-02") != "2025-01-31" {
+		t.Errorf("GET %s = %+v, want FREQ=MONTHLY next on 2025-01-31", recurringPath, recurring)
+	}
+	var problem models.Problem
+	status = doAs(t, server, "alice", "POST", "/recurring-bills", map[string]interface{}{"title": "Rent", "rule": "FREQ=HOURLY", "start_date": "2025-01-31"}, &problem)
+	if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+		t.Errorf("POST /recurring-bills with an unsupported rule = %d %q, want 400 %s", status, problem.Code, handlers.CodeValidationFailed)
+	}
+	if status := doAs(t, server, "bob", "GET", recurringPath, nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET %s as another user = %d, want 404", recurringPath, status)
+	}
+
+	// Only occurrences of the rule can be skipped; February clamps to its last day
+	skipsPath := recurringPath + "/skips"
+	if status := doAs(t, server, "alice", "POST", skipsPath, map[string]string{"date": "2025-02-28"}, nil); status != http.StatusOK {
+		t.Errorf("POST %s = %d, want 200", skipsPath, status)
+	}
+	status = doAs(t, server, "alice", "POST", skipsPath, map[string]string{"date": "2025-02-15"}, &problem)
+	if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+		t.Errorf("POST %s with another date = %d %q, want 400 %s", skipsPath, status, problem.Code, handlers.CodeValidationFailed)
+	}
+	// Dates are bounded like due dates, so that no request walks through
+	// millions of occurrences
+	for _, path := range []string{skipsPath, recurringPath + "/end"} {
+		problem = models.Problem{}
+		status = doAs(t, server, "alice", "POST", path, map[string]string{"date": "9999-12-31", "end_date": "9999-12-31"}, &problem)
+		if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+			t.Errorf("POST %s in 9999 = %d %q, want 400 %s", path, status, problem.Code, handlers.CodeValidationFailed)
+		}
+	}
+
+	// Generated bills leave out skipped occurrences and those while paused
+	today := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if generated, err := database.GenerateRecurringBills(context.Background(), today, today.AddDate(0, 4, 0)); err != nil || generated != 3 {
+		t.Fatalf("GenerateRecurringBills = %d, %v, want 3 bills", generated, err)
+	}
+	var page models.BillPage
+	if doAs(t, server, "alice", "GET", "/bills?title=Rent", nil, &page); page.Total != 3 {
+		t.Errorf("generated bills = %d, want 3", page.Total)
+	}
+	if status := doAs(t, server, "alice", "PUT", recurringPath+"/paused", map[string]bool{"paused": true}, nil); status != http.StatusOK {
+		t.Errorf("PUT %s/paused = %d, want 200", recurringPath, status)
+	}
+	today = time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+	if generated, err := database.GenerateRecurringBills(context.Background(), today, today); err != nil || generated != 0 {
+		t.Errorf("GenerateRecurringBills while paused = %d, %v, want no bills", generated, err)
+	}
+
+	// Ending without a date ends today, deleting keeps the bills
+	if status := doAs(t, server, "alice", "POST", recurringPath+"/end", nil, nil); status != http.StatusOK {
+		t.Errorf("POST %s/end = %d, want 200", recurringPath, status)
+	}
+	doAs(t, server, "alice", "GET", recurringPath, nil, &recurring)
+	if recurring.EndDate == nil || recurring.BillCount != 3 {
+		t.Errorf("GET %s after ending = %+v, want an end date and 3 bills", recurringPath, recurring)
+	}
+	if status := doAs(t, server, "alice", "DELETE", recurringPath, nil, nil); status != http.StatusOK {
+		t.Errorf("DELETE %s = %d, want 200", recurringPath, status)
+	}
+	if doAs(t, server, "alice", "GET", "/bills?title=Rent", nil, &page); page.Total != 3 {
+		t.Errorf("bills after deleting their recurring bill = %d, want 3", page.Total)
+	}
+}
+
+func TestBillPayments(t *testing.T) {
+	server := newTestServer(t)
+
+	var created map[string]int64
+	status := doAs(t, server, "alice", "POST", "/bills", map[string]interface{}{
+		"title": "Rent",
+		"items": []map[string]interface{}{{"name": "Rent", "amount": "1000.00", "quantity": 1}},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /bills = %d, want 201", status)
+	}
+	billPath := fmt.Sprintf("/bills/%d", created["id"])
+	paymentsPath := billPath + "/payments"
+
+	// Payments default to today and the other method
+	status = doAs(t, server, "alice", "POST", paymentsPath, map[string]interface{}{"amount": "400.00", "method": " Bank_Transfer ", "reference": " TX-1 "}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST %s = %d, want 201", paymentsPath, status)
+	}
+	paymentPath := fmt.Sprintf("%s/%d", paymentsPath, created["id"])
+	var payment models.Payment
+	doAs(t, server, "alice", "GET", paymentPath, nil, &payment)
+	if payment.Amount != 40000 || payment.Method != models.PaymentMethodBankTransfer || payment.Reference != "TX-1" ||
+		payment.Date.Format("2006-01-02") != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("GET %s = %+v, want 400.00 by bank transfer today", paymentPath, payment)
+	}
+	doAs(t, server, "alice", "POST", paymentsPath, map[string]interface{}{"amount": "100.00", "date": "2025-01-15"}, nil)
+
+	var bill models.Bill
+	doAs(t, server, "alice", "GET", billPath, nil, &bill)
+	if bill.Paid || bill.AmountPaid != 50000 || bill.Balance != 50000 || bill.PaymentStatus != models.PaymentStatusPartial || len(bill.Payments) != 2 {
+		t.Errorf("GET %s = %+v, want it partially paid with 2 payments", billPath, bill)
+	}
+	var page models.BillPage
+	if doAs(t, server, "alice", "GET", "/bills?payment_status=partial", nil, &page); page.Total != 1 || page.Bills[0].Balance != 50000 {
+		t.Errorf("partially paid bills = %+v, want the bill", page)
+	}
+	if status := doAs(t, server, "alice", "GET", "/bills?payment_status=late", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("GET /bills?payment_status=late = %d, want 400", status)
+	}
+
+	// Updates of a bill with payments derive paid from them, or pay the
+	// balance like PUT /bills/{id}/paid
+	update := func(paid bool) int {
+		return doAs(t, server, "alice", "PUT", billPath, map[string]interface{}{
+			"title": "Rent",
+			"paid":  paid,
+			"items": []map[string]interface{}{{"name": "Rent", "amount": "1000.00", "quantity": 1}},
+		}, nil)
+	}
+	if status := update(false); status != http.StatusOK {
+		t.Errorf("PUT %s paid false with payments = %d, want 200", billPath, status)
+	}
+	doAs(t, server, "alice", "GET", billPath, nil, &bill)
+	if bill.Paid || bill.AmountPaid != 50000 || bill.PaymentStatus != models.PaymentStatusPartial {
+		t.Errorf("GET %s after update = %+v, want it still partially paid", billPath, bill)
+	}
+	if status := update(true); status != http.StatusOK {
+		t.Errorf("PUT %s paid true with payments below the total = %d, want 200", billPath, status)
+	}
+	doAs(t, server, "alice", "GET", billPath, nil, &bill)
+	if !bill.Paid || bill.AmountPaid != 100000 || bill.PaymentStatus != models.PaymentStatusPaid || len(bill.Payments) != 3 {
+		t.Errorf("GET %s after update as paid = %+v, want it paid with a payment of the balance", billPath, bill)
+	}
+
+	// A bill with payments is not marked as unpaid; marking it as paid
+	// again changes nothing
+	var problem models.Problem
+	status = doAs(t, server, "alice", "PUT", billPath+"/paid", map[string]bool{"paid": false}, &problem)
+	if status != http.StatusConflict || problem.Code != handlers.CodeConflict {
+		t.Errorf("PUT %s/paid false = %d %q, want 409 %s", billPath, status, problem.Code, handlers.CodeConflict)
+	}
+	if status := doAs(t, server, "alice", "PUT", billPath+"/paid", map[string]bool{"paid": true}, nil); status != http.StatusOK {
+		t.Errorf("PUT %s/paid true = %d, want 200", billPath, status)
+	}
+	var payments []models.Payment
+	doAs(t, server, "alice", "GET", paymentsPath, nil, &payments)
+	if len(payments) != 3 || payments[2].Amount != 50000 {
+		t.Errorf("GET %s = %+v, want a payment of the balance", paymentsPath, payments)
+	}
+
+	// Invalid payments are rejected
+	for _, input := range []map[string]interface{}{
+		{"amount": "0"},
+		{"amount": "10.00", "date": "15/01/2025"},
+		{"amount": "10.00", "method": "crypto"},
+	} {
+		status := doAs(t, server, "alice", "POST", paymentsPath, input, &problem)
+		if status != http.StatusBadRequest || problem.Code != handlers.CodeValidationFailed {
+			t.Errorf("POST %s with %v = %d %q, want 400 %s", paymentsPath, input, status, problem.Code, handlers.CodeValidationFailed)
+		}
+	}
+
+	// Updating and deleting payments keeps the bill consistent
+	if status := doAs(t, server, "alice", "PUT", paymentPath, map[string]interface{}{"amount": "500.00", "method": "card"}, nil); status != http.StatusOK {
+		t.Errorf("PUT %s = %d, want 200", paymentPath, status)
+	}
+	doAs(t, server, "alice", "GET", billPath, nil, &bill)
+	if !bill.Paid || bill.Balance != -10000 || bill.PaymentStatus != models.PaymentStatusOverpaid {
+		t.Errorf("GET %s after overpaying = %+v, want it overpaid", billPath, bill)
+	}
+	for _, payment := range payments {
+		path := fmt.Sprintf("%s/%d", paymentsPath, payment.ID)
+		if status := doAs(t, server, "alice", "DELETE", path, nil, nil); status != http.StatusOK {
+			t.Errorf("DELETE %s = %d, want 200", path, status)
+		}
+	}
+	doAs(t, server, "alice", "GET", billPath, nil, &bill)
+	if bill.Paid || bill.PaymentStatus != models.PaymentStatusUnpaid || len(bill.Payments) != 0 {
+		t.Errorf("GET %s after deleting its payments = %+v, want it unpaid", billPath, bill)
+	}
+
+	// Missing bills and payments are told apart, other users see neither
+	status = doAs(t, server, "alice", "GET", paymentPath, nil, &problem)
+	if status != http.StatusNotFound || problem.Detail != "payment not found" {
+		t.Errorf("GET %s after delete = %d %q, want 404 payment not found", paymentPath, status, problem.Detail)
+	}
+	status = doAs(t, server, "bob", "GET", paymentPath, nil, &problem)
+	if status != http.StatusNotFound || problem.Detail != "bill not found" {
+		t.Errorf("GET %s as another user = %d %q, want 404 bill not found", paymentPath, status, problem.Detail)
+	}
+	if status := doAs(t, server, "alice", "GET", paymentsPath+"/abc", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("GET %s/abc = %d, want 400", paymentsPath, status)
+	}
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	issuer := authtest.NewIssuer(t)
+	server := httptest.NewServer(newRouter(db.NewMemoryDB(), issuer.Authenticator(t), testAdmins, models.DefaultValidationLimits(), handlers.NewHealthHandler(nil, nil), metrics.New()))
+	t.Cleanup(server.Close)
+
+	// get requests /me with the Authorization header and decodes the
+	// response into out
+	get := func(authorization string, out interface{}) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", server.URL+"/me", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	var problem models.Problem
+	resp := get("", &problem)
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") != "Bearer" || problem.Code != handlers.CodeUnauthenticated {
+		t.Errorf("GET /me without a token = %d %q %q, want 401 with a Bearer challenge", resp.StatusCode, resp.Header.Get("WWW-Authenticate"), problem.Code)
+	}
+
+	expired, err := issuer.ES256.Sign("alice", -time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = get("Bearer "+expired, &problem)
+	if want := `Bearer error="invalid_token", error_description="token is expired"`; resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") != want || problem.Detail != "token is expired" {
+		t.Errorf("GET /me with an expired token = %d %q %q, want 401 with %s", resp.StatusCode, resp.Header.Get("WWW-Authenticate"), problem.Detail, want)
+	}
+
+	// The user is created from the claims of the first token and keeps them
+	// when later tokens omit them
+	var me models.User
+	if resp := get("Bearer "+issuer.Token(t, "alice", auth.Claims{"email": "alice@example.com", "email_verified": true, "name": "Alice"}), &me); resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /me with a valid token = %d, want 200", resp.StatusCode)
+	}
+	me = models.User{}
+	get("Bearer "+issuer.Token(t, "alice", nil), &me)
+	if me.Subject != "alice" || me.Email != "alice@example.com" || !me.EmailVerified || me.Name != "Alice" {
+		t.Errorf("GET /me = %+v, want alice with the email and name of the first token", me)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	database := db.NewMemoryDB()
+	server := newTestServerWithDB(t, database)
+
+	// Only admins manage keys
+	var problem models.Problem
+	if status := do(t, server, "GET", "/admin/api-keys", nil, &problem); status != http.StatusForbidden || problem.Code != handlers.CodeForbidden {
+		t.Errorf("GET /admin/api-keys as a user = %d %q, want 403 %s", status, problem.Code, handlers.CodeForbidden)
+	}
+
+	problem = models.Problem{}
+	status := doAs(t, server, "admin", "POST", "/admin/api-keys", map[string]interface{}{"name": " ", "scopes": []string{"bills:delete"}}, &problem)
+	if status != http.StatusBadRequest || len(problem.Errors) != 2 {
+		t.Errorf("POST /admin/api-keys with an invalid input = %d %+v, want 400 with 2 field errors", status, problem.Errors)
+	}
+
+	// A read-only key of the receipts pipeline, which acts as its own user
+	var created models.CreatedAPIKey
+	status = doAs(t, server, "admin", "POST", "/admin/api-keys", map[string]interface{}{
+		"name":    "Receipts pipeline",
+		"subject": "service:receipts",
+		"scopes":  []string{"bills:read", "bills:read"},
+	}, &created)
+	if status != http.StatusCreated || created.Key == "" || !strings.HasPrefix(created.Key, created.Prefix) {
+		t.Fatalf("POST /admin/api-keys = %d %+v, want 201 with the key", status, created)
+	}
+	if len(created.Scopes) != 1 || created.LastUsedAt != nil {
+		t.Errorf("created key = %+v, want the deduplicated scopes and no use yet", created.APIKey)
+	}
+	stored, err := database.GetAPIKey(context.Background(), created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Hash == created.Key || stored.Hash != auth.HashAPIKey(created.Key) {
+		t.Errorf("stored hash = %q, want the hash of the key and not the key", stored.Hash)
+	}
+
+	var page models.BillPage
+	if status := doWithKey(t, server, created.Key, "GET", "/bills", nil, &page); status != http.StatusOK {
+		t.Errorf("GET /bills with a bills:read key = %d, want 200", status)
+	}
+	problem = models.Problem{}
+	status = doWithKey(t, server, created.Key, "POST", "/bills", map[string]interface{}{"title": "Receipt"}, &problem)
+	if status != http.StatusForbidden || problem.Detail != "the request requires the scope bills:write" {
+		t.Errorf("POST /bills with a bills:read key = %d %q, want 403 naming bills:write", status, problem.Detail)
+	}
+	var me models.User
+	if doWithKey(t, server, created.Key, "GET", "/me", nil, &me); me.Subject != "service:receipts" {
+		t.Errorf("GET /me with the key = %+v, want the user of the key", me)
+	}
+
+	// Listing shows the use but never the key
+	var keys []map[string]interface{}
+	if status := doAs(t, server, "admin", "GET", "/admin/api-keys", nil, &keys); status != http.StatusOK || len(keys) != 1 {
+		t.Fatalf("GET /admin/api-keys = %d %v, want the key", status, keys)
+	}
+	if keys[0]["last_used_at"] == nil || keys[0]["key"] != nil || keys[0]["key_hash"] != nil {
+		t.Errorf("listed key = %v, want a last use and neither key nor hash", keys[0])
+	}
+
+	// Revoked and unknown keys are rejected
+	keyPath := fmt.Sprintf("/admin/api-keys/%d", created.ID)
+	if status := doAs(t, server, "admin", "DELETE", keyPath, nil, nil); status != http.StatusOK {
+		t.Errorf("DELETE %s = %d, want 200", keyPath, status)
+	}
+	for _, key := range []string{created.Key, "acc_unknown"} {
+		problem = models.Problem{}
+		if status := doWithKey(t, server, key, "GET", "/bills", nil, &problem); status != http.StatusUnauthorized || problem.Code != handlers.CodeUnauthenticated {
+			t.Errorf("GET /bills with key %.8s = %d %q, want 401", key, status, problem.Code)
+		}
+	}
+	if status := doAs(t, server, "admin", "DELETE", "/admin/api-keys/999", nil, nil); status != http.StatusNotFound {
+		t.Errorf("DELETE /admin/api-keys/999 = %d, want 404", status)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	server := newTestServer(t)
+
+	var problem models.Problem
+	status := do(t, server, "POST", "/bills", map[string]interface{}{
+		"title":       " ",
+		"description": strings.Repeat("x", 2001),
+		"currency":    "EURO",
+		"due_date":    "2999-01-01",
+		"items": []map[string]interface{}{
+			{"name": "Refund", "amount": -5, "quantity": 1},
+			{"name": "", "amount": 1, "quantity": 0},
+		},
+	}, &problem)
+	if status != http.StatusBadRequest {
+		t.Fatalf("POST /bills = %d, want 400", status)
+	}
+
+	// All invalid fields are reported at once
+	var fields []string
+	for _, field := range problem.Errors {
+		fields = append(fields, field.Field)
+	}
+	want := "title description currency due_date items[0].amount items[1].name items[1].quantity"
+	if strings.Join(fields, " ") != want {
+		t.Errorf("invalid fields = %v, want %s", fields, want)
+	}
+
+	var created map[string]int64
+	do(t, server, "POST", "/bills", map[string]interface{}{"title": "Rent"}, &created)
+	path := fmt.Sprintf("/bills/%d/items", created["id"])
+	if status := do(t, server, "POST", path, map[string]interface{}{"name": "A", "amount": 1, "quantity": 10001}, &problem); status != http.StatusBadRequest {
+		t.Errorf("POST %s with quantity above the limit = %d, want 400", path, status)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "quantity" {
+		t.Errorf("invalid fields = %+v, want quantity", problem.Errors)
+	}
+}
+
+func TestProblemResponses(t *testing.T) {
+	server := newTestServer(t)
+
+	req, _ := http.NewRequest("POST", server.URL+"/fx-rates", strings.NewReader(`{"base_currency":"EUR","quote_currency":"eur","effective_date":"tomorrow"}`))
+	req.Header.Set("X-User-Subject", "admin")
+	req.Header.Set(logging.RequestIDHeader, "req-7")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != models.ProblemContentType {
+		t.Errorf("Content-Type = %q, want %q", got, models.ProblemContentType)
+	}
+	var problem models.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Type != "urn:accounts:problem:validation_failed" || problem.Title != "Bad Request" ||
+		problem.Instance != "/fx-rates" || problem.RequestID != "req-7" {
+		t.Errorf("problem = %+v", problem)
+	}
+
+	var fields []string
+	for _, field := range problem.Errors {
+		fields = append(fields, field.Field)
+	}
+	if want := "quote_currency rate effective_date"; strings.Join(fields, " ") != want {
+		t.Errorf("invalid fields = %v, want %s", fields, want)
+	}
+}
+
+func TestListBills(t *testing.T) {
+	server := newTestServer(t)
+
+	for i, title := range []string{"Water", "Power", "Phone"} {
+		body := map[string]interface{}{
+			"title":    title,
+			"due_date": fmt.Sprintf("2024-0%d-01", i+1),
+			"paid":     i == 0,
+			"items":    []map[string]interface{}{{"name": title, "amount": 10 * (i + 1), "quantity": 1}},
+		}
+		if status := do(t, server, "POST", "/bills", body, nil); status != http.StatusCreated {
+			t.Fatalf("POST /bills = %d, want 201", status)
+		}
+	}
+
+	var page models.BillPage
+	do(t, server, "GET", "/bills?limit=2", nil, &page)
+	if page.Total != 3 || len(page.Bills) != 2 || page.NextOffset == nil || *page.NextOffset != 2 {
+		t.Errorf("first page = %d of %d bills, next offset %v; want 2 of 3, next offset 2", len(page.Bills), page.Total, page.NextOffset)
+	}
+	if len(page.Bills) > 0 && page.Bills[0].Title != "Water" {
+		t.Errorf("first bill = %s, want Water (earliest due date)", page.Bills[0].Title)
+	}
+
+	do(t, server, "GET", "/bills?paid=false&sort=total&order=desc", nil, &page)
+	if page.Total != 2 || page.Bills[0].Title != "Phone" || page.NextOffset != nil {
+		t.Errorf("unpaid bills by total = %+v, want Phone first of 2", page.Bills)
+	}
+
+	var totals models.BillTotals
+	do(t, server, "GET", "/bills/totals", nil, &totals)
+	if len(totals.Currencies) != 1 || totals.Currencies[0].Total != 6000 || totals.Currencies[0].PaidTotal != 1000 {
+		t.Errorf("totals = %+v, want 60.00 USD of which 10.00 paid", totals.Currencies)
+	}
+}
+
+func TestConvertBills(t *testing.T) {
+	server := newTestServer(t)
+
+	do(t, server, "POST", "/bills", map[string]interface{}{
+		"title":    "Hotel",
+		"currency": "EUR",
+		"items":    []map[string]interface{}{{"name": "Night", "amount": 100, "quantity": 1}},
+	}, nil)
+
+	// Without a rate the conversion fails
+	if status := do(t, server, "GET", "/bills?convert_to=USD", nil, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("GET /bills?convert_to=USD without rates = %d, want 422", status)
+	}
+
+	rate := map[string]interface{}{"base_currency": "EUR", "quote_currency": "USD", "rate": "1.0832", "effective_date": "2024-01-01"}
+	if status := doAs(t, server, "admin", "POST", "/fx-rates", rate, nil); status != http.StatusCreated {
+		t.Fatalf("POST /fx-rates = %d, want 201", status)
+	}
+	if status := doAs(t, server, "admin", "POST", "/fx-rates", rate, nil); status != http.StatusConflict {
+		t.Errorf("POST duplicate /fx-rates = %d, want 409", status)
+	}
+
+	var page models.BillPage
+	do(t, server, "GET", "/bills?convert_to=USD&as_of=2024-06-01", nil, &page)
+	if len(page.Bills) != 1 || page.Bills[0].Converted == nil || page.Bills[0].Converted.Total != 10832 {
+		t.Errorf("converted bills = %+v, want 108.32 USD", page.Bills)
+	}
+
+	// The inverse rate is used for the opposite direction
+	do(t, server, "POST", "/bills", map[string]interface{}{
+		"title":    "Taxi",
+		"currency": "USD",
+		"items":    []map[string]interface{}{{"name": "Ride", "amount": 10.83, "quantity": 1}},
+	}, nil)
+	var totals models.BillTotals
+	do(t, server, "GET", "/bills/totals?convert_to=EUR&as_of=2024-06-01", nil, &totals)
+	if totals.Total == nil || *totals.Total != 11000 {
+		t.Errorf("total in EUR = %v, want 110.00", totals.Total)
+	}
+
+	// Currencies without cents are converted into whole amounts
+	rate = map[string]interface{}{"base_currency": "USD", "quote_currency": "JPY", "rate": "151.234", "effective_date": "2024-01-01"}
+	if status := doAs(t, server, "admin", "POST", "/fx-rates", rate, nil); status != http.StatusCreated {
+		t.Fatalf("POST /fx-rates to JPY = %d, want 201", status)
+	}
+	page = models.BillPage{}
+	do(t, server, "GET", "/bills?currency=USD&convert_to=JPY&as_of=2024-06-01", nil, &page)
+	if len(page.Bills) != 1 || page.Bills[0].Converted == nil || page.Bills[0].Converted.Total != 163800 {
+		t.Errorf("converted bills = %+v, want 1638 JPY", page.Bills)
+	}
+
+	var problem models.Problem
+	status := do(t, server, "POST", "/bills", map[string]interface{}{"title": "Ramen", "currency": "JPY", "items": []map[string]interface{}{{"name": "Bowl", "amount": 980.5, "quantity": 1}}}, &problem)
+	if status != http.StatusBadRequest || len(problem.Errors) != 1 || problem.Errors[0].Field != "items[0].amount" {
+		t.Errorf("POST /bills with a fraction of JPY = %d %+v, want 400 on items[0].amount", status, problem.Errors)
+	}
+	var created map[string]int64
+	if status := do(t, server, "POST", "/bills", map[string]interface{}{"title": "Ramen", "currency": "JPY", "items": []map[string]interface{}{{"name": "Bowl", "amount": 980, "quantity": 1}}}, &created); status != http.StatusCreated {
+		t.Fatalf("POST /bills in JPY = %d, want 201", status)
+	}
+	paymentsPath := fmt.Sprintf("/bills/%d/payments", created["id"])
+	if status := do(t, server, "POST", paymentsPath, map[string]interface{}{"amount": 0.5}, nil); status != http.StatusBadRequest {
+		t.Errorf("POST %s with a fraction of JPY = %d, want 400", paymentsPath, status)
+	}
+
+	problem = models.Problem{}
+	do(t, server, "POST", "/bills", map[string]interface{}{"title": "Souq", "currency": "BHD"}, &problem)
+	if len(problem.Errors) != 1 || !strings.Contains(problem.Errors[0].Message, "not supported") {
+		t.Errorf("POST /bills in BHD = %+v, want currency not supported", problem.Errors)
+	}
+}
+
+// hangingDB is an in-memory database whose GetBills blocks until its context is done
+type hangingDB struct {
+	*db.MemoryDB
+}
+
+func (h hangingDB) GetBills(ctx context.Context, userID int64, query *models.BillQuery) ([]models.BillSummary, int, error) {
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func TestFXRateWrites(t *testing.T) {
+	server := newTestServer(t)
+
+	// Rates are shared by all users, so only admins change them
+	rate := map[string]interface{}{"base_currency": "EUR", "quote_currency": "USD", "rate": "1.0832", "effective_date": "2024-01-01"}
+	var created map[string]int64
+	if status := doAs(t, server, "admin", "POST", "/fx-rates", rate, &created); status != http.StatusCreated {
+		t.Fatalf("POST /fx-rates as an admin = %d, want 201", status)
+	}
+	ratePath := fmt.Sprintf("/fx-rates/%d", created["id"])
+
+	// Rates are plain decimals between currencies with at most two decimal
+	// places
+	for _, invalid := range []map[string]interface{}{
+		{"base_currency": "EUR", "quote_currency": "USD", "rate": "1/3", "effective_date": "2024-02-01"},
+		{"base_currency": "EUR", "quote_currency": "USD", "rate": "1e3", "effective_date": "2024-02-01"},
+		{"base_currency": "EUR", "quote_currency": "USD", "rate": "1e999999999", "effective_date": "2024-02-01"},
+		{"base_currency": "EUR", "quote_currency": "USD", "rate": "0.00000000001", "effective_date": "2024-02-01"},
+		{"base_currency": "KWD", "quote_currency": "USD", "rate": "3.25", "effective_date": "2024-02-01"},
+		{"base_currency": "ABC", "quote_currency": "USD", "rate": "2", "effective_date": "2024-02-01"},
+	} {
+		if status := doAs(t, server, "admin", "POST", "/fx-rates", invalid, nil); status != http.StatusBadRequest {
+			t.Errorf("POST /fx-rates with %v = %d, want 400", invalid, status)
+		}
+	}
+
+	changed := map[string]interface{}{"base_currency": "EUR", "quote_currency": "USD", "rate": "100", "effective_date": "2024-01-01"}
+
+	for _, tt := range []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"POST", "/fx-rates", map[string]interface{}{"base_currency": "EUR", "quote_currency": "USD", "rate": "2", "effective_date": "2024-02-01"}},
+		{"PUT", ratePath, changed},
+		{"DELETE", ratePath, nil},
+	} {
+		var problem models.Problem
+		status := doAs(t, server, "alice", tt.method, tt.path, tt.body, &problem)
+		if status != http.StatusForbidden || problem.Detail != "the request requires the scope fx-rates:write" {
+			t.Errorf("%s %s as a user = %d %q, want 403 naming fx-rates:write", tt.method, tt.path, status, problem.Detail)
+		}
+	}
+
+	// ... and everyone reads them
+	var rates []models.FXRate
+	if status := doAs(t, server, "alice", "GET", "/fx-rates", nil, &rates); status != http.StatusOK || len(rates) != 1 || rates[0].Rate != "1.0832" {
+		t.Errorf("GET /fx-rates as a user = %d %+v, want the unchanged rate", status, rates)
+	}
+	if status := doAs(t, server, "admin", "PUT", ratePath, changed, nil); status != http.StatusOK {
+		t.Errorf("PUT %s as an admin = %d, want 200", ratePath, status)
+	}
+	if status := doAs(t, server, "admin", "DELETE", ratePath, nil, nil); status != http.StatusOK {
+		t.Errorf("DELETE %s as an admin = %d, want 200", ratePath, status)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	server := newTestServerWithDB(t, db.WithQueryTimeout(hangingDB{db.NewMemoryDB()}, 10*time.Millisecond))
+
+	var problem models.Problem
+	if status := do(t, server, "GET", "/bills", nil, &problem); status != http.StatusGatewayTimeout {
+		t.Errorf("GET /bills on a hanging database = %d, want 504", status)
+	}
+	if problem.Code != handlers.CodeTimeout {
+		t.Errorf("code = %q, want %q", problem.Code, handlers.CodeTimeout)
+	}
+
+	// Other operations are not affected
+	if status := do(t, server, "POST", "/bills", map[string]interface{}{"title": "Rent"}, nil); status != http.StatusCreated {
+		t.Errorf("POST /bills = %d, want 201", status)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	database := db.NewMemoryDB()
+	health := handlers.NewHealthHandler(database, nil)
+	server := httptest.NewServer(newRouter(database, testAuthenticator, testAdmins, models.DefaultValidationLimits(), health, metrics.New()))
+	t.Cleanup(server.Close)
+
+	var status models.HealthStatus
+	if code := do(t, server, "GET", "/healthz", nil, &status); code != http.StatusOK || status.Status != models.HealthOK {
+		t.Errorf("GET /healthz = %d %+v, want 200 ok", code, status)
+	}
+
+	status = models.HealthStatus{}
+	if code := do(t, server, "GET", "/readyz", nil, &status); code != http.StatusOK || status.Status != models.HealthOK {
+		t.Errorf("GET /readyz = %d %+v, want 200 ok", code, status)
+	}
+	if status.Checks["migrations"].Status != models.HealthSkipped {
+		t.Errorf("migrations check = %+v, want skipped for the in-memory database", status.Checks["migrations"])
+	}
+
+	// Not ready once shutting down, but still alive
+	health.SetShuttingDown()
+	status = models.HealthStatus{}
+	if code := do(t, server, "GET", "/readyz", nil, &status); code != http.StatusServiceUnavailable || status.Checks["shutdown"].Status != models.HealthFail {
+		t.Errorf("GET /readyz while shutting down = %d %+v, want 503 with a failed shutdown check", code, status)
+	}
+	if code := do(t, server, "GET", "/healthz", nil, nil); code != http.StatusOK {
+		t.Errorf("GET /healthz while shutting down = %d, want 200", code)
+	}
+}
+
+func TestReadinessMigrations(t *testing.T) {
+	database, err := db.NewSQLiteDB(&config.Config{DBPath: filepath.Join(t.TempDir(), "accounts.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	server := httptest.NewServer(newRouter(database, testAuthenticator, testAdmins, models.DefaultValidationLimits(), handlers.NewHealthHandler(database, database.Migrator()), metrics.New()))
+	t.Cleanup(server.Close)
+
+	// Not ready until the schema is migrated
+	var status models.HealthStatus
+	if code := do(t, server, "GET", "/readyz", nil, &status); code != http.StatusServiceUnavailable || status.Checks["migrations"].Status != models.HealthFail {
+		t.Errorf("GET /readyz before migrating = %d %+v, want 503 with a failed migrations check", code, status)
+	}
+	if status.Checks["database"].Status != models.HealthOK {
+		t.Errorf("database check = %+v, want ok", status.Checks["database"])
+	}
+	// The probe only reads, so it does not create the tracking table
+	var tables int
+	database.DB().QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'").Scan(&tables)
+	if tables != 0 {
+		t.Errorf("schema_migrations tables after a probe = %d, want 0", tables)
+	}
+
+	if err := database.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	status = models.HealthStatus{}
+	if code := do(t, server, "GET", "/readyz", nil, &status); code != http.StatusOK {
+		t.Errorf("GET /readyz after migrating = %d %+v, want 200", code, status)
+	}
+
+	// Not ready once the database is gone
+	database.Close()
+	status = models.HealthStatus{}
+	if code := do(t, server, "GET", "/readyz", nil, &status); code != http.StatusServiceUnavailable || status.Checks["database"].Status != models.HealthFail {
+		t.Errorf("GET /readyz after closing the database = %d %+v, want 503 with a failed database check", code, status)
+	}
+	// The errors of the driver are logged, not shown to the unauthenticated caller
+	if detail := status.Checks["database"].Detail; detail != "database is unreachable" {
+		t.Errorf("database check detail = %q, want a generic one", detail)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	server := newTestServer(t)
+
+	do(t, server, "GET", "/bills/999", nil, nil)
+
+	// The gauges cover all users, so only admins and keys with metrics:read
+	// read them
+	if status := doAs(t, server, "", "GET", "/metrics", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("GET /metrics without credentials = %d, want 401", status)
+	}
+	if status := do(t, server, "GET", "/metrics", nil, nil); status != http.StatusForbidden {
+		t.Errorf("GET /metrics as a user = %d, want 403", status)
+	}
+	if status := doAs(t, server, "admin", "GET", "/metrics", nil, nil); status != http.StatusOK {
+		t.Errorf("GET /metrics as an admin = %d, want 200", status)
+	}
+
+	var created models.CreatedAPIKey
+	status := doAs(t, server, "admin", "POST", "/admin/api-keys", map[string]interface{}{
+		"name":   "Prometheus",
+		"scopes": []string{"metrics:read"},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /admin/api-keys = %d, want 201", status)
+	}
+	if status := doWithKey(t, server, created.Key, "GET", "/bills", nil, nil); status != http.StatusForbidden {
+		t.Errorf("GET /bills with the metrics key = %d, want 403", status)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/metrics", nil)
+	req.Header.Set(auth.APIKeyHeader, created.Key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /metrics with the metrics key = %d, want 200", resp.StatusCode)
+	}
+	want := `accounts_http_requests_total{method="GET",route="/bills/{id}",status="404"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("metrics do not contain %s", want)
+	}
+}
+
+// brokenDB is an in-memory database whose GetBill fails with a driver error
+type brokenDB struct {
+	*db.MemoryDB
+}
+
+func (b brokenDB) GetBill(ctx context.Context, userID, id int64) (*models.Bill, error) {
+	return nil, errors.New(`pq: relation "bills" does not exist`)
+}
+
+func TestInternalErrorsAreLogged(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	var logs bytes.Buffer
+	logging.Setup(&config.Config{LogFormat: "json"}, &logs)
+
+	server := newTestServerWithDB(t, brokenDB{db.NewMemoryDB()})
+
+	req, _ := http.NewRequest("GET", server.URL+"/bills/1", nil)
+	req.Header.Set("X-User-Subject", "alice")
+	req.Header.Set(logging.RequestIDHeader, "req-42")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("GET /bills/1 = %d, want 500", resp.StatusCode)
+	}
+	if strings.Contains(string(body), "relation") || !strings.Contains(string(body), `"code":"internal_error"`) {
+		t.Errorf("response %s reveals the database error", body)
+	}
+	if resp.Header.Get(logging.RequestIDHeader) != "req-42" {
+		t.Errorf("X-Request-ID = %q, want req-42", resp.Header.Get(logging.RequestIDHeader))
+	}
+	if !strings.Contains(logs.String(), `"error":"pq: relation \"bills\" does not exist"`) || !strings.Contains(logs.String(), `"request_id":"req-42"`) {
+		t.Errorf("logs do not contain the database error with the request ID:\n%s", logs.String())
+	}
+}
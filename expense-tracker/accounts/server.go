@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/config"
+)
+
+// newServer creates the HTTP server with the configured timeouts
+func newServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// delayShutdown returns a context that is done delay after ctx is done.
+// onShutdown is called as soon as ctx is done, so the server can report that
+// it is not ready while it still accepts requests during the delay.
+func delayShutdown(ctx context.Context, delay time.Duration, onShutdown func()) context.Context {
+	delayed, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		<-ctx.Done()
+		onShutdown()
+		if delay > 0 {
+			slog.Info("Shutdown requested, failing readiness before draining", "delay", delay.String())
+			time.Sleep(delay)
+		}
+	}()
+	return delayed
+}
+
+// serve accepts connections on listener until ctx is done and then shuts the
+// server down gracefully: it stops accepting new connections and waits up to
+// grace for in-flight requests to finish before closing the remaining ones.
+func serve(ctx context.Context, server *http.Server, listener net.Listener, grace time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		// The server failed before a shutdown was requested
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, waiting for in-flight requests", "grace_period", grace.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Grace period expired, closing remaining connections")
+		server.Close()
+		return err
+	}
+
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	slog.Info("All in-flight requests finished")
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/config"
+)
+
+// startServer serves handler on a local port until the returned context is cancelled
+func startServer(t *testing.T, handler http.Handler, grace time.Duration) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, newServer(&config.Config{}, handler), listener, grace)
+	}()
+	return "http://" + listener.Addr().String(), cancel, done
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	url, cancel, done := startServer(t, handler, 5*time.Second)
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Error(err)
+			close(responses)
+			return
+		}
+		responses <- resp
+	}()
+
+	// Shut down while the request is in flight
+	<-started
+	cancel()
+
+	// New connections are refused once shutdown has begun
+	time.Sleep(50 * time.Millisecond)
+	if resp, err := http.Get(url); err == nil {
+		resp.Body.Close()
+		t.Error("request after shutdown succeeded")
+	}
+
+	// The in-flight request still completes
+	close(release)
+	resp, ok := <-responses
+	if !ok {
+		return
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "done" {
+		t.Errorf("in-flight request = %d %q, want 200 \"done\"", resp.StatusCode, body)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("serve = %v, want nil", err)
+	}
+}
+
+func TestShutdownGracePeriod(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	url, cancel, done := startServer(t, handler, 50*time.Millisecond)
+
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("serve = nil, want the grace period error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the grace period")
+	}
+}
+
+func TestDelayShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	notified := make(chan struct{})
+	delayed := delayShutdown(ctx, 50*time.Millisecond, func() { close(notified) })
+
+	cancel()
+	<-notified
+	if delayed.Err() != nil {
+		t.Error("delayed context done before the delay")
+	}
+
+	select {
+	case <-delayed.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("delayed context not done after the delay")
+	}
+}
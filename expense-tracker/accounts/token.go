@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/auth"
+	"github.com/jo/choreo-tutorial/accounts/config"
+)
+
+const tokenUsage = `usage: accounts token -sub SUBJECT [flags]
+
+Issues a JWT for local development and tests, offline and without an
+identity provider. The token is signed with AUTH_HS256_SECRET, or with the
+ES256 key in -key, which is generated on first use; its public key is written
+to -jwks for AUTH_JWKS_FILE. The issuer and audience are AUTH_ISSUER and
+AUTH_AUDIENCE.
+
+flags:`
+
+// runToken runs the token subcommand
+func runToken(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	subject := flags.String("sub", "", "subject of the user (required)")
+	email := flags.String("email", "", "email claim")
+	name := flags.String("name", "", "name claim")
+	ttl := flags.Duration("ttl", time.Hour, "lifetime of the token")
+	keyFile := flags.String("key", "", "PEM file of the ES256 signing key; HS256 with AUTH_HS256_SECRET if empty")
+	jwksFile := flags.String("jwks", "dev-jwks.json", "file the JWKS of the signing key is written to")
+
+	if err := flags.Parse(args); err != nil || *subject == "" {
+		flags.SetOutput(os.Stderr)
+		fmt.Fprintln(os.Stderr, tokenUsage)
+		flags.PrintDefaults()
+		return errors.New("invalid arguments")
+	}
+	if cfg.AuthIssuer == "" || cfg.AuthAudience == "" {
+		return errors.New("AUTH_ISSUER and AUTH_AUDIENCE are required")
+	}
+
+	var signer *auth.Signer
+	if *keyFile != "" {
+		key, err := auth.LoadOrCreateKeyFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		if signer, err = auth.NewKeySigner(key, cfg.AuthIssuer, cfg.AuthAudience); err != nil {
+			return err
+		}
+		jwks, err := signer.JWKS()
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(jwks, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*jwksFile, append(data, '\n'), 0o644); err != nil {
+			return err
+		}
+	} else {
+		if len(cfg.AuthHS256Secret) < auth.MinHS256SecretLength {
+			return fmt.Errorf("-key or an AUTH_HS256_SECRET of at least %d bytes is required", auth.MinHS256SecretLength)
+		}
+		signer = auth.NewHS256Signer([]byte(cfg.AuthHS256Secret), cfg.AuthIssuer, cfg.AuthAudience)
+	}
+
+	claims := auth.Claims{}
+	if *email != "" {
+		claims["email"] = *email
+	}
+	if *name != "" {
+		claims["name"] = *name
+	}
+	token, err := signer.Sign(*subject, *ttl, claims)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
@@ -0,0 +1,580 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes of the database layer
+const (
+	userIDKey          = "accounts.user.id"
+	apiKeyIDKey        = "accounts.api_key.id"
+	billIDKey          = "accounts.bill.id"
+	billItemIDKey      = "accounts.bill_item.id"
+	paymentIDKey       = "accounts.payment.id"
+	ledgerIDKey        = "accounts.ledger.id"
+	memberIDKey        = "accounts.member.id"
+	shareTypeKey       = "accounts.share.type"
+	categoryIDKey      = "accounts.category.id"
+	tagIDKey           = "accounts.tag.id"
+	merchantIDKey      = "accounts.merchant.id"
+	recurringBillIDKey = "accounts.recurring_bill.id"
+	fxRateIDKey        = "accounts.fx_rate.id"
+	resultKey          = "accounts.db.result"
+)
+
+// tracedDB wraps a Database and records a span for every operation
+type tracedDB struct {
+	db     db.Database
+	tracer trace.Tracer
+	system attribute.KeyValue
+}
+
+// InstrumentDB returns a Database that records a span for every operation of
+// database. The statements a SQL backend runs for an operation appear as its
+// child spans. dbType is the configured database type, e.g. "sqlite".
+func InstrumentDB(database db.Database, dbType string) db.Database {
+	system := dbType
+	if dbType == "postgres" {
+		system = "postgresql"
+	}
+	return &tracedDB{
+		db:     database,
+		tracer: otel.Tracer(instrumentationName),
+		system: attribute.String("db.system", system),
+	}
+}
+
+// start starts the span of an operation
+func (t *tracedDB) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "db."+operation,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(append(attrs, t.system)...),
+	)
+}
+
+// end ends the span of an operation; it is deferred with a pointer to the
+// named error result, so it sees the error that is returned. Missing and
+// conflicting records, invalid input and insufficient roles are expected
+// outcomes and do not mark the span as failed.
+func (t *tracedDB) end(span trace.Span, err *error) {
+	switch {
+	case *err == nil:
+	case errors.Is(*err, db.ErrNotFound):
+		span.SetAttributes(attribute.String(resultKey, "not_found"))
+	case errors.Is(*err, db.ErrConflict):
+		span.SetAttributes(attribute.String(resultKey, "conflict"))
+	case errors.Is(*err, db.ErrValidation):
+		span.SetAttributes(attribute.String(resultKey, "invalid"))
+	case errors.Is(*err, db.ErrForbidden):
+		span.SetAttributes(attribute.String(resultKey, "forbidden"))
+	default:
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// GetBills returns the bills matching the query with summary information
+func (t *tracedDB) GetBills(ctx context.Context, userID int64, query *models.BillQuery) (bills []models.BillSummary, total int, err error) {
+	ctx, span := t.start(ctx, "GetBills", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.GetBills(ctx, userID, query)
+}
+
+// GetBill returns a single bill with all its items
+func (t *tracedDB) GetBill(ctx context.Context, userID, id int64) (bill *models.Bill, err error) {
+	ctx, span := t.start(ctx, "GetBill", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetBill(ctx, userID, id)
+}
+
+// CreateBill creates a new bill and its items
+func (t *tracedDB) CreateBill(ctx context.Context, userID int64, bill *models.BillInput) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateBill", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.CreateBill(ctx, userID, bill)
+}
+
+// UpdateBill updates an existing bill and its items
+func (t *tracedDB) UpdateBill(ctx context.Context, userID, id int64, bill *models.BillInput) (err error) {
+	ctx, span := t.start(ctx, "UpdateBill", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, id))
+	defer t.end(span, &err)
+	return t.db.UpdateBill(ctx, userID, id, bill)
+}
+
+// SetBillPaid marks a bill as paid or unpaid
+func (t *tracedDB) SetBillPaid(ctx context.Context, userID, id int64, paid bool) (err error) {
+	ctx, span := t.start(ctx, "SetBillPaid", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, id))
+	defer t.end(span, &err)
+	return t.db.SetBillPaid(ctx, userID, id, paid)
+}
+
+// DeleteBill deletes a bill and its items
+func (t *tracedDB) DeleteBill(ctx context.Context, userID, id int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteBill", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, id))
+	defer t.end(span, &err)
+	return t.db.DeleteBill(ctx, userID, id)
+}
+
+// GetBillTotals returns the totals of the bills matching the query grouped by currency
+func (t *tracedDB) GetBillTotals(ctx context.Context, userID int64, query *models.BillQuery) (totals []models.CurrencyTotal, err error) {
+	ctx, span := t.start(ctx, "GetBillTotals", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.GetBillTotals(ctx, userID, query)
+}
+
+// GetBillItems returns all items for a bill
+func (t *tracedDB) GetBillItems(ctx context.Context, userID, billID int64) (items []models.BillItem, err error) {
+	ctx, span := t.start(ctx, "GetBillItems", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, billID))
+	defer t.end(span, &err)
+	return t.db.GetBillItems(ctx, userID, billID)
+}
+
+// GetBillItem returns a single bill item
+func (t *tracedDB) GetBillItem(ctx context.Context, userID, id int64) (item *models.BillItem, err error) {
+	ctx, span := t.start(ctx, "GetBillItem", attribute.Int64(userIDKey, userID), attribute.Int64(billItemIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetBillItem(ctx, userID, id)
+}
+
+// CreateBillItem creates a new bill item
+func (t *tracedDB) CreateBillItem(ctx context.Context, userID, billID int64, item *models.BillItemInput) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateBillItem", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, billID))
+	defer t.end(span, &err)
+	return t.db.CreateBillItem(ctx, userID, billID, item)
+}
+
+// UpdateBillItem updates an existing bill item
+func (t *tracedDB) UpdateBillItem(ctx context.Context, userID, id int64, item *models.BillItemInput) (err error) {
+	ctx, span := t.start(ctx, "UpdateBillItem", attribute.Int64(userIDKey, userID), attribute.Int64(billItemIDKey, id))
+	defer t.end(span, &err)
+	return t.db.UpdateBillItem(ctx, userID, id, item)
+}
+
+// DeleteBillItem deletes a bill item
+func (t *tracedDB) DeleteBillItem(ctx context.Context, userID, id int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteBillItem", attribute.Int64(userIDKey, userID), attribute.Int64(billItemIDKey, id))
+	defer t.end(span, &err)
+	return t.db.DeleteBillItem(ctx, userID, id)
+}
+
+// GetBillPayments returns the payments of a bill
+func (t *tracedDB) GetBillPayments(ctx context.Context, userID, billID int64) (payments []models.Payment, err error) {
+	ctx, span := t.start(ctx, "GetBillPayments", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, billID))
+	defer t.end(span, &err)
+	return t.db.GetBillPayments(ctx, userID, billID)
+}
+
+// GetBillPayment returns a single payment of a bill
+func (t *tracedDB) GetBillPayment(ctx context.Context, userID, billID, id int64) (payment *models.Payment, err error) {
+	ctx, span := t.start(ctx, "GetBillPayment", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, billID), attribute.Int64(paymentIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetBillPayment(ctx, userID, billID, id)
+}
+
+// CreateBillPayment records a payment of a bill
+func (t *tracedDB) CreateBillPayment(ctx context.Context, userID, billID int64, payment *models.PaymentInput) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateBillPayment", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, billID))
+	defer t.end(span, &err)
+	return t.db.CreateBillPayment(ctx, userID, billID, payment)
+}
+
+// UpdateBillPayment updates a payment of a bill
+func (t *tracedDB) UpdateBillPayment(ctx context.Context, userID, billID, id int64, payment *models.PaymentInput) (err error) {
+	ctx, span := t.start(ctx, "UpdateBillPayment", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, billID), attribute.Int64(paymentIDKey, id))
+	defer t.end(span, &err)
+	return t.db.UpdateBillPayment(ctx, userID, billID, id, payment)
+}
+
+// DeleteBillPayment deletes a payment of a bill
+func (t *tracedDB) DeleteBillPayment(ctx context.Context, userID, billID, id int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteBillPayment", attribute.Int64(userIDKey, userID), attribute.Int64(billIDKey, billID), attribute.Int64(paymentIDKey, id))
+	defer t.end(span, &err)
+	return t.db.DeleteBillPayment(ctx, userID, billID, id)
+}
+
+// EnsureUser returns the user with the subject of the input, creating it on first use
+func (t *tracedDB) EnsureUser(ctx context.Context, user *models.UserInput) (stored *models.User, err error) {
+	ctx, span := t.start(ctx, "EnsureUser")
+	defer t.end(span, &err)
+	return t.db.EnsureUser(ctx, user)
+}
+
+// GetUser returns a single user
+func (t *tracedDB) GetUser(ctx context.Context, id int64) (user *models.User, err error) {
+	ctx, span := t.start(ctx, "GetUser", attribute.Int64(userIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetUser(ctx, id)
+}
+
+// FindUserBySubject returns the user with the subject without creating it
+func (t *tracedDB) FindUserBySubject(ctx context.Context, subject string) (user *models.User, err error) {
+	ctx, span := t.start(ctx, "FindUserBySubject")
+	defer t.end(span, &err)
+	return t.db.FindUserBySubject(ctx, subject)
+}
+
+// FindUserByEmail returns the only user with the verified email
+func (t *tracedDB) FindUserByEmail(ctx context.Context, email string) (user *models.User, err error) {
+	ctx, span := t.start(ctx, "FindUserByEmail")
+	defer t.end(span, &err)
+	return t.db.FindUserByEmail(ctx, email)
+}
+
+// GetLedgers returns the ledgers the user has a role on
+func (t *tracedDB) GetLedgers(ctx context.Context, userID int64) (ledgers []models.Ledger, err error) {
+	ctx, span := t.start(ctx, "GetLedgers", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.GetLedgers(ctx, userID)
+}
+
+// GetLedger returns a single ledger
+func (t *tracedDB) GetLedger(ctx context.Context, userID, id int64) (ledger *models.Ledger, err error) {
+	ctx, span := t.start(ctx, "GetLedger", attribute.Int64(userIDKey, userID), attribute.Int64(ledgerIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetLedger(ctx, userID, id)
+}
+
+// CreateLedger creates a new ledger owned by the user
+func (t *tracedDB) CreateLedger(ctx context.Context, userID int64, ledger *models.LedgerInput) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateLedger", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.CreateLedger(ctx, userID, ledger)
+}
+
+// UpdateLedger renames a ledger
+func (t *tracedDB) UpdateLedger(ctx context.Context, userID, id int64, ledger *models.LedgerInput) (err error) {
+	ctx, span := t.start(ctx, "UpdateLedger", attribute.Int64(userIDKey, userID), attribute.Int64(ledgerIDKey, id))
+	defer t.end(span, &err)
+	return t.db.UpdateLedger(ctx, userID, id, ledger)
+}
+
+// DeleteLedger deletes a ledger, keeping its bills
+func (t *tracedDB) DeleteLedger(ctx context.Context, userID, id int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteLedger", attribute.Int64(userIDKey, userID), attribute.Int64(ledgerIDKey, id))
+	defer t.end(span, &err)
+	return t.db.DeleteLedger(ctx, userID, id)
+}
+
+// GetShares returns the shares of a bill or ledger
+func (t *tracedDB) GetShares(ctx context.Context, userID int64, target models.ShareTarget) (shares []models.Share, err error) {
+	ctx, span := t.start(ctx, "GetShares", shareAttributes(userID, target)...)
+	defer t.end(span, &err)
+	return t.db.GetShares(ctx, userID, target)
+}
+
+// CreateShare shares a bill or ledger with a member in a role
+func (t *tracedDB) CreateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) (err error) {
+	ctx, span := t.start(ctx, "CreateShare", append(shareAttributes(userID, target), attribute.Int64(memberIDKey, memberID))...)
+	defer t.end(span, &err)
+	return t.db.CreateShare(ctx, userID, target, memberID, role)
+}
+
+// UpdateShare changes the role of a member of a bill or ledger
+func (t *tracedDB) UpdateShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64, role models.Role) (err error) {
+	ctx, span := t.start(ctx, "UpdateShare", append(shareAttributes(userID, target), attribute.Int64(memberIDKey, memberID))...)
+	defer t.end(span, &err)
+	return t.db.UpdateShare(ctx, userID, target, memberID, role)
+}
+
+// DeleteShare revokes the share of a member of a bill or ledger
+func (t *tracedDB) DeleteShare(ctx context.Context, userID int64, target models.ShareTarget, memberID int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteShare", append(shareAttributes(userID, target), attribute.Int64(memberIDKey, memberID))...)
+	defer t.end(span, &err)
+	return t.db.DeleteShare(ctx, userID, target, memberID)
+}
+
+// shareAttributes returns the span attributes of an operation on the
+// shares of a bill or ledger
+func shareAttributes(userID int64, target models.ShareTarget) []attribute.KeyValue {
+	targetKey := billIDKey
+	if target.Type == models.ShareTypeLedger {
+		targetKey = ledgerIDKey
+	}
+	return []attribute.KeyValue{
+		attribute.Int64(userIDKey, userID),
+		attribute.String(shareTypeKey, target.Type),
+		attribute.Int64(targetKey, target.ID),
+	}
+}
+
+// GetAPIKeys returns all API keys, including revoked ones
+func (t *tracedDB) GetAPIKeys(ctx context.Context) (keys []models.APIKey, err error) {
+	ctx, span := t.start(ctx, "GetAPIKeys")
+	defer t.end(span, &err)
+	return t.db.GetAPIKeys(ctx)
+}
+
+// GetAPIKey returns a single API key
+func (t *tracedDB) GetAPIKey(ctx context.Context, id int64) (key *models.APIKey, err error) {
+	ctx, span := t.start(ctx, "GetAPIKey", attribute.Int64(apiKeyIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetAPIKey(ctx, id)
+}
+
+// FindAPIKey returns the API key with the hash, including revoked keys. The
+// hash is not recorded; it identifies the key.
+func (t *tracedDB) FindAPIKey(ctx context.Context, hash string) (key *models.APIKey, err error) {
+	ctx, span := t.start(ctx, "FindAPIKey")
+	defer t.end(span, &err)
+	return t.db.FindAPIKey(ctx, hash)
+}
+
+// CreateAPIKey stores a new API key
+func (t *tracedDB) CreateAPIKey(ctx context.Context, key *models.APIKey) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateAPIKey", attribute.Int64(userIDKey, key.UserID))
+	defer t.end(span, &err)
+	return t.db.CreateAPIKey(ctx, key)
+}
+
+// RevokeAPIKey marks an API key as revoked
+func (t *tracedDB) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	ctx, span := t.start(ctx, "RevokeAPIKey", attribute.Int64(apiKeyIDKey, id))
+	defer t.end(span, &err)
+	return t.db.RevokeAPIKey(ctx, id)
+}
+
+// TouchAPIKey records that an API key was used now
+func (t *tracedDB) TouchAPIKey(ctx context.Context, id int64) (err error) {
+	ctx, span := t.start(ctx, "TouchAPIKey", attribute.Int64(apiKeyIDKey, id))
+	defer t.end(span, &err)
+	return t.db.TouchAPIKey(ctx, id)
+}
+
+// GetCategories returns the default categories and those of the user
+func (t *tracedDB) GetCategories(ctx context.Context, userID int64) (categories []models.Category, err error) {
+	ctx, span := t.start(ctx, "GetCategories", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.GetCategories(ctx, userID)
+}
+
+// GetCategory returns a single category
+func (t *tracedDB) GetCategory(ctx context.Context, userID, id int64) (category *models.Category, err error) {
+	ctx, span := t.start(ctx, "GetCategory", attribute.Int64(userIDKey, userID), attribute.Int64(categoryIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetCategory(ctx, userID, id)
+}
+
+// CreateCategory creates a new category owned by the user
+func (t *tracedDB) CreateCategory(ctx context.Context, userID int64, category *models.CategoryInput) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateCategory", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.CreateCategory(ctx, userID, category)
+}
+
+// UpdateCategory renames and moves a category of the user
+func (t *tracedDB) UpdateCategory(ctx context.Context, userID, id int64, category *models.CategoryInput) (err error) {
+	ctx, span := t.start(ctx, "UpdateCategory", attribute.Int64(userIDKey, userID), attribute.Int64(categoryIDKey, id))
+	defer t.end(span, &err)
+	return t.db.UpdateCategory(ctx, userID, id, category)
+}
+
+// DeleteCategory deletes a category of the user and its subcategories
+func (t *tracedDB) DeleteCategory(ctx context.Context, userID, id int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteCategory", attribute.Int64(userIDKey, userID), attribute.Int64(categoryIDKey, id))
+	defer t.end(span, &err)
+	return t.db.DeleteCategory(ctx, userID, id)
+}
+
+// GetTags returns the tags of the user
+func (t *tracedDB) GetTags(ctx context.Context, userID int64) (tags []models.Tag, err error) {
+	ctx, span := t.start(ctx, "GetTags", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.GetTags(ctx, userID)
+}
+
+// GetTag returns a single tag
+func (t *tracedDB) GetTag(ctx context.Context, userID, id int64) (tag *models.Tag, err error) {
+	ctx, span := t.start(ctx, "GetTag", attribute.Int64(userIDKey, userID), attribute.Int64(tagIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetTag(ctx, userID, id)
+}
+
+// CreateTag creates a new tag owned by the user
+func (t *tracedDB) CreateTag(ctx context.Context, userID int64, tag *models.TagInput) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateTag", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.CreateTag(ctx, userID, tag)
+}
+
+// UpdateTag renames a tag of the user
+func (t *tracedDB) UpdateTag(ctx context.Context, userID, id int64, tag *models.TagInput) (err error) {
+	ctx, span := t.start(ctx, "UpdateTag", attribute.Int64(userIDKey, userID), attribute.Int64(tagIDKey, id))
+	defer t.end(span, &err)
+	return t.db.UpdateTag(ctx, userID, id, tag)
+}
+
+// DeleteTag deletes a tag of the user, removing it from its bills and items
+func (t *tracedDB) DeleteTag(ctx context.Context, userID, id int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteTag", attribute.Int64(userIDKey, userID), attribute.Int64(tagIDKey, id))
+	defer t.end(span, &err)
+	return t.db.DeleteTag(ctx, userID, id)
+}
+
+// GetMerchants returns the merchants of the user
+func (t *tracedDB) GetMerchants(ctx context.Context, userID int64) (merchants []models.Merchant, err error) {
+	ctx, span := t.start(ctx, "GetMerchants", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.GetMerchants(ctx, userID)
+}
+
+// GetMerchant returns a single merchant
+func (t *tracedDB) GetMerchant(ctx context.Context, userID, id int64) (merchant *models.Merchant, err error) {
+	ctx, span := t.start(ctx, "GetMerchant", attribute.Int64(userIDKey, userID), attribute.Int64(merchantIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetMerchant(ctx, userID, id)
+}
+
+// CreateMerchant creates a new merchant owned by the user
+func (t *tracedDB) CreateMerchant(ctx context.Context, userID int64, merchant *models.MerchantInput) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateMerchant", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.CreateMerchant(ctx, userID, merchant)
+}
+
+// UpdateMerchant updates a merchant of the user
+func (t *tracedDB) UpdateMerchant(ctx context.Context, userID, id int64, merchant *models.MerchantInput) (err error) {
+	ctx, span := t.start(ctx, "UpdateMerchant", attribute.Int64(userIDKey, userID), attribute.Int64(merchantIDKey, id))
+	defer t.end(span, &err)
+	return t.db.UpdateMerchant(ctx, userID, id, merchant)
+}
+
+// DeleteMerchant deletes a merchant of the user, keeping its bills
+func (t *tracedDB) DeleteMerchant(ctx context.Context, userID, id int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteMerchant", attribute.Int64(userIDKey, userID), attribute.Int64(merchantIDKey, id))
+	defer t.end(span, &err)
+	return t.db.DeleteMerchant(ctx, userID, id)
+}
+
+// GetRecurringBills returns the recurring bills of the user
+func (t *tracedDB) GetRecurringBills(ctx context.Context, userID int64) (recurring []models.RecurringBill, err error) {
+	ctx, span := t.start(ctx, "GetRecurringBills", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.GetRecurringBills(ctx, userID)
+}
+
+// GetRecurringBill returns a single recurring bill
+func (t *tracedDB) GetRecurringBill(ctx context.Context, userID, id int64) (recurring *models.RecurringBill, err error) {
+	ctx, span := t.start(ctx, "GetRecurringBill", attribute.Int64(userIDKey, userID), attribute.Int64(recurringBillIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetRecurringBill(ctx, userID, id)
+}
+
+// CreateRecurringBill creates a new recurring bill owned by the user
+func (t *tracedDB) CreateRecurringBill(ctx context.Context, userID int64, recurring *models.RecurringBillInput) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateRecurringBill", attribute.Int64(userIDKey, userID))
+	defer t.end(span, &err)
+	return t.db.CreateRecurringBill(ctx, userID, recurring)
+}
+
+// UpdateRecurringBill updates a recurring bill of the user
+func (t *tracedDB) UpdateRecurringBill(ctx context.Context, userID, id int64, recurring *models.RecurringBillInput) (err error) {
+	ctx, span := t.start(ctx, "UpdateRecurringBill", attribute.Int64(userIDKey, userID), attribute.Int64(recurringBillIDKey, id))
+	defer t.end(span, &err)
+	return t.db.UpdateRecurringBill(ctx, userID, id, recurring)
+}
+
+// DeleteRecurringBill deletes a recurring bill of the user, keeping its bills
+func (t *tracedDB) DeleteRecurringBill(ctx context.Context, userID, id int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteRecurringBill", attribute.Int64(userIDKey, userID), attribute.Int64(recurringBillIDKey, id))
+	defer t.end(span, &err)
+	return t.db.DeleteRecurringBill(ctx, userID, id)
+}
+
+// SetRecurringBillPaused pauses or resumes a recurring bill of the user
+func (t *tracedDB) SetRecurringBillPaused(ctx context.Context, userID, id int64, paused bool) (err error) {
+	ctx, span := t.start(ctx, "SetRecurringBillPaused", attribute.Int64(userIDKey, userID), attribute.Int64(recurringBillIDKey, id))
+	defer t.end(span, &err)
+	return t.db.SetRecurringBillPaused(ctx, userID, id, paused)
+}
+
+// SkipRecurringBill skips an upcoming occurrence of a recurring bill of the user
+func (t *tracedDB) SkipRecurringBill(ctx context.Context, userID, id int64, date time.Time) (err error) {
+	ctx, span := t.start(ctx, "SkipRecurringBill", attribute.Int64(userIDKey, userID), attribute.Int64(recurringBillIDKey, id))
+	defer t.end(span, &err)
+	return t.db.SkipRecurringBill(ctx, userID, id, date)
+}
+
+// EndRecurringBill ends a recurring bill of the user on the date
+func (t *tracedDB) EndRecurringBill(ctx context.Context, userID, id int64, date time.Time) (err error) {
+	ctx, span := t.start(ctx, "EndRecurringBill", attribute.Int64(userIDKey, userID), attribute.Int64(recurringBillIDKey, id))
+	defer t.end(span, &err)
+	return t.db.EndRecurringBill(ctx, userID, id, date)
+}
+
+// GenerateRecurringBills generates the bills of all recurring bills through the date
+func (t *tracedDB) GenerateRecurringBills(ctx context.Context, today, through time.Time) (generated int, err error) {
+	ctx, span := t.start(ctx, "GenerateRecurringBills")
+	defer t.end(span, &err)
+	return t.db.GenerateRecurringBills(ctx, today, through)
+}
+
+// GetFXRates returns the exchange rates, optionally filtered by base and quote currency
+func (t *tracedDB) GetFXRates(ctx context.Context, base, quote string) (rates []models.FXRate, err error) {
+	ctx, span := t.start(ctx, "GetFXRates")
+	defer t.end(span, &err)
+	return t.db.GetFXRates(ctx, base, quote)
+}
+
+// GetFXRate returns a single exchange rate
+func (t *tracedDB) GetFXRate(ctx context.Context, id int64) (rate *models.FXRate, err error) {
+	ctx, span := t.start(ctx, "GetFXRate", attribute.Int64(fxRateIDKey, id))
+	defer t.end(span, &err)
+	return t.db.GetFXRate(ctx, id)
+}
+
+// FindFXRate returns the most recent rate for a currency pair that is effective on the given date
+func (t *tracedDB) FindFXRate(ctx context.Context, base, quote string, on time.Time) (rate *models.FXRate, err error) {
+	ctx, span := t.start(ctx, "FindFXRate", attribute.String("accounts.fx_rate.pair", base+"/"+quote))
+	defer t.end(span, &err)
+	return t.db.FindFXRate(ctx, base, quote, on)
+}
+
+// CreateFXRate creates a new exchange rate
+func (t *tracedDB) CreateFXRate(ctx context.Context, rate *models.FXRateInput) (id int64, err error) {
+	ctx, span := t.start(ctx, "CreateFXRate")
+	defer t.end(span, &err)
+	return t.db.CreateFXRate(ctx, rate)
+}
+
+// UpdateFXRate updates an existing exchange rate
+func (t *tracedDB) UpdateFXRate(ctx context.Context, id int64, rate *models.FXRateInput) (err error) {
+	ctx, span := t.start(ctx, "UpdateFXRate", attribute.Int64(fxRateIDKey, id))
+	defer t.end(span, &err)
+	return t.db.UpdateFXRate(ctx, id, rate)
+}
+
+// DeleteFXRate deletes an exchange rate
+func (t *tracedDB) DeleteFXRate(ctx context.Context, id int64) (err error) {
+	ctx, span := t.start(ctx, "DeleteFXRate", attribute.Int64(fxRateIDKey, id))
+	defer t.end(span, &err)
+	return t.db.DeleteFXRate(ctx, id)
+}
+
+// Ping checks that the wrapped database is reachable
+func (t *tracedDB) Ping(ctx context.Context) (err error) {
+	ctx, span := t.start(ctx, "Ping")
+	defer t.end(span, &err)
+	return t.db.Ping(ctx)
+}
+
+// Migrate applies all pending schema migrations
+func (t *tracedDB) Migrate(ctx context.Context) (err error) {
+	ctx, span := t.start(ctx, "Migrate")
+	defer t.end(span, &err)
+	return t.db.Migrate(ctx)
+}
+
+// Close closes the wrapped database
+func (t *tracedDB) Close() error {
+	return t.db.Close()
+}
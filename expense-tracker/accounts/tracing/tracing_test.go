@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jo/choreo-tutorial/accounts/config"
+	"github.com/jo/choreo-tutorial/accounts/db"
+	"github.com/jo/choreo-tutorial/accounts/db/dbtest"
+	"github.com/jo/choreo-tutorial/accounts/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record installs a tracer provider that keeps the finished spans in memory
+func record(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	if _, err := Setup(context.Background(), &config.Config{TracesExporter: "none"}); err != nil {
+		t.Fatal(err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+	return exporter
+}
+
+// spanNamed returns the first recorded span with the given name
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %q", name)
+	return tracetest.SpanStub{}
+}
+
+func TestInstrumentDBConformance(t *testing.T) {
+	record(t)
+	dbtest.Run(t, func(t *testing.T) db.Database {
+		return InstrumentDB(db.NewMemoryDB(), "memory")
+	})
+}
+
+func TestTraceContinuity(t *testing.T) {
+	exporter := record(t)
+	database := InstrumentDB(db.NewMemoryDB(), "memory")
+
+	r := mux.NewRouter()
+	r.Use(Middleware())
+	r.HandleFunc("/bills/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if _, err := database.GetBill(r.Context(), 1, id); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/bills/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans, want a server and a database span", len(spans))
+	}
+
+	server := spanNamed(t, spans, "/bills/{id}")
+	if server.SpanContext.TraceID().String() != traceID {
+		t.Errorf("server span trace ID = %s, want the one from traceparent", server.SpanContext.TraceID())
+	}
+	if server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the caller's span", server.Parent.SpanID())
+	}
+
+	dbSpan := spanNamed(t, spans, "db.GetBill")
+	if dbSpan.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("database span is not a child of the server span")
+	}
+	if dbSpan.Status.Code == codes.Error {
+		t.Error("a missing bill marks the database span as failed")
+	}
+}
+
+func TestSQLStatementSpans(t *testing.T) {
+	exporter := record(t)
+
+	sqlite, err := db.NewSQLiteDB(&config.Config{DBPath: filepath.Join(t.TempDir(), "accounts.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	if err := sqlite.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	owner, err := sqlite.EnsureUser(context.Background(), &models.UserInput{Subject: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter.Reset()
+
+	database := InstrumentDB(sqlite, "sqlite")
+	if _, err := database.CreateBill(context.Background(), owner.ID, &models.BillInput{Title: "Rent", Currency: "USD"}); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	parent := spanNamed(t, spans, "db.CreateBill")
+	statements := 0
+	for _, span := range spans {
+		if span.Parent.SpanID() == parent.SpanContext.SpanID() {
+			statements++
+		}
+	}
+	if statements == 0 {
+		t.Errorf("no statement spans below db.CreateBill in %d spans", len(spans))
+	}
+}
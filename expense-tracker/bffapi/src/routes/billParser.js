@@ -2,6 +2,7 @@ const express = require('express');
 const multer = require('multer');
 const FormData = require('form-data');
 const { billParserClient, accountsClient } = require('../utils/serviceClient');
+const { createBillFromReceipt } = require('../utils/receipt');
 
 const parserRouter = express.Router();
 
@@ -37,33 +38,14 @@ parserRouter.post('/parse', upload.single('image'), async (req, res) => {
     const parsedData = parseResponse.data;
 
     // If create_bill parameter is true, create a bill from the parsed data
+    // for the signed-in user
     if (req.body.create_bill === 'true') {
-      // Generate a title if not provided
-      const title = req.body.title || 
-                    (parsedData.merchant ? 
-                      `Bill from ${parsedData.merchant}` : 
-                      `Bill for $${parsedData.total}`);
-
-      // Convert parsed receipt data to bill input format
-      const billData = {
-        title,
-        total: parsedData.total,
-        due_date: parsedData.date || new Date().toISOString().split('T')[0],
-        paid: false,
-        items: parsedData.items.map(item => ({
-          name: item.name,
-          amount: item.price,
-          quantity: item.quantity
-        }))
-      };
-
-      // Create the bill using the accounts service
-      const billResponse = await accountsClient.post('/bills', billData);
+      const bill = await createBillFromReceipt(accountsClient, req, parsedData);
 
       // Return combined response
       return res.json({
         message: 'Bill created successfully',
-        billId: billResponse.data.id,
+        billId: bill.id,
         parsedData
       });
     }
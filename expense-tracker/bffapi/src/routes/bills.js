@@ -1,17 +1,34 @@
 const express = require('express');
 const { accountsClient } = require('../utils/serviceClient');
+const { userHeaders } = require('../utils/user');
 
 const billsRouter = express.Router();
 
+// Largest page the accounts service returns (MaxBillPageSize)
+const maxBillPageSize = 500;
+
 // Get all bills
 billsRouter.get('/', async (req, res) => {
   try {
-    const response = await accountsClient.get('/bills');
-    // If response is null or undefined, return an empty array
-    if (!response || !response.data) {
-      return res.json([]);
-    }
-    res.json(response.data);
+    // Forward filter and sort parameters to the accounts service. It returns
+    // bills in pages wrapped in an envelope; follow next_offset until the
+    // last page so that clients get every bill.
+    const params = { limit: maxBillPageSize, ...req.query };
+    const bills = [];
+    let offset = params.offset;
+    do {
+      const response = await accountsClient.get('/bills', {
+        params: { ...params, offset },
+        headers: userHeaders(req),
+      });
+      // If response is null or undefined, stop with the bills so far
+      if (!response || !response.data) {
+        break;
+      }
+      bills.push(...(response.data.bills || []));
+      offset = response.data.next_offset;
+    } while (offset !== null && offset !== undefined);
+    res.json(bills);
   } catch (error) {
     console.error('Error fetching bills:', error.message);
     res.status(error.response?.status || 500).json(
@@ -24,7 +41,7 @@ billsRouter.get('/', async (req, res) => {
 billsRouter.get('/:id', async (req, res) => {
   try {
     const { id } = req.params;
-    const response = await accountsClient.get(`/bills/${id}`);
+    const response = await accountsClient.get(`/bills/${id}`, { headers: userHeaders(req) });
     res.json(response.data);
   } catch (error) {
     console.error(`Error fetching bill ${req.params.id}:`, error.message);
@@ -38,7 +55,7 @@ billsRouter.get('/:id', async (req, res) => {
 billsRouter.post('/', async (req, res) => {
   try {
     const billData = req.body;
-    const response = await accountsClient.post('/bills', billData);
+    const response = await accountsClient.post('/bills', billData, { headers: userHeaders(req) });
     res.status(201).json(response.data);
   } catch (error) {
     console.error('Error creating bill:', error.message);
@@ -53,7 +70,7 @@ billsRouter.put('/:id', async (req, res) => {
   try {
     const { id } = req.params;
     const billData = req.body;
-    const response = await accountsClient.put(`/bills/${id}`, billData);
+    const response = await accountsClient.put(`/bills/${id}`, billData, { headers: userHeaders(req) });
     res.json(response.data);
   } catch (error) {
     console.error(`Error updating bill ${req.params.id}:`, error.message);
@@ -67,7 +84,7 @@ billsRouter.put('/:id', async (req, res) => {
 billsRouter.delete('/:id', async (req, res) => {
   try {
     const { id } = req.params;
-    const response = await accountsClient.delete(`/bills/${id}`);
+    const response = await accountsClient.delete(`/bills/${id}`, { headers: userHeaders(req) });
     res.json(response.data);
   } catch (error) {
     console.error(`Error deleting bill ${req.params.id}:`, error.message);
@@ -0,0 +1,31 @@
+const { userHeaders } = require('./user');
+
+// Converts the data of a parsed receipt into the bill input of the accounts
+// service. The title of the request wins over the one derived from the
+// receipt.
+const billFromReceipt = (parsedData, title) => ({
+  title: title ||
+         (parsedData.merchant ?
+           `Bill from ${parsedData.merchant}` :
+           `Bill for $${parsedData.total}`),
+  total: parsedData.total,
+  due_date: parsedData.date || new Date().toISOString().split('T')[0],
+  paid: false,
+  // Only pass on ISO 4217 codes; the parser sometimes returns symbols
+  ...(/^[A-Za-z]{3}$/.test(parsedData.currency || '') && { currency: parsedData.currency }),
+  items: (parsedData.items || []).map(item => ({
+    name: item.name,
+    amount: item.price,
+    quantity: item.quantity
+  }))
+});
+
+// Creates a bill from a parsed receipt for the signed-in user of the request
+// and returns the response of the accounts service
+const createBillFromReceipt = async (client, req, parsedData) => {
+  const billData = billFromReceipt(parsedData, req.body.title);
+  const response = await client.post('/bills', billData, { headers: userHeaders(req) });
+  return response.data;
+};
+
+module.exports = { billFromReceipt, createBillFromReceipt };
@@ -0,0 +1,70 @@
+const test = require('node:test');
+const assert = require('node:assert');
+const { billFromReceipt, createBillFromReceipt } = require('./receipt');
+
+// assertion returns an unsigned x-jwt-assertion with the claims; the BFF only
+// decodes it, the accounts service verifies it
+const assertion = (claims) =>
+  `e30.${Buffer.from(JSON.stringify(claims)).toString('base64url')}.sig`;
+
+// request returns an Express-like request with the headers and body
+const request = (headers, body = {}) => ({
+  body,
+  get: (name) => headers[name.toLowerCase()]
+});
+
+const receipt = {
+  merchant: 'Corner Shop',
+  total: 4.5,
+  date: '2024-03-15',
+  currency: 'eur',
+  items: [
+    { name: 'Milk', price: 1.5, quantity: 1 },
+    { name: 'Bread', price: 3, quantity: 1 }
+  ]
+};
+
+test('creates the bill from a receipt as the signed-in user', async () => {
+  const jwt = assertion({ sub: 'alice', email: 'alice@example.com' });
+  const posts = [];
+  const client = {
+    post: async (path, data, config) => {
+      posts.push({ path, data, config });
+      return { data: { id: 42 } };
+    }
+  };
+
+  const bill = await createBillFromReceipt(client, request({ 'x-jwt-assertion': jwt }), receipt);
+
+  assert.deepStrictEqual(bill, { id: 42 });
+  assert.strictEqual(posts.length, 1);
+  assert.strictEqual(posts[0].path, '/bills');
+  assert.strictEqual(posts[0].config.headers.Authorization, `Bearer ${jwt}`);
+  assert.strictEqual(posts[0].config.headers['X-User-Subject'], 'alice');
+  assert.strictEqual(posts[0].data.title, 'Bill from Corner Shop');
+  assert.strictEqual(posts[0].data.items.length, 2);
+});
+
+test('passes on no identity without an assertion', async () => {
+  let headers;
+  const client = {
+    post: async (path, data, config) => {
+      headers = config.headers;
+      return { data: { id: 1 } };
+    }
+  };
+
+  await createBillFromReceipt(client, request({}), receipt);
+
+  assert.deepStrictEqual(headers, {});
+});
+
+test('maps the receipt to a bill input', () => {
+  const bill = billFromReceipt({ ...receipt, currency: '€', merchant: '' }, '');
+
+  assert.strictEqual(bill.title, 'Bill for $4.5');
+  assert.strictEqual(bill.currency, undefined);
+  assert.strictEqual(bill.due_date, '2024-03-15');
+  assert.deepStrictEqual(bill.items[0], { name: 'Milk', amount: 1.5, quantity: 1 });
+  assert.strictEqual(billFromReceipt(receipt, 'Groceries').title, 'Groceries');
+});
@@ -0,0 +1,35 @@
+// The API gateway authenticates users and passes their claims to the BFF as a
+// signed JWT in the x-jwt-assertion header. The assertion is forwarded as a
+// bearer token, which the accounts service verifies against the JWKS of the
+// gateway. In its header mode the accounts service trusts the identity
+// headers set here instead, so they are always derived from the assertion and
+// never copied from the client request.
+const userHeaders = (req) => {
+  const assertion = req.get('x-jwt-assertion');
+  if (!assertion) {
+    return {};
+  }
+
+  try {
+    const payload = JSON.parse(Buffer.from(assertion.split('.')[1], 'base64url').toString('utf8'));
+    if (!payload.sub) {
+      return {};
+    }
+    const headers = { Authorization: `Bearer ${assertion}`, 'X-User-Subject': payload.sub };
+    if (payload.email) {
+      headers['X-User-Email'] = payload.email;
+      if (payload.email_verified === true || payload.email_verified === 'true') {
+        headers['X-User-Email-Verified'] = 'true';
+      }
+    }
+    if (payload.name) {
+      headers['X-User-Name'] = payload.name;
+    }
+    return headers;
+  } catch (error) {
+    console.error('Invalid x-jwt-assertion:', error.message);
+    return {};
+  }
+};
+
+module.exports = { userHeaders };
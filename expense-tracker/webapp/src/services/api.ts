@@ -27,6 +27,7 @@ export interface Bill {
   title: string;
   description?: string;
   total: number;
+  currency?: string;
   due_date: string;
   paid: boolean;
   items?: BillItem[];
@@ -38,6 +39,7 @@ export interface Bill {
 export interface BillInput {
   title: string;
   description?: string;
+  currency?: string;
   due_date?: string;
   paid?: boolean;
   items?: {